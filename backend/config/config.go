@@ -14,6 +14,14 @@ type Config struct {
 	Database    DatabaseConfig
 	LDAP        LDAPConfig
 	JWT         JWTConfig
+	Audit       AuditConfig
+	Security    SecurityConfig
+	GeoIP       GeoIPConfig
+	Retention   RetentionConfig
+	Numbering   NumberingConfig
+	Finance     FinanceConfig
+	Workflow    WorkflowConfig
+	Budget      BudgetConfig
 	FrontendURL string
 }
 
@@ -34,6 +42,70 @@ type JWTConfig struct {
 	ExpiryHours int
 }
 
+type AuditConfig struct {
+	SigningKey string // base64 encoded ed25519 seed or private key
+}
+
+type GeoIPConfig struct {
+	DatabasePath string // MaxMind-format .mmdb file, e.g. GeoLite2-City.mmdb
+	SiteNetworks string // "10.101.0.0/16=RS Bekasi Barat;10.102.0.0/16=RS Kelapa Gading"
+}
+
+type RetentionConfig struct {
+	LoginLogDays  int    // 0 disables the retention job
+	ArchiveDir    string // monthly login_logs-YYYY-MM.ndjson.gz files
+	IntervalHours int
+}
+
+// FinanceConfig holds the discount rates used to evaluate proposals.
+type FinanceConfig struct {
+	DefaultWACC float64 // percent, for investment types without their own
+	LegacyWACC  string  // "IT=12;Operasional=9.5", copied once onto the investment type catalog
+}
+
+// WorkflowConfig controls which approval steps a proposal goes through.
+// A role listed in Thresholds only reviews proposals whose IDR cost is at
+// least its threshold; other roles review every proposal. Actual spend may
+// exceed the approved cost by OverrunTolerance percent before the proposal
+// needs re-approval.
+type WorkflowConfig struct {
+	Thresholds       string  // "CFO=1000000000;CEO=5000000000", IDR per role
+	OverrunTolerance float64 // percent
+}
+
+// BudgetConfig controls how submissions are checked against the remaining
+// capex budget of their department: block rejects a submission that does
+// not fit, warn lets it through with a warning, off only keeps the ledger.
+type BudgetConfig struct {
+	Enforcement          string
+	FiscalYearStartMonth int // 1 = January
+}
+
+// NumberingConfig controls how proposal numbers are generated. Pattern
+// tokens: {PREFIX}, {DEPT}, {FY}, {FY2}, {YYYY}, {YY}, {MM} and {SEQ} or
+// {SEQ:n} for a counter zero-padded to n digits.
+type NumberingConfig struct {
+	Pattern              string
+	Prefix               string
+	Reset                string // yearly (per fiscal year), monthly or never
+	FiscalYearStartMonth int    // 1 = January
+}
+
+// SecurityConfig holds the thresholds of the login anomaly rules.
+type SecurityConfig struct {
+	NotifyAlerts bool // forward alerts to admins as notifications
+
+	BurstWindowMinutes int
+	BurstMaxAttempts   int // attempts for one username within the window
+	BurstDistinctIPs   int // distinct IPs with a successful login within the window
+
+	SprayWindowMinutes     int
+	SprayDistinctUsernames int // failed usernames from one IP within the window
+
+	FailureWindowMinutes int
+	FailureThreshold     int // failures before a successful login
+}
+
 func Load() (*Config, error) {
 	if err := godotenv.Load(); err != nil {
 		fmt.Println("Warning: .env file not found, using environment variables")
@@ -66,6 +138,46 @@ func Load() (*Config, error) {
 			Secret:      getEnv("JWT_SECRET", "your-secret-key"),
 			ExpiryHours: jwtExpiry,
 		},
+		Audit: AuditConfig{
+			SigningKey: getEnv("AUDIT_SIGNING_KEY", ""),
+		},
+		GeoIP: GeoIPConfig{
+			DatabasePath: getEnv("GEOIP_DB_PATH", ""),
+			SiteNetworks: getEnv("SITE_NETWORKS", ""),
+		},
+		Retention: RetentionConfig{
+			LoginLogDays:  getEnvInt("LOGIN_LOG_RETENTION_DAYS", 365),
+			ArchiveDir:    getEnv("LOGIN_LOG_ARCHIVE_DIR", "./archives/login_logs"),
+			IntervalHours: getEnvInt("RETENTION_INTERVAL_HOURS", 24),
+		},
+		Numbering: NumberingConfig{
+			Pattern:              getEnv("PROPOSAL_NUMBER_PATTERN", "{PREFIX}-{FY}-{SEQ:5}"),
+			Prefix:               getEnv("PROPOSAL_NUMBER_PREFIX", "INV"),
+			Reset:                getEnv("PROPOSAL_NUMBER_RESET", "yearly"),
+			FiscalYearStartMonth: getEnvInt("FISCAL_YEAR_START_MONTH", 1),
+		},
+		Finance: FinanceConfig{
+			DefaultWACC: getEnvFloat("DEFAULT_WACC", 10),
+			LegacyWACC:  getEnv("WACC_BY_INVESTMENT_TYPE", ""),
+		},
+		Workflow: WorkflowConfig{
+			Thresholds:       getEnv("APPROVAL_THRESHOLDS_IDR", ""),
+			OverrunTolerance: getEnvFloat("OVERRUN_REAPPROVAL_PERCENT", 10),
+		},
+		Budget: BudgetConfig{
+			Enforcement:          getEnv("BUDGET_ENFORCEMENT", "warn"),
+			FiscalYearStartMonth: getEnvInt("FISCAL_YEAR_START_MONTH", 1),
+		},
+		Security: SecurityConfig{
+			NotifyAlerts:           getEnv("SECURITY_ALERT_NOTIFY", "true") == "true",
+			BurstWindowMinutes:     getEnvInt("SECURITY_BURST_WINDOW_MINUTES", 5),
+			BurstMaxAttempts:       getEnvInt("SECURITY_BURST_MAX_ATTEMPTS", 20),
+			BurstDistinctIPs:       getEnvInt("SECURITY_BURST_DISTINCT_IPS", 3),
+			SprayWindowMinutes:     getEnvInt("SECURITY_SPRAY_WINDOW_MINUTES", 15),
+			SprayDistinctUsernames: getEnvInt("SECURITY_SPRAY_DISTINCT_USERNAMES", 5),
+			FailureWindowMinutes:   getEnvInt("SECURITY_FAILURE_WINDOW_MINUTES", 30),
+			FailureThreshold:       getEnvInt("SECURITY_FAILURE_THRESHOLD", 5),
+		},
 		FrontendURL: getEnv("FRONTEND_URL", "http://localhost:3000"),
 	}, nil
 }
@@ -77,3 +189,19 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnv(key, strconv.Itoa(defaultValue)))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(getEnv(key, ""), 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
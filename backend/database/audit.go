@@ -0,0 +1,41 @@
+package database
+
+import (
+	"fui-backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const auditHeadMigration = "audit_head"
+
+// migrateAuditHead checkpoints the end of an audit chain recorded before
+// AuditHead existed, once. Later entries keep it up to date themselves.
+func migrateAuditHead(db *gorm.DB) error {
+	var applied int64
+	if err := db.Model(&models.DataMigration{}).Where("name = ?", auditHeadMigration).Count(&applied).Error; err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var head models.AuditHead
+		if err := tx.Model(&models.AuditLog{}).Count(&head.Count).Error; err != nil {
+			return err
+		}
+		if head.Count > 0 {
+			var last models.AuditLog
+			if err := tx.Order("id DESC").First(&last).Error; err != nil {
+				return err
+			}
+			head.ID, head.LastID, head.Hash = models.AuditHeadID, last.ID, last.Hash
+			if err := tx.Save(&head).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&models.DataMigration{Name: auditHeadMigration, AppliedAt: time.Now()}).Error
+	})
+}
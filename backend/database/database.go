@@ -5,6 +5,7 @@ import (
 	"fui-backend/config"
 	"fui-backend/models"
 	"log"
+	"strings"
 
 	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
@@ -14,8 +15,22 @@ import (
 var DB *gorm.DB
 
 func Connect(cfg *config.Config) error {
+	// Start write transactions with BEGIN IMMEDIATE so concurrent writers
+	// wait on the busy timeout instead of failing when they upgrade a read
+	// lock, e.g. two requests taking the next proposal number at once. The
+	// audit chain relies on it too: the head an entry is chained onto stays
+	// locked until the transaction that appends the entry commits.
+	dsn := cfg.Database.Path
+	if !strings.Contains(dsn, "_txlock=") {
+		if strings.Contains(dsn, "?") {
+			dsn += "&_txlock=immediate"
+		} else {
+			dsn += "?_txlock=immediate"
+		}
+	}
+
 	var err error
-	DB, err = gorm.Open(sqlite.Open(cfg.Database.Path), &gorm.Config{
+	DB, err = gorm.Open(sqlite.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 	})
 
@@ -35,12 +50,74 @@ func Migrate() error {
 		&models.Attachment{},
 		&models.Approval{},
 		&models.Comment{},
+		&models.AuditLog{},
+		&models.AuditHead{},
+		&models.SecurityAlert{},
+		&models.Notification{},
+		&models.ProposalStatusHistory{},
+		&models.ProposalVersion{},
+		&models.ProposalSequence{},
+		&models.ProposalLineItem{},
+		&models.ProposalCashFlow{},
+		&models.DataMigration{},
+		&models.ExchangeRate{},
+		&models.Department{},
+		&models.DepartmentBudget{},
+		&models.BudgetLedgerEntry{},
+		&models.PlanningCycle{},
+		&models.InvestmentType{},
+		&models.ProposalTemplate{},
+		&models.Vendor{},
+		&models.Quotation{},
+		&models.QuotationComparison{},
+		&models.SpendRecord{},
 	)
 
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	// Proposals submitted before the approval workflow existed wait on its
+	// first step
+	err = DB.Exec("UPDATE investment_proposals SET approval_step = 1 WHERE approval_step = 0 AND status <> ?", models.StatusDraft).Error
+	if err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	if err := migrateMoney(DB); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	// IDR proposals from before exchange rates need no conversion; those
+	// already in the workflow count as locked when they were last changed
+	err = DB.Exec(`UPDATE investment_proposals
+		SET estimated_cost_idr = estimated_cost, exchange_rate = ?,
+			rate_locked_at = CASE WHEN status IN ? THEN NULL ELSE updated_at END
+		WHERE estimated_cost_idr IS NULL AND (currency IS NULL OR currency IN ?)`,
+		models.RateOne,
+		[]models.ProposalStatus{models.StatusDraft, models.StatusRevision, models.StatusWithdrawn},
+		[]string{"", models.BaseCurrency},
+	).Error
+	if err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	if err := migrateDepartments(DB); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	if err := migrateSearchIndex(DB); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	if err := migrateAuditHead(DB); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	if err := migrateLoginTimes(DB); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
 	log.Println("Database migrated successfully")
 	return nil
 }
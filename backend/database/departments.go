@@ -0,0 +1,218 @@
+package database
+
+import (
+	"fmt"
+	"fui-backend/models"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const departmentMigration = "departments"
+
+var nonDepartmentCodeChars = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// migrateDepartments starts the department master data with the free-text
+// departments proposals and users already refer to, once. Each value is
+// resolved to a department by code or name, ignoring case, or becomes a new
+// department named after it; proposals and users are then rewritten to the
+// codes. Values that cannot be resolved are logged and left as they are.
+func migrateDepartments(db *gorm.DB) error {
+	var applied int64
+	if err := db.Model(&models.DataMigration{}).Where("name = ?", departmentMigration).Count(&applied).Error; err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var proposalValues []string
+		err := tx.Unscoped().Model(&models.InvestmentProposal{}).
+			Where("department_id <> ''").
+			Distinct().Pluck("department_id", &proposalValues).Error
+		if err != nil {
+			return fmt.Errorf("failed to read proposal departments: %w", err)
+		}
+
+		var users []models.User
+		if err := tx.Unscoped().Select("id, department").Where("department <> ''").Find(&users).Error; err != nil {
+			return fmt.Errorf("failed to read user departments: %w", err)
+		}
+
+		values := map[string]bool{}
+		for _, value := range proposalValues {
+			values[strings.TrimSpace(value)] = true
+		}
+		for _, user := range users {
+			for _, value := range strings.Split(user.Department, ",") {
+				values[strings.TrimSpace(value)] = true
+			}
+		}
+		delete(values, "")
+
+		// Single words first, so an abbreviation such as IT exists by the
+		// time Information Technology is resolved to it
+		sorted := make([]string, 0, len(values))
+		for value := range values {
+			sorted = append(sorted, value)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			wi, wj := len(strings.Fields(sorted[i])), len(strings.Fields(sorted[j]))
+			if (wi > 1) != (wj > 1) {
+				return wj > 1
+			}
+			return sorted[i] < sorted[j]
+		})
+
+		codes := map[string]string{}
+		created := 0
+		for _, value := range sorted {
+			code, isNew, err := resolveDepartment(tx, value)
+			if err != nil {
+				return err
+			}
+			if code == "" {
+				continue
+			}
+			codes[value] = code
+			if isNew {
+				created++
+			}
+		}
+
+		for _, value := range proposalValues {
+			code, ok := codes[strings.TrimSpace(value)]
+			if !ok || code == value {
+				continue
+			}
+			err := tx.Unscoped().Model(&models.InvestmentProposal{}).
+				Where("department_id = ?", value).
+				UpdateColumn("department_id", code).Error
+			if err != nil {
+				return fmt.Errorf("failed to rewrite proposal departments: %w", err)
+			}
+		}
+
+		for _, user := range users {
+			var parts []string
+			seen := map[string]bool{}
+			for _, value := range strings.Split(user.Department, ",") {
+				value = strings.TrimSpace(value)
+				if code, ok := codes[value]; ok {
+					value = code
+				}
+				if value != "" && !seen[value] {
+					seen[value] = true
+					parts = append(parts, value)
+				}
+			}
+			if department := strings.Join(parts, ", "); department != user.Department {
+				err := tx.Unscoped().Model(&models.User{}).Where("id = ?", user.ID).
+					UpdateColumn("department", department).Error
+				if err != nil {
+					return fmt.Errorf("failed to rewrite user departments: %w", err)
+				}
+			}
+		}
+
+		if err := tx.Create(&models.DataMigration{Name: departmentMigration, AppliedAt: time.Now()}).Error; err != nil {
+			return err
+		}
+		log.Printf("Department master data started with %d new departments, %d values resolved", created, len(codes))
+		return nil
+	})
+}
+
+// resolveDepartment returns the code of the department value names, and
+// whether it had to be created. A value of several words also names the
+// department whose code is its abbreviation, e.g. Information Technology
+// names IT. It returns an empty code, after logging why, when value names
+// several departments or no department can be made for it.
+func resolveDepartment(tx *gorm.DB, value string) (string, bool, error) {
+	var matches []models.Department
+	err := tx.Where("code = ? OR LOWER(name) = LOWER(?)", strings.ToUpper(value), value).Find(&matches).Error
+	if err != nil {
+		return "", false, fmt.Errorf("failed to resolve department %q: %w", value, err)
+	}
+	if abbreviation := departmentAbbreviation(value); len(matches) == 0 && abbreviation != "" {
+		if err := tx.Where("code = ?", abbreviation).Find(&matches).Error; err != nil {
+			return "", false, fmt.Errorf("failed to resolve department %q: %w", value, err)
+		}
+		if len(matches) == 1 {
+			log.Printf("Department migration: %q merged into %s", value, abbreviation)
+			// Name a department created from its bare code after value
+			if matches[0].Name == matches[0].Code {
+				if err := tx.Model(&matches[0]).UpdateColumn("name", value).Error; err != nil {
+					return "", false, fmt.Errorf("failed to rename department %s: %w", abbreviation, err)
+				}
+			}
+		}
+	}
+	switch len(matches) {
+	case 1:
+		return matches[0].Code, false, nil
+	case 0:
+	default:
+		log.Printf("Department migration: %q matches %d departments; left as is, set the code by hand", value, len(matches))
+		return "", false, nil
+	}
+
+	code := departmentCode(value)
+	if code == "" {
+		log.Printf("Department migration: no department code can be made from %q; left as is", value)
+		return "", false, nil
+	}
+	var taken int64
+	if err := tx.Model(&models.Department{}).Where("code = ?", code).Count(&taken).Error; err != nil {
+		return "", false, err
+	}
+	if taken > 0 {
+		log.Printf("Department migration: code %s for %q belongs to another department; left as is", code, value)
+		return "", false, nil
+	}
+
+	department := models.Department{Code: code, Name: value, IsActive: true}
+	if err := tx.Create(&department).Error; err != nil {
+		return "", false, fmt.Errorf("failed to create department %q: %w", value, err)
+	}
+	return code, true, nil
+}
+
+// departmentCode turns a free-text department into a code, e.g.
+// "Teknologi Informasi" into TEKNOLOGI_INFORMASI. Names too long for a code
+// are abbreviated instead, e.g. "Information Technology" to IT.
+func departmentCode(value string) string {
+	code := strings.Trim(nonDepartmentCodeChars.ReplaceAllString(strings.ToUpper(value), "_"), "_")
+	if len(code) <= 20 {
+		return code
+	}
+	if abbreviation := departmentAbbreviation(value); abbreviation != "" {
+		return abbreviation
+	}
+	return strings.TrimRight(code[:20], "_")
+}
+
+// departmentConnectors are left out of abbreviations.
+var departmentConnectors = map[string]bool{"AND": true, "OF": true, "THE": true, "DAN": true}
+
+// departmentAbbreviation returns the initials of a department of several
+// words, e.g. IT for "Information Technology" or SDMU for "Sumber Daya
+// Manusia dan Umum", or "" for a single word.
+func departmentAbbreviation(value string) string {
+	words := strings.Fields(nonDepartmentCodeChars.ReplaceAllString(strings.ToUpper(value), " "))
+	var initials strings.Builder
+	for _, word := range words {
+		if !departmentConnectors[word] {
+			initials.WriteByte(word[0])
+		}
+	}
+	if initials.Len() < 2 || initials.Len() > 20 {
+		return ""
+	}
+	return initials.String()
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fui-backend/config"
+	"fui-backend/models"
+
+	"gorm.io/gorm/logger"
+)
+
+func TestMigrateDepartments(t *testing.T) {
+	cfg := &config.Config{Database: config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")}}
+	if err := Connect(cfg); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	DB.Logger = logger.Default.LogMode(logger.Silent)
+	if err := Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	// Data from before the master data, with the migration not yet applied
+	if err := DB.Where("name = ?", departmentMigration).Delete(&models.DataMigration{}).Error; err != nil {
+		t.Fatalf("reset migration: %v", err)
+	}
+	for _, d := range []models.Department{
+		{Code: "FIN", Name: "Finance", IsActive: true},
+		{Code: "RAD-BKS", Name: "Radiologi", Unit: "RS Bekasi", IsActive: true},
+		{Code: "RAD-JKT", Name: "Radiologi", Unit: "RS Jakarta", IsActive: true},
+	} {
+		if err := DB.Create(&d).Error; err != nil {
+			t.Fatalf("seed department: %v", err)
+		}
+	}
+
+	user := models.User{Username: "u", Email: "u@example.com", FullName: "U", Role: models.RoleDirektur, Department: "finance, Teknologi Informasi, ???, FIN"}
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+
+	proposals := map[string]string{ // department_id before -> after
+		"finance":                "FIN",
+		"fin":                    "FIN",
+		"Teknologi Informasi":    "TEKNOLOGI_INFORMASI",
+		"teknologi informasi":    "TEKNOLOGI_INFORMASI",
+		"IT":                     "IT",
+		"Information Technology": "IT", // abbreviated as IT
+		"information technology": "IT",
+		"radiologi":              "radiologi", // two units share the name
+		"???":                    "???",
+	}
+	ids := map[string]uint{}
+	for department := range proposals {
+		p := models.InvestmentProposal{ProposalNumber: "P-" + department, Title: "P", DepartmentID: department, SubmittedByID: user.ID, ProposalDate: time.Now()}
+		if err := DB.Create(&p).Error; err != nil {
+			t.Fatalf("seed proposal: %v", err)
+		}
+		ids[department] = p.ID
+	}
+
+	if err := migrateDepartments(DB); err != nil {
+		t.Fatalf("migrateDepartments: %v", err)
+	}
+
+	for before, want := range proposals {
+		var p models.InvestmentProposal
+		DB.First(&p, ids[before])
+		if p.DepartmentID != want {
+			t.Errorf("proposal department %q became %q, want %q", before, p.DepartmentID, want)
+		}
+	}
+
+	DB.First(&user, user.ID)
+	if want := "FIN, TEKNOLOGI_INFORMASI, ???"; user.Department != want {
+		t.Errorf("user department = %q, want %q", user.Department, want)
+	}
+
+	var created []models.Department
+	DB.Where("code IN ?", []string{"TEKNOLOGI_INFORMASI", "IT"}).Order("code").Find(&created)
+	if len(created) != 2 || created[0].Name != "Information Technology" || created[1].Name != "Teknologi Informasi" || !created[1].IsActive {
+		t.Errorf("created departments = %+v, want active Information Technology (IT) and Teknologi Informasi", created)
+	}
+	var count int64
+	DB.Model(&models.Department{}).Count(&count)
+	if count != 5 {
+		t.Errorf("%d departments, want 5", count)
+	}
+
+	// Runs once
+	p := models.InvestmentProposal{ProposalNumber: "P-late", Title: "P", DepartmentID: "Legal", SubmittedByID: user.ID, ProposalDate: time.Now()}
+	DB.Create(&p)
+	if err := migrateDepartments(DB); err != nil {
+		t.Fatalf("migrateDepartments again: %v", err)
+	}
+	DB.First(&p, p.ID)
+	if p.DepartmentID != "Legal" {
+		t.Errorf("second run rewrote %q to %q", "Legal", p.DepartmentID)
+	}
+}
+
+func TestDepartmentCode(t *testing.T) {
+	tests := map[string]string{
+		"Teknologi Informasi":          "TEKNOLOGI_INFORMASI",
+		" it ":                         "IT",
+		"R&D / Lab":                    "R_D_LAB",
+		"Sumber Daya Manusia dan Umum": "SDMU",
+		"Information Technology":       "IT",
+		"Pemeliharaansaranaprasarana":  "PEMELIHARAANSARANAPR",
+		"???":                          "",
+	}
+	for in, want := range tests {
+		if got := departmentCode(in); got != want {
+			t.Errorf("departmentCode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package database
+
+import (
+	"database/sql/driver"
+	"net"
+
+	sqlite "github.com/glebarez/go-sqlite"
+)
+
+// SQL functions registered on the SQLite driver. They must be registered
+// before the first connection is opened.
+func init() {
+	// ip_in_cidr(ip, cidr) reports whether ip falls inside the cidr range.
+	sqlite.MustRegisterDeterministicScalarFunction("ip_in_cidr", 2, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		ip, _ := args[0].(string)
+		cidr, _ := args[1].(string)
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return false, nil
+		}
+
+		parsed := net.ParseIP(ip)
+		return parsed != nil && network.Contains(parsed), nil
+	})
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"fmt"
+	"fui-backend/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const loginTimeMigration = "login_at_utc"
+
+// migrateLoginTimes rewrites login_at of logins recorded before it was
+// stored as models.LoginTime, once. Until then each row kept the offset of
+// the server that wrote it, so the text did not sort in time order.
+func migrateLoginTimes(db *gorm.DB) error {
+	var applied int64
+	if err := db.Model(&models.DataMigration{}).Where("name = ?", loginTimeMigration).Count(&applied).Error; err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		rewritten := 0
+		for {
+			// Stored LoginTimes look like 2006-01-02 15:04:05+00:00
+			var logs []models.LoginLog
+			err := tx.Unscoped().Select("id", "login_at").
+				Where("NOT (login_at LIKE '%+00:00' AND length(login_at) = 25)").
+				Order("id ASC").Limit(1000).
+				Find(&logs).Error
+			if err != nil {
+				return fmt.Errorf("failed to read login times: %w", err)
+			}
+			if len(logs) == 0 {
+				break
+			}
+
+			for _, l := range logs {
+				err := tx.Unscoped().Model(&models.LoginLog{}).Where("id = ?", l.ID).
+					UpdateColumn("login_at", models.LoginTime(l.LoginAt)).Error
+				if err != nil {
+					return fmt.Errorf("failed to rewrite login times: %w", err)
+				}
+			}
+			rewritten += len(logs)
+		}
+
+		if err := tx.Create(&models.DataMigration{Name: loginTimeMigration, AppliedAt: time.Now()}).Error; err != nil {
+			return err
+		}
+		log.Printf("Rewrote %d login times in UTC", rewritten)
+		return nil
+	})
+}
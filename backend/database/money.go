@@ -0,0 +1,90 @@
+package database
+
+import (
+	"fmt"
+	"fui-backend/models"
+	"log"
+	"math/big"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const moneyMigration = "money_minor_units"
+
+// moneyColumns held float64 amounts before they became models.Amount, an
+// integer number of hundredths. Computed columns are rounded without being
+// reported.
+var moneyColumns = []struct {
+	Table, Column string
+	Computed      bool
+}{
+	{"investment_proposals", "estimated_cost", false},
+	{"investment_proposals", "fin_npv", true},
+	{"proposal_line_items", "unit_price", false},
+	{"proposal_line_items", "subtotal", true},
+	{"proposal_line_items", "tax_amount", true},
+	{"proposal_line_items", "total", true},
+	{"proposal_cash_flows", "inflow", false},
+	{"proposal_cash_flows", "outflow", false},
+}
+
+// migrateMoney converts float amounts to hundredths, once. Each value is
+// taken at its shortest decimal form, so 0.29 becomes 29 rather than 28.
+// Values with more than two decimals are rounded half away from zero and
+// logged with their original value.
+func migrateMoney(db *gorm.DB) error {
+	var applied int64
+	if err := db.Model(&models.DataMigration{}).Where("name = ?", moneyMigration).Count(&applied).Error; err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		converted := 0
+		for _, col := range moneyColumns {
+			var rows []struct {
+				ID    uint
+				Value float64
+			}
+			err := tx.Table(col.Table).
+				Select(fmt.Sprintf("id, %s AS value", col.Column)).
+				Where(col.Column + " IS NOT NULL").
+				Scan(&rows).Error
+			if err != nil {
+				return fmt.Errorf("failed to read %s.%s: %w", col.Table, col.Column, err)
+			}
+
+			for _, row := range rows {
+				amount, err := models.AmountFromFloat(row.Value)
+				if err != nil {
+					return fmt.Errorf("cannot convert %s.%s of row %d (%v): %w", col.Table, col.Column, row.ID, row.Value, err)
+				}
+				if !col.Computed && !exactAmount(row.Value, amount) {
+					log.Printf("Money migration: %s.%s of row %d rounded from %s to %s", col.Table, col.Column, row.ID, strconv.FormatFloat(row.Value, 'f', -1, 64), amount)
+				}
+
+				err = tx.Table(col.Table).Where("id = ?", row.ID).Update(col.Column, amount.Minor()).Error
+				if err != nil {
+					return fmt.Errorf("failed to convert %s.%s: %w", col.Table, col.Column, err)
+				}
+				converted++
+			}
+		}
+
+		if err := tx.Create(&models.DataMigration{Name: moneyMigration, AppliedAt: time.Now()}).Error; err != nil {
+			return err
+		}
+		log.Printf("Converted %d amounts to minor units", converted)
+		return nil
+	})
+}
+
+// exactAmount reports whether amount equals the shortest decimal form of f.
+func exactAmount(f float64, amount models.Amount) bool {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	return r.Cmp(big.NewRat(amount.Minor(), 100)) == 0
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// The search index is an FTS5 table with one row per proposal, comment and
+// attachment. Triggers on the source tables keep it in sync on every write;
+// RebuildSearchIndex repopulates it from scratch.
+//
+// Columns: title, description, justification, expected_benefit,
+// risk_analysis and content (comment text or attachment file name) are
+// searchable; kind, proposal_id and source_id identify the row.
+const SearchIndexTable = "search_index"
+
+const (
+	SearchKindProposal   = "proposal"
+	SearchKindComment    = "comment"
+	SearchKindAttachment = "attachment"
+)
+
+const createSearchIndexSQL = `CREATE VIRTUAL TABLE search_index USING fts5(
+	title, description, justification, expected_benefit, risk_analysis, content,
+	kind UNINDEXED, proposal_id UNINDEXED, source_id UNINDEXED,
+	tokenize = 'unicode61 remove_diacritics 2'
+)`
+
+const (
+	insertProposalSearchSQL = `INSERT INTO search_index (title, description, justification, expected_benefit, risk_analysis, content, kind, proposal_id, source_id)
+	SELECT title, description, justification, expected_benefit, risk_analysis, '', 'proposal', id, id FROM investment_proposals`
+	insertCommentSearchSQL = `INSERT INTO search_index (title, description, justification, expected_benefit, risk_analysis, content, kind, proposal_id, source_id)
+	SELECT '', '', '', '', '', content, 'comment', proposal_id, id FROM comments`
+	insertAttachmentSearchSQL = `INSERT INTO search_index (title, description, justification, expected_benefit, risk_analysis, content, kind, proposal_id, source_id)
+	SELECT '', '', '', '', '', file_name, 'attachment', proposal_id, id FROM attachments`
+)
+
+var searchTriggers = []string{
+	`CREATE TRIGGER IF NOT EXISTS search_proposals_ai AFTER INSERT ON investment_proposals BEGIN
+		INSERT INTO search_index (title, description, justification, expected_benefit, risk_analysis, content, kind, proposal_id, source_id)
+		VALUES (new.title, new.description, new.justification, new.expected_benefit, new.risk_analysis, '', 'proposal', new.id, new.id);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS search_proposals_au AFTER UPDATE OF title, description, justification, expected_benefit, risk_analysis ON investment_proposals BEGIN
+		DELETE FROM search_index WHERE kind = 'proposal' AND source_id = old.id;
+		INSERT INTO search_index (title, description, justification, expected_benefit, risk_analysis, content, kind, proposal_id, source_id)
+		VALUES (new.title, new.description, new.justification, new.expected_benefit, new.risk_analysis, '', 'proposal', new.id, new.id);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS search_proposals_ad AFTER DELETE ON investment_proposals BEGIN
+		DELETE FROM search_index WHERE proposal_id = old.id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS search_comments_ai AFTER INSERT ON comments BEGIN
+		INSERT INTO search_index (title, description, justification, expected_benefit, risk_analysis, content, kind, proposal_id, source_id)
+		VALUES ('', '', '', '', '', new.content, 'comment', new.proposal_id, new.id);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS search_comments_au AFTER UPDATE OF content, proposal_id ON comments BEGIN
+		DELETE FROM search_index WHERE kind = 'comment' AND source_id = old.id;
+		INSERT INTO search_index (title, description, justification, expected_benefit, risk_analysis, content, kind, proposal_id, source_id)
+		VALUES ('', '', '', '', '', new.content, 'comment', new.proposal_id, new.id);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS search_comments_ad AFTER DELETE ON comments BEGIN
+		DELETE FROM search_index WHERE kind = 'comment' AND source_id = old.id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS search_attachments_ai AFTER INSERT ON attachments BEGIN
+		INSERT INTO search_index (title, description, justification, expected_benefit, risk_analysis, content, kind, proposal_id, source_id)
+		VALUES ('', '', '', '', '', new.file_name, 'attachment', new.proposal_id, new.id);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS search_attachments_au AFTER UPDATE OF file_name, proposal_id ON attachments BEGIN
+		DELETE FROM search_index WHERE kind = 'attachment' AND source_id = old.id;
+		INSERT INTO search_index (title, description, justification, expected_benefit, risk_analysis, content, kind, proposal_id, source_id)
+		VALUES ('', '', '', '', '', new.file_name, 'attachment', new.proposal_id, new.id);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS search_attachments_ad AFTER DELETE ON attachments BEGIN
+		DELETE FROM search_index WHERE kind = 'attachment' AND source_id = old.id;
+	END`,
+}
+
+// migrateSearchIndex creates the search index and its triggers. A newly
+// created index is filled from the existing rows.
+func migrateSearchIndex(db *gorm.DB) error {
+	var count int64
+	err := db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", SearchIndexTable).
+		Scan(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to check search index: %w", err)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if count == 0 {
+			if err := tx.Exec(createSearchIndexSQL).Error; err != nil {
+				return fmt.Errorf("failed to create search index: %w", err)
+			}
+		}
+
+		for _, trigger := range searchTriggers {
+			if err := tx.Exec(trigger).Error; err != nil {
+				return fmt.Errorf("failed to create search trigger: %w", err)
+			}
+		}
+
+		if count == 0 {
+			if err := fillSearchIndex(tx); err != nil {
+				return err
+			}
+			log.Println("Search index created")
+		}
+		return nil
+	})
+}
+
+// RebuildSearchIndex empties the search index and repopulates it from the
+// proposals, comments and attachments tables.
+func RebuildSearchIndex() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM search_index").Error; err != nil {
+			return fmt.Errorf("failed to clear search index: %w", err)
+		}
+		if err := fillSearchIndex(tx); err != nil {
+			return err
+		}
+		if err := tx.Exec("INSERT INTO search_index (search_index) VALUES ('optimize')").Error; err != nil {
+			return fmt.Errorf("failed to optimize search index: %w", err)
+		}
+		return nil
+	})
+}
+
+func fillSearchIndex(tx *gorm.DB) error {
+	for _, stmt := range []string{insertProposalSearchSQL, insertCommentSearchSQL, insertAttachmentSearchSQL} {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to fill search index: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"fui-backend/database"
+	"fui-backend/models"
+	"fui-backend/services"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+func (h *AuditHandler) GetAuditLogs(c *fiber.Ctx) error {
+	db := database.GetDB()
+	var logs []models.AuditLog
+
+	query := db.Order("id DESC").Limit(200)
+	if entityType := c.Query("entity_type"); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if entityID := c.QueryInt("entity_id"); entityID > 0 {
+		query = query.Where("entity_id = ?", entityID)
+	}
+
+	if err := query.Find(&logs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch audit logs",
+		})
+	}
+
+	return c.JSON(logs)
+}
+
+func (h *AuditHandler) VerifyAuditChain(c *fiber.Ctx) error {
+	result, err := h.auditService.Verify()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to verify audit chain",
+		})
+	}
+
+	return c.JSON(result)
+}
+
+func (h *AuditHandler) ExportAuditLogs(c *fiber.Ctx) error {
+	var buf bytes.Buffer
+	if err := h.auditService.ExportSigned(&buf, uint(c.QueryInt("after_id"))); err != nil {
+		if errors.Is(err, services.ErrAuditSigningKeyMissing) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to export audit logs",
+		})
+	}
+
+	filename := fmt.Sprintf("audit-export-%s.ndjson", time.Now().Format("20060102-150405"))
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+
+	return c.Send(buf.Bytes())
+}
+
+func (h *AuditHandler) GetPublicKey(c *fiber.Ctx) error {
+	key, err := h.auditService.PublicKey()
+	if err != nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"algorithm":  "ed25519",
+		"public_key": key,
+	})
+}
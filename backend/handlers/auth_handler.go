@@ -12,8 +12,10 @@ import (
 )
 
 type AuthHandler struct {
-	ldapService *services.LDAPService
-	jwtService  *services.JWTService
+	ldapService    *services.LDAPService
+	jwtService     *services.JWTService
+	anomalyService *services.AnomalyService
+	geoIPService   *services.GeoIPService
 }
 
 type LoginRequest struct {
@@ -26,10 +28,12 @@ type LoginResponse struct {
 	User  *models.User `json:"user"`
 }
 
-func NewAuthHandler(ldapService *services.LDAPService, jwtService *services.JWTService) *AuthHandler {
+func NewAuthHandler(ldapService *services.LDAPService, jwtService *services.JWTService, anomalyService *services.AnomalyService, geoIPService *services.GeoIPService) *AuthHandler {
 	return &AuthHandler{
-		ldapService: ldapService,
-		jwtService:  jwtService,
+		ldapService:    ldapService,
+		jwtService:     jwtService,
+		anomalyService: anomalyService,
+		geoIPService:   geoIPService,
 	}
 }
 
@@ -149,8 +153,11 @@ func (h *AuthHandler) logSuccessfulLogin(userID uint, username, ipAddress, userA
 		Success:   true,
 		LoginAt:   time.Now(),
 	}
+	h.geoIPService.Enrich(&loginLog)
 	
-	db.Create(&loginLog)
+	if err := db.Create(&loginLog).Error; err == nil {
+		h.detectAnomalies(&loginLog)
+	}
 }
 
 func (h *AuthHandler) logFailedLogin(username, ipAddress, userAgent, reason string) {
@@ -165,8 +172,15 @@ func (h *AuthHandler) logFailedLogin(username, ipAddress, userAgent, reason stri
 		FailReason: reason,
 		LoginAt:    time.Now(),
 	}
+	h.geoIPService.Enrich(&loginLog)
 	
-	db.Create(&loginLog)
+	if err := db.Create(&loginLog).Error; err == nil {
+		h.detectAnomalies(&loginLog)
+	}
+}
+
+func (h *AuthHandler) detectAnomalies(loginLog *models.LoginLog) {
+	h.anomalyService.Detect(loginLog)
 }
 
 func (h *AuthHandler) GetProfile(c *fiber.Ctx) error {
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"fui-backend/database"
+	"fui-backend/models"
+	"fui-backend/services"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type BudgetHandler struct {
+	authorizationService *services.AuthorizationService
+	budgetService        *services.BudgetService
+}
+
+func NewBudgetHandler(authorizationService *services.AuthorizationService, budgetService *services.BudgetService) *BudgetHandler {
+	return &BudgetHandler{
+		authorizationService: authorizationService,
+		budgetService:        budgetService,
+	}
+}
+
+var budgetLedgerSortColumns = map[string]sortColumn{
+	"created_at": {Column: "created_at", Kind: sortTime},
+}
+
+// GetConsumption returns the budget, reserved, committed and remaining
+// amounts per department and fiscal year. Filters: fiscal_year, department
+// (codes, comma-separated).
+func (h *BudgetHandler) GetConsumption(c *fiber.Ctx) error {
+	filter, ferr := h.budgetFilter(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	usage, err := h.budgetService.Consumption(database.GetDB(), *filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch budget consumption",
+		})
+	}
+
+	return c.JSON(usage)
+}
+
+// GetLedger lists budget ledger entries, latest first. Filters:
+// fiscal_year, department, proposal_id, kind.
+func (h *BudgetHandler) GetLedger(c *fiber.Ctx) error {
+	page, err := parsePageRequest(c, budgetLedgerSortColumns, "created_at")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	filter, ferr := h.budgetFilter(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	query := database.GetDB().Model(&models.BudgetLedgerEntry{})
+	if filter.Departments != nil {
+		query = query.Where("department_id IN (?)",
+			database.GetDB().Model(&models.Department{}).Select("id").Where("UPPER(code) IN ?", filter.Departments))
+	}
+	if filter.FiscalYear != 0 {
+		query = query.Where("fiscal_year = ?", filter.FiscalYear)
+	}
+	if filter.ProposalID != 0 {
+		query = query.Where("proposal_id = ?", filter.ProposalID)
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch budget ledger",
+		})
+	}
+
+	pageQuery, err := page.apply(query.Session(&gorm.Session{}), "id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	entries := []models.BudgetLedgerEntry{}
+	err = pageQuery.
+		Preload("Department").
+		Preload("Proposal", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		}).
+		Preload("Actor").
+		Find(&entries).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch budget ledger",
+		})
+	}
+
+	resp := PageResponse{Total: total}
+	if len(entries) > page.Limit {
+		last := &entries[page.Limit-1]
+		resp.NextCursor = page.nextCursor(len(entries), cursorTime(last.CreatedAt), last.ID)
+		entries = entries[:page.Limit]
+	}
+	resp.Data = entries
+
+	return c.JSON(resp)
+}
+
+// budgetFilter reads the filters shared by the budget endpoints and limits
+// the departments to those the user may see. Pass a returned error to
+// errorResponse.
+func (h *BudgetHandler) budgetFilter(c *fiber.Ctx) (*services.BudgetFilter, *fiber.Error) {
+	sub, err := requestSubject(c, h.authorizationService)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "user not found")
+	}
+
+	filter := &services.BudgetFilter{
+		FiscalYear: c.QueryInt("fiscal_year"),
+		ProposalID: uint(c.QueryInt("proposal_id")),
+	}
+	if filter.FiscalYear < 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid fiscal year")
+	}
+
+	allowed := h.authorizationService.BudgetDepartments(sub)
+	if department := c.Query("department"); department != "" {
+		filter.Departments = []string{}
+		for _, code := range strings.Split(strings.ToUpper(department), ",") {
+			code = strings.TrimSpace(code)
+			if allowed == nil || containsString(allowed, code) {
+				filter.Departments = append(filter.Departments, code)
+			}
+		}
+	} else {
+		filter.Departments = allowed
+	}
+	return filter, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
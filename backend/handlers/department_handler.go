@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"errors"
+	"fui-backend/database"
+	"fui-backend/models"
+	"fui-backend/services"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DepartmentHandler struct {
+	auditService      *services.AuditService
+	departmentService *services.DepartmentService
+}
+
+func NewDepartmentHandler(auditService *services.AuditService, departmentService *services.DepartmentService) *DepartmentHandler {
+	return &DepartmentHandler{
+		auditService:      auditService,
+		departmentService: departmentService,
+	}
+}
+
+// GetDepartments lists departments with their head and budgets, ordered by
+// code. Filters: q (code or name), unit, active.
+func (h *DepartmentHandler) GetDepartments(c *fiber.Ctx) error {
+	query := database.GetDB().Model(&models.Department{})
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + escapeLike(q) + "%"
+		query = query.Where("code LIKE ? ESCAPE '\\' OR name LIKE ? ESCAPE '\\'", like, like)
+	}
+	if unit := c.Query("unit"); unit != "" {
+		query = query.Where("unit = ?", unit)
+	}
+	if active := c.Query("active"); active != "" {
+		query = query.Where("is_active = ?", active == "true")
+	}
+
+	departments := []models.Department{}
+	err := query.Preload("Head").Preload("Budgets", orderBudgets).Order("code ASC").Find(&departments).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch departments",
+		})
+	}
+
+	return c.JSON(departments)
+}
+
+// GetActiveDepartments lists the departments proposals and users may refer
+// to, for any signed-in user.
+func (h *DepartmentHandler) GetActiveDepartments(c *fiber.Ctx) error {
+	departments := []models.Department{}
+	err := database.GetDB().
+		Where("is_active = ?", true).
+		Order("code ASC").
+		Find(&departments).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch departments",
+		})
+	}
+
+	return c.JSON(departments)
+}
+
+func (h *DepartmentHandler) GetDepartment(c *fiber.Ctx) error {
+	department, ferr := loadDepartment(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	return c.JSON(department)
+}
+
+func (h *DepartmentHandler) CreateDepartment(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var req services.DepartmentInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	db := database.GetDB()
+	var department models.Department
+	if err := h.departmentService.Apply(db, &department, req); err != nil {
+		return departmentError(c, err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&department).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityDepartment, department.ID, "create", &userID, department)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create department",
+		})
+	}
+
+	db.Preload("Head").First(&department, department.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(department)
+}
+
+// UpdateDepartment changes the name, unit, head or active flag. The code
+// stays as it is.
+func (h *DepartmentHandler) UpdateDepartment(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	department, ferr := loadDepartment(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	var req services.DepartmentInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	db := database.GetDB()
+	if err := h.departmentService.Apply(db, department, req); err != nil {
+		return departmentError(c, err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(department).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityDepartment, department.ID, "update", &userID, department)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update department",
+		})
+	}
+
+	db.Preload("Head").Preload("Budgets", orderBudgets).First(department, department.ID)
+
+	return c.JSON(department)
+}
+
+// DeleteDepartment removes a department that nothing refers to yet.
+// Departments in use can be deactivated instead.
+func (h *DepartmentHandler) DeleteDepartment(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	department, ferr := loadDepartment(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	db := database.GetDB()
+	inUse, err := h.departmentService.InUse(db, department)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete department",
+		})
+	}
+	if inUse {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "department is used by proposals or users; deactivate it instead",
+		})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("department_id = ?", department.ID).Delete(&models.DepartmentBudget{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(department).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityDepartment, department.ID, "delete", &userID, department)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete department",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "department deleted successfully",
+	})
+}
+
+// GetBudgets lists the capex budgets of a department by fiscal year.
+func (h *DepartmentHandler) GetBudgets(c *fiber.Ctx) error {
+	department, ferr := loadDepartment(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	budgets := department.Budgets
+	if budgets == nil {
+		budgets = []models.DepartmentBudget{}
+	}
+	return c.JSON(budgets)
+}
+
+// SetBudget creates or replaces the capex budget of a fiscal year.
+func (h *DepartmentHandler) SetBudget(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	department, ferr := loadDepartment(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	year, err := strconv.Atoi(c.Params("year"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid fiscal year",
+		})
+	}
+
+	var req services.DepartmentBudgetInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+	if err := h.departmentService.ValidateBudget(year, req); err != nil {
+		return departmentError(c, err)
+	}
+
+	db := database.GetDB()
+	budget := models.DepartmentBudget{DepartmentID: department.ID, FiscalYear: year}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(&budget).FirstOrInit(&budget).Error; err != nil {
+			return err
+		}
+		action := "update"
+		if budget.ID == 0 {
+			action = "create"
+		}
+
+		budget.Amount = req.Amount
+		budget.Notes = strings.TrimSpace(req.Notes)
+		if err := tx.Save(&budget).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityDepartmentBudget, budget.ID, action, &userID, budget)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to save budget",
+		})
+	}
+
+	return c.JSON(budget)
+}
+
+func (h *DepartmentHandler) DeleteBudget(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	department, ferr := loadDepartment(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	year, err := strconv.Atoi(c.Params("year"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid fiscal year",
+		})
+	}
+
+	db := database.GetDB()
+	var budget models.DepartmentBudget
+	if err := db.Where("department_id = ? AND fiscal_year = ?", department.ID, year).First(&budget).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "budget not found",
+		})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&budget).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityDepartmentBudget, budget.ID, "delete", &userID, budget)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete budget",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "budget deleted successfully",
+	})
+}
+
+// loadDepartment loads the department named by the :id parameter with its
+// head and budgets. Pass a returned error to errorResponse.
+func loadDepartment(c *fiber.Ctx) (*models.Department, *fiber.Error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid department id")
+	}
+
+	var department models.Department
+	err = database.GetDB().Preload("Head").Preload("Budgets", orderBudgets).First(&department, id).Error
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "department not found")
+	}
+	return &department, nil
+}
+
+func orderBudgets(db *gorm.DB) *gorm.DB {
+	return db.Order("fiscal_year DESC")
+}
+
+func departmentError(c *fiber.Ctx, err error) error {
+	var deptErr *services.DepartmentError
+	if errors.As(err, &deptErr) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":    deptErr.Error(),
+			"problems": deptErr.Problems,
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "failed to save department",
+	})
+}
@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"fui-backend/database"
+	"fui-backend/models"
+	"fui-backend/services"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const maxExchangeRateFileSize = 2 << 20
+
+type ExchangeRateHandler struct {
+	auditService        *services.AuditService
+	exchangeRateService *services.ExchangeRateService
+}
+
+func NewExchangeRateHandler(auditService *services.AuditService, exchangeRateService *services.ExchangeRateService) *ExchangeRateHandler {
+	return &ExchangeRateHandler{
+		auditService:        auditService,
+		exchangeRateService: exchangeRateService,
+	}
+}
+
+var exchangeRateSortColumns = map[string]sortColumn{
+	"effective_date": {Column: "effective_date", Kind: sortTime},
+	"currency":       {Column: "currency", Kind: sortString},
+	"created_at":     {Column: "created_at", Kind: sortTime},
+}
+
+func exchangeRateSortValue(rate *models.ExchangeRate, sortKey string) string {
+	switch sortKey {
+	case "currency":
+		return rate.Currency
+	case "created_at":
+		return cursorTime(rate.CreatedAt)
+	default:
+		return cursorTime(rate.EffectiveDate)
+	}
+}
+
+// GetExchangeRates lists exchange rates, latest effective date first.
+// Filters: currency, source, from, to (on the effective date).
+func (h *ExchangeRateHandler) GetExchangeRates(c *fiber.Ctx) error {
+	page, err := parsePageRequest(c, exchangeRateSortColumns, "effective_date")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	query := database.GetDB().Model(&models.ExchangeRate{})
+	if currency := c.Query("currency"); currency != "" {
+		query = query.Where("currency IN ?", strings.Split(strings.ToUpper(currency), ","))
+	}
+	if source := c.Query("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+	if from := c.Query("from"); from != "" {
+		t, err := parseDateParam(from, false)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		query = query.Where("effective_date >= ?", t)
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseDateParam(to, true)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		query = query.Where("effective_date <= ?", t)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch exchange rates",
+		})
+	}
+
+	pageQuery, err := page.apply(query.Session(&gorm.Session{}), "id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	rates := []models.ExchangeRate{}
+	if err := pageQuery.Preload("CreatedBy").Find(&rates).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch exchange rates",
+		})
+	}
+
+	resp := PageResponse{Total: total}
+	if len(rates) > page.Limit {
+		last := &rates[page.Limit-1]
+		resp.NextCursor = page.nextCursor(len(rates), exchangeRateSortValue(last, page.SortKey), last.ID)
+		rates = rates[:page.Limit]
+	}
+	resp.Data = rates
+
+	return c.JSON(resp)
+}
+
+// GetCurrentExchangeRates returns the rate in effect today of every
+// currency.
+func (h *ExchangeRateHandler) GetCurrentExchangeRates(c *fiber.Ctx) error {
+	rates, err := h.exchangeRateService.Current(database.GetDB())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch exchange rates",
+		})
+	}
+
+	return c.JSON(rates)
+}
+
+func (h *ExchangeRateHandler) CreateExchangeRate(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var req services.ExchangeRateInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	rate, err := h.exchangeRateService.Build(req, models.ExchangeRateManual, userID)
+	if err != nil {
+		return exchangeRateError(c, err)
+	}
+
+	db := database.GetDB()
+	if ferr := checkExchangeRateUnique(db, &rate); ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&rate).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityExchangeRate, rate.ID, "create", &userID, rate)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create exchange rate",
+		})
+	}
+
+	db.Preload("CreatedBy").First(&rate, rate.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(rate)
+}
+
+// UpdateExchangeRate corrects a rate. Proposals already submitted keep the
+// rate they were locked at.
+func (h *ExchangeRateHandler) UpdateExchangeRate(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid exchange rate id",
+		})
+	}
+
+	userID := c.Locals("user_id").(uint)
+
+	var req services.ExchangeRateInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	db := database.GetDB()
+	var rate models.ExchangeRate
+
+	if err := db.First(&rate, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "exchange rate not found",
+		})
+	}
+
+	updated, err := h.exchangeRateService.Build(req, models.ExchangeRateManual, userID)
+	if err != nil {
+		return exchangeRateError(c, err)
+	}
+	updated.ID, updated.CreatedAt = rate.ID, rate.CreatedAt
+
+	if ferr := checkExchangeRateUnique(db, &updated); ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("CreatedBy").Save(&updated).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityExchangeRate, updated.ID, "update", &userID, updated)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update exchange rate",
+		})
+	}
+
+	db.Preload("CreatedBy").First(&updated, updated.ID)
+
+	return c.JSON(updated)
+}
+
+func (h *ExchangeRateHandler) DeleteExchangeRate(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid exchange rate id",
+		})
+	}
+
+	userID := c.Locals("user_id").(uint)
+
+	db := database.GetDB()
+	var rate models.ExchangeRate
+
+	if err := db.First(&rate, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "exchange rate not found",
+		})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&rate).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityExchangeRate, rate.ID, "delete", &userID, rate)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete exchange rate",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "exchange rate deleted successfully",
+	})
+}
+
+// ImportExchangeRates loads rates from an uploaded CSV file (form field
+// "file") with the columns currency, effective_date and rate. Nothing is
+// stored unless every line is valid; a rate for a currency and date that
+// already exists is replaced.
+func (h *ExchangeRateHandler) ImportExchangeRates(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "file is required",
+		})
+	}
+	if header.Size > maxExchangeRateFileSize {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": fmt.Sprintf("file must not exceed %d MB", maxExchangeRateFileSize>>20),
+		})
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "failed to read file",
+		})
+	}
+	defer file.Close()
+
+	rates, err := h.exchangeRateService.ParseCSV(file, userID)
+	if err != nil {
+		return exchangeRateError(c, err)
+	}
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := h.exchangeRateService.Import(tx, rates); err != nil {
+			return err
+		}
+		for _, r := range rates {
+			var stored models.ExchangeRate
+			if err := tx.Where("currency = ? AND effective_date = ?", r.Currency, r.EffectiveDate).First(&stored).Error; err != nil {
+				return err
+			}
+			if err := h.auditService.Record(tx, models.AuditEntityExchangeRate, stored.ID, "import", &userID, stored); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to import exchange rates",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":  "exchange rates imported successfully",
+		"imported": len(rates),
+	})
+}
+
+// checkExchangeRateUnique rejects a second rate for a currency on the same
+// date.
+func checkExchangeRateUnique(db *gorm.DB, rate *models.ExchangeRate) *fiber.Error {
+	var count int64
+	err := db.Model(&models.ExchangeRate{}).
+		Where("currency = ? AND effective_date = ? AND id <> ?", rate.Currency, rate.EffectiveDate, rate.ID).
+		Count(&count).Error
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to save exchange rate")
+	}
+	if count > 0 {
+		return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("a %s rate effective %s already exists", rate.Currency, rate.EffectiveDate.Format("2006-01-02")))
+	}
+	return nil
+}
+
+func exchangeRateError(c *fiber.Ctx, err error) error {
+	var rateErr *services.ExchangeRateError
+	if errors.As(err, &rateErr) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":    rateErr.Error(),
+			"problems": rateErr.Problems,
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "failed to save exchange rate",
+	})
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"fui-backend/config"
+	"fui-backend/database"
+	"fui-backend/models"
+	"fui-backend/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB connects the database package to a migrated scratch database
+// that is removed when tb ends.
+func openTestDB(tb testing.TB) *gorm.DB {
+	tb.Helper()
+
+	cfg := &config.Config{Database: config.DatabaseConfig{Path: filepath.Join(tb.TempDir(), "test.db")}}
+	if err := database.Connect(cfg); err != nil {
+		tb.Fatalf("connect: %v", err)
+	}
+	db := database.GetDB()
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	if err := database.Migrate(); err != nil {
+		tb.Fatalf("migrate: %v", err)
+	}
+	tb.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// testServer serves the proposal API from a scratch database. Requests are
+// authenticated as the user whose ID is in the X-User header.
+type testServer struct {
+	db       *gorm.DB
+	app      *fiber.App
+	workflow *services.ProposalWorkflowService
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	db := openTestDB(t)
+	cfg := &config.Config{
+		Numbering: config.NumberingConfig{Pattern: "{PREFIX}-{FY}-{SEQ:5}", Prefix: "INV", Reset: "yearly", FiscalYearStartMonth: 1},
+		Budget:    config.BudgetConfig{Enforcement: "warn", FiscalYearStartMonth: 1},
+		Workflow:  config.WorkflowConfig{OverrunTolerance: 10},
+		Finance:   config.FinanceConfig{DefaultWACC: 10},
+	}
+
+	audit := services.NewAuditService(&cfg.Audit)
+	authz := services.NewAuthorizationService()
+	rates := services.NewExchangeRateService()
+	types := services.NewInvestmentTypeService()
+	departments := services.NewDepartmentService()
+	cycles := services.NewPlanningCycleService(types)
+	budget, err := services.NewBudgetService(&cfg.Budget)
+	if err != nil {
+		t.Fatalf("NewBudgetService: %v", err)
+	}
+	workflow, err := services.NewProposalWorkflowService(&cfg.Workflow, audit, authz, services.NewNotificationService(), rates, budget, cycles, types)
+	if err != nil {
+		t.Fatalf("NewProposalWorkflowService: %v", err)
+	}
+	numbers, err := services.NewProposalNumberService(&cfg.Numbering)
+	if err != nil {
+		t.Fatalf("NewProposalNumberService: %v", err)
+	}
+	finance, err := services.NewFinancialEvaluationService(&cfg.Finance)
+	if err != nil {
+		t.Fatalf("NewFinancialEvaluationService: %v", err)
+	}
+	lineItems := services.NewProposalLineItemService()
+	templates := services.NewProposalTemplateService(departments, types, lineItems, finance)
+
+	proposalHandler := NewProposalHandler(audit, authz, workflow, services.NewProposalVersionService(), numbers, lineItems, finance, rates, departments, cycles, types, templates)
+	quotationHandler := NewQuotationHandler(audit, authz, services.NewQuotationService(rates))
+	spendHandler := NewSpendHandler(audit, authz, workflow, services.NewSpendService(rates))
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Get("X-User"))
+		if err != nil {
+			return c.SendStatus(fiber.StatusUnauthorized)
+		}
+		var user models.User
+		if err := db.First(&user, id).Error; err != nil {
+			return c.SendStatus(fiber.StatusUnauthorized)
+		}
+		c.Locals("user_id", user.ID)
+		c.Locals("role", user.Role)
+		return c.Next()
+	})
+
+	proposals := app.Group("/proposals")
+	proposals.Post("/", proposalHandler.CreateProposal)
+	proposals.Post("/from-template/:id", proposalHandler.CreateFromTemplate)
+	proposals.Get("/:id", proposalHandler.GetProposal)
+	proposals.Post("/:id/clone", proposalHandler.CloneProposal)
+	proposals.Post("/:id/submit", proposalHandler.SubmitProposal)
+	proposals.Post("/:id/approve", proposalHandler.ApproveProposal)
+	proposals.Post("/:id/start", proposalHandler.StartProposal)
+	proposals.Post("/:id/complete", proposalHandler.CompleteProposal)
+	proposals.Post("/:id/close", proposalHandler.CloseProposal)
+	proposals.Post("/:id/approve-overrun", proposalHandler.ApproveOverrun)
+	proposals.Post("/:id/quotations", quotationHandler.CreateQuotation)
+	proposals.Post("/:id/quotations/compare", quotationHandler.CompareQuotations)
+	proposals.Put("/:id/quotations/:quotationId", quotationHandler.UpdateQuotation)
+	proposals.Delete("/:id/quotations/:quotationId", quotationHandler.DeleteQuotation)
+	proposals.Post("/:id/spend", spendHandler.CreateSpendRecord)
+	proposals.Put("/:id/spend/:spendId", spendHandler.UpdateSpendRecord)
+	proposals.Delete("/:id/spend/:spendId", spendHandler.DeleteSpendRecord)
+
+	return &testServer{db: db, app: app, workflow: workflow}
+}
+
+// do sends a request as user and decodes the JSON response into out, when
+// given. It returns the status and the raw body.
+func (s *testServer) do(t *testing.T, user *models.User, method, path string, body interface{}, out interface{}) (int, string) {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("encode request: %v", err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", strconv.Itoa(int(user.ID)))
+
+	resp, err := s.app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	if out != nil && len(raw) > 0 {
+		if err := json.Unmarshal(raw, out); err != nil {
+			t.Fatalf("%s %s: decode %s: %v", method, path, raw, err)
+		}
+	}
+	return resp.StatusCode, string(raw)
+}
+
+// createUser adds an active user with the given role and department.
+func (s *testServer) createUser(t *testing.T, username string, role models.UserRole, department string) *models.User {
+	t.Helper()
+
+	user := models.User{Username: username, Email: username + "@example.com", FullName: username, Role: role, Department: department, IsActive: true}
+	if err := s.db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return &user
+}
+
+// mustCreate inserts a row for a test, failing it on error.
+func (s *testServer) mustCreate(t *testing.T, value interface{}) {
+	t.Helper()
+
+	if err := s.db.Create(value).Error; err != nil {
+		t.Fatalf("create %T: %v", value, err)
+	}
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"errors"
+	"fui-backend/database"
+	"fui-backend/models"
+	"fui-backend/services"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type InvestmentTypeHandler struct {
+	auditService          *services.AuditService
+	investmentTypeService *services.InvestmentTypeService
+}
+
+func NewInvestmentTypeHandler(auditService *services.AuditService, investmentTypeService *services.InvestmentTypeService) *InvestmentTypeHandler {
+	return &InvestmentTypeHandler{
+		auditService:          auditService,
+		investmentTypeService: investmentTypeService,
+	}
+}
+
+// GetInvestmentTypes lists the catalog ordered by code. Filters: q (code or
+// name), active.
+func (h *InvestmentTypeHandler) GetInvestmentTypes(c *fiber.Ctx) error {
+	query := database.GetDB().Model(&models.InvestmentType{})
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + escapeLike(q) + "%"
+		query = query.Where("code LIKE ? ESCAPE '\\' OR name LIKE ? ESCAPE '\\'", like, like)
+	}
+	if active := c.Query("active"); active != "" {
+		query = query.Where("is_active = ?", active == "true")
+	}
+
+	types := []models.InvestmentType{}
+	if err := query.Order("code ASC").Find(&types).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch investment types",
+		})
+	}
+
+	return c.JSON(types)
+}
+
+// GetActiveInvestmentTypes lists the types proposals may use, with their
+// field schemas, for any signed-in user.
+func (h *InvestmentTypeHandler) GetActiveInvestmentTypes(c *fiber.Ctx) error {
+	types := []models.InvestmentType{}
+	err := database.GetDB().
+		Where("is_active = ?", true).
+		Order("code ASC").
+		Find(&types).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch investment types",
+		})
+	}
+
+	return c.JSON(types)
+}
+
+func (h *InvestmentTypeHandler) GetInvestmentType(c *fiber.Ctx) error {
+	t, ferr := loadInvestmentType(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	return c.JSON(t)
+}
+
+func (h *InvestmentTypeHandler) CreateInvestmentType(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var req services.InvestmentTypeInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	db := database.GetDB()
+	var t models.InvestmentType
+	if err := h.investmentTypeService.Apply(db, &t, req); err != nil {
+		return investmentTypeError(c, err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&t).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityInvestmentType, t.ID, "create", &userID, t)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create investment type",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(t)
+}
+
+// UpdateInvestmentType changes the name, description, field schema or
+// active flag. The code stays as it is.
+func (h *InvestmentTypeHandler) UpdateInvestmentType(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	t, ferr := loadInvestmentType(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	var req services.InvestmentTypeInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	db := database.GetDB()
+	if err := h.investmentTypeService.Apply(db, t, req); err != nil {
+		return investmentTypeError(c, err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(t).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityInvestmentType, t.ID, "update", &userID, t)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update investment type",
+		})
+	}
+
+	return c.JSON(t)
+}
+
+// DeleteInvestmentType removes a type that no proposal or planning cycle
+// refers to. Types in use can be deactivated instead.
+func (h *InvestmentTypeHandler) DeleteInvestmentType(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	t, ferr := loadInvestmentType(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	db := database.GetDB()
+	inUse, err := h.investmentTypeService.InUse(db, t)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete investment type",
+		})
+	}
+	if inUse {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "investment type is used by proposals or planning cycles; deactivate it instead",
+		})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(t).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityInvestmentType, t.ID, "delete", &userID, t)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete investment type",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "investment type deleted successfully",
+	})
+}
+
+// loadInvestmentType loads the type named by the :id parameter. Pass a
+// returned error to errorResponse.
+func loadInvestmentType(c *fiber.Ctx) (*models.InvestmentType, *fiber.Error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid investment type id")
+	}
+
+	var t models.InvestmentType
+	if err := database.GetDB().First(&t, id).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "investment type not found")
+	}
+	return &t, nil
+}
+
+func investmentTypeError(c *fiber.Ctx, err error) error {
+	var typeErr *services.InvestmentTypeError
+	if errors.As(err, &typeErr) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":    typeErr.Error(),
+			"problems": typeErr.Problems,
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "failed to save investment type",
+	})
+}
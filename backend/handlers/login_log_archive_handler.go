@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"fui-backend/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type LoginLogArchiveHandler struct {
+	retentionService *services.RetentionService
+}
+
+func NewLoginLogArchiveHandler(retentionService *services.RetentionService) *LoginLogArchiveHandler {
+	return &LoginLogArchiveHandler{retentionService: retentionService}
+}
+
+func (h *LoginLogArchiveHandler) GetArchives(c *fiber.Ctx) error {
+	archives, err := h.retentionService.ListArchives()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to list archives",
+		})
+	}
+
+	return c.JSON(archives)
+}
+
+func (h *LoginLogArchiveHandler) DownloadArchive(c *fiber.Ctx) error {
+	path, err := h.retentionService.ArchivePath(c.Params("name"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "archive not found",
+		})
+	}
+
+	return c.Download(path)
+}
+
+// RunRetention archives expired login logs immediately instead of waiting
+// for the scheduled job.
+func (h *LoginLogArchiveHandler) RunRetention(c *fiber.Ctx) error {
+	result, err := h.retentionService.ArchiveLoginLogs()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to archive login logs",
+		})
+	}
+
+	return c.JSON(result)
+}
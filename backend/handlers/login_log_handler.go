@@ -1,10 +1,16 @@
 package handlers
 
 import (
+	"fmt"
 	"fui-backend/database"
 	"fui-backend/models"
+	"net"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 type LoginLogHandler struct{}
@@ -23,99 +29,362 @@ type LoginLogResponse struct {
 	Success    bool   `json:"success"`
 	FailReason string `json:"fail_reason,omitempty"`
 	LoginAt    string `json:"login_at"`
+
+	Browser     string `json:"browser"`
+	OS          string `json:"os"`
+	DeviceType  string `json:"device_type"`
+	Country     string `json:"country"`
+	CountryCode string `json:"country_code"`
+	City        string `json:"city"`
+	Site        string `json:"site"`
 }
 
-func (h *LoginLogHandler) GetLoginLogs(c *fiber.Ctx) error {
-	db := database.GetDB()
-	
-	var logs []models.LoginLog
-	result := db.Order("login_at DESC").Limit(100).Find(&logs)
-	
-	if result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch login logs",
-		})
+var loginLogSortColumns = map[string]sortColumn{
+	"login_at":   {Column: "login_logs.login_at", Kind: sortTime, UTC: true},
+	"username":   {Column: "login_logs.username", Kind: sortString},
+	"ip_address": {Column: "login_logs.ip_address", Kind: sortString},
+	"success":    {Column: "login_logs.success", Kind: sortBool},
+	"id":         {Column: "login_logs.id", Kind: sortNumber},
+}
+
+// loginLogRow is a login log joined with the full name of its user.
+type loginLogRow struct {
+	models.LoginLog
+	FullName string
+}
+
+// loginLogEnrichmentFilters maps exact-match query params to the enriched
+// columns of login_logs. browser is matched separately, by name with or
+// without its version.
+var loginLogEnrichmentFilters = map[string]string{
+	"os":           "login_logs.os",
+	"device_type":  "login_logs.device_type",
+	"country":      "login_logs.country",
+	"country_code": "login_logs.country_code",
+	"city":         "login_logs.city",
+	"site":         "login_logs.site",
+}
+
+// applyLoginLogFilters adds the filters supported by the login log API:
+// from/to (date range), success, username (prefix), ip (address or CIDR),
+// fail_reason (substring), browser ("Chrome" or "Chrome 120") and the other
+// enriched fields (exact match).
+func applyLoginLogFilters(query *gorm.DB, c *fiber.Ctx) (*gorm.DB, error) {
+	if from := c.Query("from"); from != "" {
+		t, err := parseDateParam(from, false)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("login_logs.login_at >= ?", models.LoginTimeAfter(t))
 	}
 
-	// Get user full names
-	userMap := make(map[uint]string)
-	for _, log := range logs {
-		if log.UserID != nil {
-			var user models.User
-			db.Select("id, full_name").First(&user, *log.UserID)
-			userMap[user.ID] = user.FullName
+	if to := c.Query("to"); to != "" {
+		t, err := parseDateParam(to, true)
+		if err != nil {
+			return nil, err
 		}
+		query = query.Where("login_logs.login_at <= ?", models.LoginTime(t))
 	}
 
-	// Format response
-	var response []LoginLogResponse
-	for _, log := range logs {
-		fullName := ""
-		if log.UserID != nil {
-			fullName = userMap[*log.UserID]
+	if success := c.Query("success"); success != "" {
+		value, err := strconv.ParseBool(success)
+		if err != nil {
+			return nil, fmt.Errorf("success must be true or false")
 		}
-		
+		query = query.Where("login_logs.success = ?", value)
+	}
+
+	if username := c.Query("username"); username != "" {
+		query = query.Where("login_logs.username LIKE ? ESCAPE '\\'", escapeLike(username)+"%")
+	}
+
+	if ip := c.Query("ip"); ip != "" {
+		if strings.Contains(ip, "/") {
+			if _, _, err := net.ParseCIDR(ip); err != nil {
+				return nil, fmt.Errorf("invalid CIDR: %s", ip)
+			}
+			query = query.Where("ip_in_cidr(login_logs.ip_address, ?)", ip)
+		} else {
+			query = query.Where("login_logs.ip_address = ?", ip)
+		}
+	}
+
+	if reason := c.Query("fail_reason"); reason != "" {
+		query = query.Where("login_logs.fail_reason LIKE ? ESCAPE '\\'", "%"+escapeLike(reason)+"%")
+	}
+
+	// Browsers are stored with their major version, e.g. "Chrome 120"
+	if browser := c.Query("browser"); browser != "" {
+		query = query.Where("login_logs.browser = ? OR login_logs.browser LIKE ? ESCAPE '\\'", browser, escapeLike(browser)+" %")
+	}
+
+	for param, column := range loginLogEnrichmentFilters {
+		if value := c.Query(param); value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+
+	return query, nil
+}
+
+func loginLogSortValue(log *loginLogRow, sortKey string) string {
+	switch sortKey {
+	case "username":
+		return log.Username
+	case "ip_address":
+		return log.IPAddress
+	case "success":
+		return strconv.FormatBool(log.Success)
+	case "id":
+		return strconv.FormatUint(uint64(log.ID), 10)
+	default:
+		return cursorTime(log.LoginAt)
+	}
+}
+
+// findLoginLogs runs a filtered, keyset-paginated query over login_logs.
+// User names are resolved with a join, so a page costs one count query and
+// one select regardless of its size.
+func findLoginLogs(c *fiber.Ctx, base *gorm.DB) ([]loginLogRow, *PageResponse, error) {
+	page, err := parsePageRequest(c, loginLogSortColumns, "login_at")
+	if err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	query, err := applyLoginLogFilters(base.Model(&models.LoginLog{}), c)
+	if err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, nil, err
+	}
+
+	pageQuery, err := page.apply(query.Session(&gorm.Session{}), "login_logs.id")
+	if err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	var logs []loginLogRow
+	err = pageQuery.
+		Select("login_logs.*, users.full_name AS full_name").
+		Joins("LEFT JOIN users ON users.id = login_logs.user_id").
+		Find(&logs).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp := &PageResponse{Total: total}
+	if len(logs) > page.Limit {
+		last := &logs[page.Limit-1]
+		resp.NextCursor = page.nextCursor(len(logs), loginLogSortValue(last, page.SortKey), last.ID)
+		logs = logs[:page.Limit]
+	}
+
+	return logs, resp, nil
+}
+
+func loginLogError(c *fiber.Ctx, err error) error {
+	if e, ok := err.(*fiber.Error); ok {
+		return c.Status(e.Code).JSON(fiber.Map{
+			"error": e.Message,
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to fetch login logs",
+	})
+}
+
+func (h *LoginLogHandler) GetLoginLogs(c *fiber.Ctx) error {
+	db := database.GetDB()
+
+	logs, page, err := findLoginLogs(c, db)
+	if err != nil {
+		return loginLogError(c, err)
+	}
+
+	page.Data = newLoginLogResponses(logs)
+	return c.JSON(page)
+}
+
+func newLoginLogResponses(logs []loginLogRow) []LoginLogResponse {
+	response := make([]LoginLogResponse, 0, len(logs))
+	for _, log := range logs {
 		response = append(response, LoginLogResponse{
 			ID:         log.ID,
 			UserID:     log.UserID,
 			Username:   log.Username,
-			FullName:   fullName,
+			FullName:   log.FullName,
 			IPAddress:  log.IPAddress,
 			UserAgent:  log.UserAgent,
 			Success:    log.Success,
 			FailReason: log.FailReason,
-			LoginAt:    log.LoginAt.Format("2006-01-02 15:04:05"),
+			LoginAt:    log.LoginAt.Local().Format("2006-01-02 15:04:05"),
+
+			Browser:     log.Browser,
+			OS:          log.OS,
+			DeviceType:  log.DeviceType,
+			Country:     log.Country,
+			CountryCode: log.CountryCode,
+			City:        log.City,
+			Site:        log.Site,
 		})
 	}
 
-	return c.JSON(response)
+	return response
 }
 
 func (h *LoginLogHandler) GetUserLoginHistory(c *fiber.Ctx) error {
 	username := c.Params("username")
 	db := database.GetDB()
-	
-	var logs []models.LoginLog
-	result := db.Where("username = ?", username).Order("login_at DESC").Limit(50).Find(&logs)
-	
-	if result.Error != nil {
+
+	logs, page, err := findLoginLogs(c, db.Where("login_logs.username = ?", username))
+	if err != nil {
+		return loginLogError(c, err)
+	}
+
+	page.Data = newLoginLogResponses(logs)
+	return c.JSON(page)
+}
+
+type LoginStatsBucket struct {
+	Bucket      string `json:"bucket"`
+	Success     int64  `json:"success"`
+	Failure     int64  `json:"failure"`
+	UniqueUsers int64  `json:"unique_users"`
+}
+
+type LoginStatsCount struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// loginStatsBuckets maps a bucket granularity to a SQL expression over
+// login_at. Timestamps are stored in UTC and bucketed in the server's local
+// time.
+var loginStatsBuckets = map[string]string{
+	"hour": "strftime('%Y-%m-%d %H:00', login_at, 'localtime')",
+	"day":  "date(login_at, 'localtime')",
+	"week": "date(login_at, 'localtime', '-6 days', 'weekday 1')",
+}
+
+// GetLoginStats returns login totals and trends for a date range (default:
+// the last 30 days). Query params: from, to, bucket (hour, day, week) and
+// top (size of the top-N lists, default 10).
+func (h *LoginLogHandler) GetLoginStats(c *fiber.Ctx) error {
+	db := database.GetDB()
+
+	bucket := c.Query("bucket", "day")
+	bucketExpr, ok := loginStatsBuckets[bucket]
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "bucket must be hour, day or week",
+		})
+	}
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	var err error
+	if v := c.Query("from"); v != "" {
+		if from, err = parseDateParam(v, false); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = parseDateParam(v, true); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if from.After(to) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "from must be before to",
+		})
+	}
+
+	top := c.QueryInt("top", 10)
+	if top <= 0 || top > 100 {
+		top = 10
+	}
+
+	inRange := func() *gorm.DB {
+		return db.Model(&models.LoginLog{}).Where("login_at >= ? AND login_at <= ?", models.LoginTimeAfter(from), models.LoginTime(to))
+	}
+
+	var totals struct {
+		Total       int64
+		Successful  int64
+		Failed      int64
+		UniqueUsers int64
+	}
+	err = inRange().Select(`COUNT(*) AS total,
+		COALESCE(SUM(CASE WHEN success THEN 1 ELSE 0 END), 0) AS successful,
+		COALESCE(SUM(CASE WHEN success THEN 0 ELSE 1 END), 0) AS failed,
+		COUNT(DISTINCT CASE WHEN success THEN user_id END) AS unique_users`).
+		Scan(&totals).Error
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch login logs",
+			"error": "Failed to fetch login stats",
 		})
 	}
 
-	// Format response
-	var response []LoginLogResponse
-	for _, log := range logs {
-		response = append(response, LoginLogResponse{
-			ID:         log.ID,
-			UserID:     log.UserID,
-			Username:   log.Username,
-			IPAddress:  log.IPAddress,
-			UserAgent:  log.UserAgent,
-			Success:    log.Success,
-			FailReason: log.FailReason,
-			LoginAt:    log.LoginAt.Format("2006-01-02 15:04:05"),
+	series := []LoginStatsBucket{}
+	err = inRange().Select(bucketExpr + ` AS bucket,
+		SUM(CASE WHEN success THEN 1 ELSE 0 END) AS success,
+		SUM(CASE WHEN success THEN 0 ELSE 1 END) AS failure,
+		COUNT(DISTINCT CASE WHEN success THEN user_id END) AS unique_users`).
+		Group("bucket").Order("bucket").
+		Scan(&series).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch login stats",
 		})
 	}
 
-	return c.JSON(response)
-}
+	topFailures := func(column string) ([]LoginStatsCount, error) {
+		counts := []LoginStatsCount{}
+		err := inRange().Where("success = ?", false).
+			Select(column + " AS key, COUNT(*) AS count").
+			Group(column).Order("count DESC, key").Limit(top).
+			Scan(&counts).Error
+		return counts, err
+	}
+
+	topUsernames, err := topFailures("username")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch login stats",
+		})
+	}
+	topIPs, err := topFailures("ip_address")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch login stats",
+		})
+	}
+
+	failReasons := []LoginStatsCount{}
+	err = inRange().Where("success = ?", false).
+		Select("COALESCE(NULLIF(fail_reason, ''), 'Unknown') AS key, COUNT(*) AS count").
+		Group("key").Order("count DESC").
+		Scan(&failReasons).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch login stats",
+		})
+	}
 
-func (h *LoginLogHandler) GetLoginStats(c *fiber.Ctx) error {
-	db := database.GetDB()
-	
-	var totalLogins int64
-	var successfulLogins int64
-	var failedLogins int64
-	
-	db.Model(&models.LoginLog{}).Count(&totalLogins)
-	db.Model(&models.LoginLog{}).Where("success = ?", true).Count(&successfulLogins)
-	db.Model(&models.LoginLog{}).Where("success = ?", false).Count(&failedLogins)
-	
 	return c.JSON(fiber.Map{
-		"total_logins":      totalLogins,
-		"successful_logins": successfulLogins,
-		"failed_logins":     failedLogins,
+		"from":                  from,
+		"to":                    to,
+		"bucket":                bucket,
+		"total_logins":          totals.Total,
+		"successful_logins":     totals.Successful,
+		"failed_logins":         totals.Failed,
+		"unique_users":          totals.UniqueUsers,
+		"series":                series,
+		"top_failing_usernames": topUsernames,
+		"top_failing_ips":       topIPs,
+		"fail_reasons":          failReasons,
 	})
 }
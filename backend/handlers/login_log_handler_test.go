@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fui-backend/database"
+	"fui-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// countQueries counts the SELECT statements db runs from now on.
+func countQueries(tb testing.TB, db *gorm.DB) *int64 {
+	tb.Helper()
+
+	var queries int64
+	count := func(*gorm.DB) { atomic.AddInt64(&queries, 1) }
+	for name, err := range map[string]error{
+		"query": db.Callback().Query().After("gorm:query").Register("test:count_query", count),
+		"row":   db.Callback().Row().After("gorm:row").Register("test:count_row", count),
+		"raw":   db.Callback().Raw().After("gorm:raw").Register("test:count_raw", count),
+	} {
+		if err != nil {
+			tb.Fatalf("register %s callback: %v", name, err)
+		}
+	}
+	return &queries
+}
+
+// seedLoginLogs adds userCount users and rowCount login logs, one a minute
+// going back from now. Every fourth login fails.
+func seedLoginLogs(tb testing.TB, db *gorm.DB, userCount, rowCount int) {
+	tb.Helper()
+
+	users := make([]models.User, 0, userCount)
+	for i := 0; i < userCount; i++ {
+		users = append(users, models.User{
+			Username: fmt.Sprintf("user%04d", i),
+			Email:    fmt.Sprintf("user%04d@example.com", i),
+			FullName: fmt.Sprintf("User %04d", i),
+			Role:     models.RoleCorpFA,
+		})
+	}
+	if err := db.CreateInBatches(&users, 500).Error; err != nil {
+		tb.Fatalf("seed users: %v", err)
+	}
+
+	reasons := []string{"Password salah", "Password LDAP salah", "User tidak aktif"}
+	now := time.Now()
+	logs := make([]models.LoginLog, 0, rowCount)
+	for i := 0; i < rowCount; i++ {
+		user := users[i%userCount]
+		entry := models.LoginLog{
+			Username:  user.Username,
+			IPAddress: fmt.Sprintf("10.%d.%d.%d", 100+i%3, (i/256)%256, i%256),
+			UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0",
+			Success:   i%4 != 0,
+			LoginAt:   now.Add(-time.Duration(i) * time.Minute),
+		}
+		if entry.Success {
+			entry.UserID = &user.ID
+		} else {
+			entry.FailReason = reasons[i%len(reasons)]
+		}
+		logs = append(logs, entry)
+	}
+	if err := db.CreateInBatches(&logs, 250).Error; err != nil {
+		tb.Fatalf("seed login logs: %v", err)
+	}
+}
+
+var loginLogScenarios = []struct {
+	name  string
+	query string
+}{
+	{"first page", "limit=100"},
+	{"failed only", "limit=100&success=false"},
+	{"username prefix", "limit=100&username=user00"},
+	{"date range", "limit=100&from=" + time.Now().AddDate(0, 0, -1).Format("2006-01-02")},
+	{"CIDR", "limit=100&ip=10.101.0.0/16"},
+	{"sort by username", "limit=100&sort=username&order=asc"},
+}
+
+func getLoginLogs(tb testing.TB, app *fiber.App, query string) {
+	tb.Helper()
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/logs?"+query, nil), -1)
+	if err != nil {
+		tb.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != fiber.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		tb.Fatalf("%s: status %d: %s", query, resp.StatusCode, body)
+	}
+}
+
+// A page of login logs must not look up users row by row.
+func TestFindLoginLogsQueryCount(t *testing.T) {
+	db := openTestDB(t)
+	seedLoginLogs(t, db, 50, 500)
+	queries := countQueries(t, db)
+
+	app := fiber.New()
+	app.Get("/logs", NewLoginLogHandler().GetLoginLogs)
+
+	for _, sc := range loginLogScenarios {
+		t.Run(sc.name, func(t *testing.T) {
+			atomic.StoreInt64(queries, 0)
+			getLoginLogs(t, app, sc.query)
+			if n := atomic.LoadInt64(queries); n > 2 {
+				t.Errorf("page issued %d queries, want at most 2 (count and select)", n)
+			}
+		})
+	}
+}
+
+var benchLoginLogs = flag.Int("loginlogs", 100000, "number of login logs BenchmarkFindLoginLogs seeds")
+
+func BenchmarkFindLoginLogs(b *testing.B) {
+	db := openTestDB(b)
+	seedLoginLogs(b, db, 1000, *benchLoginLogs)
+	queries := countQueries(b, db)
+
+	app := fiber.New()
+	app.Get("/logs", NewLoginLogHandler().GetLoginLogs)
+
+	for _, sc := range loginLogScenarios {
+		b.Run(sc.name, func(b *testing.B) {
+			atomic.StoreInt64(queries, 0)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				getLoginLogs(b, app, sc.query)
+			}
+			b.ReportMetric(float64(atomic.LoadInt64(queries))/float64(b.N), "queries/page")
+		})
+	}
+}
+
+// Logins written with different UTC offsets are listed and counted by the
+// instant they happened, not by the text of their timestamps, whether they
+// were written before login_at was stored in UTC or after.
+func TestLoginLogsNormalizeOffsets(t *testing.T) {
+	db := openTestDB(t)
+
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.Local)
+	west := time.FixedZone("UTC-12", -12*3600)
+	east := time.FixedZone("UTC+14", 14*3600)
+	logins := []time.Time{
+		day.Add(30 * time.Minute).In(west),           // on day, written as the day before
+		day.Add(90 * time.Minute).In(west),           // on day, written as the day before
+		day.Add(23 * time.Hour).In(east),             // on day, written as the day after
+		day.Add(12 * time.Hour).UTC(),                // on day
+		day.Add(-30 * time.Minute).In(east),          // the day before, written as day
+		day.Add(24*time.Hour + time.Minute).In(west), // the day after, written as day
+	}
+	for i, at := range logins {
+		if i%2 == 0 {
+			entry := models.LoginLog{Username: fmt.Sprintf("user%d", i), IPAddress: "10.0.0.1", Success: true, LoginAt: at}
+			if err := db.Create(&entry).Error; err != nil {
+				t.Fatalf("seed: %v", err)
+			}
+			continue
+		}
+		// As written before login_at was stored in UTC
+		err := db.Exec("INSERT INTO login_logs (username, ip_address, success, login_at) VALUES (?, '10.0.0.1', 1, ?)",
+			fmt.Sprintf("user%d", i), at.Format(time.RFC3339Nano)).Error
+		if err != nil {
+			t.Fatalf("seed legacy login: %v", err)
+		}
+	}
+	db.Where("name = ?", "login_at_utc").Delete(&models.DataMigration{})
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	// Keep the SQL of the stats totals to check its plan
+	var statsSQL string
+	var statsVars []interface{}
+	err := db.Callback().Row().After("gorm:row").Register("test:stats_sql", func(tx *gorm.DB) {
+		if sql := tx.Statement.SQL.String(); strings.Contains(sql, "AS total") {
+			statsSQL, statsVars = sql, tx.Statement.Vars
+		}
+	})
+	if err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+	t.Cleanup(func() { db.Callback().Row().Remove("test:stats_sql") })
+
+	app := fiber.New()
+	handler := NewLoginLogHandler()
+	app.Get("/logs", handler.GetLoginLogs)
+	app.Get("/stats", handler.GetLoginStats)
+	get := func(path string, v interface{}) {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil), -1)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		defer resp.Body.Close()
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+
+	date := day.Format("2006-01-02")
+	var stats struct {
+		TotalLogins int64              `json:"total_logins"`
+		Series      []LoginStatsBucket `json:"series"`
+	}
+	get("/stats?bucket=day&from="+date+"&to="+date, &stats)
+	if stats.TotalLogins != 4 {
+		t.Errorf("total_logins = %d, want 4", stats.TotalLogins)
+	}
+	if len(stats.Series) != 1 || stats.Series[0].Bucket != date || stats.Series[0].Success != 4 {
+		t.Errorf("series = %+v, want one %s bucket with 4 logins", stats.Series, date)
+	}
+
+	// The list agrees with the stats, page by page in time order
+	var listed []string
+	cursor := ""
+	for {
+		var page struct {
+			Data       []LoginLogResponse `json:"data"`
+			NextCursor string             `json:"next_cursor"`
+			Total      int64              `json:"total"`
+		}
+		get("/logs?limit=1&from="+date+"&to="+date+"&cursor="+cursor, &page)
+		if page.Total != 4 {
+			t.Fatalf("list total = %d, want 4 as in the stats", page.Total)
+		}
+		for _, l := range page.Data {
+			listed = append(listed, l.Username)
+		}
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if got := strings.Join(listed, " "); got != "user2 user3 user1 user0" {
+		t.Errorf("listed %s, want user2 user3 user1 user0", got)
+	}
+
+	var plan []struct{ Detail string }
+	if err := db.Raw("EXPLAIN QUERY PLAN "+statsSQL, statsVars...).Scan(&plan).Error; err != nil {
+		t.Fatalf("explain %q: %v", statsSQL, err)
+	}
+	usesIndex := false
+	for _, step := range plan {
+		usesIndex = usesIndex || strings.Contains(step.Detail, "USING INDEX idx_login_logs_login_at (deleted_at=? AND login_at>")
+	}
+	if !usesIndex {
+		t.Errorf("stats plan = %+v, want a search on idx_login_logs_login_at", plan)
+	}
+}
+
+func TestLoginLogBrowserFilter(t *testing.T) {
+	db := openTestDB(t)
+	for i, browser := range []string{"Chrome 120", "Chrome 99", "Chromium 120", "Samsung Internet 23", "Other"} {
+		entry := models.LoginLog{Username: fmt.Sprintf("user%d", i), IPAddress: "10.0.0.1", Success: i != 1, LoginAt: time.Now(), Browser: browser}
+		if err := db.Create(&entry).Error; err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	app := fiber.New()
+	app.Get("/logs", NewLoginLogHandler().GetLoginLogs)
+
+	tests := []struct {
+		query string
+		want  int64
+	}{
+		{"browser=Chrome", 2},
+		{"browser=Chrome%20120", 1},
+		{"browser=Chrome%2012", 0},
+		{"browser=Chrom", 0},
+		{"browser=Samsung%20Internet", 1},
+		{"browser=Chrome&success=true", 1},
+		{"browser=Other", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", "/logs?"+tt.query, nil), -1)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			defer resp.Body.Close()
+			var page PageResponse
+			if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if page.Total != tt.want {
+				t.Errorf("total = %d, want %d", page.Total, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fui-backend/database"
+	"fui-backend/models"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type NotificationHandler struct{}
+
+func NewNotificationHandler() *NotificationHandler {
+	return &NotificationHandler{}
+}
+
+// GetNotifications returns the latest notifications of the current user.
+// Pass unread=true to only return unread ones.
+func (h *NotificationHandler) GetNotifications(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	db := database.GetDB()
+	notifications := []models.Notification{}
+
+	query := db.Where("user_id = ?", userID)
+	if c.QueryBool("unread") {
+		query = query.Where("read_at IS NULL")
+	}
+
+	if err := query.Order("created_at DESC").Limit(100).Find(&notifications).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch notifications",
+		})
+	}
+
+	var unread int64
+	db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unread)
+
+	return c.JSON(fiber.Map{
+		"data":   notifications,
+		"unread": unread,
+	})
+}
+
+func (h *NotificationHandler) MarkAsRead(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid notification id",
+		})
+	}
+
+	userID := c.Locals("user_id").(uint)
+
+	db := database.GetDB()
+	var notification models.Notification
+
+	if err := db.Where("user_id = ?", userID).First(&notification, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "notification not found",
+		})
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		if err := db.Save(&notification).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to update notification",
+			})
+		}
+	}
+
+	return c.JSON(notification)
+}
+
+func (h *NotificationHandler) MarkAllAsRead(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	db := database.GetDB()
+	result := db.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update notifications",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "notifications marked as read",
+		"updated": result.RowsAffected,
+	})
+}
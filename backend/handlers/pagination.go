@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+type sortKind int
+
+const (
+	sortString sortKind = iota
+	sortNumber
+	sortTime
+	sortBool
+)
+
+// sortColumn maps a sort key accepted from the query string to a column.
+// UTC marks a time column stored in UTC, which cursor values are converted
+// to so they compare with the stored text.
+type sortColumn struct {
+	Column string
+	Kind   sortKind
+	UTC    bool
+}
+
+// PageResponse is the envelope returned by paginated list endpoints.
+type PageResponse struct {
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Total      int64       `json:"total"`
+}
+
+// pageCursor identifies the last row of a page for keyset pagination: the
+// value of the sort column plus the row ID as a tie-breaker.
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+// pageRequest holds the parsed paging parameters of a list request.
+type pageRequest struct {
+	Limit   int
+	SortKey string
+	Sort    sortColumn
+	Desc    bool
+	Cursor  *pageCursor
+}
+
+// parsePageRequest reads limit, sort, order and cursor from the query string.
+func parsePageRequest(c *fiber.Ctx, columns map[string]sortColumn, defaultSort string) (*pageRequest, error) {
+	req := &pageRequest{
+		Limit:   c.QueryInt("limit", defaultPageSize),
+		SortKey: c.Query("sort", defaultSort),
+		Desc:    true,
+	}
+
+	if req.Limit <= 0 || req.Limit > maxPageSize {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+	}
+
+	col, ok := columns[req.SortKey]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort column: %s", req.SortKey)
+	}
+	req.Sort = col
+
+	switch strings.ToLower(c.Query("order", "desc")) {
+	case "asc":
+		req.Desc = false
+	case "desc":
+	default:
+		return nil, fmt.Errorf("order must be asc or desc")
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		cur, err := decodeCursor(raw)
+		if err != nil || cur.Sort != req.SortKey || cur.Desc != req.Desc {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		req.Cursor = cur
+	}
+
+	return req, nil
+}
+
+// apply adds ordering, the keyset condition and the limit to query. One extra
+// row is fetched so the caller can tell whether there is a next page.
+func (p *pageRequest) apply(query *gorm.DB, idColumn string) (*gorm.DB, error) {
+	dir := "ASC"
+	op := ">"
+	if p.Desc {
+		dir = "DESC"
+		op = "<"
+	}
+
+	if p.Cursor != nil {
+		value, err := p.Sort.parse(p.Cursor.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		query = query.Where(
+			fmt.Sprintf("(%s %s ?) OR (%s = ? AND %s %s ?)", p.Sort.Column, op, p.Sort.Column, idColumn, op),
+			value, value, p.Cursor.ID,
+		)
+	}
+
+	return query.
+		Order(fmt.Sprintf("%s %s, %s %s", p.Sort.Column, dir, idColumn, dir)).
+		Limit(p.Limit + 1), nil
+}
+
+// nextCursor returns the cursor pointing after the last row of the page, or
+// an empty string when fetched does not exceed the page size.
+func (p *pageRequest) nextCursor(fetched int, lastValue string, lastID uint) string {
+	if fetched <= p.Limit {
+		return ""
+	}
+	return encodeCursor(&pageCursor{Sort: p.SortKey, Desc: p.Desc, Value: lastValue, ID: lastID})
+}
+
+func (col sortColumn) parse(value string) (interface{}, error) {
+	switch col.Kind {
+	case sortNumber:
+		return strconv.ParseFloat(value, 64)
+	case sortTime:
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if col.UTC {
+			t = t.UTC()
+		}
+		return t, err
+	case sortBool:
+		return strconv.ParseBool(value)
+	default:
+		return value, nil
+	}
+}
+
+func encodeCursor(cur *pageCursor) string {
+	raw, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(raw string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var cur pageCursor
+	if err := json.Unmarshal(data, &cur); err != nil {
+		return nil, err
+	}
+	return &cur, nil
+}
+
+func cursorTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+// parseDateParam accepts either YYYY-MM-DD or RFC3339. A bare date used as
+// an upper bound is moved to the end of that day.
+func parseDateParam(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date: %s", value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+// escapeLike escapes LIKE wildcards so user input is matched literally.
+// Use together with ESCAPE '\'.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"errors"
+	"fui-backend/database"
+	"fui-backend/models"
+	"fui-backend/services"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PlanningCycleHandler struct {
+	auditService         *services.AuditService
+	authorizationService *services.AuthorizationService
+	planningCycleService *services.PlanningCycleService
+}
+
+func NewPlanningCycleHandler(auditService *services.AuditService, authorizationService *services.AuthorizationService, planningCycleService *services.PlanningCycleService) *PlanningCycleHandler {
+	return &PlanningCycleHandler{
+		auditService:         auditService,
+		authorizationService: authorizationService,
+		planningCycleService: planningCycleService,
+	}
+}
+
+// PlanningCycleView is a planning cycle with the state of its window.
+type PlanningCycleView struct {
+	models.PlanningCycle
+	State string `json:"state"`
+}
+
+func planningCycleView(cycle models.PlanningCycle, now time.Time) PlanningCycleView {
+	return PlanningCycleView{PlanningCycle: cycle, State: cycle.StateAt(now)}
+}
+
+// GetPlanningCycles lists planning cycles, latest window first. Filters:
+// fiscal_year, state (upcoming, open or closed).
+func (h *PlanningCycleHandler) GetPlanningCycles(c *fiber.Ctx) error {
+	query := database.GetDB().Model(&models.PlanningCycle{})
+	if year := c.QueryInt("fiscal_year"); year != 0 {
+		query = query.Where("fiscal_year = ?", year)
+	}
+
+	cycles := []models.PlanningCycle{}
+	if err := query.Order("opens_at DESC").Find(&cycles).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch planning cycles",
+		})
+	}
+
+	now := time.Now()
+	state := c.Query("state")
+	views := []PlanningCycleView{}
+	for _, cycle := range cycles {
+		view := planningCycleView(cycle, now)
+		if state == "" || view.State == state {
+			views = append(views, view)
+		}
+	}
+
+	return c.JSON(views)
+}
+
+// GetCurrentPlanningCycle returns the cycle open for submissions, or null
+// when none is.
+func (h *PlanningCycleHandler) GetCurrentPlanningCycle(c *fiber.Ctx) error {
+	now := time.Now()
+	cycle, err := h.planningCycleService.Current(database.GetDB(), now)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch planning cycle",
+		})
+	}
+	if cycle == nil {
+		return c.JSON(nil)
+	}
+
+	return c.JSON(planningCycleView(*cycle, now))
+}
+
+func (h *PlanningCycleHandler) GetPlanningCycle(c *fiber.Ctx) error {
+	cycle, ferr := loadPlanningCycle(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	return c.JSON(planningCycleView(*cycle, time.Now()))
+}
+
+// GetPlanningCycleSummary sums up the proposals of a cycle the current user
+// may see, by status, investment type and department.
+func (h *PlanningCycleHandler) GetPlanningCycleSummary(c *fiber.Ctx) error {
+	sub, err := requestSubject(c, h.authorizationService)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
+
+	cycle, ferr := loadPlanningCycle(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	query := h.authorizationService.ScopeProposals(database.GetDB().Model(&models.InvestmentProposal{}), sub)
+	summary, err := h.planningCycleService.Summary(query, cycle)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch planning cycle summary",
+		})
+	}
+
+	return c.JSON(summary)
+}
+
+func (h *PlanningCycleHandler) CreatePlanningCycle(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var req services.PlanningCycleInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	db := database.GetDB()
+	cycle := models.PlanningCycle{CreatedByID: userID}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := h.planningCycleService.Apply(tx, &cycle, req); err != nil {
+			return err
+		}
+		if err := tx.Create(&cycle).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityPlanningCycle, cycle.ID, "create", &userID, cycle)
+	})
+	if err != nil {
+		return planningCycleError(c, err, "failed to create planning cycle")
+	}
+
+	db.Preload("CreatedBy").First(&cycle, cycle.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(planningCycleView(cycle, time.Now()))
+}
+
+// UpdatePlanningCycle changes a cycle. Proposals already tagged to it stay
+// tagged, even when their investment type is no longer collected.
+func (h *PlanningCycleHandler) UpdatePlanningCycle(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	cycle, ferr := loadPlanningCycle(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	var req services.PlanningCycleInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	db := database.GetDB()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := h.planningCycleService.Apply(tx, cycle, req); err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Save(cycle).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityPlanningCycle, cycle.ID, "update", &userID, cycle)
+	})
+	if err != nil {
+		return planningCycleError(c, err, "failed to update planning cycle")
+	}
+
+	return c.JSON(planningCycleView(*cycle, time.Now()))
+}
+
+// DeletePlanningCycle removes a cycle no proposal is tagged to.
+func (h *PlanningCycleHandler) DeletePlanningCycle(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	cycle, ferr := loadPlanningCycle(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	db := database.GetDB()
+	var tagged int64
+	if err := db.Unscoped().Model(&models.InvestmentProposal{}).Where("planning_cycle_id = ?", cycle.ID).Count(&tagged).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete planning cycle",
+		})
+	}
+	if tagged > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "planning cycle has proposals",
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(cycle).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityPlanningCycle, cycle.ID, "delete", &userID, cycle)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete planning cycle",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "planning cycle deleted successfully",
+	})
+}
+
+// loadPlanningCycle loads the cycle named by the :id parameter with its
+// creator. Pass a returned error to errorResponse.
+func loadPlanningCycle(c *fiber.Ctx) (*models.PlanningCycle, *fiber.Error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid planning cycle id")
+	}
+
+	var cycle models.PlanningCycle
+	if err := database.GetDB().Preload("CreatedBy").First(&cycle, id).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "planning cycle not found")
+	}
+	return &cycle, nil
+}
+
+func planningCycleError(c *fiber.Ctx, err error, message string) error {
+	var cycleErr *services.PlanningCycleError
+	if errors.As(err, &cycleErr) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":    cycleErr.Error(),
+			"problems": cycleErr.Problems,
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": message,
+	})
+}
@@ -1,38 +1,101 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"fui-backend/database"
 	"fui-backend/models"
+	"fui-backend/services"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
-type ProposalHandler struct{}
+type ProposalHandler struct {
+	auditService          *services.AuditService
+	authorizationService  *services.AuthorizationService
+	workflowService       *services.ProposalWorkflowService
+	versionService        *services.ProposalVersionService
+	numberService         *services.ProposalNumberService
+	lineItemService       *services.ProposalLineItemService
+	financeService        *services.FinancialEvaluationService
+	exchangeRateService   *services.ExchangeRateService
+	departmentService     *services.DepartmentService
+	planningCycleService  *services.PlanningCycleService
+	investmentTypeService *services.InvestmentTypeService
+	templateService       *services.ProposalTemplateService
+}
+
+func NewProposalHandler(auditService *services.AuditService, authorizationService *services.AuthorizationService, workflowService *services.ProposalWorkflowService, versionService *services.ProposalVersionService, numberService *services.ProposalNumberService, lineItemService *services.ProposalLineItemService, financeService *services.FinancialEvaluationService, exchangeRateService *services.ExchangeRateService, departmentService *services.DepartmentService, planningCycleService *services.PlanningCycleService, investmentTypeService *services.InvestmentTypeService, templateService *services.ProposalTemplateService) *ProposalHandler {
+	return &ProposalHandler{
+		auditService:          auditService,
+		authorizationService:  authorizationService,
+		workflowService:       workflowService,
+		versionService:        versionService,
+		numberService:         numberService,
+		lineItemService:       lineItemService,
+		financeService:        financeService,
+		exchangeRateService:   exchangeRateService,
+		departmentService:     departmentService,
+		planningCycleService:  planningCycleService,
+		investmentTypeService: investmentTypeService,
+		templateService:       templateService,
+	}
+}
 
-func NewProposalHandler() *ProposalHandler {
-	return &ProposalHandler{}
+// requestSubject returns the authorization subject of the current user.
+func requestSubject(c *fiber.Ctx, authorizationService *services.AuthorizationService) (*services.Subject, error) {
+	return authorizationService.Subject(c.Locals("user_id").(uint), c.Locals("role").(models.UserRole))
 }
 
 type CreateProposalRequest struct {
-	Title               string    `json:"title"`
-	Description         string    `json:"description"`
-	InvestmentType      string    `json:"investment_type"`
-	EstimatedCost       float64   `json:"estimated_cost"`
-	Currency            string    `json:"currency"`
-	ExpectedStartDate   time.Time `json:"expected_start_date"`
-	ExpectedCompletDate time.Time `json:"expected_complete_date"`
-	Justification       string    `json:"justification"`
-	ExpectedBenefit     string    `json:"expected_benefit"`
-	RiskAnalysis        string    `json:"risk_analysis"`
-	DepartmentID        string    `json:"department_id"`
+	Title               string        `json:"title"`
+	Description         string        `json:"description"`
+	InvestmentType      string        `json:"investment_type"`
+	EstimatedCost       models.Amount `json:"estimated_cost"`
+	Currency            string        `json:"currency"`
+	ExpectedStartDate   time.Time     `json:"expected_start_date"`
+	ExpectedCompletDate time.Time     `json:"expected_complete_date"`
+	Justification       string        `json:"justification"`
+	ExpectedBenefit     string        `json:"expected_benefit"`
+	RiskAnalysis        string        `json:"risk_analysis"`
+	DepartmentID        string        `json:"department_id"`
+
+	// PlanningCycleID tags the proposal to a cycle. Leave it out to keep
+	// the current cycle, or to join the open one.
+	PlanningCycleID *uint `json:"planning_cycle_id"`
+
+	// Urgent lets the proposal be submitted outside the window of its cycle
+	// with an urgent_justification
+	Urgent              bool   `json:"urgent"`
+	UrgentJustification string `json:"urgent_justification"`
+
+	// CustomFields are checked against the schema of the investment type.
+	// Leave it out to keep the current values.
+	CustomFields map[string]interface{} `json:"custom_fields"`
+
+	// LineItems replaces the cost breakdown and sets estimated_cost. Leave
+	// it out to keep the current items; send [] to remove them.
+	LineItems []services.ProposalLineItemInput `json:"line_items"`
+
+	// CashFlows replaces the projected cash flows; same rules as LineItems
+	CashFlows []services.CashFlowInput `json:"cash_flows"`
 }
 
 func (h *ProposalHandler) CreateProposal(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uint)
-	
+	sub, err := requestSubject(c, h.authorizationService)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
+
 	var req CreateProposalRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -48,14 +111,24 @@ func (h *ProposalHandler) CreateProposal(c *fiber.Ctx) error {
 
 	db := database.GetDB()
 
-	// Generate proposal number
-	proposalNumber := fmt.Sprintf("INV-%s-%d", time.Now().Format("200601"), time.Now().Unix())
+	department, err := h.departmentService.Resolve(db, req.DepartmentID)
+	if err != nil {
+		return departmentReferenceError(c, err)
+	}
+	investmentType, err := h.investmentTypeService.Resolve(db, req.InvestmentType)
+	if err != nil {
+		return investmentTypeReferenceError(c, err)
+	}
+	customFields, err := h.investmentTypeService.CheckFields(investmentType, req.CustomFields, false)
+	if err != nil {
+		return proposalContentError(c, err)
+	}
 
 	proposal := models.InvestmentProposal{
-		ProposalNumber:      proposalNumber,
 		Title:               req.Title,
 		Description:         req.Description,
-		InvestmentType:      req.InvestmentType,
+		InvestmentType:      investmentTypeCode(investmentType),
+		CustomFields:        customFields,
 		EstimatedCost:       req.EstimatedCost,
 		Currency:            req.Currency,
 		ProposalDate:        time.Now(),
@@ -66,48 +139,530 @@ func (h *ProposalHandler) CreateProposal(c *fiber.Ctx) error {
 		RiskAnalysis:        req.RiskAnalysis,
 		Status:              models.StatusDraft,
 		SubmittedByID:       userID,
-		DepartmentID:        req.DepartmentID,
+		DepartmentID:        department,
+		Urgent:              req.Urgent,
+		UrgentJustification: req.UrgentJustification,
+	}
+
+	if err := h.planningCycleService.Assign(db, &proposal, req.PlanningCycleID); err != nil {
+		return planningCycleReferenceError(c, err)
+	}
+
+	return h.createDraft(c, &proposal, sub, req.LineItems, req.CashFlows)
+}
+
+type CloneProposalRequest struct {
+	IncludeAttachments bool `json:"include_attachments"`
+}
+
+// CloneProposal copies a proposal the current user may see into a new
+// draft of theirs, with a fresh number and without its approvals, comments
+// and history. The draft joins the open planning cycle and is quoted at
+// today's rate. Its department, investment type and custom fields are
+// checked like those of a new proposal. Attachments are copied when
+// include_attachments is set; the copies refer to the same files.
+func (h *ProposalHandler) CloneProposal(c *fiber.Ctx) error {
+	source, ferr := h.loadVisibleProposal(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	sub, err := requestSubject(c, h.authorizationService)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
+
+	var req CloneProposalRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+	}
+
+	db := database.GetDB()
+	query := db.Preload("LineItems", orderLineItems).Preload("CashFlows", orderCashFlows)
+	if req.IncludeAttachments {
+		query = query.Preload("Attachments")
+	}
+	if err := query.First(source, source.ID).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch proposal",
+		})
+	}
+
+	proposal := models.InvestmentProposal{
+		Title:               source.Title,
+		Description:         source.Description,
+		InvestmentType:      source.InvestmentType,
+		CustomFields:        source.CustomFields,
+		EstimatedCost:       source.EstimatedCost,
+		Currency:            source.Currency,
+		ProposalDate:        time.Now(),
+		ExpectedStartDate:   source.ExpectedStartDate,
+		ExpectedCompletDate: source.ExpectedCompletDate,
+		Justification:       source.Justification,
+		ExpectedBenefit:     source.ExpectedBenefit,
+		RiskAnalysis:        source.RiskAnalysis,
+		Status:              models.StatusDraft,
+		SubmittedByID:       sub.UserID,
+		DepartmentID:        source.DepartmentID,
+		ClonedFromID:        &source.ID,
+	}
+	if problems, err := h.checkCopiedDraft(db, &proposal); err != nil || len(problems) > 0 {
+		return copiedDraftError(c, problems, err)
+	}
+	for _, a := range source.Attachments {
+		proposal.Attachments = append(proposal.Attachments, models.Attachment{
+			FileName:   a.FileName,
+			FilePath:   a.FilePath,
+			FileSize:   a.FileSize,
+			FileType:   a.FileType,
+			UploadedBy: a.UploadedBy,
+		})
+	}
+
+	if err := h.planningCycleService.Assign(db, &proposal, nil); err != nil {
+		return planningCycleReferenceError(c, err)
+	}
+
+	var lineItems []services.ProposalLineItemInput
+	if len(source.LineItems) > 0 {
+		lineItems = services.LineItemInputs(source.LineItems)
+	}
+	var cashFlows []services.CashFlowInput
+	if len(source.CashFlows) > 0 {
+		cashFlows = services.CashFlowInputs(source.CashFlows)
+	}
+	return h.createDraft(c, &proposal, sub, lineItems, cashFlows)
+}
+
+// CreateFromTemplate starts a new draft from an active proposal template.
+func (h *ProposalHandler) CreateFromTemplate(c *fiber.Ctx) error {
+	sub, err := requestSubject(c, h.authorizationService)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
+
+	template, ferr := loadProposalTemplate(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+	if !template.IsActive {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "template is not active",
+		})
+	}
+
+	db := database.GetDB()
+	proposal, lineItems, cashFlows := h.templateService.Draft(template, sub.UserID)
+	if problems, err := h.checkCopiedDraft(db, proposal); err != nil || len(problems) > 0 {
+		return copiedDraftError(c, problems, err)
 	}
+	if err := h.planningCycleService.Assign(db, proposal, nil); err != nil {
+		return planningCycleReferenceError(c, err)
+	}
+
+	return h.createDraft(c, proposal, sub, lineItems, cashFlows)
+}
 
-	if err := db.Create(&proposal).Error; err != nil {
+// createDraft prices the line items and evaluates the cash flows of a new
+// proposal, when given, quotes its IDR cost and inserts it with the next
+// number.
+func (h *ProposalHandler) createDraft(c *fiber.Ctx, proposal *models.InvestmentProposal, sub *services.Subject, lineItems []services.ProposalLineItemInput, cashFlows []services.CashFlowInput) error {
+	db := database.GetDB()
+	userID := sub.UserID
+
+	if lineItems != nil {
+		if err := h.applyLineItems(proposal, lineItems); err != nil {
+			return proposalContentError(c, err)
+		}
+	}
+	if err := h.applyCashFlows(db, proposal, cashFlows); err != nil {
+		return proposalContentError(c, err)
+	}
+	if err := h.exchangeRateService.Quote(db, proposal); err != nil {
+		return proposalContentError(c, err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Assigns the next proposal number; line items, cash flows and
+		// attachments are inserted with it
+		if err := h.numberService.Create(tx, proposal); err != nil {
+			return err
+		}
+		if err := h.workflowService.RecordCreated(tx, proposal, sub); err != nil {
+			return err
+		}
+		if _, err := h.versionService.Record(tx, proposal, userID); err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityProposal, proposal.ID, "create", &userID, proposal)
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "failed to create proposal",
 		})
 	}
 
 	// Load relations
-	db.Preload("SubmittedBy").First(&proposal, proposal.ID)
+	db.Preload("SubmittedBy").Preload("LineItems", orderLineItems).Preload("CashFlows", orderCashFlows).Preload("Attachments").First(proposal, proposal.ID)
 
 	return c.Status(fiber.StatusCreated).JSON(proposal)
 }
 
+var proposalSortColumns = map[string]sortColumn{
+	"created_at":             {Column: "created_at", Kind: sortTime},
+	"updated_at":             {Column: "updated_at", Kind: sortTime},
+	"proposal_date":          {Column: "proposal_date", Kind: sortTime},
+	"expected_start_date":    {Column: "expected_start_date", Kind: sortTime},
+	"expected_complete_date": {Column: "expected_complet_date", Kind: sortTime},
+	"proposal_number":        {Column: "proposal_number", Kind: sortString},
+	"title":                  {Column: "title", Kind: sortString},
+	"investment_type":        {Column: "investment_type", Kind: sortString},
+	"department_id":          {Column: "department_id", Kind: sortString},
+	"status":                 {Column: "status", Kind: sortString},
+	"currency":               {Column: "currency", Kind: sortString},
+	"estimated_cost":         {Column: "estimated_cost", Kind: sortNumber},
+	"estimated_cost_idr":     {Column: "COALESCE(estimated_cost_idr, -1)", Kind: sortNumber},
+	"submitted_by_id":        {Column: "submitted_by_id", Kind: sortNumber},
+	"id":                     {Column: "id", Kind: sortNumber},
+}
+
+func proposalSortValue(p *models.InvestmentProposal, sortKey string) string {
+	switch sortKey {
+	case "updated_at":
+		return cursorTime(p.UpdatedAt)
+	case "proposal_date":
+		return cursorTime(p.ProposalDate)
+	case "expected_start_date":
+		return cursorTime(p.ExpectedStartDate)
+	case "expected_complete_date":
+		return cursorTime(p.ExpectedCompletDate)
+	case "proposal_number":
+		return p.ProposalNumber
+	case "title":
+		return p.Title
+	case "investment_type":
+		return p.InvestmentType
+	case "department_id":
+		return p.DepartmentID
+	case "status":
+		return string(p.Status)
+	case "currency":
+		return p.Currency
+	case "estimated_cost":
+		return strconv.FormatInt(p.EstimatedCost.Minor(), 10)
+	case "estimated_cost_idr":
+		if p.EstimatedCostIDR == nil {
+			return "-1"
+		}
+		return strconv.FormatInt(p.EstimatedCostIDR.Minor(), 10)
+	case "submitted_by_id":
+		return strconv.FormatUint(uint64(p.SubmittedByID), 10)
+	case "id":
+		return strconv.FormatUint(uint64(p.ID), 10)
+	default:
+		return cursorTime(p.CreatedAt)
+	}
+}
+
+// applyProposalFilters adds the list filters: q (title, description or
+// proposal number), status, investment_type, department_id,
+// planning_cycle_id, urgent, submitted_by_id, min_cost/max_cost,
+// min_cost_idr/max_cost_idr and from/to on the proposal date.
+func applyProposalFilters(query *gorm.DB, c *fiber.Ctx) (*gorm.DB, error) {
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + escapeLike(q) + "%"
+		query = query.Where(
+			"title LIKE ? ESCAPE '\\' OR description LIKE ? ESCAPE '\\' OR proposal_number LIKE ? ESCAPE '\\'",
+			like, like, like,
+		)
+	}
+
+	for param, column := range map[string]string{
+		"status":          "status",
+		"investment_type": "investment_type",
+		"department_id":   "department_id",
+		"currency":        "currency",
+	} {
+		if value := c.Query(param); value != "" {
+			query = query.Where(column+" IN ?", strings.Split(value, ","))
+		}
+	}
+
+	if submitter := c.Query("submitted_by_id"); submitter != "" {
+		id, err := strconv.Atoi(submitter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid submitted_by_id")
+		}
+		query = query.Where("submitted_by_id = ?", id)
+	}
+
+	if cycle := c.Query("planning_cycle_id"); cycle != "" {
+		id, err := strconv.Atoi(cycle)
+		if err != nil {
+			return nil, fmt.Errorf("invalid planning_cycle_id")
+		}
+		query = query.Where("planning_cycle_id = ?", id)
+	}
+	if v := c.Query("urgent"); v != "" {
+		urgent, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid urgent")
+		}
+		query = query.Where("urgent = ?", urgent)
+	}
+
+	if v := c.Query("min_cost"); v != "" {
+		cost, err := models.ParseAmount(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min_cost")
+		}
+		query = query.Where("estimated_cost >= ?", cost)
+	}
+	if v := c.Query("max_cost"); v != "" {
+		cost, err := models.ParseAmount(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_cost")
+		}
+		query = query.Where("estimated_cost <= ?", cost)
+	}
+	if v := c.Query("min_cost_idr"); v != "" {
+		cost, err := models.ParseAmount(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min_cost_idr")
+		}
+		query = query.Where("estimated_cost_idr >= ?", cost)
+	}
+	if v := c.Query("max_cost_idr"); v != "" {
+		cost, err := models.ParseAmount(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_cost_idr")
+		}
+		query = query.Where("estimated_cost_idr <= ?", cost)
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, err := parseDateParam(from, false)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("proposal_date >= ?", t)
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseDateParam(to, true)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("proposal_date <= ?", t)
+	}
+
+	return query, nil
+}
+
+type ProposalPageResponse struct {
+	PageResponse
+	StatusCounts map[models.ProposalStatus]int64 `json:"status_counts"`
+	TotalCostIDR models.Amount                   `json:"total_cost_idr"`
+}
+
+// GetProposals returns a keyset-paginated page of the proposals visible to
+// the current user. See applyProposalFilters for filters; sort accepts any
+// key of proposalSortColumns. total, status_counts and total_cost_idr cover
+// every matching row, not just the page; total_cost_idr leaves out
+// proposals in a currency without an exchange rate.
 func (h *ProposalHandler) GetProposals(c *fiber.Ctx) error {
-	userID := c.Locals("user_id").(uint)
-	role := c.Locals("role").(models.UserRole)
+	sub, err := requestSubject(c, h.authorizationService)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
+
+	page, err := parsePageRequest(c, proposalSortColumns, "created_at")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
 	db := database.GetDB()
-	var proposals []models.InvestmentProposal
+	query := db.Model(&models.InvestmentProposal{})
 
-	query := db.Preload("SubmittedBy").Preload("Approvals.Approver")
+	query = h.authorizationService.ScopeProposals(query, sub)
 
-	// Filter based on role
-	if role != models.RoleAdmin && role != models.RoleCEO && role != models.RoleCFO {
-		// Regular users only see their own proposals
-		query = query.Where("submitted_by_id = ?", userID)
+	query, err = applyProposalFilters(query, c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
 
-	// Add filters from query params
-	if status := c.Query("status"); status != "" {
-		query = query.Where("status = ?", status)
+	resp := ProposalPageResponse{StatusCounts: map[models.ProposalStatus]int64{}}
+
+	var counts []struct {
+		Status  models.ProposalStatus
+		Count   int64
+		CostIDR models.Amount `gorm:"column:cost_idr"`
+	}
+	err = query.Session(&gorm.Session{}).
+		Select("status, COUNT(*) AS count, SUM(estimated_cost_idr) AS cost_idr").
+		Group("status").
+		Scan(&counts).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch proposals",
+		})
+	}
+	for _, row := range counts {
+		resp.StatusCounts[row.Status] = row.Count
+		resp.Total += row.Count
+		resp.TotalCostIDR += row.CostIDR
 	}
 
-	if err := query.Order("created_at DESC").Find(&proposals).Error; err != nil {
+	pageQuery, err := page.apply(query.Session(&gorm.Session{}), "id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	proposals := []models.InvestmentProposal{}
+	err = pageQuery.
+		Preload("SubmittedBy").
+		Preload("Approvals.Approver").
+		Find(&proposals).Error
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "failed to fetch proposals",
 		})
 	}
 
-	return c.JSON(proposals)
+	if len(proposals) > page.Limit {
+		last := &proposals[page.Limit-1]
+		resp.NextCursor = page.nextCursor(len(proposals), proposalSortValue(last, page.SortKey), last.ID)
+		proposals = proposals[:page.Limit]
+	}
+	resp.Data = proposals
+
+	return c.JSON(resp)
+}
+
+var proposalExportHeader = []string{
+	"proposal_number", "title", "status", "department_id", "investment_type",
+	"proposal_date", "submitted_by", "currency", "estimated_cost",
+	"exchange_rate", "estimated_cost_idr", "rate_locked_at",
+	"line_no", "description", "category", "quantity", "unit_price",
+	"line_currency", "tax_rate", "subtotal", "tax_amount", "line_total",
+}
+
+// ExportProposals returns the proposals visible to the current user as CSV
+// with one row per line item; a proposal without items gets one row with
+// empty item columns. It takes the filters of GetProposals.
+func (h *ProposalHandler) ExportProposals(c *fiber.Ctx) error {
+	sub, err := requestSubject(c, h.authorizationService)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
+
+	query := h.authorizationService.ScopeProposals(database.GetDB().Model(&models.InvestmentProposal{}), sub)
+	query, err = applyProposalFilters(query, c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(proposalExportHeader)
+
+	var batch []models.InvestmentProposal
+	err = query.
+		Preload("SubmittedBy").
+		Preload("LineItems", orderLineItems).
+		FindInBatches(&batch, 200, func(tx *gorm.DB, n int) error {
+			for _, p := range batch {
+				writeProposalRows(w, &p)
+			}
+			return nil
+		}).Error
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to export proposals",
+		})
+	}
+
+	filename := fmt.Sprintf("proposals-%s.csv", time.Now().Format("20060102-150405"))
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+
+	return c.Send(buf.Bytes())
+}
+
+func writeProposalRows(w *csv.Writer, p *models.InvestmentProposal) {
+	// Empty until the currency has an exchange rate
+	var rate, costIDR, lockedAt string
+	if p.ExchangeRate != nil && p.EstimatedCostIDR != nil {
+		rate, costIDR = p.ExchangeRate.String(), p.EstimatedCostIDR.String()
+	}
+	if p.RateLockedAt != nil {
+		lockedAt = p.RateLockedAt.Format("2006-01-02")
+	}
+
+	proposal := []string{
+		csvText(p.ProposalNumber),
+		csvText(p.Title),
+		string(p.Status),
+		csvText(p.DepartmentID),
+		csvText(p.InvestmentType),
+		p.ProposalDate.Format("2006-01-02"),
+		csvText(p.SubmittedBy.FullName),
+		p.Currency,
+		p.EstimatedCost.String(),
+		rate,
+		costIDR,
+		lockedAt,
+	}
+
+	if len(p.LineItems) == 0 {
+		w.Write(append(proposal, make([]string, len(proposalExportHeader)-len(proposal))...))
+		return
+	}
+	for _, item := range p.LineItems {
+		w.Write(append(proposal[:len(proposal):len(proposal)],
+			strconv.Itoa(item.Position),
+			csvText(item.Description),
+			item.Category,
+			strconv.FormatFloat(item.Quantity, 'f', -1, 64),
+			item.UnitPrice.String(),
+			item.Currency,
+			strconv.FormatFloat(item.TaxRate, 'f', -1, 64),
+			item.Subtotal.String(),
+			item.TaxAmount.String(),
+			item.Total.String(),
+		))
+	}
+}
+
+// csvText keeps spreadsheets from evaluating user text as a formula.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
 }
 
 func (h *ProposalHandler) GetProposal(c *fiber.Ctx) error {
@@ -118,13 +673,19 @@ func (h *ProposalHandler) GetProposal(c *fiber.Ctx) error {
 		})
 	}
 
-	userID := c.Locals("user_id").(uint)
-	role := c.Locals("role").(models.UserRole)
+	sub, err := requestSubject(c, h.authorizationService)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
 
 	db := database.GetDB()
 	var proposal models.InvestmentProposal
 
 	query := db.Preload("SubmittedBy").
+		Preload("LineItems", orderLineItems).
+		Preload("CashFlows", orderCashFlows).
 		Preload("Attachments").
 		Preload("Approvals.Approver").
 		Preload("Comments.User")
@@ -136,12 +697,16 @@ func (h *ProposalHandler) GetProposal(c *fiber.Ctx) error {
 	}
 
 	// Check access permissions
-	if role != models.RoleAdmin && role != models.RoleCEO && role != models.RoleCFO {
-		if proposal.SubmittedByID != userID {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"error": "access denied",
-			})
-		}
+	allowed, err := h.authorizationService.CanViewProposal(sub, &proposal)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch proposal",
+		})
+	}
+	if !allowed {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "access denied",
+		})
 	}
 
 	return c.JSON(proposal)
@@ -156,6 +721,12 @@ func (h *ProposalHandler) UpdateProposal(c *fiber.Ctx) error {
 	}
 
 	userID := c.Locals("user_id").(uint)
+	sub, err := requestSubject(c, h.authorizationService)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
 
 	var req CreateProposalRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -167,30 +738,49 @@ func (h *ProposalHandler) UpdateProposal(c *fiber.Ctx) error {
 	db := database.GetDB()
 	var proposal models.InvestmentProposal
 
-	if err := db.First(&proposal, id).Error; err != nil {
+	if err := db.Preload("LineItems", orderLineItems).Preload("CashFlows", orderCashFlows).First(&proposal, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "proposal not found",
 		})
 	}
 
 	// Check ownership
-	if proposal.SubmittedByID != userID {
+	if !h.authorizationService.CanEditProposal(sub, &proposal) {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"error": "access denied",
 		})
 	}
 
 	// Can only edit draft or revision status
-	if proposal.Status != models.StatusDraft && proposal.Status != models.StatusRevision {
+	if !h.workflowService.CanEdit(&proposal) {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "cannot edit proposal in current status",
 		})
 	}
 
+	department, err := h.departmentService.Resolve(db, req.DepartmentID)
+	if err != nil {
+		return departmentReferenceError(c, err)
+	}
+	investmentType, err := h.investmentTypeService.Resolve(db, req.InvestmentType)
+	if err != nil {
+		return investmentTypeReferenceError(c, err)
+	}
+	customFields := req.CustomFields
+	if customFields == nil {
+		customFields = proposal.CustomFields
+	}
+	customFields, err = h.investmentTypeService.CheckFields(investmentType, customFields, false)
+	if err != nil {
+		return proposalContentError(c, err)
+	}
+
 	// Update fields
+	original := proposal
 	proposal.Title = req.Title
 	proposal.Description = req.Description
-	proposal.InvestmentType = req.InvestmentType
+	proposal.InvestmentType = investmentTypeCode(investmentType)
+	proposal.CustomFields = customFields
 	proposal.EstimatedCost = req.EstimatedCost
 	proposal.Currency = req.Currency
 	proposal.ExpectedStartDate = req.ExpectedStartDate
@@ -198,15 +788,62 @@ func (h *ProposalHandler) UpdateProposal(c *fiber.Ctx) error {
 	proposal.Justification = req.Justification
 	proposal.ExpectedBenefit = req.ExpectedBenefit
 	proposal.RiskAnalysis = req.RiskAnalysis
-	proposal.DepartmentID = req.DepartmentID
+	proposal.DepartmentID = department
+	proposal.Urgent = req.Urgent
+	proposal.UrgentJustification = req.UrgentJustification
+
+	if err := h.planningCycleService.Assign(db, &proposal, req.PlanningCycleID); err != nil {
+		return planningCycleReferenceError(c, err)
+	}
 
-	if err := db.Save(&proposal).Error; err != nil {
+	// Existing items are priced again, as the currency may have changed
+	lineItems := req.LineItems
+	if lineItems == nil && len(proposal.LineItems) > 0 {
+		lineItems = services.LineItemInputs(proposal.LineItems)
+	}
+	if lineItems != nil {
+		if err := h.applyLineItems(&proposal, lineItems); err != nil {
+			return proposalContentError(c, err)
+		}
+	}
+
+	// The evaluation is redone, as the investment type sets the WACC
+	if err := h.applyCashFlows(db, &proposal, req.CashFlows); err != nil {
+		return proposalContentError(c, err)
+	}
+	if err := h.exchangeRateService.Quote(db, &proposal); err != nil {
+		return proposalContentError(c, err)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := h.versionService.EnsureBaseline(tx, &original); err != nil {
+			return err
+		}
+		if err := tx.Omit("LineItems", "CashFlows").Save(&proposal).Error; err != nil {
+			return err
+		}
+		if lineItems != nil {
+			if err := h.lineItemService.Replace(tx, &proposal); err != nil {
+				return err
+			}
+		}
+		if req.CashFlows != nil {
+			if err := h.financeService.ReplaceCashFlows(tx, &proposal); err != nil {
+				return err
+			}
+		}
+		if _, err := h.versionService.Record(tx, &proposal, userID); err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityProposal, proposal.ID, "update", &userID, proposal)
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "failed to update proposal",
 		})
 	}
 
-	db.Preload("SubmittedBy").First(&proposal, proposal.ID)
+	db.Preload("SubmittedBy").Preload("LineItems", orderLineItems).Preload("CashFlows", orderCashFlows).First(&proposal, proposal.ID)
 
 	return c.JSON(proposal)
 }
@@ -220,7 +857,12 @@ func (h *ProposalHandler) DeleteProposal(c *fiber.Ctx) error {
 	}
 
 	userID := c.Locals("user_id").(uint)
-	role := c.Locals("role").(models.UserRole)
+	sub, err := requestSubject(c, h.authorizationService)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
 
 	db := database.GetDB()
 	var proposal models.InvestmentProposal
@@ -232,20 +874,26 @@ func (h *ProposalHandler) DeleteProposal(c *fiber.Ctx) error {
 	}
 
 	// Check permissions
-	if role != models.RoleAdmin && proposal.SubmittedByID != userID {
+	if !h.authorizationService.CanDeleteProposal(sub, &proposal) {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"error": "access denied",
 		})
 	}
 
 	// Can only delete draft proposals
-	if proposal.Status != models.StatusDraft {
+	if !h.workflowService.CanDelete(&proposal) {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "can only delete draft proposals",
 		})
 	}
 
-	if err := db.Delete(&proposal).Error; err != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&proposal).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityProposal, proposal.ID, "delete", &userID, proposal)
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "failed to delete proposal",
 		})
@@ -257,6 +905,79 @@ func (h *ProposalHandler) DeleteProposal(c *fiber.Ctx) error {
 }
 
 func (h *ProposalHandler) SubmitProposal(c *fiber.Ctx) error {
+	return h.transition(c, services.ActionSubmit)
+}
+
+func (h *ProposalHandler) ApproveProposal(c *fiber.Ctx) error {
+	return h.transition(c, services.ActionApprove)
+}
+
+func (h *ProposalHandler) RejectProposal(c *fiber.Ctx) error {
+	return h.transition(c, services.ActionReject)
+}
+
+func (h *ProposalHandler) RequestRevision(c *fiber.Ctx) error {
+	return h.transition(c, services.ActionRequestRevision)
+}
+
+func (h *ProposalHandler) WithdrawProposal(c *fiber.Ctx) error {
+	return h.transition(c, services.ActionWithdraw)
+}
+
+func (h *ProposalHandler) CancelProposal(c *fiber.Ctx) error {
+	return h.transition(c, services.ActionCancel)
+}
+
+func (h *ProposalHandler) StartProposal(c *fiber.Ctx) error {
+	return h.transition(c, services.ActionStart)
+}
+
+func (h *ProposalHandler) CompleteProposal(c *fiber.Ctx) error {
+	return h.transition(c, services.ActionComplete)
+}
+
+func (h *ProposalHandler) CloseProposal(c *fiber.Ctx) error {
+	return h.transition(c, services.ActionClose)
+}
+
+// ApproveOverrun re-approves a proposal whose actual spend went over its
+// approved cost by more than the tolerance. The body may carry a reason.
+func (h *ProposalHandler) ApproveOverrun(c *fiber.Ctx) error {
+	sub, err := requestSubject(c, h.authorizationService)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+	}
+
+	proposal, ferr := h.loadVisibleProposal(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	if _, err := h.workflowService.ApproveOverrun(sub, proposal, req.Reason); err != nil {
+		return transitionError(c, err)
+	}
+
+	database.GetDB().Preload("SubmittedBy").Preload("Approvals.Approver").First(proposal, proposal.ID)
+
+	return c.JSON(proposal)
+}
+
+// transition runs a state machine action on the proposal. The body may carry
+// a reason, which reject, request_revision, withdraw and cancel require.
+func (h *ProposalHandler) transition(c *fiber.Ctx, action services.ProposalAction) error {
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -264,7 +985,23 @@ func (h *ProposalHandler) SubmitProposal(c *fiber.Ctx) error {
 		})
 	}
 
-	userID := c.Locals("user_id").(uint)
+	sub, err := requestSubject(c, h.authorizationService)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+	}
 
 	db := database.GetDB()
 	var proposal models.InvestmentProposal
@@ -275,26 +1012,302 @@ func (h *ProposalHandler) SubmitProposal(c *fiber.Ctx) error {
 		})
 	}
 
-	if proposal.SubmittedByID != userID {
+	if _, err := h.workflowService.Transition(sub, &proposal, action, req.Reason); err != nil {
+		return transitionError(c, err)
+	}
+
+	db.Preload("SubmittedBy").Preload("Approvals.Approver").First(&proposal, proposal.ID)
+
+	return c.JSON(proposal)
+}
+
+// applyLineItems prices the line items and makes their total the estimated
+// cost of the proposal.
+func (h *ProposalHandler) applyLineItems(proposal *models.InvestmentProposal, inputs []services.ProposalLineItemInput) error {
+	items, err := h.lineItemService.Build(proposal, inputs)
+	if err != nil {
+		return err
+	}
+	proposal.LineItems = items
+	proposal.EstimatedCost = services.LineItemsTotal(items)
+	return nil
+}
+
+// applyCashFlows replaces the cash flows of the proposal when inputs is
+// not nil and evaluates them.
+func (h *ProposalHandler) applyCashFlows(tx *gorm.DB, proposal *models.InvestmentProposal, inputs []services.CashFlowInput) error {
+	if inputs != nil {
+		flows, err := h.financeService.BuildCashFlows(proposal, inputs)
+		if err != nil {
+			return err
+		}
+		proposal.CashFlows = flows
+	}
+	return h.financeService.Evaluate(tx, proposal)
+}
+
+func proposalContentError(c *fiber.Ctx, err error) error {
+	var itemErr *services.LineItemError
+	var flowErr *services.CashFlowError
+	var fieldErr *services.CustomFieldError
+	switch {
+	case errors.As(err, &itemErr):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":    itemErr.Error(),
+			"problems": itemErr.Problems,
+		})
+	case errors.As(err, &flowErr):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":    flowErr.Error(),
+			"problems": flowErr.Problems,
+		})
+	case errors.As(err, &fieldErr):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":    fieldErr.Error(),
+			"problems": fieldErr.Problems,
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to save proposal",
+		})
+	}
+}
+
+// departmentReferenceError reports a department that is not in the
+// department master data.
+func departmentReferenceError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrUnknownDepartment) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "failed to check department",
+	})
+}
+
+// investmentTypeReferenceError reports an investment type that is not in
+// the catalog.
+func investmentTypeReferenceError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrUnknownInvestmentType) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "failed to check investment type",
+	})
+}
+
+// checkCopiedDraft resolves the department and investment type of a draft
+// copied from a proposal or template, and checks its custom fields against
+// the current schema, as CreateProposal does for a new draft. What was
+// valid in the source may not be any more, e.g. a deactivated department.
+func (h *ProposalHandler) checkCopiedDraft(db *gorm.DB, proposal *models.InvestmentProposal) ([]string, error) {
+	var problems []string
+
+	department, err := h.departmentService.Resolve(db, proposal.DepartmentID)
+	switch {
+	case errors.Is(err, services.ErrUnknownDepartment):
+		problems = append(problems, fmt.Sprintf("department %s is not an active department", proposal.DepartmentID))
+	case err != nil:
+		return nil, err
+	default:
+		proposal.DepartmentID = department
+	}
+
+	investmentType, err := h.investmentTypeService.Resolve(db, proposal.InvestmentType)
+	switch {
+	case errors.Is(err, services.ErrUnknownInvestmentType):
+		problems = append(problems, fmt.Sprintf("investment type %s is not an active investment type", proposal.InvestmentType))
+		return problems, nil
+	case err != nil:
+		return nil, err
+	}
+	proposal.InvestmentType = investmentTypeCode(investmentType)
+
+	fields, err := h.investmentTypeService.CheckFields(investmentType, proposal.CustomFields, false)
+	var fieldErr *services.CustomFieldError
+	switch {
+	case errors.As(err, &fieldErr):
+		problems = append(problems, fieldErr.Problems...)
+	case err != nil:
+		return nil, err
+	default:
+		proposal.CustomFields = fields
+	}
+	return problems, nil
+}
+
+// copiedDraftError reports the problems checkCopiedDraft found, or its
+// error.
+func copiedDraftError(c *fiber.Ctx, problems []string, err error) error {
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to check proposal",
+		})
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":    "the copy is no longer valid: " + strings.Join(problems, ", "),
+		"problems": problems,
+	})
+}
+
+// investmentTypeCode returns the code proposals store for t, "" for none.
+func investmentTypeCode(t *models.InvestmentType) string {
+	if t == nil {
+		return ""
+	}
+	return t.Code
+}
+
+// planningCycleReferenceError reports a planning cycle that does not exist.
+func planningCycleReferenceError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrUnknownPlanningCycle) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "failed to check planning cycle",
+	})
+}
+
+func orderLineItems(db *gorm.DB) *gorm.DB {
+	return db.Order("position ASC")
+}
+
+func orderCashFlows(db *gorm.DB) *gorm.DB {
+	return db.Order("year ASC")
+}
+
+func transitionError(c *fiber.Ctx, err error) error {
+	var guardErr *services.TransitionGuardError
+	switch {
+	case errors.As(err, &guardErr):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":    guardErr.Error(),
+			"problems": guardErr.Problems,
+		})
+	case errors.Is(err, services.ErrTransitionForbidden):
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"error": "access denied",
 		})
+	case errors.Is(err, services.ErrInvalidTransition), errors.Is(err, services.ErrUnknownAction):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update proposal status",
+		})
+	}
+}
+
+// loadVisibleProposal loads the proposal named by the :id parameter and
+// checks that the current user may see it. Pass a returned error to
+// errorResponse.
+func (h *ProposalHandler) loadVisibleProposal(c *fiber.Ctx) (*models.InvestmentProposal, *fiber.Error) {
+	return visibleProposal(c, h.authorizationService)
+}
+
+func visibleProposal(c *fiber.Ctx, authorizationService *services.AuthorizationService) (*models.InvestmentProposal, *fiber.Error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid proposal id")
+	}
+
+	sub, err := requestSubject(c, authorizationService)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "user not found")
+	}
+
+	var proposal models.InvestmentProposal
+	if err := database.GetDB().First(&proposal, id).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "proposal not found")
+	}
+
+	allowed, err := authorizationService.CanViewProposal(sub, &proposal)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to fetch proposal")
+	}
+	if !allowed {
+		return nil, fiber.NewError(fiber.StatusForbidden, "access denied")
+	}
+
+	return &proposal, nil
+}
+
+func errorResponse(c *fiber.Ctx, err *fiber.Error) error {
+	return c.Status(err.Code).JSON(fiber.Map{
+		"error": err.Message,
+	})
+}
+
+// GetProposalHistory returns the status timeline of a proposal.
+func (h *ProposalHandler) GetProposalHistory(c *fiber.Ctx) error {
+	proposal, ferr := h.loadVisibleProposal(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	history, err := h.workflowService.History(proposal.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch history",
+		})
+	}
+
+	return c.JSON(history)
+}
+
+// GetProposalVersions returns every saved version of a proposal, oldest
+// first.
+func (h *ProposalHandler) GetProposalVersions(c *fiber.Ctx) error {
+	proposal, ferr := h.loadVisibleProposal(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	versions, err := h.versionService.List(proposal.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch versions",
+		})
 	}
 
-	if proposal.Status != models.StatusDraft && proposal.Status != models.StatusRevision {
+	return c.JSON(versions)
+}
+
+// GetProposalVersionDiff returns the field-level changes from version :a to
+// version :b.
+func (h *ProposalHandler) GetProposalVersionDiff(c *fiber.Ctx) error {
+	proposal, ferr := h.loadVisibleProposal(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	a, errA := strconv.Atoi(c.Params("a"))
+	b, errB := strconv.Atoi(c.Params("b"))
+	if errA != nil || errB != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "proposal already submitted",
+			"error": "invalid version number",
 		})
 	}
 
-	proposal.Status = models.StatusSubmitted
-	if err := db.Save(&proposal).Error; err != nil {
+	diff, err := h.versionService.Diff(proposal.ID, a, b)
+	if errors.Is(err, services.ErrVersionNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to submit proposal",
+			"error": "failed to compare versions",
 		})
 	}
 
-	return c.JSON(proposal)
+	return c.JSON(diff)
 }
 
 func (h *ProposalHandler) AddComment(c *fiber.Ctx) error {
@@ -306,6 +1319,12 @@ func (h *ProposalHandler) AddComment(c *fiber.Ctx) error {
 	}
 
 	userID := c.Locals("user_id").(uint)
+	sub, err := requestSubject(c, h.authorizationService)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
 
 	var req struct {
 		Content string `json:"content"`
@@ -332,13 +1351,31 @@ func (h *ProposalHandler) AddComment(c *fiber.Ctx) error {
 		})
 	}
 
+	allowed, err := h.authorizationService.CanViewProposal(sub, &proposal)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to add comment",
+		})
+	}
+	if !allowed {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "access denied",
+		})
+	}
+
 	comment := models.Comment{
 		ProposalID: uint(id),
 		UserID:     userID,
 		Content:    req.Content,
 	}
 
-	if err := db.Create(&comment).Error; err != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityComment, comment.ID, "create", &userID, comment)
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "failed to add comment",
 		})
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"fui-backend/models"
+)
+
+// seedCatalog adds the IT and FIN departments and the IT investment type,
+// whose proposals may carry a license_count.
+func seedCatalog(t *testing.T, s *testServer) {
+	t.Helper()
+
+	s.mustCreate(t, &models.Department{Code: "IT", Name: "Teknologi Informasi", IsActive: true})
+	s.mustCreate(t, &models.Department{Code: "FIN", Name: "Finance", IsActive: true})
+	s.mustCreate(t, &models.InvestmentType{
+		Code: "IT",
+		Name: "Teknologi Informasi",
+		FieldSchema: models.FieldSchema{Type: "object", Properties: map[string]models.FieldDefinition{
+			"license_count": {Type: models.FieldInteger},
+		}},
+		IsActive: true,
+	})
+}
+
+type problemResponse struct {
+	Error    string   `json:"error"`
+	Problems []string `json:"problems"`
+}
+
+func TestCloneProposalChecksCopiedReferences(t *testing.T) {
+	s := newTestServer(t)
+	seedCatalog(t, s)
+	admin := s.createUser(t, "admin", models.RoleAdmin, "")
+
+	var source models.InvestmentProposal
+	status, body := s.do(t, admin, "POST", "/proposals", map[string]interface{}{
+		"title":           "Laptops",
+		"department_id":   "IT",
+		"investment_type": "IT",
+		"custom_fields":   map[string]interface{}{"license_count": 5},
+	}, &source)
+	if status != 201 {
+		t.Fatalf("create: %d %s", status, body)
+	}
+
+	tests := []struct {
+		name     string
+		setup    string // SQL run before cloning, undone after
+		undo     string
+		problems []string // empty when the clone succeeds
+	}{
+		{name: "valid"},
+		{
+			name:     "department deactivated",
+			setup:    "UPDATE departments SET is_active = false WHERE code = 'IT'",
+			undo:     "UPDATE departments SET is_active = true WHERE code = 'IT'",
+			problems: []string{"department IT is not an active department"},
+		},
+		{
+			name:     "investment type deactivated",
+			setup:    "UPDATE investment_types SET is_active = false WHERE code = 'IT'",
+			undo:     "UPDATE investment_types SET is_active = true WHERE code = 'IT'",
+			problems: []string{"investment type IT is not an active investment type"},
+		},
+		{
+			name:     "custom field dropped from the schema",
+			setup:    `UPDATE investment_types SET field_schema = '{"type":"object","properties":{}}' WHERE code = 'IT'`,
+			undo:     `UPDATE investment_types SET field_schema = '{"type":"object","properties":{"license_count":{"type":"integer"}}}' WHERE code = 'IT'`,
+			problems: []string{"custom field license_count is not defined for Teknologi Informasi"},
+		},
+		{
+			name:  "legacy department name",
+			setup: fmt.Sprintf("UPDATE investment_proposals SET department_id = 'finance' WHERE id = %d", source.ID),
+			undo:  fmt.Sprintf("UPDATE investment_proposals SET department_id = 'IT' WHERE id = %d", source.ID),
+		},
+		{
+			name:     "all problems at once",
+			setup:    "UPDATE departments SET is_active = false WHERE code = 'IT'; UPDATE investment_types SET is_active = false WHERE code = 'IT'",
+			undo:     "UPDATE departments SET is_active = true WHERE code = 'IT'; UPDATE investment_types SET is_active = true WHERE code = 'IT'",
+			problems: []string{"department IT is not an active department", "investment type IT is not an active investment type"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, stmt := range strings.Split(tt.setup, "; ") {
+				if stmt != "" {
+					s.db.Exec(stmt)
+				}
+			}
+			defer func() {
+				for _, stmt := range strings.Split(tt.undo, "; ") {
+					if stmt != "" {
+						s.db.Exec(stmt)
+					}
+				}
+			}()
+
+			var before int64
+			s.db.Model(&models.InvestmentProposal{}).Count(&before)
+
+			var clone models.InvestmentProposal
+			var problems problemResponse
+			path := fmt.Sprintf("/proposals/%d/clone", source.ID)
+			if len(tt.problems) == 0 {
+				status, body := s.do(t, admin, "POST", path, nil, &clone)
+				if status != 201 {
+					t.Fatalf("clone: %d %s", status, body)
+				}
+				if clone.DepartmentID != "IT" && clone.DepartmentID != "FIN" || clone.InvestmentType != "IT" || clone.ClonedFromID == nil {
+					t.Errorf("clone = department %q, type %q, cloned from %v", clone.DepartmentID, clone.InvestmentType, clone.ClonedFromID)
+				}
+				if tt.name == "legacy department name" && clone.DepartmentID != "FIN" {
+					t.Errorf("department = %q, want the name resolved to FIN", clone.DepartmentID)
+				}
+				return
+			}
+
+			status, body := s.do(t, admin, "POST", path, nil, &problems)
+			if status != 400 {
+				t.Fatalf("clone: %d %s, want 400", status, body)
+			}
+			if strings.Join(problems.Problems, "|") != strings.Join(tt.problems, "|") {
+				t.Errorf("problems = %q, want %q", problems.Problems, tt.problems)
+			}
+			var after int64
+			s.db.Model(&models.InvestmentProposal{}).Count(&after)
+			if after != before {
+				t.Errorf("a draft was created despite the problems")
+			}
+		})
+	}
+}
+
+func TestCreateFromTemplateChecksReferences(t *testing.T) {
+	s := newTestServer(t)
+	seedCatalog(t, s)
+	admin := s.createUser(t, "admin", models.RoleAdmin, "")
+
+	template := models.ProposalTemplate{Name: "Laptops", InvestmentType: "IT", DepartmentID: "IT", IsActive: true, CreatedByID: admin.ID}
+	s.mustCreate(t, &template)
+	path := fmt.Sprintf("/proposals/from-template/%d", template.ID)
+
+	if status, body := s.do(t, admin, "POST", path, nil, nil); status != 201 {
+		t.Fatalf("from template: %d %s", status, body)
+	}
+
+	s.db.Model(&models.Department{}).Where("code = ?", "IT").Update("is_active", false)
+	var problems problemResponse
+	status, body := s.do(t, admin, "POST", path, nil, &problems)
+	if status != 400 || len(problems.Problems) != 1 || problems.Problems[0] != "department IT is not an active department" {
+		t.Errorf("from template with an inactive department: %d %s", status, body)
+	}
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"errors"
+	"fui-backend/database"
+	"fui-backend/models"
+	"fui-backend/services"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type ProposalTemplateHandler struct {
+	auditService    *services.AuditService
+	templateService *services.ProposalTemplateService
+}
+
+func NewProposalTemplateHandler(auditService *services.AuditService, templateService *services.ProposalTemplateService) *ProposalTemplateHandler {
+	return &ProposalTemplateHandler{
+		auditService:    auditService,
+		templateService: templateService,
+	}
+}
+
+// GetProposalTemplates lists all templates ordered by name. Filters:
+// investment_type, active, q (name or title).
+func (h *ProposalTemplateHandler) GetProposalTemplates(c *fiber.Ctx) error {
+	query := database.GetDB().Model(&models.ProposalTemplate{})
+
+	if t := c.Query("investment_type"); t != "" {
+		query = query.Where("investment_type = ?", t)
+	}
+	if active := c.Query("active"); active != "" {
+		query = query.Where("is_active = ?", active == "true")
+	}
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + escapeLike(q) + "%"
+		query = query.Where("name LIKE ? ESCAPE '\\' OR title LIKE ? ESCAPE '\\'", like, like)
+	}
+
+	templates := []models.ProposalTemplate{}
+	if err := query.Preload("CreatedBy").Order("name ASC").Find(&templates).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch proposal templates",
+		})
+	}
+
+	return c.JSON(templates)
+}
+
+// GetActiveProposalTemplates lists the templates new proposals may start
+// from, for any signed-in user. Filter: investment_type.
+func (h *ProposalTemplateHandler) GetActiveProposalTemplates(c *fiber.Ctx) error {
+	query := database.GetDB().Where("is_active = ?", true)
+	if t := c.Query("investment_type"); t != "" {
+		query = query.Where("investment_type = ?", t)
+	}
+
+	templates := []models.ProposalTemplate{}
+	if err := query.Order("name ASC").Find(&templates).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch proposal templates",
+		})
+	}
+
+	return c.JSON(templates)
+}
+
+func (h *ProposalTemplateHandler) GetProposalTemplate(c *fiber.Ctx) error {
+	template, ferr := loadProposalTemplate(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	database.GetDB().Preload("CreatedBy").First(template, template.ID)
+
+	return c.JSON(template)
+}
+
+func (h *ProposalTemplateHandler) CreateProposalTemplate(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var req services.ProposalTemplateInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	db := database.GetDB()
+	template := models.ProposalTemplate{CreatedByID: userID}
+	if err := h.templateService.Apply(db, &template, req); err != nil {
+		return proposalTemplateError(c, err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&template).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityProposalTemplate, template.ID, "create", &userID, template)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create proposal template",
+		})
+	}
+
+	db.Preload("CreatedBy").First(&template, template.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(template)
+}
+
+// UpdateProposalTemplate replaces the content of a template. Proposals
+// already created from it keep theirs.
+func (h *ProposalTemplateHandler) UpdateProposalTemplate(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	template, ferr := loadProposalTemplate(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	var req services.ProposalTemplateInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	db := database.GetDB()
+	if err := h.templateService.Apply(db, template, req); err != nil {
+		return proposalTemplateError(c, err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("CreatedBy").Save(template).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityProposalTemplate, template.ID, "update", &userID, template)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update proposal template",
+		})
+	}
+
+	db.Preload("CreatedBy").First(template, template.ID)
+
+	return c.JSON(template)
+}
+
+// DeleteProposalTemplate removes a template. Proposals created from it
+// keep their content and lose the reference.
+func (h *ProposalTemplateHandler) DeleteProposalTemplate(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	template, ferr := loadProposalTemplate(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.InvestmentProposal{}).
+			Unscoped().
+			Where("template_id = ?", template.ID).
+			UpdateColumn("template_id", nil).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(template).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityProposalTemplate, template.ID, "delete", &userID, template)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete proposal template",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "proposal template deleted successfully",
+	})
+}
+
+// loadProposalTemplate loads the template named by the :id parameter. Pass
+// a returned error to errorResponse.
+func loadProposalTemplate(c *fiber.Ctx) (*models.ProposalTemplate, *fiber.Error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid proposal template id")
+	}
+
+	var template models.ProposalTemplate
+	if err := database.GetDB().First(&template, id).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "proposal template not found")
+	}
+	return &template, nil
+}
+
+func proposalTemplateError(c *fiber.Ctx, err error) error {
+	var templateErr *services.ProposalTemplateError
+	if errors.As(err, &templateErr) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":    templateErr.Error(),
+			"problems": templateErr.Problems,
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "failed to save proposal template",
+	})
+}
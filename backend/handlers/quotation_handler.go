@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"errors"
+	"fui-backend/database"
+	"fui-backend/models"
+	"fui-backend/services"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type QuotationHandler struct {
+	auditService         *services.AuditService
+	authorizationService *services.AuthorizationService
+	quotationService     *services.QuotationService
+}
+
+func NewQuotationHandler(auditService *services.AuditService, authorizationService *services.AuthorizationService, quotationService *services.QuotationService) *QuotationHandler {
+	return &QuotationHandler{
+		auditService:         auditService,
+		authorizationService: authorizationService,
+		quotationService:     quotationService,
+	}
+}
+
+// GetQuotations lists the vendor quotations of a proposal in the order
+// they were added.
+func (h *QuotationHandler) GetQuotations(c *fiber.Ctx) error {
+	proposal, ferr := visibleProposal(c, h.authorizationService)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	quotations := []models.Quotation{}
+	err := database.GetDB().
+		Preload("Vendor").Preload("Attachment").Preload("CreatedBy").
+		Where("proposal_id = ?", proposal.ID).
+		Order("id ASC").
+		Find(&quotations).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch quotations",
+		})
+	}
+
+	return c.JSON(quotations)
+}
+
+// CreateQuotation adds a vendor quotation to a proposal. Like any change to
+// the quotations, it clears the recommendation of the proposal.
+func (h *QuotationHandler) CreateQuotation(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	proposal, ferr := h.loadChangeableProposal(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	var req services.QuotationInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	db := database.GetDB()
+	quotation := models.Quotation{CreatedByID: userID}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := h.quotationService.CheckChangeable(tx, proposal); err != nil {
+			return err
+		}
+		if err := h.quotationService.Apply(tx, proposal, &quotation, req); err != nil {
+			return err
+		}
+		if err := tx.Omit("Vendor", "Attachment", "CreatedBy").Create(&quotation).Error; err != nil {
+			return err
+		}
+		if err := h.quotationService.Reset(tx, proposal); err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityQuotation, quotation.ID, "create", &userID, quotation)
+	})
+	if err != nil {
+		return quotationError(c, err, "failed to create quotation")
+	}
+
+	db.Preload("Vendor").Preload("Attachment").Preload("CreatedBy").First(&quotation, quotation.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(quotation)
+}
+
+func (h *QuotationHandler) UpdateQuotation(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	proposal, ferr := h.loadChangeableProposal(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+	quotation, ferr := loadQuotation(c, proposal)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	var req services.QuotationInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	db := database.GetDB()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := h.quotationService.CheckChangeable(tx, proposal); err != nil {
+			return err
+		}
+		if err := tx.Where("proposal_id = ?", proposal.ID).First(quotation, quotation.ID).Error; err != nil {
+			return err
+		}
+		if err := h.quotationService.Apply(tx, proposal, quotation, req); err != nil {
+			return err
+		}
+		if err := tx.Omit("Vendor", "Attachment", "CreatedBy").Save(quotation).Error; err != nil {
+			return err
+		}
+		if err := h.quotationService.Reset(tx, proposal); err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityQuotation, quotation.ID, "update", &userID, quotation)
+	})
+	if err != nil {
+		return quotationError(c, err, "failed to update quotation")
+	}
+
+	db.Preload("Vendor").Preload("Attachment").Preload("CreatedBy").First(quotation, quotation.ID)
+
+	return c.JSON(quotation)
+}
+
+func (h *QuotationHandler) DeleteQuotation(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	proposal, ferr := h.loadChangeableProposal(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+	quotation, ferr := loadQuotation(c, proposal)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := h.quotationService.CheckChangeable(tx, proposal); err != nil {
+			return err
+		}
+		if err := tx.Where("proposal_id = ?", proposal.ID).First(quotation, quotation.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(quotation).Error; err != nil {
+			return err
+		}
+		if err := h.quotationService.Reset(tx, proposal); err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityQuotation, quotation.ID, "delete", &userID, quotation)
+	})
+	if err != nil {
+		return quotationError(c, err, "failed to delete quotation")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "quotation deleted successfully",
+	})
+}
+
+// CompareQuotations ranks the quotations of a proposal by weighted
+// criteria, records the comparison and makes its recommended vendor the
+// proposal's. Procurement cannot approve the proposal before it has one.
+func (h *QuotationHandler) CompareQuotations(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	proposal, ferr := h.loadChangeableProposal(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	var req services.ComparisonInput
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+	}
+
+	db := database.GetDB()
+	var comparison *models.QuotationComparison
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := h.quotationService.CheckChangeable(tx, proposal); err != nil {
+			return err
+		}
+		var err error
+		comparison, err = h.quotationService.Compare(tx, proposal, req)
+		if err != nil {
+			return err
+		}
+		comparison.ComparedByID = userID
+		if err := h.quotationService.Recommend(tx, proposal, comparison); err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityComparison, comparison.ID, "create", &userID, comparison)
+	})
+	if err != nil {
+		return quotationError(c, err, "failed to compare quotations")
+	}
+
+	db.Preload("RecommendedVendor").Preload("ComparedBy").First(comparison, comparison.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(comparison)
+}
+
+// GetComparisons lists the quotation comparisons of a proposal, newest
+// first. The first one holds the current recommendation, unless the
+// quotations changed since.
+func (h *QuotationHandler) GetComparisons(c *fiber.Ctx) error {
+	proposal, ferr := visibleProposal(c, h.authorizationService)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	comparisons := []models.QuotationComparison{}
+	err := database.GetDB().
+		Preload("RecommendedVendor").Preload("ComparedBy").
+		Where("proposal_id = ?", proposal.ID).
+		Order("created_at DESC, id DESC").
+		Find(&comparisons).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch quotation comparisons",
+		})
+	}
+
+	return c.JSON(comparisons)
+}
+
+// loadChangeableProposal loads a proposal the current user may see and
+// whose quotations may still change. Changes check again with
+// CheckChangeable inside their transaction. Pass a returned error to
+// errorResponse.
+func (h *QuotationHandler) loadChangeableProposal(c *fiber.Ctx) (*models.InvestmentProposal, *fiber.Error) {
+	proposal, ferr := visibleProposal(c, h.authorizationService)
+	if ferr != nil {
+		return nil, ferr
+	}
+	if !h.quotationService.CanChange(proposal) {
+		return nil, fiber.NewError(fiber.StatusConflict, services.ErrQuotationsLocked.Error())
+	}
+	return proposal, nil
+}
+
+// loadQuotation loads the quotation of proposal named by the :quotationId
+// parameter. Pass a returned error to errorResponse.
+func loadQuotation(c *fiber.Ctx, proposal *models.InvestmentProposal) (*models.Quotation, *fiber.Error) {
+	id, err := strconv.Atoi(c.Params("quotationId"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid quotation id")
+	}
+
+	var quotation models.Quotation
+	if err := database.GetDB().Where("proposal_id = ?", proposal.ID).First(&quotation, id).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "quotation not found")
+	}
+	return &quotation, nil
+}
+
+// quotationError responds to an error of a quotation transaction, with
+// message when it failed unexpectedly.
+func quotationError(c *fiber.Ctx, err error, message string) error {
+	var quotationErr *services.QuotationError
+	switch {
+	case errors.As(err, &quotationErr):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":    quotationErr.Error(),
+			"problems": quotationErr.Problems,
+		})
+	case errors.Is(err, services.ErrQuotationsLocked):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "quotation not found",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": message,
+	})
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+
+	"fui-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// advanceAfterLoad makes procurement approve the proposal right after the
+// handler first loads it, as a concurrent request would, once.
+func advanceAfterLoad(t *testing.T, s *testServer, proposalID uint) {
+	t.Helper()
+
+	armed := true
+	name := fmt.Sprintf("test:advance_%d", proposalID)
+	err := s.db.Callback().Query().After("gorm:query").Register(name, func(db *gorm.DB) {
+		if !armed || db.Statement.Table != "investment_proposals" {
+			return
+		}
+		armed = false
+		err := s.db.Session(&gorm.Session{NewDB: true}).Model(&models.InvestmentProposal{}).
+			Where("id = ?", proposalID).
+			UpdateColumn("approval_step", models.WorkflowStep(models.RoleSourcingAndProcurement)+1).Error
+		if err != nil {
+			t.Errorf("advance proposal: %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+	t.Cleanup(func() { s.db.Callback().Query().Remove(name) })
+}
+
+func TestQuotationChangesRecheckProposalInTransaction(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.createUser(t, "admin", models.RoleAdmin, "")
+	first := models.Vendor{Code: "V1", Name: "Vendor Satu", IsActive: true}
+	second := models.Vendor{Code: "V2", Name: "Vendor Dua", IsActive: true}
+	s.mustCreate(t, &first)
+	s.mustCreate(t, &second)
+
+	tests := []struct {
+		name   string
+		method string
+		path   func(proposal, quotation uint) string
+		body   map[string]interface{}
+	}{
+		{"create", "POST", func(p, _ uint) string { return fmt.Sprintf("/proposals/%d/quotations", p) }, map[string]interface{}{"vendor_id": second.ID, "price": 900}},
+		{"update", "PUT", func(p, q uint) string { return fmt.Sprintf("/proposals/%d/quotations/%d", p, q) }, map[string]interface{}{"vendor_id": first.ID, "price": 800}},
+		{"delete", "DELETE", func(p, q uint) string { return fmt.Sprintf("/proposals/%d/quotations/%d", p, q) }, nil},
+		{"compare", "POST", func(p, _ uint) string { return fmt.Sprintf("/proposals/%d/quotations/compare", p) }, nil},
+	}
+	for i, tt := range tests {
+		for _, race := range []bool{false, true} {
+			name := tt.name
+			if race {
+				name += " after procurement approved"
+			}
+			t.Run(name, func(t *testing.T) {
+				proposal := models.InvestmentProposal{
+					ProposalNumber: fmt.Sprintf("INV-%d-%v", i, race),
+					Title:          "Laptops",
+					Status:         models.StatusReviewing,
+					ApprovalStep:   models.WorkflowStep(models.RoleSourcingAndProcurement),
+					SubmittedByID:  admin.ID,
+				}
+				s.mustCreate(t, &proposal)
+				quotation := models.Quotation{ProposalID: proposal.ID, VendorID: first.ID, Price: 100000, Currency: "IDR", CreatedByID: admin.ID}
+				s.mustCreate(t, &quotation)
+
+				var before int64
+				s.db.Model(&models.AuditLog{}).Count(&before)
+				if race {
+					advanceAfterLoad(t, s, proposal.ID)
+				}
+
+				status, body := s.do(t, admin, tt.method, tt.path(proposal.ID, quotation.ID), tt.body, nil)
+				if !race {
+					if status != 200 && status != 201 {
+						t.Fatalf("%s: %d %s", tt.name, status, body)
+					}
+					return
+				}
+
+				if status != 409 {
+					t.Fatalf("%s: %d %s, want 409", tt.name, status, body)
+				}
+				var quotations []models.Quotation
+				s.db.Where("proposal_id = ?", proposal.ID).Find(&quotations)
+				if len(quotations) != 1 || quotations[0].VendorID != first.ID || quotations[0].Price != 100000 {
+					t.Errorf("quotations = %+v, want the first one unchanged", quotations)
+				}
+				var comparisons, after int64
+				s.db.Model(&models.QuotationComparison{}).Where("proposal_id = ?", proposal.ID).Count(&comparisons)
+				s.db.Model(&models.AuditLog{}).Count(&after)
+				if comparisons != 0 || after != before {
+					t.Errorf("%d comparisons and %d audit entries recorded, want none", comparisons, after-before)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"fui-backend/database"
+	"fui-backend/models"
+	"fui-backend/services"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const maxSearchLimit = 100
+
+// Markers passed to highlight() and snippet(). They cannot occur in indexed
+// text, so the text can be HTML-escaped before they become <mark> tags.
+const (
+	searchMarkStart = "\x02"
+	searchMarkEnd   = "\x03"
+)
+
+type SearchHandler struct {
+	authorizationService *services.AuthorizationService
+}
+
+func NewSearchHandler(authorizationService *services.AuthorizationService) *SearchHandler {
+	return &SearchHandler{authorizationService: authorizationService}
+}
+
+type SearchResult struct {
+	Kind           string                `json:"kind"` // proposal, comment or attachment
+	SourceID       uint                  `json:"source_id"`
+	ProposalID     uint                  `json:"proposal_id"`
+	ProposalNumber string                `json:"proposal_number"`
+	Title          string                `json:"title"`
+	Status         models.ProposalStatus `json:"status"`
+	Snippet        string                `json:"snippet"`
+	Rank           float64               `json:"rank"`
+}
+
+type SearchResponse struct {
+	Data  []SearchResult `json:"data"`
+	Total int64          `json:"total"`
+}
+
+// Search runs a full-text query over proposals, comments and attachment
+// names. Results are ordered by bm25 rank (title matches weigh most) and
+// carry HTML snippets with matches wrapped in <mark>; everything else in the
+// snippet is escaped. Params: q, limit (max 100), offset, kind.
+func (h *SearchHandler) Search(c *fiber.Ctx) error {
+	sub, err := requestSubject(c, h.authorizationService)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
+
+	match := buildMatchQuery(c.Query("q"))
+	if match == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "q is required",
+		})
+	}
+
+	limit := c.QueryInt("limit", 20)
+	offset := c.QueryInt("offset", 0)
+	if limit <= 0 || limit > maxSearchLimit || offset < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid limit or offset",
+		})
+	}
+
+	db := database.GetDB()
+	query := db.Table(database.SearchIndexTable).
+		Joins("JOIN investment_proposals ON investment_proposals.id = search_index.proposal_id AND investment_proposals.deleted_at IS NULL").
+		Where("search_index MATCH ?", match)
+	query = h.authorizationService.ScopeProposals(query, sub)
+
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("search_index.kind IN ?", strings.Split(kind, ","))
+	}
+
+	resp := SearchResponse{Data: []SearchResult{}}
+	if err := query.Count(&resp.Total).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid search query",
+		})
+	}
+
+	var rows []struct {
+		SearchResult
+		TitleHighlight string
+	}
+	err = query.
+		Select(`search_index.kind AS kind,
+			search_index.source_id AS source_id,
+			search_index.proposal_id AS proposal_id,
+			investment_proposals.proposal_number AS proposal_number,
+			investment_proposals.title AS title,
+			investment_proposals.status AS status,
+			highlight(search_index, 0, ?, ?) AS title_highlight,
+			snippet(search_index, -1, ?, ?, '…', 16) AS snippet,
+			bm25(search_index, 10.0, 3.0, 2.0, 2.0, 2.0, 1.0) AS rank`,
+			searchMarkStart, searchMarkEnd, searchMarkStart, searchMarkEnd).
+		Order("rank").
+		Limit(limit).Offset(offset).
+		Scan(&rows).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to search",
+		})
+	}
+
+	for _, row := range rows {
+		result := row.SearchResult
+		if row.Kind == database.SearchKindProposal {
+			result.Title = markSearchMatches(row.TitleHighlight)
+		} else {
+			result.Title = html.EscapeString(result.Title)
+		}
+		result.Snippet = markSearchMatches(result.Snippet)
+		resp.Data = append(resp.Data, result)
+	}
+
+	return c.JSON(resp)
+}
+
+// RebuildIndex repopulates the search index from the source tables.
+func (h *SearchHandler) RebuildIndex(c *fiber.Ctx) error {
+	if err := database.RebuildSearchIndex(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to rebuild search index",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "search index rebuilt",
+	})
+}
+
+// buildMatchQuery turns free text into an FTS5 query: every word must match,
+// the last one as a prefix so partial input still finds results. FTS5
+// operators in the input are treated as plain text.
+func buildMatchQuery(input string) string {
+	words := strings.FieldsFunc(input, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		return ""
+	}
+
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = `"` + word + `"`
+	}
+	terms[len(terms)-1] += "*"
+
+	return strings.Join(terms, " ")
+}
+
+func markSearchMatches(text string) string {
+	text = html.EscapeString(text)
+	text = strings.ReplaceAll(text, searchMarkStart, "<mark>")
+	return strings.ReplaceAll(text, searchMarkEnd, "</mark>")
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"fui-backend/database"
+	"fui-backend/models"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type SecurityAlertHandler struct{}
+
+func NewSecurityAlertHandler() *SecurityAlertHandler {
+	return &SecurityAlertHandler{}
+}
+
+var securityAlertSortColumns = map[string]sortColumn{
+	"created_at": {Column: "created_at", Kind: sortTime},
+	"rule":       {Column: "rule", Kind: sortString},
+	"severity":   {Column: "severity", Kind: sortString},
+	"username":   {Column: "username", Kind: sortString},
+}
+
+func securityAlertSortValue(alert *models.SecurityAlert, sortKey string) string {
+	switch sortKey {
+	case "rule":
+		return string(alert.Rule)
+	case "severity":
+		return alert.Severity
+	case "username":
+		return alert.Username
+	default:
+		return cursorTime(alert.CreatedAt)
+	}
+}
+
+// GetAlerts lists security alerts, newest first. Filters: status, rule,
+// severity, username, ip, from, to.
+func (h *SecurityAlertHandler) GetAlerts(c *fiber.Ctx) error {
+	page, err := parsePageRequest(c, securityAlertSortColumns, "created_at")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	db := database.GetDB()
+	query := db.Model(&models.SecurityAlert{})
+
+	for param, column := range map[string]string{
+		"status":   "status",
+		"rule":     "rule",
+		"severity": "severity",
+		"username": "username",
+		"ip":       "ip_address",
+	} {
+		if value := c.Query(param); value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, err := parseDateParam(from, false)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		query = query.Where("created_at >= ?", t)
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseDateParam(to, true)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		query = query.Where("created_at <= ?", t)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch security alerts",
+		})
+	}
+
+	pageQuery, err := page.apply(query.Session(&gorm.Session{}), "id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	alerts := []models.SecurityAlert{}
+	if err := pageQuery.Find(&alerts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch security alerts",
+		})
+	}
+
+	resp := PageResponse{Total: total}
+	if len(alerts) > page.Limit {
+		last := &alerts[page.Limit-1]
+		resp.NextCursor = page.nextCursor(len(alerts), securityAlertSortValue(last, page.SortKey), last.ID)
+		alerts = alerts[:page.Limit]
+	}
+	resp.Data = alerts
+
+	return c.JSON(resp)
+}
+
+func (h *SecurityAlertHandler) AcknowledgeAlert(c *fiber.Ctx) error {
+	return h.setStatus(c, models.AlertStatusAcknowledged)
+}
+
+func (h *SecurityAlertHandler) ResolveAlert(c *fiber.Ctx) error {
+	return h.setStatus(c, models.AlertStatusResolved)
+}
+
+func (h *SecurityAlertHandler) setStatus(c *fiber.Ctx, status models.AlertStatus) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid alert id",
+		})
+	}
+
+	userID := c.Locals("user_id").(uint)
+
+	db := database.GetDB()
+	var alert models.SecurityAlert
+
+	if err := db.First(&alert, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "alert not found",
+		})
+	}
+
+	alert.Status = status
+	if alert.AcknowledgedByID == nil {
+		now := time.Now()
+		alert.AcknowledgedByID = &userID
+		alert.AcknowledgedAt = &now
+	}
+
+	if err := db.Save(&alert).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update alert",
+		})
+	}
+
+	return c.JSON(alert)
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"errors"
+	"fui-backend/database"
+	"fui-backend/models"
+	"fui-backend/services"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type SpendHandler struct {
+	auditService         *services.AuditService
+	authorizationService *services.AuthorizationService
+	workflowService      *services.ProposalWorkflowService
+	spendService         *services.SpendService
+}
+
+func NewSpendHandler(auditService *services.AuditService, authorizationService *services.AuthorizationService, workflowService *services.ProposalWorkflowService, spendService *services.SpendService) *SpendHandler {
+	return &SpendHandler{
+		auditService:         auditService,
+		authorizationService: authorizationService,
+		workflowService:      workflowService,
+		spendService:         spendService,
+	}
+}
+
+// GetSpendRecords lists the actual spend of a proposal by invoice date.
+func (h *SpendHandler) GetSpendRecords(c *fiber.Ctx) error {
+	proposal, ferr := visibleProposal(c, h.authorizationService)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	records := []models.SpendRecord{}
+	err := database.GetDB().
+		Preload("Vendor").Preload("CreatedBy").
+		Where("proposal_id = ?", proposal.ID).
+		Order("invoice_date ASC, id ASC").
+		Find(&records).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch spend records",
+		})
+	}
+
+	return c.JSON(records)
+}
+
+// CreateSpendRecord records an invoice paid for a proposal being executed.
+// Like any change to the spend, it updates the actual cost of the proposal
+// and asks for re-approval when it overruns.
+func (h *SpendHandler) CreateSpendRecord(c *fiber.Ctx) error {
+	sub, proposal, ferr := h.loadSpendingProposal(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	var req services.SpendRecordInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	db := database.GetDB()
+	record := models.SpendRecord{CreatedByID: sub.UserID}
+	if err := h.spendService.Apply(db, proposal, &record, req); err != nil {
+		return spendError(c, err, "failed to create spend record")
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Vendor", "CreatedBy").Create(&record).Error; err != nil {
+			return err
+		}
+		if err := h.workflowService.ReconcileSpend(tx, proposal, sub); err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntitySpendRecord, record.ID, "create", &sub.UserID, record)
+	})
+	if err != nil {
+		return spendError(c, err, "failed to create spend record")
+	}
+
+	db.Preload("Vendor").Preload("CreatedBy").First(&record, record.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(record)
+}
+
+func (h *SpendHandler) UpdateSpendRecord(c *fiber.Ctx) error {
+	sub, proposal, ferr := h.loadSpendingProposal(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+	record, ferr := loadSpendRecord(c, proposal)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	var req services.SpendRecordInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	db := database.GetDB()
+	if err := h.spendService.Apply(db, proposal, record, req); err != nil {
+		return spendError(c, err, "failed to update spend record")
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Vendor", "CreatedBy").Save(record).Error; err != nil {
+			return err
+		}
+		if err := h.workflowService.ReconcileSpend(tx, proposal, sub); err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntitySpendRecord, record.ID, "update", &sub.UserID, record)
+	})
+	if err != nil {
+		return spendError(c, err, "failed to update spend record")
+	}
+
+	db.Preload("Vendor").Preload("CreatedBy").First(record, record.ID)
+
+	return c.JSON(record)
+}
+
+func (h *SpendHandler) DeleteSpendRecord(c *fiber.Ctx) error {
+	sub, proposal, ferr := h.loadSpendingProposal(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+	record, ferr := loadSpendRecord(c, proposal)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(record).Error; err != nil {
+			return err
+		}
+		if err := h.workflowService.ReconcileSpend(tx, proposal, sub); err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntitySpendRecord, record.ID, "delete", &sub.UserID, record)
+	})
+	if err != nil {
+		return spendError(c, err, "failed to delete spend record")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "spend record deleted successfully",
+	})
+}
+
+// GetVariance compares the actual spend of a proposal with its estimated
+// cost.
+func (h *SpendHandler) GetVariance(c *fiber.Ctx) error {
+	proposal, ferr := visibleProposal(c, h.authorizationService)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	variance, err := h.spendService.Variance(database.GetDB(), proposal)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to compute spend variance",
+		})
+	}
+
+	return c.JSON(variance)
+}
+
+// GetVarianceReport compares actual spend with estimated cost for every
+// approved or executed proposal the user may see. Filters: status,
+// department_id, overrun (true for proposals that spent more than
+// estimated).
+func (h *SpendHandler) GetVarianceReport(c *fiber.Ctx) error {
+	sub, err := requestSubject(c, h.authorizationService)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
+
+	query := h.authorizationService.ScopeProposals(database.GetDB().Model(&models.InvestmentProposal{}), sub)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("investment_proposals.status = ?", status)
+	}
+	if department := c.Query("department_id"); department != "" {
+		query = query.Where("investment_proposals.department_id = ?", department)
+	}
+	if c.Query("overrun") == "true" {
+		query = query.Where("investment_proposals.actual_cost_idr > investment_proposals.estimated_cost_idr")
+	}
+
+	report, err := h.spendService.Report(query)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to compute spend variance",
+		})
+	}
+
+	return c.JSON(report)
+}
+
+// loadSpendingProposal loads a proposal the current user may see and
+// record spend against, with the user. Pass a returned error to
+// errorResponse.
+func (h *SpendHandler) loadSpendingProposal(c *fiber.Ctx) (*services.Subject, *models.InvestmentProposal, *fiber.Error) {
+	sub, err := requestSubject(c, h.authorizationService)
+	if err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusUnauthorized, "user not found")
+	}
+	proposal, ferr := visibleProposal(c, h.authorizationService)
+	if ferr != nil {
+		return nil, nil, ferr
+	}
+	if !h.authorizationService.CanRecordSpend(sub, proposal) {
+		return nil, nil, fiber.NewError(fiber.StatusForbidden, "not allowed to record spend for this proposal")
+	}
+	if !h.workflowService.CanSpend(proposal) {
+		return nil, nil, fiber.NewError(fiber.StatusConflict, "spend can only be recorded while the proposal is in progress or completed")
+	}
+	return sub, proposal, nil
+}
+
+// loadSpendRecord loads the spend record of proposal named by the :spendId
+// parameter. Pass a returned error to errorResponse.
+func loadSpendRecord(c *fiber.Ctx, proposal *models.InvestmentProposal) (*models.SpendRecord, *fiber.Error) {
+	id, err := strconv.Atoi(c.Params("spendId"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid spend record id")
+	}
+
+	var record models.SpendRecord
+	if err := database.GetDB().Where("proposal_id = ?", proposal.ID).First(&record, id).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "spend record not found")
+	}
+	return &record, nil
+}
+
+// spendError responds to an error of a spend change, with message when it
+// failed unexpectedly.
+func spendError(c *fiber.Ctx, err error, message string) error {
+	var spendErr *services.SpendError
+	if errors.As(err, &spendErr) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":    spendErr.Error(),
+			"problems": spendErr.Problems,
+		})
+	}
+	if errors.Is(err, services.ErrInvalidTransition) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": message,
+	})
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"fui-backend/models"
+)
+
+func TestSpendOnUnconvertedProposal(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.createUser(t, "admin", models.RoleAdmin, "")
+
+	// Approved before costs were converted: USD 1.000 without an IDR cost
+	submitted := time.Date(2025, 3, 1, 0, 0, 0, 0, time.Local)
+	proposal := models.InvestmentProposal{
+		ProposalNumber: "INV-2025-00001",
+		Title:          "Server",
+		Status:         models.StatusInProgress,
+		ApprovalStep:   len(models.ApprovalWorkflow),
+		EstimatedCost:  1000_00,
+		Currency:       "USD",
+		ProposalDate:   submitted,
+		SubmittedByID:  admin.ID,
+	}
+	s.mustCreate(t, &proposal)
+	path := fmt.Sprintf("/proposals/%d/spend", proposal.ID)
+	spend := func(amount int) map[string]interface{} {
+		return map[string]interface{}{"po_number": "PO-1", "invoice_amount": amount, "currency": "IDR", "invoice_date": "2026-01-15"}
+	}
+
+	var problems problemResponse
+	status, body := s.do(t, admin, "POST", path, spend(1000000), &problems)
+	if status != 400 || len(problems.Problems) != 1 {
+		t.Fatalf("spend without a USD rate: %d %s, want 400 with a problem", status, body)
+	}
+	var records int64
+	s.db.Model(&models.SpendRecord{}).Where("proposal_id = ?", proposal.ID).Count(&records)
+	if records != 0 {
+		t.Errorf("%d spend records saved, want none", records)
+	}
+
+	// Converted at the rate on the submit date, not today's
+	s.mustCreate(t, &models.ExchangeRate{Currency: "USD", EffectiveDate: submitted.AddDate(0, -2, 0), Rate: 15000 * models.RateOne, Source: models.ExchangeRateManual})
+	s.mustCreate(t, &models.ExchangeRate{Currency: "USD", EffectiveDate: submitted.AddDate(0, 6, 0), Rate: 17000 * models.RateOne, Source: models.ExchangeRateManual})
+
+	if status, body := s.do(t, admin, "POST", path, spend(16000000), nil); status != 201 {
+		t.Fatalf("spend: %d %s", status, body)
+	}
+	s.db.First(&proposal, proposal.ID)
+	if proposal.EstimatedCostIDR == nil || *proposal.EstimatedCostIDR != 15000000_00 {
+		t.Errorf("estimated_cost_idr = %v, want 15000000 at the submit-date rate", proposal.EstimatedCostIDR)
+	}
+	if proposal.RateLockedAt == nil || !proposal.RateLockedAt.Equal(submitted) {
+		t.Errorf("rate_locked_at = %v, want %v", proposal.RateLockedAt, submitted)
+	}
+	if proposal.OverrunPending {
+		t.Errorf("IDR 16.000.000 is within 10%% of 15.000.000, want no overrun")
+	}
+
+	if status, body := s.do(t, admin, "POST", path, map[string]interface{}{"po_number": "PO-2", "invoice_amount": 1000000, "currency": "IDR", "invoice_date": "2026-01-16"}, nil); status != 201 {
+		t.Fatalf("second spend: %d %s", status, body)
+	}
+	s.db.First(&proposal, proposal.ID)
+	if !proposal.OverrunPending {
+		t.Errorf("IDR 17.000.000 exceeds 15.000.000 by more than 10%%, want an overrun")
+	}
+}
+
+func TestSpendOverrunReapproval(t *testing.T) {
+	s := newTestServer(t)
+	owner := s.createUser(t, "owner", models.RoleCorpFA, "")
+	cfo := s.createUser(t, "cfo", models.RoleCFO, "")
+
+	limit := models.Amount(1000000_00)
+	proposal := models.InvestmentProposal{
+		ProposalNumber:   "INV-2026-00001",
+		Title:            "Server",
+		Status:           models.StatusInProgress,
+		ApprovalStep:     len(models.ApprovalWorkflow),
+		EstimatedCost:    limit,
+		EstimatedCostIDR: &limit,
+		Currency:         "IDR",
+		ProposalDate:     time.Now(),
+		SubmittedByID:    owner.ID,
+	}
+	s.mustCreate(t, &proposal)
+
+	body := map[string]interface{}{"po_number": "PO-1", "invoice_amount": 1200000, "invoice_date": time.Now().Format("2006-01-02")}
+	if status, body := s.do(t, owner, "POST", fmt.Sprintf("/proposals/%d/spend", proposal.ID), body, nil); status != 201 {
+		t.Fatalf("spend: %d %s", status, body)
+	}
+
+	var pending models.Approval
+	s.db.Where("proposal_id = ? AND status = ?", proposal.ID, models.ApprovalPending).First(&pending)
+	if pending.Step != models.OverrunStep || pending.ApproverRole != models.OverrunApprover {
+		t.Fatalf("pending approval = step %d for %s, want step %d for %s", pending.Step, pending.ApproverRole, models.OverrunStep, models.OverrunApprover)
+	}
+
+	var approved models.InvestmentProposal
+	if status, body := s.do(t, cfo, "POST", fmt.Sprintf("/proposals/%d/approve-overrun", proposal.ID), map[string]string{"reason": "harga naik"}, &approved); status != 200 {
+		t.Fatalf("approve overrun: %d %s", status, body)
+	}
+	if approved.OverrunPending || approved.ApprovedCostIDR == nil || *approved.ApprovedCostIDR != 1200000_00 {
+		t.Errorf("after re-approval: overrun_pending %v, approved_cost_idr %v, want false and 1200000", approved.OverrunPending, approved.ApprovedCostIDR)
+	}
+	s.db.First(&pending, pending.ID)
+	if pending.Status != models.ApprovalApproved || pending.ApproverID != cfo.ID {
+		t.Errorf("overrun approval = %s by %d, want approved by the CFO", pending.Status, pending.ApproverID)
+	}
+}
@@ -3,16 +3,19 @@ package handlers
 import (
 	"fui-backend/database"
 	"fui-backend/models"
+	"fui-backend/services"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-type UserHandler struct{}
+type UserHandler struct {
+	departmentService *services.DepartmentService
+}
 
-func NewUserHandler() *UserHandler {
-	return &UserHandler{}
+func NewUserHandler(departmentService *services.DepartmentService) *UserHandler {
+	return &UserHandler{departmentService: departmentService}
 }
 
 type CreateUserRequest struct {
@@ -82,6 +85,11 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 
 	db := database.GetDB()
 
+	department, err := h.departmentService.ResolveList(db, req.Department)
+	if err != nil {
+		return departmentReferenceError(c, err)
+	}
+
 	// Check if username already exists
 	var existingUser models.User
 	if err := db.Where("username = ?", req.Username).First(&existingUser).Error; err == nil {
@@ -95,7 +103,7 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 		Email:      req.Email,
 		FullName:   req.FullName,
 		Role:       req.Role,
-		Department: req.Department,
+		Department: department,
 		IsActive:   true,
 		IsLDAPUser: req.IsLDAPUser,
 	}
@@ -144,11 +152,16 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 		})
 	}
 
+	department, err := h.departmentService.ResolveList(db, req.Department)
+	if err != nil {
+		return departmentReferenceError(c, err)
+	}
+
 	// Update fields
 	user.Email = req.Email
 	user.FullName = req.FullName
 	user.Role = req.Role
-	user.Department = req.Department
+	user.Department = department
 	user.IsActive = req.IsActive
 
 	if err := db.Save(&user).Error; err != nil {
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"fui-backend/database"
+	"fui-backend/models"
+	"fui-backend/services"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type VendorHandler struct {
+	auditService  *services.AuditService
+	vendorService *services.VendorService
+}
+
+func NewVendorHandler(auditService *services.AuditService, vendorService *services.VendorService) *VendorHandler {
+	return &VendorHandler{
+		auditService:  auditService,
+		vendorService: vendorService,
+	}
+}
+
+// GetVendors lists vendors ordered by name. Filters: q (code or name),
+// active.
+func (h *VendorHandler) GetVendors(c *fiber.Ctx) error {
+	query := database.GetDB().Model(&models.Vendor{})
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + escapeLike(q) + "%"
+		query = query.Where("code LIKE ? ESCAPE '\\' OR name LIKE ? ESCAPE '\\'", like, like)
+	}
+	if active := c.Query("active"); active != "" {
+		query = query.Where("is_active = ?", active == "true")
+	}
+
+	vendors := []models.Vendor{}
+	if err := query.Order("name ASC").Find(&vendors).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch vendors",
+		})
+	}
+
+	return c.JSON(vendors)
+}
+
+func (h *VendorHandler) GetVendor(c *fiber.Ctx) error {
+	vendor, ferr := loadVendor(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	return c.JSON(vendor)
+}
+
+func (h *VendorHandler) CreateVendor(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var req services.VendorInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	db := database.GetDB()
+	var vendor models.Vendor
+	if err := h.vendorService.Apply(db, &vendor, req); err != nil {
+		return vendorError(c, err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&vendor).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityVendor, vendor.ID, "create", &userID, vendor)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create vendor",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(vendor)
+}
+
+// UpdateVendor changes the details or active flag of a vendor. The code
+// stays as it is.
+func (h *VendorHandler) UpdateVendor(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	vendor, ferr := loadVendor(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	var req services.VendorInput
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	db := database.GetDB()
+	if err := h.vendorService.Apply(db, vendor, req); err != nil {
+		return vendorError(c, err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(vendor).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityVendor, vendor.ID, "update", &userID, vendor)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update vendor",
+		})
+	}
+
+	return c.JSON(vendor)
+}
+
+// DeleteVendor removes a vendor without quotations or spend records.
+// Vendors in use can be deactivated instead.
+func (h *VendorHandler) DeleteVendor(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	vendor, ferr := loadVendor(c)
+	if ferr != nil {
+		return errorResponse(c, ferr)
+	}
+
+	db := database.GetDB()
+	inUse, err := h.vendorService.InUse(db, vendor)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete vendor",
+		})
+	}
+	if inUse {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "vendor has quotations or spend records; deactivate it instead",
+		})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(vendor).Error; err != nil {
+			return err
+		}
+		return h.auditService.Record(tx, models.AuditEntityVendor, vendor.ID, "delete", &userID, vendor)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete vendor",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "vendor deleted successfully",
+	})
+}
+
+// loadVendor loads the vendor named by the :id parameter. Pass a returned
+// error to errorResponse.
+func loadVendor(c *fiber.Ctx) (*models.Vendor, *fiber.Error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid vendor id")
+	}
+
+	var vendor models.Vendor
+	if err := database.GetDB().First(&vendor, id).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "vendor not found")
+	}
+	return &vendor, nil
+}
+
+func vendorError(c *fiber.Ctx, err error) error {
+	var vendorErr *services.VendorError
+	if errors.As(err, &vendorErr) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":    vendorErr.Error(),
+			"problems": vendorErr.Problems,
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "failed to save vendor",
+	})
+}
@@ -33,8 +33,59 @@ func main() {
 	}
 
 	// Initialize services
-	ldapService := services.NewLDAPService(&cfg.LDAP)
+	departmentService := services.NewDepartmentService()
+	ldapService := services.NewLDAPService(&cfg.LDAP, departmentService)
 	jwtService := services.NewJWTService(&cfg.JWT)
+	auditService := services.NewAuditService(&cfg.Audit)
+	notificationService := services.NewNotificationService()
+	authorizationService := services.NewAuthorizationService()
+	exchangeRateService := services.NewExchangeRateService()
+	investmentTypeService := services.NewInvestmentTypeService()
+	if err := investmentTypeService.Backfill(database.GetDB()); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+	if err := investmentTypeService.SeedWACC(database.GetDB(), cfg.Finance.LegacyWACC); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+	planningCycleService := services.NewPlanningCycleService(investmentTypeService)
+	budgetService, err := services.NewBudgetService(&cfg.Budget)
+	if err != nil {
+		log.Fatalf("Invalid budget config: %v", err)
+	}
+	if err := budgetService.Backfill(database.GetDB()); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+	proposalWorkflowService, err := services.NewProposalWorkflowService(&cfg.Workflow, auditService, authorizationService, notificationService, exchangeRateService, budgetService, planningCycleService, investmentTypeService)
+	if err != nil {
+		log.Fatalf("Invalid approval threshold config: %v", err)
+	}
+	if err := proposalWorkflowService.MigrateOverrunStep(database.GetDB()); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+	proposalVersionService := services.NewProposalVersionService()
+	proposalLineItemService := services.NewProposalLineItemService()
+	financialEvaluationService, err := services.NewFinancialEvaluationService(&cfg.Finance)
+	if err != nil {
+		log.Fatalf("Invalid WACC config: %v", err)
+	}
+	proposalNumberService, err := services.NewProposalNumberService(&cfg.Numbering)
+	if err != nil {
+		log.Fatalf("Invalid proposal numbering config: %v", err)
+	}
+	vendorService := services.NewVendorService()
+	quotationService := services.NewQuotationService(exchangeRateService)
+	spendService := services.NewSpendService(exchangeRateService)
+	proposalTemplateService := services.NewProposalTemplateService(departmentService, investmentTypeService, proposalLineItemService, financialEvaluationService)
+	anomalyService := services.NewAnomalyService(&cfg.Security, notificationService)
+
+	geoIPService, err := services.NewGeoIPService(&cfg.GeoIP)
+	if err != nil {
+		log.Println("Warning: GeoIP enrichment limited:", err)
+	}
+	defer geoIPService.Close()
+
+	retentionService := services.NewRetentionService(&cfg.Retention)
+	retentionService.Start()
 
 	// Test LDAP connection
 	if err := ldapService.TestConnection(); err != nil {
@@ -44,11 +95,25 @@ func main() {
 	}
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(ldapService, jwtService)
-	proposalHandler := handlers.NewProposalHandler()
-	userHandler := handlers.NewUserHandler()
+	authHandler := handlers.NewAuthHandler(ldapService, jwtService, anomalyService, geoIPService)
+	proposalHandler := handlers.NewProposalHandler(auditService, authorizationService, proposalWorkflowService, proposalVersionService, proposalNumberService, proposalLineItemService, financialEvaluationService, exchangeRateService, departmentService, planningCycleService, investmentTypeService, proposalTemplateService)
+	userHandler := handlers.NewUserHandler(departmentService)
 	configHandler := handlers.NewConfigHandler(cfg)
 	loginLogHandler := handlers.NewLoginLogHandler()
+	auditHandler := handlers.NewAuditHandler(auditService)
+	loginLogArchiveHandler := handlers.NewLoginLogArchiveHandler(retentionService)
+	securityAlertHandler := handlers.NewSecurityAlertHandler()
+	notificationHandler := handlers.NewNotificationHandler()
+	searchHandler := handlers.NewSearchHandler(authorizationService)
+	exchangeRateHandler := handlers.NewExchangeRateHandler(auditService, exchangeRateService)
+	departmentHandler := handlers.NewDepartmentHandler(auditService, departmentService)
+	budgetHandler := handlers.NewBudgetHandler(authorizationService, budgetService)
+	planningCycleHandler := handlers.NewPlanningCycleHandler(auditService, authorizationService, planningCycleService)
+	investmentTypeHandler := handlers.NewInvestmentTypeHandler(auditService, investmentTypeService)
+	proposalTemplateHandler := handlers.NewProposalTemplateHandler(auditService, proposalTemplateService)
+	vendorHandler := handlers.NewVendorHandler(auditService, vendorService)
+	quotationHandler := handlers.NewQuotationHandler(auditService, authorizationService, quotationService)
+	spendHandler := handlers.NewSpendHandler(auditService, authorizationService, proposalWorkflowService, spendService)
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
@@ -75,7 +140,7 @@ func main() {
 	}))
 
 	// Setup routes
-	routes.SetupRoutes(app, authHandler, proposalHandler, userHandler, configHandler, loginLogHandler, jwtService)
+	routes.SetupRoutes(app, authHandler, proposalHandler, userHandler, configHandler, loginLogHandler, loginLogArchiveHandler, auditHandler, securityAlertHandler, notificationHandler, searchHandler, exchangeRateHandler, departmentHandler, budgetHandler, planningCycleHandler, investmentTypeHandler, proposalTemplateHandler, vendorHandler, quotationHandler, spendHandler, jwtService)
 
 	// Health check
 	app.Get("/health", func(c *fiber.Ctx) error {
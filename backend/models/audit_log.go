@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// AuditLog is an append-only, hash-chained record of changes to proposals
+// and approvals. Each row stores the hash of the previous row, so editing or
+// deleting any row directly in the database breaks the chain.
+type AuditLog struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	EntityType string    `gorm:"index:idx_audit_entity;not null" json:"entity_type"` // proposal, approval, comment
+	EntityID   uint      `gorm:"index:idx_audit_entity" json:"entity_id"`
+	Action     string    `gorm:"not null" json:"action"`
+	ActorID    *uint     `json:"actor_id"`
+	Payload    string    `gorm:"type:text" json:"payload"` // JSON snapshot of the entity
+	PrevHash   string    `gorm:"type:varchar(64);uniqueIndex" json:"prev_hash"`
+	Hash       string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"hash"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+const (
+	AuditEntityProposal = "proposal"
+	AuditEntityApproval = "approval"
+	AuditEntityComment  = "comment"
+
+	AuditEntityExchangeRate     = "exchange_rate"
+	AuditEntityDepartment       = "department"
+	AuditEntityDepartmentBudget = "department_budget"
+	AuditEntityPlanningCycle    = "planning_cycle"
+	AuditEntityInvestmentType   = "investment_type"
+	AuditEntityProposalTemplate = "proposal_template"
+	AuditEntityVendor           = "vendor"
+	AuditEntityQuotation        = "quotation"
+	AuditEntityComparison       = "quotation_comparison"
+	AuditEntitySpendRecord      = "spend_record"
+)
+
+// AuditHead is a single-row checkpoint of the newest audit entry. Record
+// updates it in the same transaction that appends the entry, so entries
+// deleted from the end of the chain no longer go unnoticed.
+type AuditHead struct {
+	ID        uint      `gorm:"primarykey" json:"-"`
+	LastID    uint      `json:"last_id"`
+	Count     int64     `json:"count"`
+	Hash      string    `gorm:"type:varchar(64)" json:"hash"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AuditHeadID is the primary key of the only AuditHead row.
+const AuditHeadID = 1
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Kinds of budget ledger entries.
+const (
+	BudgetReserve = "reserve"
+	BudgetCommit  = "commit"
+	BudgetRelease = "release"
+)
+
+// BudgetLedgerEntry moves the IDR cost of a proposal within the capex budget
+// of its department and fiscal year. Reserved and Committed are the changes
+// to the two balances: submitting reserves the cost, the final approval
+// turns the reservation into a commitment, and rejecting, returning for
+// revision, withdrawing or cancelling releases it. A re-approved spend
+// overrun commits what it adds.
+type BudgetLedgerEntry struct {
+	ID           uint                `gorm:"primarykey" json:"id"`
+	DepartmentID uint                `gorm:"not null;index:idx_budget_ledger_year" json:"department_id"`
+	Department   *Department         `gorm:"foreignKey:DepartmentID" json:"department,omitempty"`
+	FiscalYear   int                 `gorm:"not null;index:idx_budget_ledger_year" json:"fiscal_year"`
+	ProposalID   uint                `gorm:"not null;index" json:"proposal_id"`
+	Proposal     *InvestmentProposal `gorm:"foreignKey:ProposalID" json:"proposal,omitempty"`
+	Kind         string              `gorm:"type:varchar(10);not null" json:"kind"`
+	Reserved     Amount              `gorm:"not null" json:"reserved"`
+	Committed    Amount              `gorm:"not null" json:"committed"`
+	OverBudget   bool                `json:"over_budget"` // reserved more than the budget had left
+	ActorID      *uint               `json:"actor_id"`
+	Actor        *User               `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+	CreatedAt    time.Time           `json:"created_at"`
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// DataMigration records a one-off data migration that has been applied, so
+// Migrate runs it only once.
+type DataMigration struct {
+	Name      string    `gorm:"primarykey;type:varchar(100)" json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
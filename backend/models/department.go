@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Department is the master record that InvestmentProposal.DepartmentID and
+// User.Department refer to by Code.
+type Department struct {
+	ID        uint               `gorm:"primarykey" json:"id"`
+	Code      string             `gorm:"type:varchar(20);uniqueIndex;not null" json:"code"`
+	Name      string             `gorm:"not null;uniqueIndex:idx_department_name_unit" json:"name"`
+	Unit      string             `gorm:"uniqueIndex:idx_department_name_unit" json:"unit"` // hospital or business unit, e.g. RS Bekasi Barat
+	HeadID    *uint              `json:"head_id"`
+	Head      *User              `gorm:"foreignKey:HeadID" json:"head,omitempty"`
+	IsActive  bool               `gorm:"not null" json:"is_active"`
+	Budgets   []DepartmentBudget `gorm:"foreignKey:DepartmentID" json:"budgets,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// DepartmentBudget is the capex a department may spend in a fiscal year,
+// in IDR.
+type DepartmentBudget struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	DepartmentID uint      `gorm:"not null;uniqueIndex:idx_department_budget_year" json:"department_id"`
+	FiscalYear   int       `gorm:"not null;uniqueIndex:idx_department_budget_year" json:"fiscal_year"`
+	Amount       Amount    `gorm:"not null" json:"amount"`
+	Notes        string    `gorm:"type:text" json:"notes"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
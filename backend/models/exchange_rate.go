@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// BaseCurrency is the currency proposals are converted to for reporting
+// and approval thresholds.
+const BaseCurrency = "IDR"
+
+const (
+	ExchangeRateManual = "manual"
+	ExchangeRateCSV    = "csv"
+)
+
+// ExchangeRate is the value in IDR of one unit of Currency from
+// EffectiveDate until the next rate of that currency takes effect.
+type ExchangeRate struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	Currency      string    `gorm:"type:varchar(3);not null;uniqueIndex:idx_exchange_rate_date" json:"currency"`
+	EffectiveDate time.Time `gorm:"not null;uniqueIndex:idx_exchange_rate_date" json:"effective_date"`
+	Rate          Rate      `gorm:"not null" json:"rate"`
+	Source        string    `gorm:"type:varchar(10);not null" json:"source"` // manual, csv
+	CreatedByID   uint      `json:"created_by_id"`
+	CreatedBy     User      `gorm:"foreignKey:CreatedByID" json:"created_by"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
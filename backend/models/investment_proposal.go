@@ -15,15 +15,26 @@ const (
 	StatusApproved  ProposalStatus = "approved"
 	StatusRejected  ProposalStatus = "rejected"
 	StatusRevision  ProposalStatus = "revision"
+	StatusWithdrawn ProposalStatus = "withdrawn"
+	StatusCancelled ProposalStatus = "cancelled"
+
+	// Execution of an approved proposal
+	StatusInProgress ProposalStatus = "in_progress"
+	StatusCompleted  ProposalStatus = "completed"
+	StatusClosed     ProposalStatus = "closed"
 )
 
+// FundedStatuses are the statuses of proposals whose cost was approved:
+// approved and the execution statuses that follow it.
+var FundedStatuses = []ProposalStatus{StatusApproved, StatusInProgress, StatusCompleted, StatusClosed}
+
 type InvestmentProposal struct {
 	ID                  uint           `gorm:"primarykey" json:"id"`
 	ProposalNumber      string         `gorm:"uniqueIndex;not null" json:"proposal_number"`
 	Title               string         `gorm:"not null" json:"title"`
 	Description         string         `gorm:"type:text" json:"description"`
-	InvestmentType      string         `json:"investment_type"` // IT, Operasional, dll
-	EstimatedCost       float64        `json:"estimated_cost"`
+	InvestmentType      string         `json:"investment_type"` // code of an InvestmentType
+	EstimatedCost       Amount         `json:"estimated_cost"`
 	Currency            string         `gorm:"default:'IDR'" json:"currency"`
 	ProposalDate        time.Time      `json:"proposal_date"`
 	ExpectedStartDate   time.Time      `json:"expected_start_date"`
@@ -33,11 +44,65 @@ type InvestmentProposal struct {
 	RiskAnalysis        string         `gorm:"type:text" json:"risk_analysis"`
 	Status              ProposalStatus `gorm:"type:varchar(20);default:'draft'" json:"status"`
 
+	// EstimatedCostIDR is the estimated cost converted at ExchangeRate. It
+	// is indicative while the proposal is edited and locked on submit, at
+	// RateLockedAt. Nil when no rate exists for the currency yet.
+	EstimatedCostIDR *Amount    `gorm:"column:estimated_cost_idr" json:"estimated_cost_idr"`
+	ExchangeRate     *Rate      `json:"exchange_rate"`
+	RateLockedAt     *time.Time `json:"rate_locked_at"`
+
+	// ApprovalStep is the 1-based ApprovalWorkflow step the proposal is
+	// waiting on; 0 until it is submitted.
+	ApprovalStep int `gorm:"default:0" json:"approval_step"`
+
+	// BudgetWarning is set on the response to a submit that exceeds the
+	// remaining department budget, when budgets are only warned about.
+	BudgetWarning string `gorm:"-" json:"budget_warning,omitempty"`
+
 	// Relations
 	SubmittedByID uint   `json:"submitted_by_id"`
 	SubmittedBy   User   `gorm:"foreignKey:SubmittedByID" json:"submitted_by"`
 	DepartmentID  string `json:"department_id"`
 
+	// PlanningCycleID is the planning round the proposal belongs to. A
+	// proposal submitted outside its window is Urgent, with an
+	// UrgentJustification for why it cannot wait for the next round.
+	PlanningCycleID     *uint          `json:"planning_cycle_id"`
+	PlanningCycle       *PlanningCycle `gorm:"foreignKey:PlanningCycleID" json:"planning_cycle,omitempty"`
+	Urgent              bool           `json:"urgent"`
+	UrgentJustification string         `gorm:"type:text" json:"urgent_justification"`
+
+	// RecommendedQuotationID is the quotation procurement recommends, from
+	// the latest QuotationComparison; cleared when the quotations change.
+	// The procurement step cannot be approved without it.
+	RecommendedQuotationID *uint `json:"recommended_quotation_id"`
+	RecommendedVendorID    *uint `json:"recommended_vendor_id"`
+
+	// ActualCostIDR is the IDR total of the SpendRecords of the proposal.
+	// ApprovedCostIDR is the cost its spend is held to once an overrun was
+	// re-approved; until then the EstimatedCostIDR. OverrunPending is set
+	// while spend exceeds that cost by more than the tolerance and waits
+	// for re-approval.
+	ActualCostIDR   Amount  `gorm:"column:actual_cost_idr;default:0" json:"actual_cost_idr"`
+	ApprovedCostIDR *Amount `gorm:"column:approved_cost_idr" json:"approved_cost_idr"`
+	OverrunPending  bool    `json:"overrun_pending"`
+
+	// ClonedFromID and TemplateID record what a draft was started from
+	ClonedFromID *uint `json:"cloned_from_id"`
+	TemplateID   *uint `json:"template_id"`
+
+	// CustomFields holds the extra data the FieldSchema of the investment
+	// type defines, keyed by field name
+	CustomFields map[string]interface{} `gorm:"type:text;serializer:json" json:"custom_fields"`
+
+	// Cost breakdown, ordered by Position
+	LineItems []ProposalLineItem `gorm:"foreignKey:ProposalID" json:"line_items,omitempty"`
+
+	// Projected cash flows, ordered by Year, and the evaluation computed
+	// from them
+	CashFlows  []ProposalCashFlow  `gorm:"foreignKey:ProposalID" json:"cash_flows,omitempty"`
+	Financials FinancialEvaluation `gorm:"embedded;embeddedPrefix:fin_" json:"financials"`
+
 	// Attachments
 	Attachments []Attachment `gorm:"foreignKey:ProposalID" json:"attachments,omitempty"`
 
@@ -52,6 +117,20 @@ type InvestmentProposal struct {
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// SpendLimitIDR returns the IDR cost the spend of the proposal is held to,
+// nil when its cost was never converted.
+func (p *InvestmentProposal) SpendLimitIDR() *Amount {
+	if p.ApprovedCostIDR != nil {
+		return p.ApprovedCostIDR
+	}
+	return p.EstimatedCostIDR
+}
+
+// Cost returns the estimated cost in the proposal's currency.
+func (p *InvestmentProposal) Cost() Money {
+	return NewMoney(p.EstimatedCost, p.Currency)
+}
+
 type Attachment struct {
 	ID         uint      `gorm:"primarykey" json:"id"`
 	ProposalID uint      `json:"proposal_id"`
@@ -63,13 +142,22 @@ type Attachment struct {
 	CreatedAt  time.Time `json:"created_at"`
 }
 
+const (
+	ApprovalPending   = "pending"
+	ApprovalApproved  = "approved"
+	ApprovalRejected  = "rejected"
+	ApprovalRevision  = "revision"
+	ApprovalCancelled = "cancelled"
+)
+
 type Approval struct {
 	ID           uint       `gorm:"primarykey" json:"id"`
 	ProposalID   uint       `json:"proposal_id"`
 	ApproverID   uint       `json:"approver_id"`
 	Approver     User       `gorm:"foreignKey:ApproverID" json:"approver"`
 	ApproverRole UserRole   `json:"approver_role"`
-	Status       string     `json:"status"` // pending, approved, rejected
+	Step         int        `json:"step"`
+	Status       string     `json:"status"` // pending, approved, rejected, revision, cancelled
 	Comments     string     `gorm:"type:text" json:"comments"`
 	ApprovedAt   *time.Time `json:"approved_at"`
 	CreatedAt    time.Time  `json:"created_at"`
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Types of custom fields, as in JSON Schema.
+const (
+	FieldString  = "string"
+	FieldInteger = "integer"
+	FieldNumber  = "number"
+	FieldBoolean = "boolean"
+)
+
+// InvestmentType is an entry of the investment type catalog. Proposals
+// refer to it by Code; FieldSchema defines the extra data a proposal of the
+// type carries in CustomFields, e.g. the license count of an IT purchase.
+// Cash flows of its proposals are discounted at WACC, or at the default
+// WACC when it is nil.
+type InvestmentType struct {
+	ID          uint        `gorm:"primarykey" json:"id"`
+	Code        string      `gorm:"not null;uniqueIndex" json:"code"` // e.g. IT, RENOVASI
+	Name        string      `gorm:"not null" json:"name"`
+	Description string      `gorm:"type:text" json:"description"`
+	FieldSchema FieldSchema `gorm:"type:text;serializer:json" json:"field_schema"`
+	WACC        *float64    `gorm:"column:wacc" json:"wacc"` // percent
+	IsActive    bool        `gorm:"not null" json:"is_active"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// FieldSchema is the subset of JSON Schema that describes custom fields:
+// an object with typed properties, some of them required.
+type FieldSchema struct {
+	Type       string                     `json:"type"` // always "object"
+	Properties map[string]FieldDefinition `json:"properties"`
+	Required   []string                   `json:"required,omitempty"`
+}
+
+// FieldDefinition describes one custom field. Minimum and Maximum apply to
+// numbers, MinLength, MaxLength, Pattern and Format ("date", YYYY-MM-DD) to
+// strings.
+type FieldDefinition struct {
+	Type        string        `json:"type"`
+	Title       string        `json:"title,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Enum        []interface{} `json:"enum,omitempty"`
+	Minimum     *float64      `json:"minimum,omitempty"`
+	Maximum     *float64      `json:"maximum,omitempty"`
+	MinLength   *int          `json:"minLength,omitempty"`
+	MaxLength   *int          `json:"maxLength,omitempty"`
+	Pattern     string        `json:"pattern,omitempty"`
+	Format      string        `json:"format,omitempty"`
+}
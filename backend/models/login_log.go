@@ -6,15 +6,55 @@ import (
 	"gorm.io/gorm"
 )
 
+// LoginLog is stored with LoginAt in UTC, in whole seconds (see LoginTime).
+//
+// LoginLog indexes lead with deleted_at because every GORM query filters on
+// "deleted_at IS NULL"; this lets SQLite walk login_at in order instead of
+// sorting the whole table.
 type LoginLog struct {
-	ID         uint           `gorm:"primarykey" json:"id"`
-	UserID     *uint          `json:"user_id"` // Nullable if login failed
-	Username   string         `gorm:"not null" json:"username"`
-	IPAddress  string         `json:"ip_address"`
-	UserAgent  string         `json:"user_agent"`
-	Success    bool           `gorm:"default:false" json:"success"`
-	FailReason string         `json:"fail_reason,omitempty"`
-	LoginAt    time.Time      `gorm:"autoCreateTime" json:"login_at"`
-	CreatedAt  time.Time      `json:"created_at"`
-	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+	ID         uint   `gorm:"primarykey" json:"id"`
+	UserID     *uint  `json:"user_id"` // Nullable if login failed
+	Username   string `gorm:"not null;index:idx_login_logs_username,priority:2" json:"username"`
+	IPAddress  string `json:"ip_address"`
+	UserAgent  string `json:"user_agent"`
+	Success    bool   `gorm:"default:false;index:idx_login_logs_success,priority:2" json:"success"`
+	FailReason string `json:"fail_reason,omitempty"`
+
+	// Enrichment, filled in at write time
+	Browser     string `json:"browser"`
+	OS          string `json:"os"`
+	DeviceType  string `json:"device_type"` // desktop, mobile, tablet, bot
+	Country     string `json:"country"`
+	CountryCode string `json:"country_code"`
+	City        string `json:"city"`
+	Site        string `json:"site"` // site name for internal networks
+
+	LoginAt   time.Time      `gorm:"autoCreateTime;index:idx_login_logs_login_at,priority:2;index:idx_login_logs_success,priority:3;index:idx_login_logs_username,priority:3" json:"login_at"`
+	CreatedAt time.Time      `json:"created_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index;index:idx_login_logs_login_at,priority:1;index:idx_login_logs_success,priority:1;index:idx_login_logs_username,priority:1" json:"-"`
+}
+
+// LoginTime returns t the way LoginAt is stored: in UTC and truncated to
+// whole seconds. SQLite keeps times as text, which sorts in time order only
+// when every row has the same offset and length, so login_at can then be
+// compared and indexed as it is. Bounds compared against login_at must be
+// rounded the same way: down for an upper bound, up for a lower one (see
+// LoginTimeAfter).
+func LoginTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Second)
+}
+
+// LoginTimeAfter rounds a lower bound up to the first stored login time at
+// or after t.
+func LoginTimeAfter(t time.Time) time.Time {
+	return LoginTime(t.Add(time.Second - time.Nanosecond))
+}
+
+// BeforeSave stores LoginAt as LoginTime, whichever offset it was set in.
+func (l *LoginLog) BeforeSave(tx *gorm.DB) error {
+	if l.LoginAt.IsZero() {
+		l.LoginAt = time.Now()
+	}
+	l.LoginAt = LoginTime(l.LoginAt)
+	return nil
 }
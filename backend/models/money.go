@@ -0,0 +1,293 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// AmountScale is the number of decimal places an Amount keeps.
+const AmountScale = 2
+
+const amountUnit = 100 // 10^AmountScale
+
+var ErrAmountOverflow = errors.New("amount out of range")
+
+// Amount is a fixed-point decimal with two decimal places, stored as an
+// integer number of hundredths so sums and comparisons are exact, in Go and
+// in SQL. It is written to JSON as a plain number, e.g. 1500000000.25, and
+// read from a number or a string; extra decimals are rounded half away from
+// zero.
+type Amount int64
+
+// ParseAmount parses a decimal such as "1500000000.25" or "-3".
+func ParseAmount(s string) (Amount, error) {
+	v, err := parseFixed(s, amountUnit)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", s, err)
+	}
+	return Amount(v), nil
+}
+
+// AmountFromFloat converts a float, e.g. a computed estimate, rounding to
+// the nearest hundredth. The float is taken at its shortest decimal form,
+// so 0.29 becomes 0.29 rather than 0.28999....
+func AmountFromFloat(f float64) (Amount, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, ErrAmountOverflow
+	}
+	return ParseAmount(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+func amountFromRat(r *big.Rat) (Amount, error) {
+	v, err := fixedFromRat(r, amountUnit)
+	return Amount(v), err
+}
+
+// Mul multiplies the amount by a factor such as a quantity or a tax rate
+// fraction, rounding to the nearest hundredth. The factor is taken at its
+// shortest decimal form.
+func (a Amount) Mul(factor float64) (Amount, error) {
+	f, ok := new(big.Rat).SetString(strconv.FormatFloat(factor, 'f', -1, 64))
+	if !ok {
+		return 0, ErrAmountOverflow
+	}
+	return amountFromRat(new(big.Rat).Mul(a.rat(), f))
+}
+
+// Add returns a+b, failing instead of wrapping around.
+func (a Amount) Add(b Amount) (Amount, error) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, ErrAmountOverflow
+	}
+	return sum, nil
+}
+
+// Minor returns the amount in hundredths.
+func (a Amount) Minor() int64 {
+	return int64(a)
+}
+
+// Float64 returns the amount as a float, for statistics such as NPV where
+// exactness is not needed.
+func (a Amount) Float64() float64 {
+	return float64(a) / amountUnit
+}
+
+func (a Amount) rat() *big.Rat {
+	return big.NewRat(int64(a), amountUnit)
+}
+
+// String formats the amount with two decimals, e.g. "1500000000.25".
+func (a Amount) String() string {
+	return formatFixed(int64(a), amountUnit, AmountScale)
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(compactFixed(a.String())), nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	v, err := unmarshalFixed(data, amountUnit)
+	if err != nil {
+		return fmt.Errorf("invalid amount: %w", err)
+	}
+	if v != nil {
+		*a = Amount(*v)
+	}
+	return nil
+}
+
+// Scan reads the integer number of hundredths.
+func (a *Amount) Scan(value interface{}) error {
+	v, err := scanFixed(value)
+	if err != nil {
+		return fmt.Errorf("cannot scan into Amount: %w", err)
+	}
+	*a = Amount(v)
+	return nil
+}
+
+func (a Amount) Value() (driver.Value, error) {
+	return int64(a), nil
+}
+
+func (Amount) GormDataType() string {
+	return "int"
+}
+
+// Money is an amount in a currency. Tables keep the two in their own
+// columns; Cost, Quotation.Money and SpendRecord.Money pair them, so an
+// amount is never converted or added at the rate of another currency.
+type Money struct {
+	Amount   Amount `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// NewMoney pairs amount with currency, upper-cased; no currency is IDR.
+func NewMoney(amount Amount, currency string) Money {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		currency = BaseCurrency
+	}
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Add returns the sum of two amounts in the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("cannot add %s to %s", o.Currency, m.Currency)
+	}
+	sum, err := m.Amount.Add(o.Amount)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+// String formats the money as e.g. "USD 1250.50".
+func (m Money) String() string {
+	return m.Currency + " " + m.Amount.String()
+}
+
+// RateScale is the number of decimal places a Rate keeps.
+const RateScale = 6
+
+const rateUnit = 1_000_000 // 10^RateScale
+
+// Rate is a fixed-point decimal with six decimal places, such as an
+// exchange rate. It is stored and serialized like Amount.
+type Rate int64
+
+// RateOne is a rate of 1, such as IDR to IDR.
+const RateOne Rate = rateUnit
+
+// ParseRate parses a decimal such as "15850.25".
+func ParseRate(s string) (Rate, error) {
+	v, err := parseFixed(s, rateUnit)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q: %w", s, err)
+	}
+	return Rate(v), nil
+}
+
+// Apply multiplies an amount by the rate, rounding to the nearest
+// hundredth.
+func (r Rate) Apply(a Amount) (Amount, error) {
+	return amountFromRat(new(big.Rat).Mul(a.rat(), big.NewRat(int64(r), rateUnit)))
+}
+
+func (r Rate) String() string {
+	return compactFixed(formatFixed(int64(r), rateUnit, RateScale))
+}
+
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	v, err := unmarshalFixed(data, rateUnit)
+	if err != nil {
+		return fmt.Errorf("invalid rate: %w", err)
+	}
+	if v != nil {
+		*r = Rate(*v)
+	}
+	return nil
+}
+
+func (r *Rate) Scan(value interface{}) error {
+	v, err := scanFixed(value)
+	if err != nil {
+		return fmt.Errorf("cannot scan into Rate: %w", err)
+	}
+	*r = Rate(v)
+	return nil
+}
+
+func (r Rate) Value() (driver.Value, error) {
+	return int64(r), nil
+}
+
+func (Rate) GormDataType() string {
+	return "int"
+}
+
+// parseFixed parses a decimal into an integer number of 1/unit, rounding
+// half away from zero.
+func parseFixed(s string, unit int64) (int64, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok || strings.Contains(s, "/") {
+		return 0, errors.New("not a decimal number")
+	}
+	return fixedFromRat(r, unit)
+}
+
+func fixedFromRat(r *big.Rat, unit int64) (int64, error) {
+	scaled := new(big.Rat).Mul(r, big.NewRat(unit, 1))
+
+	// Round half away from zero
+	num, den := scaled.Num(), scaled.Denom()
+	q, m := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(m), big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(int64(num.Sign())))
+	}
+
+	if !q.IsInt64() {
+		return 0, ErrAmountOverflow
+	}
+	return q.Int64(), nil
+}
+
+func formatFixed(v, unit int64, scale int) string {
+	sign, u := "", uint64(v)
+	if v < 0 {
+		sign, u = "-", uint64(-v)
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, u/uint64(unit), scale, u%uint64(unit))
+}
+
+// compactFixed drops trailing zeros, e.g. "1500000000.50" to "1500000000.5".
+func compactFixed(s string) string {
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// unmarshalFixed reads a JSON number or decimal string; nil for null.
+func unmarshalFixed(data []byte, unit int64) (*int64, error) {
+	s := string(data)
+	if s == "null" {
+		return nil, nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	v, err := parseFixed(s, unit)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// scanFixed reads a stored integer number of 1/unit. Floats are refused:
+// they are amounts left unconverted or arithmetic that lost exactness.
+func scanFixed(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return v, nil
+	case float64:
+		return 0, fmt.Errorf("got float %v, want an integer number of minor units", v)
+	case []byte:
+		return strconv.ParseInt(string(v), 10, 64)
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return 0, fmt.Errorf("unsupported type %T", value)
+	}
+}
@@ -0,0 +1,219 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestAmountJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Amount
+		out  string
+	}{
+		{`0`, 0, `0`},
+		{`1500000000.25`, 150000000025, `1500000000.25`},
+		{`"1500000000.25"`, 150000000025, `1500000000.25`},
+		{`1500000000.5`, 150000000050, `1500000000.5`},
+		{`0.29`, 29, `0.29`},
+		{`-3`, -300, `-3`},
+		{`"  42.10 "`, 4210, `42.1`},
+		{`0.005`, 1, `0.01`},    // rounded half away from zero
+		{`-0.005`, -1, `-0.01`}, // rounded half away from zero
+		{`0.0049`, 0, `0`},
+		{`1e3`, 100000, `1000`},
+		{`92233720368547758.07`, Amount(math.MaxInt64), `92233720368547758.07`},
+		{`-92233720368547758.08`, Amount(math.MinInt64), `-92233720368547758.08`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var a Amount
+			if err := json.Unmarshal([]byte(tt.in), &a); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if a != tt.want {
+				t.Fatalf("Unmarshal = %d, want %d", a, tt.want)
+			}
+
+			out, err := json.Marshal(a)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if string(out) != tt.out {
+				t.Fatalf("Marshal = %s, want %s", out, tt.out)
+			}
+
+			var back Amount
+			if err := json.Unmarshal(out, &back); err != nil || back != a {
+				t.Errorf("round trip = %d, %v; want %d", back, err, a)
+			}
+		})
+	}
+}
+
+func TestAmountJSONNullKeepsValue(t *testing.T) {
+	a := Amount(4210)
+	if err := json.Unmarshal([]byte(`null`), &a); err != nil || a != 4210 {
+		t.Errorf("Unmarshal(null) = %d, %v; want 4210 unchanged", a, err)
+	}
+}
+
+func TestAmountJSONInvalid(t *testing.T) {
+	for _, in := range []string{`"abc"`, `"1/3"`, `""`, `true`, `92233720368547758.08`, `"1e400"`} {
+		t.Run(in, func(t *testing.T) {
+			var a Amount
+			if err := json.Unmarshal([]byte(in), &a); err == nil {
+				t.Errorf("Unmarshal(%s) = %d, want an error", in, a)
+			}
+		})
+	}
+}
+
+func TestAmountScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    Amount
+		wantErr bool
+	}{
+		{"nil", nil, 0, false},
+		{"int64", int64(150000000025), 150000000025, false},
+		{"negative", int64(-300), -300, false},
+		{"bytes", []byte("4210"), 4210, false},
+		{"string", "4210", 4210, false},
+		{"float", float64(42.1), 0, true},
+		{"integral float", float64(4210), 0, true},
+		{"decimal string", "42.10", 0, true},
+		{"bool", true, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Amount(-1)
+			err := a.Scan(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Scan(%#v) = %d, want an error", tt.value, a)
+				}
+				return
+			}
+			if err != nil || a != tt.want {
+				t.Fatalf("Scan(%#v) = %d, %v; want %d", tt.value, a, err, tt.want)
+			}
+
+			// Value gives back what Scan reads
+			v, err := a.Value()
+			if err != nil {
+				t.Fatalf("Value: %v", err)
+			}
+			var back Amount
+			if err := back.Scan(v); err != nil || back != a {
+				t.Errorf("round trip = %d, %v; want %d", back, err, a)
+			}
+		})
+	}
+}
+
+func TestAmountArithmetic(t *testing.T) {
+	tests := []struct {
+		name   string
+		a      Amount
+		factor float64
+		want   Amount
+	}{
+		{"quantity", 1999, 3, 5997},
+		{"tax", 10000, 0.11, 1100},
+		{"rounds half away from zero", 5, 0.5, 3},
+		{"negative rounds half away from zero", -5, 0.5, -3},
+		{"tolerance", 100000, 1.1, 110000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.a.Mul(tt.factor)
+			if err != nil || got != tt.want {
+				t.Errorf("%d.Mul(%v) = %d, %v; want %d", tt.a, tt.factor, got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := Amount(math.MaxInt64).Mul(2); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("Mul overflow: err = %v, want ErrAmountOverflow", err)
+	}
+	if _, err := Amount(math.MaxInt64).Add(1); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("Add overflow: err = %v, want ErrAmountOverflow", err)
+	}
+	if _, err := Amount(math.MinInt64).Add(-1); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("Add underflow: err = %v, want ErrAmountOverflow", err)
+	}
+}
+
+func TestMoney(t *testing.T) {
+	if m := NewMoney(125050, " usd "); m.Currency != "USD" || m.String() != "USD 1250.50" {
+		t.Errorf("NewMoney = %+v (%s), want USD 1250.50", m, m)
+	}
+	if m := (&InvestmentProposal{EstimatedCost: 100}).Cost(); m.Currency != BaseCurrency {
+		t.Errorf("cost without a currency = %s, want it in %s", m, BaseCurrency)
+	}
+
+	tests := []struct {
+		name    string
+		a, b    Money
+		want    Money
+		wantErr string
+	}{
+		{"same currency", NewMoney(1050, "USD"), NewMoney(25, "usd"), NewMoney(1075, "USD"), ""},
+		{"other currency", NewMoney(1050, "USD"), NewMoney(25, "EUR"), Money{}, "cannot add EUR to USD"},
+		{"overflow", NewMoney(math.MaxInt64, "IDR"), NewMoney(1, "IDR"), Money{}, ErrAmountOverflow.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.a.Add(tt.b)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("%s + %s: err = %v, want %s", tt.a, tt.b, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("%s + %s = %s, %v; want %s", tt.a, tt.b, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateJSONAndApply(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   Rate
+		out    string
+		amount Amount
+		idr    Amount
+	}{
+		{`1`, RateOne, `1`, 4210, 4210},
+		{`15850.25`, 15850250000, `15850.25`, 100, 1585025},
+		{`"0.000001"`, 1, `0.000001`, 100000000, 100},
+		{`0.0000005`, 1, `0.000001`, 100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var r Rate
+			if err := json.Unmarshal([]byte(tt.in), &r); err != nil || r != tt.want {
+				t.Fatalf("Unmarshal = %d, %v; want %d", r, err, tt.want)
+			}
+			if out, _ := json.Marshal(r); string(out) != tt.out {
+				t.Errorf("Marshal = %s, want %s", out, tt.out)
+			}
+
+			v, _ := r.Value()
+			var back Rate
+			if err := back.Scan(v); err != nil || back != r {
+				t.Errorf("Scan round trip = %d, %v; want %d", back, err, r)
+			}
+
+			if idr, err := r.Apply(tt.amount); err != nil || idr != tt.idr {
+				t.Errorf("Apply(%d) = %d, %v; want %d", tt.amount, idr, err, tt.idr)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type Notification struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	Type      string     `gorm:"type:varchar(50)" json:"type"` // security_alert, proposal, ...
+	Title     string     `gorm:"not null" json:"title"`
+	Message   string     `gorm:"type:text" json:"message"`
+	Link      string     `json:"link"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Planning cycle states, derived from the submission window.
+const (
+	CycleUpcoming = "upcoming"
+	CycleOpen     = "open"
+	CycleClosed   = "closed"
+)
+
+// PlanningCycle is an annual planning round that collects proposals for a
+// fiscal year. Proposals are submitted between OpensAt and ClosesAt, both
+// dates inclusive; later ones must be marked urgent.
+type PlanningCycle struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"not null;uniqueIndex" json:"name"` // e.g. Capex FY2027
+	FiscalYear  int       `gorm:"not null;index" json:"fiscal_year"`
+	OpensAt     time.Time `gorm:"not null" json:"opens_at"`
+	ClosesAt    time.Time `gorm:"not null" json:"closes_at"`
+	Description string    `gorm:"type:text" json:"description"`
+
+	// InvestmentTypes the cycle collects; empty for any type
+	InvestmentTypes []string `gorm:"type:text;serializer:json" json:"investment_types"`
+
+	// BudgetEnvelope is the total IDR the cycle may approve
+	BudgetEnvelope Amount `gorm:"not null" json:"budget_envelope"`
+
+	CreatedByID uint      `json:"created_by_id"`
+	CreatedBy   User      `gorm:"foreignKey:CreatedByID" json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// StateAt returns whether the submission window is upcoming, open or
+// closed at t.
+func (c *PlanningCycle) StateAt(t time.Time) string {
+	switch {
+	case t.Before(c.OpensAt):
+		return CycleUpcoming
+	case t.Before(c.ClosesAt.AddDate(0, 0, 1)):
+		return CycleOpen
+	default:
+		return CycleClosed
+	}
+}
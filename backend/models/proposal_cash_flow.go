@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// ProposalCashFlow is the projected inflow and outflow of a proposal in one
+// year. Year 0 is the initial investment; cash flows of year t are
+// discounted t times.
+type ProposalCashFlow struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	ProposalID uint      `gorm:"uniqueIndex:idx_proposal_cash_flow_year;not null" json:"proposal_id"`
+	Year       int       `gorm:"uniqueIndex:idx_proposal_cash_flow_year;not null" json:"year"`
+	Inflow     Amount    `gorm:"not null;default:0" json:"inflow"`
+	Outflow    Amount    `gorm:"not null;default:0" json:"outflow"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// FinancialEvaluation is computed from the cash flows of a proposal. Rates
+// are percentages. IRR and PaybackYears stay nil when the cash flows have
+// none; everything is nil while a proposal has no cash flows.
+type FinancialEvaluation struct {
+	DiscountRate *float64   `json:"discount_rate"` // WACC the evaluation used, percent
+	NPV          *Amount    `json:"npv"`
+	IRR          *float64   `json:"irr"`
+	PaybackYears *float64   `json:"payback_years"`
+	ROI          *float64   `json:"roi"`
+	EvaluatedAt  *time.Time `json:"evaluated_at"`
+}
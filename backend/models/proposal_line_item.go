@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+const (
+	LineItemEquipment    = "equipment"
+	LineItemInstallation = "installation"
+	LineItemTraining     = "training"
+	LineItemContingency  = "contingency"
+	LineItemOther        = "other"
+)
+
+var LineItemCategories = []string{LineItemEquipment, LineItemInstallation, LineItemTraining, LineItemContingency, LineItemOther}
+
+// ProposalLineItem is one row of a proposal's cost breakdown. Subtotal,
+// TaxAmount and Total are computed by the server; the proposal's
+// EstimatedCost is the sum of the totals.
+type ProposalLineItem struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	ProposalID  uint      `gorm:"index;not null" json:"proposal_id"`
+	Position    int       `gorm:"not null" json:"position"`
+	Description string    `gorm:"not null" json:"description"`
+	Category    string    `gorm:"type:varchar(20);not null" json:"category"`
+	Quantity    float64   `gorm:"not null" json:"quantity"`
+	UnitPrice   Amount    `gorm:"not null" json:"unit_price"`
+	Currency    string    `gorm:"type:varchar(3);not null" json:"currency"`
+	TaxRate     float64   `gorm:"not null;default:0" json:"tax_rate"` // percent, e.g. 11 for PPN 11%
+	Subtotal    Amount    `json:"subtotal"`
+	TaxAmount   Amount    `json:"tax_amount"`
+	Total       Amount    `json:"total"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
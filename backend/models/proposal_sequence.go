@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// ProposalSequence is the last proposal number counter used in a scope: a
+// reset period, plus the department when the number pattern includes one.
+type ProposalSequence struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Scope     string    `gorm:"type:varchar(100);uniqueIndex;not null" json:"scope"`
+	Value     int64     `gorm:"not null" json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
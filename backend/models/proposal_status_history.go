@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// ProposalStatusHistory is one entry of a proposal's status timeline.
+type ProposalStatusHistory struct {
+	ID           uint           `gorm:"primarykey" json:"id"`
+	ProposalID   uint           `gorm:"index;not null" json:"proposal_id"`
+	Action       string         `gorm:"type:varchar(30);not null" json:"action"`
+	FromStatus   ProposalStatus `gorm:"type:varchar(20)" json:"from_status"`
+	ToStatus     ProposalStatus `gorm:"type:varchar(20);not null" json:"to_status"`
+	ApprovalStep int            `json:"approval_step"`
+	ActorID      uint           `json:"actor_id"`
+	Actor        User           `gorm:"foreignKey:ActorID" json:"actor"`
+	ActorRole    UserRole       `gorm:"type:varchar(50)" json:"actor_role"`
+	Reason       string         `gorm:"type:text" json:"reason"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
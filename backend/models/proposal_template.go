@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// ProposalTemplate is a reusable starting point for the proposals of an
+// investment type, such as the yearly laptop replacement. New drafts take
+// its content; the template itself is never submitted.
+type ProposalTemplate struct {
+	ID             uint   `gorm:"primarykey" json:"id"`
+	Name           string `gorm:"not null;uniqueIndex" json:"name"`
+	InvestmentType string `gorm:"not null;index" json:"investment_type"` // code of an InvestmentType
+	IsActive       bool   `gorm:"not null" json:"is_active"`
+
+	// Proposal content
+	Title           string                 `json:"title"`
+	Description     string                 `gorm:"type:text" json:"description"`
+	Currency        string                 `json:"currency"`
+	Justification   string                 `gorm:"type:text" json:"justification"`
+	ExpectedBenefit string                 `gorm:"type:text" json:"expected_benefit"`
+	RiskAnalysis    string                 `gorm:"type:text" json:"risk_analysis"`
+	DepartmentID    string                 `json:"department_id"`
+	CustomFields    map[string]interface{} `gorm:"type:text;serializer:json" json:"custom_fields"`
+	LineItems       []TemplateLineItem     `gorm:"type:text;serializer:json" json:"line_items"`
+	CashFlows       []TemplateCashFlow     `gorm:"type:text;serializer:json" json:"cash_flows"`
+
+	CreatedByID uint      `json:"created_by_id"`
+	CreatedBy   User      `gorm:"foreignKey:CreatedByID" json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TemplateLineItem is a line item of a template, priced when a proposal is
+// created from it.
+type TemplateLineItem struct {
+	Description string  `json:"description"`
+	Category    string  `json:"category"`
+	Quantity    float64 `json:"quantity"`
+	UnitPrice   Amount  `json:"unit_price"`
+	Currency    string  `json:"currency"`
+	TaxRate     float64 `json:"tax_rate"`
+}
+
+// TemplateCashFlow is a projected cash flow of a template.
+type TemplateCashFlow struct {
+	Year    int    `json:"year"`
+	Inflow  Amount `json:"inflow"`
+	Outflow Amount `json:"outflow"`
+}
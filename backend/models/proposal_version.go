@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// ProposalVersion is a saved state of a proposal's content. Snapshot holds
+// the versioned fields as JSON; see services.ProposalSnapshot.
+type ProposalVersion struct {
+	ID          uint           `gorm:"primarykey" json:"id"`
+	ProposalID  uint           `gorm:"uniqueIndex:idx_proposal_version;not null" json:"proposal_id"`
+	Version     int            `gorm:"uniqueIndex:idx_proposal_version;not null" json:"version"`
+	Status      ProposalStatus `gorm:"type:varchar(20)" json:"status"`
+	Snapshot    string         `gorm:"type:text;not null" json:"-"`
+	ChangedByID uint           `json:"changed_by_id"`
+	ChangedBy   User           `gorm:"foreignKey:ChangedByID" json:"changed_by"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// Quotation is an offer of a vendor for the goods or work of a proposal.
+type Quotation struct {
+	ID             uint        `gorm:"primarykey" json:"id"`
+	ProposalID     uint        `gorm:"index;not null" json:"proposal_id"`
+	VendorID       uint        `gorm:"index;not null" json:"vendor_id"`
+	Vendor         Vendor      `gorm:"foreignKey:VendorID" json:"vendor"`
+	Reference      string      `json:"reference"` // the vendor's quotation number
+	Price          Amount      `gorm:"not null" json:"price"`
+	Currency       string      `gorm:"type:varchar(3);not null" json:"currency"`
+	LeadTimeDays   int         `gorm:"not null" json:"lead_time_days"`
+	WarrantyMonths int         `gorm:"not null" json:"warranty_months"`
+	AttachmentID   *uint       `json:"attachment_id"` // the quotation document, attached to the proposal
+	Attachment     *Attachment `gorm:"foreignKey:AttachmentID" json:"attachment,omitempty"`
+	Notes          string      `gorm:"type:text" json:"notes"`
+	CreatedByID    uint        `json:"created_by_id"`
+	CreatedBy      User        `gorm:"foreignKey:CreatedByID" json:"created_by"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
+// Money returns the quoted price in its currency.
+func (q *Quotation) Money() Money {
+	return NewMoney(q.Price, q.Currency)
+}
+
+// ComparisonWeights is the weight of each criterion quotations are ranked
+// by. Only their ratio matters.
+type ComparisonWeights struct {
+	Price    float64 `json:"price"`
+	LeadTime float64 `json:"lead_time"`
+	Warranty float64 `json:"warranty"`
+}
+
+// QuotationScore is the rank of one quotation in a comparison. The
+// criterion scores run from 0 for the worst quotation to 100 for the best;
+// Score is their weighted average.
+type QuotationScore struct {
+	Rank           int     `json:"rank"`
+	QuotationID    uint    `json:"quotation_id"`
+	VendorID       uint    `json:"vendor_id"`
+	VendorName     string  `json:"vendor_name"`
+	Price          Amount  `json:"price"`
+	Currency       string  `json:"currency"`
+	PriceIDR       Amount  `json:"price_idr"`
+	LeadTimeDays   int     `json:"lead_time_days"`
+	WarrantyMonths int     `json:"warranty_months"`
+	PriceScore     float64 `json:"price_score"`
+	LeadTimeScore  float64 `json:"lead_time_score"`
+	WarrantyScore  float64 `json:"warranty_score"`
+	Score          float64 `json:"score"`
+}
+
+// QuotationComparison records how procurement ranked the quotations of a
+// proposal and which one it recommends. Reason explains a recommendation
+// other than the best-ranked quotation.
+type QuotationComparison struct {
+	ID                     uint              `gorm:"primarykey" json:"id"`
+	ProposalID             uint              `gorm:"index;not null" json:"proposal_id"`
+	Weights                ComparisonWeights `gorm:"type:text;serializer:json" json:"weights"`
+	Ranking                []QuotationScore  `gorm:"type:text;serializer:json" json:"ranking"`
+	RecommendedQuotationID uint              `gorm:"not null" json:"recommended_quotation_id"`
+	RecommendedVendorID    uint              `gorm:"not null" json:"recommended_vendor_id"`
+	RecommendedVendor      Vendor            `gorm:"foreignKey:RecommendedVendorID" json:"recommended_vendor"`
+	Reason                 string            `gorm:"type:text" json:"reason"`
+	ComparedByID           uint              `json:"compared_by_id"`
+	ComparedBy             User              `gorm:"foreignKey:ComparedByID" json:"compared_by"`
+	CreatedAt              time.Time         `json:"created_at"`
+}
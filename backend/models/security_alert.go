@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+type AlertRule string
+
+const (
+	AlertNewIP                AlertRule = "new_ip"
+	AlertNewSubnet            AlertRule = "new_subnet"
+	AlertLoginBurst           AlertRule = "login_burst"
+	AlertPasswordSpray        AlertRule = "password_spray"
+	AlertSuccessAfterFailures AlertRule = "success_after_failures"
+)
+
+type AlertStatus string
+
+const (
+	AlertStatusOpen         AlertStatus = "open"
+	AlertStatusAcknowledged AlertStatus = "acknowledged"
+	AlertStatusResolved     AlertStatus = "resolved"
+)
+
+type SecurityAlert struct {
+	ID               uint        `gorm:"primarykey" json:"id"`
+	Rule             AlertRule   `gorm:"type:varchar(50);index;not null" json:"rule"`
+	Severity         string      `gorm:"type:varchar(20)" json:"severity"` // low, medium, high
+	Status           AlertStatus `gorm:"type:varchar(20);default:'open';index" json:"status"`
+	Username         string      `gorm:"index" json:"username"`
+	UserID           *uint       `json:"user_id"`
+	IPAddress        string      `json:"ip_address"`
+	LoginLogID       uint        `json:"login_log_id"`
+	Message          string      `json:"message"`
+	Details          string      `gorm:"type:text" json:"details"` // JSON
+	AcknowledgedByID *uint       `json:"acknowledged_by_id"`
+	AcknowledgedAt   *time.Time  `json:"acknowledged_at"`
+	CreatedAt        time.Time   `gorm:"index" json:"created_at"`
+	UpdatedAt        time.Time   `json:"updated_at"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// SpendRecord is an invoice paid for an approved proposal, under the
+// purchase order it was raised against. AmountIDR is the invoice amount at
+// the rate in effect on the invoice date.
+type SpendRecord struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	ProposalID    uint      `gorm:"index;not null" json:"proposal_id"`
+	PONumber      string    `gorm:"column:po_number;type:varchar(50);not null" json:"po_number"`
+	InvoiceNumber string    `gorm:"type:varchar(50)" json:"invoice_number"`
+	VendorID      *uint     `json:"vendor_id"`
+	Vendor        *Vendor   `gorm:"foreignKey:VendorID" json:"vendor,omitempty"`
+	InvoiceAmount Amount    `gorm:"not null" json:"invoice_amount"`
+	Currency      string    `gorm:"type:varchar(3);not null" json:"currency"`
+	InvoiceDate   time.Time `gorm:"not null" json:"invoice_date"`
+	ExchangeRate  Rate      `gorm:"not null" json:"exchange_rate"`
+	AmountIDR     Amount    `gorm:"column:amount_idr;not null" json:"amount_idr"`
+	Description   string    `gorm:"type:text" json:"description"`
+	CreatedByID   uint      `json:"created_by_id"`
+	CreatedBy     User      `gorm:"foreignKey:CreatedByID" json:"created_by"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Money returns the invoice amount in its currency.
+func (r *SpendRecord) Money() Money {
+	return NewMoney(r.InvoiceAmount, r.Currency)
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Vendor is a supplier that procurement requests quotations from.
+type Vendor struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Code        string    `gorm:"type:varchar(20);uniqueIndex;not null" json:"code"`
+	Name        string    `gorm:"not null" json:"name"`
+	TaxID       string    `json:"tax_id"` // NPWP
+	ContactName string    `json:"contact_name"`
+	Email       string    `json:"email"`
+	Phone       string    `json:"phone"`
+	Address     string    `gorm:"type:text" json:"address"`
+	IsActive    bool      `gorm:"not null" json:"is_active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
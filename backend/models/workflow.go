@@ -0,0 +1,29 @@
+package models
+
+// ApprovalWorkflow is the order in which roles review a submitted proposal.
+var ApprovalWorkflow = []UserRole{
+	RoleCorpFA,
+	RoleDirektur,
+	RoleSourcingAndProcurement,
+	RoleCFO,
+	RoleCEO,
+}
+
+// OverrunApprover re-approves a proposal whose actual spend exceeds its
+// approved cost by more than the configured tolerance, after the workflow.
+var OverrunApprover = RoleCFO
+
+// OverrunStep is the step of overrun re-approvals. No workflow step can
+// take it, however ApprovalWorkflow changes.
+const OverrunStep = -1
+
+// WorkflowStep returns the 1-based ApprovalWorkflow step of role, or 0 if
+// the role does not take part in the workflow.
+func WorkflowStep(role UserRole) int {
+	for i, r := range ApprovalWorkflow {
+		if r == role {
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -9,7 +9,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func SetupRoutes(app *fiber.App, authHandler *handlers.AuthHandler, proposalHandler *handlers.ProposalHandler, userHandler *handlers.UserHandler, configHandler *handlers.ConfigHandler, loginLogHandler *handlers.LoginLogHandler, jwtService *services.JWTService) {
+func SetupRoutes(app *fiber.App, authHandler *handlers.AuthHandler, proposalHandler *handlers.ProposalHandler, userHandler *handlers.UserHandler, configHandler *handlers.ConfigHandler, loginLogHandler *handlers.LoginLogHandler, loginLogArchiveHandler *handlers.LoginLogArchiveHandler, auditHandler *handlers.AuditHandler, securityAlertHandler *handlers.SecurityAlertHandler, notificationHandler *handlers.NotificationHandler, searchHandler *handlers.SearchHandler, exchangeRateHandler *handlers.ExchangeRateHandler, departmentHandler *handlers.DepartmentHandler, budgetHandler *handlers.BudgetHandler, planningCycleHandler *handlers.PlanningCycleHandler, investmentTypeHandler *handlers.InvestmentTypeHandler, proposalTemplateHandler *handlers.ProposalTemplateHandler, vendorHandler *handlers.VendorHandler, quotationHandler *handlers.QuotationHandler, spendHandler *handlers.SpendHandler, jwtService *services.JWTService) {
 	api := app.Group("/api")
 
 	// Public routes
@@ -27,12 +27,97 @@ func SetupRoutes(app *fiber.App, authHandler *handlers.AuthHandler, proposalHand
 	proposals := protected.Group("/proposals")
 	proposals.Get("/", proposalHandler.GetProposals)
 	proposals.Post("/", proposalHandler.CreateProposal)
+	proposals.Get("/export", proposalHandler.ExportProposals)
+	proposals.Post("/from-template/:id", proposalHandler.CreateFromTemplate)
 	proposals.Get("/:id", proposalHandler.GetProposal)
 	proposals.Put("/:id", proposalHandler.UpdateProposal)
 	proposals.Delete("/:id", proposalHandler.DeleteProposal)
+	proposals.Post("/:id/clone", proposalHandler.CloneProposal)
 	proposals.Post("/:id/submit", proposalHandler.SubmitProposal)
+	proposals.Post("/:id/approve", proposalHandler.ApproveProposal)
+	proposals.Post("/:id/reject", proposalHandler.RejectProposal)
+	proposals.Post("/:id/request-revision", proposalHandler.RequestRevision)
+	proposals.Post("/:id/withdraw", proposalHandler.WithdrawProposal)
+	proposals.Post("/:id/cancel", proposalHandler.CancelProposal)
+	proposals.Post("/:id/start", proposalHandler.StartProposal)
+	proposals.Post("/:id/complete", proposalHandler.CompleteProposal)
+	proposals.Post("/:id/close", proposalHandler.CloseProposal)
+	proposals.Post("/:id/approve-overrun", proposalHandler.ApproveOverrun)
+	proposals.Get("/:id/history", proposalHandler.GetProposalHistory)
+	proposals.Get("/:id/versions", proposalHandler.GetProposalVersions)
+	proposals.Get("/:id/versions/:a/diff/:b", proposalHandler.GetProposalVersionDiff)
 	proposals.Post("/:id/comments", proposalHandler.AddComment)
 
+	// Vendor quotations, compared by procurement before it approves
+	procurement := middleware.RoleMiddleware(models.RoleAdmin, models.RoleSourcingAndProcurement)
+	proposals.Get("/:id/quotations", quotationHandler.GetQuotations)
+	proposals.Post("/:id/quotations", procurement, quotationHandler.CreateQuotation)
+	proposals.Post("/:id/quotations/compare", procurement, quotationHandler.CompareQuotations)
+	proposals.Get("/:id/quotations/comparisons", quotationHandler.GetComparisons)
+	proposals.Put("/:id/quotations/:quotationId", procurement, quotationHandler.UpdateQuotation)
+	proposals.Delete("/:id/quotations/:quotationId", procurement, quotationHandler.DeleteQuotation)
+
+	// Actual spend of proposals being executed
+	proposals.Get("/:id/spend", spendHandler.GetSpendRecords)
+	proposals.Post("/:id/spend", spendHandler.CreateSpendRecord)
+	proposals.Put("/:id/spend/:spendId", spendHandler.UpdateSpendRecord)
+	proposals.Delete("/:id/spend/:spendId", spendHandler.DeleteSpendRecord)
+	proposals.Get("/:id/variance", spendHandler.GetVariance)
+
+	// Search
+	protected.Get("/search", searchHandler.Search)
+
+	// Departments proposals and users may refer to
+	protected.Get("/departments", departmentHandler.GetActiveDepartments)
+
+	// Investment types proposals may use, with their custom field schemas
+	protected.Get("/investment-types", investmentTypeHandler.GetActiveInvestmentTypes)
+
+	// Templates new proposals may start from
+	protected.Get("/proposal-templates", proposalTemplateHandler.GetActiveProposalTemplates)
+
+	// Vendors, maintained by procurement
+	vendors := protected.Group("/vendors")
+	vendors.Get("/", vendorHandler.GetVendors)
+	vendors.Get("/:id", vendorHandler.GetVendor)
+	vendors.Post("/", procurement, vendorHandler.CreateVendor)
+	vendors.Put("/:id", procurement, vendorHandler.UpdateVendor)
+	vendors.Delete("/:id", procurement, vendorHandler.DeleteVendor)
+
+	// Exchange rates, maintained by Corp FA
+	rateAdmin := middleware.RoleMiddleware(models.RoleAdmin, models.RoleCorpFA)
+	exchangeRates := protected.Group("/exchange-rates")
+	exchangeRates.Get("/", exchangeRateHandler.GetExchangeRates)
+	exchangeRates.Get("/current", exchangeRateHandler.GetCurrentExchangeRates)
+	exchangeRates.Post("/", rateAdmin, exchangeRateHandler.CreateExchangeRate)
+	exchangeRates.Post("/import", rateAdmin, exchangeRateHandler.ImportExchangeRates)
+	exchangeRates.Put("/:id", rateAdmin, exchangeRateHandler.UpdateExchangeRate)
+	exchangeRates.Delete("/:id", rateAdmin, exchangeRateHandler.DeleteExchangeRate)
+
+	// Budget consumption and spend variance; a Direktur only sees their own
+	// departments
+	budgets := protected.Group("/budgets", middleware.RoleMiddleware(models.RoleAdmin, models.RoleCorpFA, models.RoleCFO, models.RoleCEO, models.RoleDirektur))
+	budgets.Get("/consumption", budgetHandler.GetConsumption)
+	budgets.Get("/ledger", budgetHandler.GetLedger)
+	budgets.Get("/variance", spendHandler.GetVarianceReport)
+
+	// Planning cycles, maintained by Corp FA
+	cycleAdmin := middleware.RoleMiddleware(models.RoleAdmin, models.RoleCorpFA)
+	planningCycles := protected.Group("/planning-cycles")
+	planningCycles.Get("/", planningCycleHandler.GetPlanningCycles)
+	planningCycles.Get("/current", planningCycleHandler.GetCurrentPlanningCycle)
+	planningCycles.Get("/:id", planningCycleHandler.GetPlanningCycle)
+	planningCycles.Get("/:id/summary", planningCycleHandler.GetPlanningCycleSummary)
+	planningCycles.Post("/", cycleAdmin, planningCycleHandler.CreatePlanningCycle)
+	planningCycles.Put("/:id", cycleAdmin, planningCycleHandler.UpdatePlanningCycle)
+	planningCycles.Delete("/:id", cycleAdmin, planningCycleHandler.DeletePlanningCycle)
+
+	// Notification routes
+	notifications := protected.Group("/notifications")
+	notifications.Get("/", notificationHandler.GetNotifications)
+	notifications.Post("/read-all", notificationHandler.MarkAllAsRead)
+	notifications.Post("/:id/read", notificationHandler.MarkAsRead)
+
 	// Admin only routes
 	admin := protected.Group("/admin", middleware.RoleMiddleware(models.RoleAdmin))
 	
@@ -43,6 +128,30 @@ func SetupRoutes(app *fiber.App, authHandler *handlers.AuthHandler, proposalHand
 	admin.Put("/users/:id", userHandler.UpdateUser)
 	admin.Delete("/users/:id", userHandler.DeleteUser)
 	admin.Post("/users/:id/toggle-status", userHandler.ToggleUserStatus)
+
+	// Departments and capex budgets
+	admin.Get("/departments", departmentHandler.GetDepartments)
+	admin.Post("/departments", departmentHandler.CreateDepartment)
+	admin.Get("/departments/:id", departmentHandler.GetDepartment)
+	admin.Put("/departments/:id", departmentHandler.UpdateDepartment)
+	admin.Delete("/departments/:id", departmentHandler.DeleteDepartment)
+	admin.Get("/departments/:id/budgets", departmentHandler.GetBudgets)
+	admin.Put("/departments/:id/budgets/:year", departmentHandler.SetBudget)
+	admin.Delete("/departments/:id/budgets/:year", departmentHandler.DeleteBudget)
+
+	// Investment type catalog
+	admin.Get("/investment-types", investmentTypeHandler.GetInvestmentTypes)
+	admin.Post("/investment-types", investmentTypeHandler.CreateInvestmentType)
+	admin.Get("/investment-types/:id", investmentTypeHandler.GetInvestmentType)
+	admin.Put("/investment-types/:id", investmentTypeHandler.UpdateInvestmentType)
+	admin.Delete("/investment-types/:id", investmentTypeHandler.DeleteInvestmentType)
+
+	// Proposal templates
+	admin.Get("/proposal-templates", proposalTemplateHandler.GetProposalTemplates)
+	admin.Post("/proposal-templates", proposalTemplateHandler.CreateProposalTemplate)
+	admin.Get("/proposal-templates/:id", proposalTemplateHandler.GetProposalTemplate)
+	admin.Put("/proposal-templates/:id", proposalTemplateHandler.UpdateProposalTemplate)
+	admin.Delete("/proposal-templates/:id", proposalTemplateHandler.DeleteProposalTemplate)
 	
 	// LDAP Configuration
 	admin.Get("/config/ldap", configHandler.GetLDAPConfig)
@@ -53,4 +162,21 @@ func SetupRoutes(app *fiber.App, authHandler *handlers.AuthHandler, proposalHand
 	admin.Get("/logs/login", loginLogHandler.GetLoginLogs)
 	admin.Get("/logs/login/stats", loginLogHandler.GetLoginStats)
 	admin.Get("/logs/login/user/:username", loginLogHandler.GetUserLoginHistory)
+	admin.Get("/logs/login/archives", loginLogArchiveHandler.GetArchives)
+	admin.Get("/logs/login/archives/:name", loginLogArchiveHandler.DownloadArchive)
+	admin.Post("/logs/login/archives/run", loginLogArchiveHandler.RunRetention)
+
+	// Audit Trail
+	admin.Get("/audit", auditHandler.GetAuditLogs)
+	admin.Get("/audit/verify", auditHandler.VerifyAuditChain)
+	admin.Get("/audit/export", auditHandler.ExportAuditLogs)
+	admin.Get("/audit/public-key", auditHandler.GetPublicKey)
+
+	// Security Alerts
+	admin.Get("/security/alerts", securityAlertHandler.GetAlerts)
+	admin.Post("/security/alerts/:id/acknowledge", securityAlertHandler.AcknowledgeAlert)
+	admin.Post("/security/alerts/:id/resolve", securityAlertHandler.ResolveAlert)
+
+	// Search index
+	admin.Post("/search/rebuild", searchHandler.RebuildIndex)
 }
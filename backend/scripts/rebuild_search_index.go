@@ -0,0 +1,41 @@
+//go:build ignore
+
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"fui-backend/config"
+	"fui-backend/database"
+)
+
+// Rebuilds the full-text search index from proposals, comments and
+// attachments.
+//
+//	go run scripts/rebuild_search_index.go
+func main() {
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Initialize database
+	if err := database.Connect(cfg); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	// Migrate creates the index and triggers if they do not exist yet
+	if err := database.Migrate(); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	if err := database.RebuildSearchIndex(); err != nil {
+		log.Fatalf("Failed to rebuild search index: %v", err)
+	}
+
+	var count int64
+	database.GetDB().Raw("SELECT COUNT(*) FROM search_index").Scan(&count)
+	fmt.Printf("✓ Search index rebuilt: %d rows\n", count)
+}
@@ -0,0 +1,91 @@
+//go:build ignore
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"fui-backend/config"
+	"fui-backend/database"
+	"fui-backend/services"
+)
+
+func main() {
+	exportFile := flag.String("export", "", "verify a signed export file instead of the database")
+	publicKey := flag.String("pubkey", "", "expected base64 public key of the export signer")
+	genKey := flag.Bool("genkey", false, "generate a new AUDIT_SIGNING_KEY and exit")
+	flag.Parse()
+
+	if *genKey {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			log.Fatalf("Failed to generate key: %v", err)
+		}
+		fmt.Printf("AUDIT_SIGNING_KEY=%s\n", base64.StdEncoding.EncodeToString(priv.Seed()))
+		fmt.Printf("Public key: %s\n", base64.StdEncoding.EncodeToString(pub))
+		return
+	}
+
+	if *exportFile != "" {
+		data, err := os.ReadFile(*exportFile)
+		if err != nil {
+			log.Fatalf("Failed to read export: %v", err)
+		}
+
+		trailer, err := services.VerifySignedExport(data, *publicKey)
+		if err != nil {
+			fmt.Printf("✗ Export verification failed: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("✓ Export signature and chain are valid")
+		fmt.Printf("  Records: %d\n", trailer.RecordCount)
+		fmt.Printf("  Head hash: %s\n", trailer.HeadHash)
+		fmt.Printf("  Signed by: %s\n", trailer.PublicKey)
+		fmt.Printf("  Exported at: %s\n", trailer.ExportedAt)
+		return
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Initialize database
+	if err := database.Connect(cfg); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	result, err := services.NewAuditService(&cfg.Audit).Verify()
+	if err != nil {
+		log.Fatalf("Failed to verify audit chain: %v", err)
+	}
+
+	if !result.Valid {
+		b := result.FirstBreak
+		fmt.Println("✗ Audit chain is broken")
+		if b.AuditLogID != 0 {
+			fmt.Printf("  Audit entry: %d\n", b.AuditLogID)
+		}
+		if b.ApprovalID != 0 {
+			fmt.Printf("  Approval: %d\n", b.ApprovalID)
+		}
+		fmt.Printf("  Reason: %s\n", b.Reason)
+		if b.Expected != "" || b.Actual != "" {
+			fmt.Printf("  Expected: %s\n", b.Expected)
+			fmt.Printf("  Actual: %s\n", b.Actual)
+		}
+		os.Exit(1)
+	}
+
+	fmt.Println("✓ Audit chain is intact")
+	fmt.Printf("  Entries checked: %d\n", result.Checked)
+	fmt.Printf("  Head hash: %s\n", result.HeadHash)
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"fui-backend/config"
+	"fui-backend/database"
+	"fui-backend/models"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AnomalyService runs rule-based checks over login logs and stores the
+// findings as security alerts.
+type AnomalyService struct {
+	config              *config.SecurityConfig
+	notificationService *NotificationService
+	running             sync.WaitGroup
+}
+
+func NewAnomalyService(cfg *config.SecurityConfig, notificationService *NotificationService) *AnomalyService {
+	return &AnomalyService{
+		config:              cfg,
+		notificationService: notificationService,
+	}
+}
+
+// Detect evaluates a login log that has just been written in the
+// background, so a slow or failing check never holds up or fails the login
+// itself. Errors and panics are logged.
+func (s *AnomalyService) Detect(entry *models.LoginLog) {
+	copied := *entry
+	entry = &copied
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Warning: login anomaly detection panicked for login log %d: %v", entry.ID, r)
+			}
+		}()
+
+		if _, err := s.Evaluate(entry); err != nil {
+			log.Printf("Warning: login anomaly detection failed for login log %d: %v", entry.ID, err)
+		}
+	}()
+}
+
+// Wait blocks until the evaluations started by Detect have finished.
+func (s *AnomalyService) Wait() {
+	s.running.Wait()
+}
+
+// Evaluate checks a login log that has just been written and returns the
+// alerts that were raised for it.
+func (s *AnomalyService) Evaluate(entry *models.LoginLog) ([]models.SecurityAlert, error) {
+	db := database.GetDB()
+
+	checks := []func(*gorm.DB, *models.LoginLog) (*models.SecurityAlert, error){
+		s.checkLoginBurst,
+	}
+	if entry.Success {
+		checks = append(checks, s.checkNewIP, s.checkSuccessAfterFailures)
+	} else {
+		checks = append(checks, s.checkPasswordSpray)
+	}
+
+	var raised []models.SecurityAlert
+	for _, check := range checks {
+		alert, err := check(db, entry)
+		if err != nil {
+			return raised, err
+		}
+		if alert == nil {
+			continue
+		}
+
+		if err := s.raise(db, alert); err != nil {
+			return raised, err
+		}
+		raised = append(raised, *alert)
+	}
+
+	return raised, nil
+}
+
+// checkNewIP flags a successful login from an IP, or a whole subnet, the user
+// has never logged in from before. A user's very first login is not flagged.
+func (s *AnomalyService) checkNewIP(db *gorm.DB, entry *models.LoginLog) (*models.SecurityAlert, error) {
+	if entry.UserID == nil {
+		return nil, nil
+	}
+
+	previous := func() *gorm.DB {
+		return db.Model(&models.LoginLog{}).
+			Where("user_id = ? AND success = ? AND id <> ?", *entry.UserID, true, entry.ID)
+	}
+
+	var total, sameIP int64
+	if err := previous().Count(&total).Error; err != nil {
+		return nil, err
+	}
+	if total == 0 {
+		return nil, nil
+	}
+
+	if err := previous().Where("ip_address = ?", entry.IPAddress).Count(&sameIP).Error; err != nil {
+		return nil, err
+	}
+	if sameIP > 0 {
+		return nil, nil
+	}
+
+	rule, severity := models.AlertNewIP, "low"
+	message := fmt.Sprintf("%s logged in from new IP %s", entry.Username, entry.IPAddress)
+
+	if subnet := subnetOf(entry.IPAddress); subnet != "" {
+		var sameSubnet int64
+		if err := previous().Where("ip_in_cidr(ip_address, ?)", subnet).Count(&sameSubnet).Error; err != nil {
+			return nil, err
+		}
+		if sameSubnet == 0 {
+			rule, severity = models.AlertNewSubnet, "medium"
+			message = fmt.Sprintf("%s logged in from new subnet %s", entry.Username, subnet)
+		}
+	}
+
+	return newAlert(entry, rule, severity, message, map[string]interface{}{
+		"previous_logins": total,
+		"subnet":          subnetOf(entry.IPAddress),
+	}), nil
+}
+
+// checkLoginBurst flags more attempts for one username than a person could
+// make by hand, or successful logins from several IPs within minutes.
+func (s *AnomalyService) checkLoginBurst(db *gorm.DB, entry *models.LoginLog) (*models.SecurityAlert, error) {
+	window := time.Duration(s.config.BurstWindowMinutes) * time.Minute
+	since := entry.LoginAt.Add(-window)
+
+	if raised, err := s.recentlyRaised(db, models.AlertLoginBurst, "username = ?", entry.Username, since); err != nil || raised {
+		return nil, err
+	}
+
+	var attempts int64
+	err := db.Model(&models.LoginLog{}).
+		Where("username = ? AND login_at >= ?", entry.Username, since).
+		Count(&attempts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var distinctIPs int64
+	err = db.Model(&models.LoginLog{}).
+		Where("username = ? AND success = ? AND login_at >= ?", entry.Username, true, since).
+		Distinct("ip_address").Count(&distinctIPs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	details := map[string]interface{}{
+		"window_minutes": s.config.BurstWindowMinutes,
+		"attempts":       attempts,
+		"distinct_ips":   distinctIPs,
+	}
+
+	switch {
+	case s.config.BurstDistinctIPs > 0 && distinctIPs >= int64(s.config.BurstDistinctIPs):
+		return newAlert(entry, models.AlertLoginBurst, "high",
+			fmt.Sprintf("%s logged in from %d different IPs within %d minutes", entry.Username, distinctIPs, s.config.BurstWindowMinutes),
+			details), nil
+	case s.config.BurstMaxAttempts > 0 && attempts >= int64(s.config.BurstMaxAttempts):
+		return newAlert(entry, models.AlertLoginBurst, "medium",
+			fmt.Sprintf("%d login attempts for %s within %d minutes", attempts, entry.Username, s.config.BurstWindowMinutes),
+			details), nil
+	}
+
+	return nil, nil
+}
+
+// checkPasswordSpray flags one IP failing against many different usernames.
+func (s *AnomalyService) checkPasswordSpray(db *gorm.DB, entry *models.LoginLog) (*models.SecurityAlert, error) {
+	if s.config.SprayDistinctUsernames <= 0 {
+		return nil, nil
+	}
+
+	since := entry.LoginAt.Add(-time.Duration(s.config.SprayWindowMinutes) * time.Minute)
+
+	if raised, err := s.recentlyRaised(db, models.AlertPasswordSpray, "ip_address = ?", entry.IPAddress, since); err != nil || raised {
+		return nil, err
+	}
+
+	var usernames int64
+	err := db.Model(&models.LoginLog{}).
+		Where("ip_address = ? AND success = ? AND login_at >= ?", entry.IPAddress, false, since).
+		Distinct("username").Count(&usernames).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if usernames < int64(s.config.SprayDistinctUsernames) {
+		return nil, nil
+	}
+
+	return newAlert(entry, models.AlertPasswordSpray, "high",
+		fmt.Sprintf("%d usernames failed to log in from %s within %d minutes", usernames, entry.IPAddress, s.config.SprayWindowMinutes),
+		map[string]interface{}{
+			"window_minutes":     s.config.SprayWindowMinutes,
+			"distinct_usernames": usernames,
+		}), nil
+}
+
+// checkSuccessAfterFailures flags a successful login that follows a run of
+// failures for the same username since the previous success.
+func (s *AnomalyService) checkSuccessAfterFailures(db *gorm.DB, entry *models.LoginLog) (*models.SecurityAlert, error) {
+	if s.config.FailureThreshold <= 0 {
+		return nil, nil
+	}
+
+	since := entry.LoginAt.Add(-time.Duration(s.config.FailureWindowMinutes) * time.Minute)
+
+	var lastSuccess models.LoginLog
+	err := db.Where("username = ? AND success = ? AND id < ? AND login_at >= ?", entry.Username, true, entry.ID, since).
+		Order("id DESC").Limit(1).Find(&lastSuccess).Error
+	if err != nil {
+		return nil, err
+	}
+
+	query := db.Model(&models.LoginLog{}).
+		Where("username = ? AND success = ? AND id < ? AND login_at >= ?", entry.Username, false, entry.ID, since)
+	if lastSuccess.ID != 0 {
+		query = query.Where("id > ?", lastSuccess.ID)
+	}
+
+	var failures int64
+	if err := query.Count(&failures).Error; err != nil {
+		return nil, err
+	}
+
+	if failures < int64(s.config.FailureThreshold) {
+		return nil, nil
+	}
+
+	return newAlert(entry, models.AlertSuccessAfterFailures, "high",
+		fmt.Sprintf("%s logged in after %d failed attempts", entry.Username, failures),
+		map[string]interface{}{
+			"window_minutes":  s.config.FailureWindowMinutes,
+			"failed_attempts": failures,
+		}), nil
+}
+
+func (s *AnomalyService) recentlyRaised(db *gorm.DB, rule models.AlertRule, cond string, value string, since time.Time) (bool, error) {
+	var count int64
+	err := db.Model(&models.SecurityAlert{}).
+		Where("rule = ? AND created_at >= ?", rule, since.Local()). // created_at is written in local time
+		Where(cond, value).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (s *AnomalyService) raise(db *gorm.DB, alert *models.SecurityAlert) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(alert).Error; err != nil {
+			return fmt.Errorf("failed to store security alert: %w", err)
+		}
+
+		if !s.config.NotifyAlerts {
+			return nil
+		}
+
+		return s.notificationService.NotifyRoles(tx, []models.UserRole{models.RoleAdmin}, NotificationMessage{
+			Type:    "security_alert",
+			Title:   fmt.Sprintf("Security alert (%s): %s", alert.Severity, alert.Rule),
+			Message: alert.Message,
+			Link:    fmt.Sprintf("/dashboard/security/alerts?id=%d", alert.ID),
+		})
+	})
+}
+
+func newAlert(entry *models.LoginLog, rule models.AlertRule, severity, message string, details map[string]interface{}) *models.SecurityAlert {
+	raw, _ := json.Marshal(details)
+	return &models.SecurityAlert{
+		Rule:       rule,
+		Severity:   severity,
+		Status:     models.AlertStatusOpen,
+		Username:   entry.Username,
+		UserID:     entry.UserID,
+		IPAddress:  entry.IPAddress,
+		LoginLogID: entry.ID,
+		Message:    message,
+		Details:    string(raw),
+	}
+}
+
+// subnetOf returns the /24 (IPv4) or /64 (IPv6) network containing ip.
+func subnetOf(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
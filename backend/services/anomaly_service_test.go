@@ -0,0 +1,200 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"fui-backend/config"
+	"fui-backend/models"
+
+	"gorm.io/gorm"
+)
+
+func TestAnomalyRules(t *testing.T) {
+	cfg := &config.SecurityConfig{
+		BurstWindowMinutes:     5,
+		BurstMaxAttempts:       3,
+		BurstDistinctIPs:       2,
+		SprayWindowMinutes:     15,
+		SprayDistinctUsernames: 3,
+		FailureWindowMinutes:   30,
+		FailureThreshold:       3,
+	}
+	now := time.Now()
+	userID := uint(1)
+	login := func(username, ip string, success bool, ago time.Duration) models.LoginLog {
+		entry := models.LoginLog{Username: username, IPAddress: ip, Success: success, LoginAt: now.Add(-ago)}
+		if success {
+			entry.UserID = &userID
+		}
+		return entry
+	}
+	type check func(*AnomalyService, *gorm.DB, *models.LoginLog) (*models.SecurityAlert, error)
+
+	tests := []struct {
+		name     string
+		check    check
+		previous []models.LoginLog
+		entry    models.LoginLog
+		wantRule models.AlertRule // empty when no alert is raised
+	}{
+		{
+			name:     "new IP",
+			check:    (*AnomalyService).checkNewIP,
+			previous: []models.LoginLog{login("andi", "10.0.0.5", true, 24*time.Hour)},
+			entry:    login("andi", "10.0.0.9", true, 0),
+			wantRule: models.AlertNewIP,
+		},
+		{
+			name:     "new subnet",
+			check:    (*AnomalyService).checkNewIP,
+			previous: []models.LoginLog{login("andi", "10.0.0.5", true, 24*time.Hour)},
+			entry:    login("andi", "10.0.1.9", true, 0),
+			wantRule: models.AlertNewSubnet,
+		},
+		{
+			name:     "known IP",
+			check:    (*AnomalyService).checkNewIP,
+			previous: []models.LoginLog{login("andi", "10.0.0.5", true, 24*time.Hour)},
+			entry:    login("andi", "10.0.0.5", true, 0),
+		},
+		{
+			name:  "first login from a new IP",
+			check: (*AnomalyService).checkNewIP,
+			entry: login("andi", "10.0.0.9", true, 0),
+		},
+		{
+			name:     "burst of attempts",
+			check:    (*AnomalyService).checkLoginBurst,
+			previous: []models.LoginLog{login("andi", "10.0.0.5", false, 2*time.Minute), login("andi", "10.0.0.5", false, time.Minute)},
+			entry:    login("andi", "10.0.0.5", false, 0),
+			wantRule: models.AlertLoginBurst,
+		},
+		{
+			name:     "burst of IPs",
+			check:    (*AnomalyService).checkLoginBurst,
+			previous: []models.LoginLog{login("andi", "10.0.0.5", true, time.Minute)},
+			entry:    login("andi", "192.168.1.5", true, 0),
+			wantRule: models.AlertLoginBurst,
+		},
+		{
+			name:     "attempts spread beyond the burst window",
+			check:    (*AnomalyService).checkLoginBurst,
+			previous: []models.LoginLog{login("andi", "10.0.0.5", false, 20*time.Minute), login("andi", "10.0.0.5", false, 10*time.Minute)},
+			entry:    login("andi", "10.0.0.5", false, 0),
+		},
+		{
+			name:     "password spray",
+			check:    (*AnomalyService).checkPasswordSpray,
+			previous: []models.LoginLog{login("andi", "10.0.0.5", false, 5*time.Minute), login("budi", "10.0.0.5", false, 3*time.Minute)},
+			entry:    login("citra", "10.0.0.5", false, 0),
+			wantRule: models.AlertPasswordSpray,
+		},
+		{
+			name:     "failures for several usernames from other IPs",
+			check:    (*AnomalyService).checkPasswordSpray,
+			previous: []models.LoginLog{login("andi", "10.0.0.6", false, 5*time.Minute), login("budi", "10.0.0.7", false, 3*time.Minute)},
+			entry:    login("citra", "10.0.0.5", false, 0),
+		},
+		{
+			name:  "success after failures",
+			check: (*AnomalyService).checkSuccessAfterFailures,
+			previous: []models.LoginLog{
+				login("andi", "10.0.0.5", false, 3*time.Minute),
+				login("andi", "10.0.0.5", false, 2*time.Minute),
+				login("andi", "10.0.0.5", false, time.Minute),
+			},
+			entry:    login("andi", "10.0.0.5", true, 0),
+			wantRule: models.AlertSuccessAfterFailures,
+		},
+		{
+			name:  "failures before the previous success",
+			check: (*AnomalyService).checkSuccessAfterFailures,
+			previous: []models.LoginLog{
+				login("andi", "10.0.0.5", false, 4*time.Minute),
+				login("andi", "10.0.0.5", false, 3*time.Minute),
+				login("andi", "10.0.0.5", true, 2*time.Minute),
+				login("andi", "10.0.0.5", false, time.Minute),
+			},
+			entry: login("andi", "10.0.0.5", true, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			service := NewAnomalyService(cfg, nil)
+			for i := range tt.previous {
+				if err := db.Create(&tt.previous[i]).Error; err != nil {
+					t.Fatalf("seed login: %v", err)
+				}
+			}
+			entry := tt.entry
+			if err := db.Create(&entry).Error; err != nil {
+				t.Fatalf("create login: %v", err)
+			}
+
+			alert, err := tt.check(service, db, &entry)
+			if err != nil {
+				t.Fatalf("check: %v", err)
+			}
+			switch {
+			case tt.wantRule == "" && alert != nil:
+				t.Errorf("raised %s: %s, want no alert", alert.Rule, alert.Message)
+			case tt.wantRule != "" && alert == nil:
+				t.Errorf("no alert, want %s", tt.wantRule)
+			case alert != nil && (alert.Rule != tt.wantRule || alert.LoginLogID != entry.ID):
+				t.Errorf("raised %s for login log %d, want %s for %d", alert.Rule, alert.LoginLogID, tt.wantRule, entry.ID)
+			}
+		})
+	}
+}
+
+// A check that hangs or panics neither blocks nor fails the caller of
+// Detect, and its alerts are not stored.
+func TestAnomalyDetectIsolatesFailures(t *testing.T) {
+	db := openTestDB(t)
+	service := NewAnomalyService(&config.SecurityConfig{BurstWindowMinutes: 5, BurstMaxAttempts: 1}, nil)
+
+	release := make(chan struct{})
+	reached := false
+	err := db.Callback().Query().After("gorm:query").Register("test:hang_alerts", func(tx *gorm.DB) {
+		if tx.Statement.Table == "security_alerts" {
+			<-release
+			reached = true
+			panic("security_alerts unavailable")
+		}
+	})
+	if err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+	t.Cleanup(func() { db.Callback().Query().Remove("test:hang_alerts") })
+
+	entry := models.LoginLog{Username: "andi", IPAddress: "10.0.0.5"}
+	if err := db.Create(&entry).Error; err != nil {
+		t.Fatalf("create login: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		service.Detect(&entry)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Detect blocked on a hanging check")
+	}
+
+	close(release)
+	service.Wait()
+	db.Callback().Query().Remove("test:hang_alerts")
+	if !reached {
+		t.Fatal("the check never read security_alerts")
+	}
+
+	var alerts int64
+	db.Model(&models.SecurityAlert{}).Count(&alerts)
+	if alerts != 0 {
+		t.Errorf("%d alerts stored, want none", alerts)
+	}
+}
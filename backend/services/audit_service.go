@@ -0,0 +1,453 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fui-backend/config"
+	"fui-backend/database"
+	"fui-backend/models"
+	"io"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrAuditSigningKeyMissing = errors.New("audit signing key is not configured")
+
+type AuditService struct {
+	config *config.AuditConfig
+}
+
+// ChainBreak describes the first place where the audit chain or the
+// approvals table no longer matches what was recorded.
+type ChainBreak struct {
+	AuditLogID uint   `json:"audit_log_id,omitempty"`
+	ApprovalID uint   `json:"approval_id,omitempty"`
+	Reason     string `json:"reason"`
+	Expected   string `json:"expected,omitempty"`
+	Actual     string `json:"actual,omitempty"`
+}
+
+type AuditVerifyResult struct {
+	Valid      bool        `json:"valid"`
+	Checked    int64       `json:"checked"`
+	HeadHash   string      `json:"head_hash"`
+	FirstBreak *ChainBreak `json:"first_break,omitempty"`
+}
+
+// ApprovalSnapshot is the part of an approval that is stored in the audit
+// payload and compared against the approvals table during verification.
+type ApprovalSnapshot struct {
+	ID           uint            `json:"id"`
+	ProposalID   uint            `json:"proposal_id"`
+	ApproverID   uint            `json:"approver_id"`
+	ApproverRole models.UserRole `json:"approver_role"`
+	Step         int             `json:"step"`
+	Status       string          `json:"status"`
+	Comments     string          `json:"comments"`
+	ApprovedAt   *time.Time      `json:"approved_at"`
+}
+
+// AuditExportTrailer is the last line of a signed export. The signature
+// covers every byte of the export that precedes this line.
+type AuditExportTrailer struct {
+	Type         string    `json:"type"`
+	Algorithm    string    `json:"algorithm"`
+	PublicKey    string    `json:"public_key"`
+	RecordCount  int       `json:"record_count"`
+	HeadHash     string    `json:"head_hash"`
+	ExportedAt   time.Time `json:"exported_at"`
+	SignedSHA256 string    `json:"signed_sha256"`
+	Signature    string    `json:"signature"`
+}
+
+func NewAuditService(cfg *config.AuditConfig) *AuditService {
+	return &AuditService{config: cfg}
+}
+
+// Record appends an entry to the audit chain using tx, so the entry is
+// committed together with the change it describes. tx must be a
+// transaction: write transactions begin IMMEDIATE (see database.Connect),
+// so no other writer can read the same chain head before tx commits. The
+// unique index on prev_hash still rejects a second entry chained onto the
+// same row should that ever be bypassed. The audit head checkpoint is
+// moved to the new entry in tx as well.
+func (s *AuditService) Record(tx *gorm.DB, entityType string, entityID uint, action string, actorID *uint, snapshot interface{}) error {
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit payload: %w", err)
+	}
+
+	var last models.AuditLog
+	if err := tx.Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+		return fmt.Errorf("failed to read audit chain head: %w", err)
+	}
+
+	entry := models.AuditLog{
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		ActorID:    actorID,
+		Payload:    string(payload),
+		PrevHash:   last.Hash,
+		CreatedAt:  time.Now().UTC().Truncate(time.Microsecond),
+	}
+	entry.Hash = computeAuditHash(&entry)
+
+	if err := tx.Create(&entry).Error; err != nil {
+		return fmt.Errorf("failed to append audit entry: %w", err)
+	}
+
+	var head models.AuditHead
+	if err := tx.Limit(1).Find(&head, models.AuditHeadID).Error; err != nil {
+		return fmt.Errorf("failed to read audit head: %w", err)
+	}
+	head.ID, head.LastID, head.Hash = models.AuditHeadID, entry.ID, entry.Hash
+	head.Count++
+	if err := tx.Save(&head).Error; err != nil {
+		return fmt.Errorf("failed to update audit head: %w", err)
+	}
+
+	return nil
+}
+
+// RecordApproval appends the current state of an approval to the chain.
+func (s *AuditService) RecordApproval(tx *gorm.DB, approval *models.Approval, action string, actorID *uint) error {
+	return s.Record(tx, models.AuditEntityApproval, approval.ID, action, actorID, approvalSnapshot(approval))
+}
+
+// Verify walks the chain in insertion order up to the audit head, checks
+// that the chain still ends there and then that every audited approval
+// still matches its latest audited state. Entries appended while Verify
+// runs are left for the next run.
+func (s *AuditService) Verify() (*AuditVerifyResult, error) {
+	db := database.GetDB()
+	result := &AuditVerifyResult{Valid: true}
+
+	var head models.AuditHead
+	if err := db.Limit(1).Find(&head, models.AuditHeadID).Error; err != nil {
+		return nil, fmt.Errorf("failed to read audit head: %w", err)
+	}
+
+	var batch []models.AuditLog
+	var lastID uint
+	err := db.Where("id <= ?", head.LastID).FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			entry := &batch[i]
+			result.Checked++
+
+			if entry.PrevHash != result.HeadHash {
+				result.fail(&ChainBreak{
+					AuditLogID: entry.ID,
+					Reason:     "previous hash does not match the preceding entry (row edited, deleted or inserted)",
+					Expected:   result.HeadHash,
+					Actual:     entry.PrevHash,
+				})
+				return errStopVerify
+			}
+
+			if hash := computeAuditHash(entry); hash != entry.Hash {
+				result.fail(&ChainBreak{
+					AuditLogID: entry.ID,
+					Reason:     "entry content does not match its hash",
+					Expected:   hash,
+					Actual:     entry.Hash,
+				})
+				return errStopVerify
+			}
+
+			result.HeadHash = entry.Hash
+			lastID = entry.ID
+		}
+		return nil
+	}).Error
+	if err != nil && !errors.Is(err, errStopVerify) {
+		return nil, fmt.Errorf("failed to read audit chain: %w", err)
+	}
+	if !result.Valid {
+		return result, nil
+	}
+
+	if lastID != head.LastID || result.HeadHash != head.Hash || result.Checked != head.Count {
+		result.fail(&ChainBreak{
+			AuditLogID: head.LastID,
+			Reason:     fmt.Sprintf("chain ends before the audit head (%d entries checked, %d recorded; entries deleted from the end)", result.Checked, head.Count),
+			Expected:   head.Hash,
+			Actual:     result.HeadHash,
+		})
+		return result, nil
+	}
+
+	// Read the newest entry before the head, which Record moves in the same
+	// transaction, so an entry committed in between is not reported
+	var newest models.AuditLog
+	if err := db.Order("id DESC").Limit(1).Find(&newest).Error; err != nil {
+		return nil, fmt.Errorf("failed to read audit chain: %w", err)
+	}
+	if err := db.Limit(1).Find(&head, models.AuditHeadID).Error; err != nil {
+		return nil, fmt.Errorf("failed to read audit head: %w", err)
+	}
+	if newest.ID > head.LastID {
+		result.fail(&ChainBreak{
+			AuditLogID: newest.ID,
+			Reason:     "entry is past the audit head (appended outside the application)",
+			Expected:   head.Hash,
+			Actual:     newest.PrevHash,
+		})
+		return result, nil
+	}
+
+	if err := s.verifyApprovals(result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+var errStopVerify = errors.New("stop verification")
+
+func (r *AuditVerifyResult) fail(b *ChainBreak) {
+	r.Valid = false
+	r.FirstBreak = b
+}
+
+func (s *AuditService) verifyApprovals(result *AuditVerifyResult) error {
+	db := database.GetDB()
+
+	var approvals []models.Approval
+	if err := db.Order("id ASC").Find(&approvals).Error; err != nil {
+		return fmt.Errorf("failed to read approvals: %w", err)
+	}
+
+	for i := range approvals {
+		approval := &approvals[i]
+
+		var entry models.AuditLog
+		err := db.Where("entity_type = ? AND entity_id = ?", models.AuditEntityApproval, approval.ID).
+			Order("id DESC").Limit(1).Find(&entry).Error
+		if err != nil {
+			return fmt.Errorf("failed to read approval audit entries: %w", err)
+		}
+
+		if entry.ID == 0 {
+			result.fail(&ChainBreak{
+				ApprovalID: approval.ID,
+				Reason:     "approval has no audit entry",
+			})
+			return nil
+		}
+
+		expected := entry.Payload
+		actual, _ := json.Marshal(approvalSnapshot(approval))
+		if !sameJSON(expected, string(actual)) {
+			result.fail(&ChainBreak{
+				AuditLogID: entry.ID,
+				ApprovalID: approval.ID,
+				Reason:     "approval row differs from its latest audited state",
+				Expected:   expected,
+				Actual:     string(actual),
+			})
+			return nil
+		}
+	}
+
+	// Approvals are never deleted, so every audited approval must still exist
+	var missing models.AuditLog
+	err := db.Where("entity_type = ? AND entity_id NOT IN (?)", models.AuditEntityApproval, db.Model(&models.Approval{}).Select("id")).
+		Order("entity_id ASC, id DESC").Limit(1).Find(&missing).Error
+	if err != nil {
+		return fmt.Errorf("failed to read approval audit entries: %w", err)
+	}
+	if missing.ID != 0 {
+		result.fail(&ChainBreak{
+			AuditLogID: missing.ID,
+			ApprovalID: missing.EntityID,
+			Reason:     "audited approval is missing from the approvals table",
+			Expected:   missing.Payload,
+		})
+	}
+
+	return nil
+}
+
+// ExportSigned writes audit entries with an ID greater than afterID as
+// NDJSON, followed by a signature trailer line.
+func (s *AuditService) ExportSigned(w io.Writer, afterID uint) error {
+	key, err := s.signingKey()
+	if err != nil {
+		return err
+	}
+
+	var entries []models.AuditLog
+	if err := database.GetDB().Where("id > ?", afterID).Order("id ASC").Find(&entries).Error; err != nil {
+		return fmt.Errorf("failed to read audit entries: %w", err)
+	}
+
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	headHash := ""
+	for i := range entries {
+		if err := enc.Encode(&entries[i]); err != nil {
+			return fmt.Errorf("failed to encode audit entry: %w", err)
+		}
+		headHash = entries[i].Hash
+	}
+
+	digest := sha256.Sum256(body.Bytes())
+	trailer := AuditExportTrailer{
+		Type:         "signature",
+		Algorithm:    "ed25519",
+		PublicKey:    base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		RecordCount:  len(entries),
+		HeadHash:     headHash,
+		ExportedAt:   time.Now().UTC(),
+		SignedSHA256: hex.EncodeToString(digest[:]),
+		Signature:    base64.StdEncoding.EncodeToString(ed25519.Sign(key, body.Bytes())),
+	}
+
+	if _, err := w.Write(body.Bytes()); err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(&trailer)
+}
+
+// PublicKey returns the base64 encoded key external auditors use to check
+// signed exports.
+func (s *AuditService) PublicKey() (string, error) {
+	key, err := s.signingKey()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)), nil
+}
+
+func (s *AuditService) signingKey() (ed25519.PrivateKey, error) {
+	if s.config.SigningKey == "" {
+		return nil, ErrAuditSigningKeyMissing
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(s.config.SigningKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit signing key: %w", err)
+	}
+
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	default:
+		return nil, fmt.Errorf("invalid audit signing key: expected %d or %d bytes, got %d", ed25519.SeedSize, ed25519.PrivateKeySize, len(raw))
+	}
+}
+
+// VerifySignedExport checks the trailer signature of an export produced by
+// ExportSigned and that the exported entries form an unbroken chain. If
+// publicKey is empty the key embedded in the trailer is used.
+func VerifySignedExport(data []byte, publicKey string) (*AuditExportTrailer, error) {
+	trimmed := bytes.TrimRight(data, "\n")
+	cut := bytes.LastIndexByte(trimmed, '\n') + 1
+	body, trailerLine := data[:cut], trimmed[cut:]
+
+	var trailer AuditExportTrailer
+	if err := json.Unmarshal(trailerLine, &trailer); err != nil || trailer.Type != "signature" {
+		return nil, fmt.Errorf("export has no signature trailer")
+	}
+
+	if publicKey == "" {
+		publicKey = trailer.PublicKey
+	} else if publicKey != trailer.PublicKey {
+		return &trailer, fmt.Errorf("export was signed with a different key")
+	}
+
+	pub, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return &trailer, fmt.Errorf("invalid public key")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(trailer.Signature)
+	if err != nil || !ed25519.Verify(ed25519.PublicKey(pub), body, sig) {
+		return &trailer, fmt.Errorf("signature does not match export content")
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	prevHash := ""
+	first := true
+	for scanner.Scan() {
+		var entry models.AuditLog
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return &trailer, fmt.Errorf("invalid export line: %w", err)
+		}
+		if !first && entry.PrevHash != prevHash {
+			return &trailer, fmt.Errorf("chain broken at audit entry %d", entry.ID)
+		}
+		if computeAuditHash(&entry) != entry.Hash {
+			return &trailer, fmt.Errorf("audit entry %d does not match its hash", entry.ID)
+		}
+		prevHash = entry.Hash
+		first = false
+	}
+
+	return &trailer, scanner.Err()
+}
+
+func computeAuditHash(entry *models.AuditLog) string {
+	actor := ""
+	if entry.ActorID != nil {
+		actor = strconv.FormatUint(uint64(*entry.ActorID), 10)
+	}
+
+	fields := []string{
+		entry.PrevHash,
+		entry.EntityType,
+		strconv.FormatUint(uint64(entry.EntityID), 10),
+		entry.Action,
+		actor,
+		entry.Payload,
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	// Length-prefix each field so values cannot be shifted between fields.
+	h := sha256.New()
+	for _, field := range fields {
+		fmt.Fprintf(h, "%d:%s;", len(field), field)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func approvalSnapshot(a *models.Approval) ApprovalSnapshot {
+	snapshot := ApprovalSnapshot{
+		ID:           a.ID,
+		ProposalID:   a.ProposalID,
+		ApproverID:   a.ApproverID,
+		ApproverRole: a.ApproverRole,
+		Step:         a.Step,
+		Status:       a.Status,
+		Comments:     a.Comments,
+	}
+	if a.ApprovedAt != nil {
+		t := a.ApprovedAt.UTC().Truncate(time.Second)
+		snapshot.ApprovedAt = &t
+	}
+	return snapshot
+}
+
+func sameJSON(a, b string) bool {
+	var va, vb interface{}
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	ca, _ := json.Marshal(va)
+	cb, _ := json.Marshal(vb)
+	return bytes.Equal(ca, cb)
+}
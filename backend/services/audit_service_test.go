@@ -0,0 +1,203 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"fui-backend/config"
+	"fui-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// seedAuditChain records a proposal, the opening and approval of its first
+// step and a comment, and returns the entries in chain order.
+func seedAuditChain(t *testing.T, db *gorm.DB, audit *AuditService) ([]models.AuditLog, *models.Approval) {
+	t.Helper()
+
+	actor := uint(1)
+	if err := audit.Record(db, models.AuditEntityProposal, 1, "create", &actor, map[string]string{"title": "Laptops"}); err != nil {
+		t.Fatalf("record proposal: %v", err)
+	}
+	approval := models.Approval{ProposalID: 1, ApproverRole: models.RoleCorpFA, Step: 1, Status: models.ApprovalPending}
+	if err := db.Create(&approval).Error; err != nil {
+		t.Fatalf("create approval: %v", err)
+	}
+	if err := audit.RecordApproval(db, &approval, "create", &actor); err != nil {
+		t.Fatalf("record approval: %v", err)
+	}
+	now := time.Now()
+	approval.Status, approval.ApproverID, approval.ApprovedAt = models.ApprovalApproved, 2, &now
+	if err := db.Save(&approval).Error; err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	if err := audit.RecordApproval(db, &approval, models.ApprovalApproved, &approval.ApproverID); err != nil {
+		t.Fatalf("record approval decision: %v", err)
+	}
+	if err := audit.Record(db, models.AuditEntityComment, 1, "create", &actor, map[string]string{"content": "OK"}); err != nil {
+		t.Fatalf("record comment: %v", err)
+	}
+
+	var entries []models.AuditLog
+	db.Order("id ASC").Find(&entries)
+	if len(entries) != 4 {
+		t.Fatalf("%d audit entries, want 4", len(entries))
+	}
+	return entries, &approval
+}
+
+func TestAuditVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, db *gorm.DB, entries []models.AuditLog, approval *models.Approval)
+		// The first break: the entry or approval it is found at and the
+		// start of its reason. No reason when the chain is intact.
+		breakAt    func(entries []models.AuditLog, approval *models.Approval) ChainBreak
+		wantReason string
+	}{
+		{name: "intact"},
+		{
+			name: "payload edited",
+			tamper: func(t *testing.T, db *gorm.DB, entries []models.AuditLog, _ *models.Approval) {
+				db.Model(&models.AuditLog{}).Where("id = ?", entries[0].ID).UpdateColumn("payload", `{"title":"Mobil"}`)
+			},
+			breakAt: func(entries []models.AuditLog, _ *models.Approval) ChainBreak {
+				return ChainBreak{AuditLogID: entries[0].ID}
+			},
+			wantReason: "entry content does not match its hash",
+		},
+		{
+			name: "payload edited and rehashed",
+			tamper: func(t *testing.T, db *gorm.DB, entries []models.AuditLog, _ *models.Approval) {
+				entry := entries[0]
+				entry.Payload = `{"title":"Mobil"}`
+				db.Model(&entry).UpdateColumns(map[string]interface{}{"payload": entry.Payload, "hash": computeAuditHash(&entry)})
+			},
+			breakAt: func(entries []models.AuditLog, _ *models.Approval) ChainBreak {
+				return ChainBreak{AuditLogID: entries[1].ID}
+			},
+			wantReason: "previous hash does not match",
+		},
+		{
+			name: "actor edited",
+			tamper: func(t *testing.T, db *gorm.DB, entries []models.AuditLog, _ *models.Approval) {
+				db.Model(&models.AuditLog{}).Where("id = ?", entries[3].ID).UpdateColumn("actor_id", 9)
+			},
+			breakAt: func(entries []models.AuditLog, _ *models.Approval) ChainBreak {
+				return ChainBreak{AuditLogID: entries[3].ID}
+			},
+			wantReason: "entry content does not match its hash",
+		},
+		{
+			name: "row deleted",
+			tamper: func(t *testing.T, db *gorm.DB, entries []models.AuditLog, _ *models.Approval) {
+				db.Delete(&models.AuditLog{}, entries[1].ID)
+			},
+			breakAt: func(entries []models.AuditLog, _ *models.Approval) ChainBreak {
+				return ChainBreak{AuditLogID: entries[2].ID}
+			},
+			wantReason: "previous hash does not match",
+		},
+		{
+			name: "tail entry deleted",
+			tamper: func(t *testing.T, db *gorm.DB, entries []models.AuditLog, _ *models.Approval) {
+				db.Delete(&models.AuditLog{}, entries[3].ID)
+			},
+			breakAt: func(entries []models.AuditLog, _ *models.Approval) ChainBreak {
+				return ChainBreak{AuditLogID: entries[3].ID}
+			},
+			wantReason: "chain ends before the audit head",
+		},
+		{
+			name: "entry appended past the head",
+			tamper: func(t *testing.T, db *gorm.DB, entries []models.AuditLog, _ *models.Approval) {
+				last := entries[3]
+				forged := models.AuditLog{EntityType: models.AuditEntityComment, EntityID: 2, Action: "create", Payload: `{"content":"forged"}`, PrevHash: last.Hash, CreatedAt: time.Now().UTC()}
+				forged.Hash = computeAuditHash(&forged)
+				if err := db.Create(&forged).Error; err != nil {
+					t.Fatalf("append forged entry: %v", err)
+				}
+			},
+			breakAt: func(entries []models.AuditLog, _ *models.Approval) ChainBreak {
+				return ChainBreak{AuditLogID: entries[3].ID + 1}
+			},
+			wantReason: "entry is past the audit head",
+		},
+		{
+			name: "row inserted",
+			tamper: func(t *testing.T, db *gorm.DB, entries []models.AuditLog, _ *models.Approval) {
+				// Make room before the last entry and forge one there
+				last := entries[3]
+				db.Model(&models.AuditLog{}).Where("id = ?", last.ID).UpdateColumn("id", last.ID+10)
+				forged := models.AuditLog{ID: last.ID, EntityType: models.AuditEntityComment, EntityID: 2, Action: "create", Payload: `{"content":"forged"}`, PrevHash: last.Hash, CreatedAt: time.Now().UTC()}
+				forged.Hash = computeAuditHash(&forged)
+				if err := db.Create(&forged).Error; err != nil {
+					t.Fatalf("insert forged entry: %v", err)
+				}
+			},
+			breakAt: func(entries []models.AuditLog, _ *models.Approval) ChainBreak {
+				return ChainBreak{AuditLogID: entries[3].ID}
+			},
+			wantReason: "previous hash does not match",
+		},
+		{
+			name: "approval row tampered",
+			tamper: func(t *testing.T, db *gorm.DB, _ []models.AuditLog, approval *models.Approval) {
+				db.Model(approval).UpdateColumn("status", models.ApprovalRejected)
+			},
+			breakAt: func(entries []models.AuditLog, approval *models.Approval) ChainBreak {
+				return ChainBreak{AuditLogID: entries[2].ID, ApprovalID: approval.ID}
+			},
+			wantReason: "approval row differs from its latest audited state",
+		},
+		{
+			name: "approval deleted",
+			tamper: func(t *testing.T, db *gorm.DB, _ []models.AuditLog, approval *models.Approval) {
+				db.Delete(&models.Approval{}, approval.ID)
+			},
+			breakAt: func(entries []models.AuditLog, approval *models.Approval) ChainBreak {
+				return ChainBreak{AuditLogID: entries[2].ID, ApprovalID: approval.ID}
+			},
+			wantReason: "audited approval is missing from the approvals table",
+		},
+		{
+			name: "approval inserted without audit",
+			tamper: func(t *testing.T, db *gorm.DB, _ []models.AuditLog, _ *models.Approval) {
+				db.Create(&models.Approval{ID: 50, ProposalID: 1, ApproverRole: models.RoleDirektur, Step: 2, Status: models.ApprovalApproved})
+			},
+			breakAt:    func(_ []models.AuditLog, _ *models.Approval) ChainBreak { return ChainBreak{ApprovalID: 50} },
+			wantReason: "approval has no audit entry",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			audit := NewAuditService(&config.AuditConfig{})
+			entries, approval := seedAuditChain(t, db, audit)
+			if tt.tamper != nil {
+				tt.tamper(t, db, entries, approval)
+			}
+
+			result, err := audit.Verify()
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if tt.wantReason == "" {
+				if !result.Valid || result.FirstBreak != nil || result.Checked != 4 || result.HeadHash != entries[3].Hash {
+					t.Errorf("Verify() = %+v, want a valid chain of 4 ending at %s", result, entries[3].Hash)
+				}
+				return
+			}
+
+			if result.Valid || result.FirstBreak == nil {
+				t.Fatalf("Verify() = %+v, want a break", result)
+			}
+			got, want := result.FirstBreak, tt.breakAt(entries, approval)
+			if got.AuditLogID != want.AuditLogID || got.ApprovalID != want.ApprovalID || !strings.HasPrefix(got.Reason, tt.wantReason) {
+				t.Errorf("first break = entry %d, approval %d: %s; want entry %d, approval %d: %s",
+					got.AuditLogID, got.ApprovalID, got.Reason, want.AuditLogID, want.ApprovalID, tt.wantReason)
+			}
+		})
+	}
+}
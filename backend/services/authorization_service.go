@@ -0,0 +1,174 @@
+package services
+
+import (
+	"fmt"
+	"fui-backend/database"
+	"fui-backend/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// AuthorizationService holds the access policies for proposals. Handlers ask
+// it instead of checking roles themselves, so every endpoint applies the
+// same rules.
+//
+// Everyone sees their own proposals. On top of that:
+//   - Admin, CEO and CFO see every proposal
+//   - Corp FA sees every proposal that has been submitted
+//   - Direktur sees every proposal of their departments
+//   - Sourcing dan Procurement sees proposals at or past its workflow step
+type AuthorizationService struct{}
+
+// Subject is the user a decision is made for.
+type Subject struct {
+	UserID      uint
+	Role        models.UserRole
+	Departments []string
+}
+
+func NewAuthorizationService() *AuthorizationService {
+	return &AuthorizationService{}
+}
+
+// Subject loads the departments of the user: those listed on the user and
+// those the user heads. The role comes from the token, as it does for
+// RoleMiddleware.
+func (s *AuthorizationService) Subject(userID uint, role models.UserRole) (*Subject, error) {
+	db := database.GetDB()
+	var user models.User
+	if err := db.Select("id, department").First(&user, userID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+
+	var headed []string
+	if err := db.Model(&models.Department{}).Where("head_id = ?", userID).Pluck("code", &headed).Error; err != nil {
+		return nil, fmt.Errorf("failed to load departments: %w", err)
+	}
+
+	departments := splitDepartments(user.Department)
+	for _, code := range headed {
+		if !containsFold(departments, code) {
+			departments = append(departments, code)
+		}
+	}
+
+	return &Subject{
+		UserID:      userID,
+		Role:        role,
+		Departments: departments,
+	}, nil
+}
+
+// ScopeProposals limits query, which must select from investment_proposals,
+// to the proposals sub may see.
+func (s *AuthorizationService) ScopeProposals(query *gorm.DB, sub *Subject) *gorm.DB {
+	if s.seesAllProposals(sub) {
+		return query
+	}
+
+	conditions := []string{"investment_proposals.submitted_by_id = ?"}
+	args := []interface{}{sub.UserID}
+
+	switch sub.Role {
+	case models.RoleCorpFA:
+		conditions = append(conditions, "investment_proposals.status <> ?")
+		args = append(args, models.StatusDraft)
+
+	case models.RoleDirektur:
+		// Both sides upper-cased, like department codes, so values stored
+		// before departments were codes match regardless of case
+		if len(sub.Departments) > 0 {
+			conditions = append(conditions, "UPPER(investment_proposals.department_id) IN ?")
+			args = append(args, upperDepartments(sub))
+		}
+
+	case models.RoleSourcingAndProcurement:
+		conditions = append(conditions, "investment_proposals.status IN ? OR (investment_proposals.status <> ? AND investment_proposals.approval_step >= ?)")
+		args = append(args, models.FundedStatuses, models.StatusDraft, models.WorkflowStep(sub.Role))
+	}
+
+	return query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+}
+
+// CanViewProposal reports whether sub may see the proposal. It runs the same
+// scope as ScopeProposals, so lists and detail pages never disagree.
+func (s *AuthorizationService) CanViewProposal(sub *Subject, proposal *models.InvestmentProposal) (bool, error) {
+	if s.seesAllProposals(sub) || proposal.SubmittedByID == sub.UserID {
+		return true, nil
+	}
+
+	var count int64
+	query := database.GetDB().Model(&models.InvestmentProposal{}).Where("investment_proposals.id = ?", proposal.ID)
+	if err := s.ScopeProposals(query, sub).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CanEditProposal reports whether sub may change or submit the proposal.
+// Only the submitter may.
+func (s *AuthorizationService) CanEditProposal(sub *Subject, proposal *models.InvestmentProposal) bool {
+	return proposal.SubmittedByID == sub.UserID
+}
+
+// CanDeleteProposal reports whether sub may delete the proposal: the
+// submitter or an admin.
+func (s *AuthorizationService) CanDeleteProposal(sub *Subject, proposal *models.InvestmentProposal) bool {
+	return sub.Role == models.RoleAdmin || proposal.SubmittedByID == sub.UserID
+}
+
+// CanRecordSpend reports whether sub may record the actual spend of the
+// proposal: the submitter, who runs the investment, Corp FA or an admin.
+func (s *AuthorizationService) CanRecordSpend(sub *Subject, proposal *models.InvestmentProposal) bool {
+	return sub.Role == models.RoleAdmin || sub.Role == models.RoleCorpFA || proposal.SubmittedByID == sub.UserID
+}
+
+// BudgetDepartments returns the codes of the departments whose budgets sub
+// may see, or nil for every department. Admin, CEO, CFO and Corp FA see all
+// budgets, anyone else those of their own departments.
+func (s *AuthorizationService) BudgetDepartments(sub *Subject) []string {
+	switch sub.Role {
+	case models.RoleAdmin, models.RoleCEO, models.RoleCFO, models.RoleCorpFA:
+		return nil
+	}
+
+	return upperDepartments(sub)
+}
+
+func upperDepartments(sub *Subject) []string {
+	codes := make([]string, 0, len(sub.Departments))
+	for _, d := range sub.Departments {
+		codes = append(codes, strings.ToUpper(d))
+	}
+	return codes
+}
+
+func (s *AuthorizationService) seesAllProposals(sub *Subject) bool {
+	switch sub.Role {
+	case models.RoleAdmin, models.RoleCEO, models.RoleCFO:
+		return true
+	}
+	return false
+}
+
+// splitDepartments parses User.Department, which may list several
+// departments separated by commas.
+func splitDepartments(value string) []string {
+	var departments []string
+	for _, d := range strings.Split(value, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			departments = append(departments, d)
+		}
+	}
+	return departments
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
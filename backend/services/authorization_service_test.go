@@ -0,0 +1,36 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"fui-backend/models"
+)
+
+// A Direktur sees the proposals of their departments whatever the case of
+// either side, and the budgets of the same departments.
+func TestDirekturDepartmentsIgnoreCase(t *testing.T) {
+	db := openTestDB(t)
+	authz := NewAuthorizationService()
+	sub := &Subject{UserID: 99, Role: models.RoleDirektur, Departments: []string{"it", "Fin"}}
+
+	for i, department := range []string{"IT", "it", "FIN", "HR"} {
+		proposal := models.InvestmentProposal{ProposalNumber: fmt.Sprintf("INV-%d", i), Title: "P", Status: models.StatusReviewing, DepartmentID: department, SubmittedByID: 1}
+		if err := db.Create(&proposal).Error; err != nil {
+			t.Fatalf("seed proposal: %v", err)
+		}
+	}
+
+	var visible []string
+	if err := authz.ScopeProposals(db.Model(&models.InvestmentProposal{}), sub).Order("id").Pluck("department_id", &visible).Error; err != nil {
+		t.Fatalf("ScopeProposals: %v", err)
+	}
+	if want := []string{"IT", "it", "FIN"}; !reflect.DeepEqual(visible, want) {
+		t.Errorf("visible departments = %v, want %v", visible, want)
+	}
+
+	if got, want := authz.BudgetDepartments(sub), []string{"IT", "FIN"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("BudgetDepartments = %v, want %v", got, want)
+	}
+}
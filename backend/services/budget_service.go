@@ -0,0 +1,435 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"fui-backend/config"
+	"fui-backend/models"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Budget enforcement modes, see config.BudgetConfig.
+const (
+	BudgetBlock = "block"
+	BudgetWarn  = "warn"
+	BudgetOff   = "off"
+)
+
+const budgetLedgerMigration = "budget_ledger"
+
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// BudgetConsumption is how much of a department's capex budget for a fiscal
+// year is reserved by proposals in the workflow and committed by approved
+// ones. Budget and Remaining are nil when no budget is set for the year.
+type BudgetConsumption struct {
+	DepartmentID       uint           `json:"department_id"`
+	DepartmentCode     string         `json:"department_code"`
+	DepartmentName     string         `json:"department_name"`
+	Unit               string         `json:"unit"`
+	FiscalYear         int            `json:"fiscal_year"`
+	Budget             *models.Amount `json:"budget"`
+	Reserved           models.Amount  `json:"reserved"`
+	Committed          models.Amount  `json:"committed"`
+	Remaining          *models.Amount `json:"remaining"`
+	UtilizationPercent *float64       `json:"utilization_percent"` // (reserved + committed) / budget
+}
+
+// BudgetFilter narrows budget consumption and ledger queries. Zero values
+// match everything; a non-nil empty Departments matches nothing.
+type BudgetFilter struct {
+	FiscalYear  int
+	Departments []string // department codes, upper-cased
+	ProposalID  uint
+}
+
+// BudgetService keeps the budget ledger: the IDR cost of proposals reserved
+// and committed against the capex budgets of their departments.
+type BudgetService struct {
+	enforcement          string
+	fiscalYearStartMonth int
+}
+
+func NewBudgetService(cfg *config.BudgetConfig) (*BudgetService, error) {
+	enforcement := strings.ToLower(strings.TrimSpace(cfg.Enforcement))
+	switch enforcement {
+	case BudgetBlock, BudgetWarn, BudgetOff:
+	default:
+		return nil, fmt.Errorf("invalid budget enforcement %q: expected block, warn or off", cfg.Enforcement)
+	}
+	if cfg.FiscalYearStartMonth < 1 || cfg.FiscalYearStartMonth > 12 {
+		return nil, fmt.Errorf("invalid fiscal year start month %d", cfg.FiscalYearStartMonth)
+	}
+	return &BudgetService{
+		enforcement:          enforcement,
+		fiscalYearStartMonth: cfg.FiscalYearStartMonth,
+	}, nil
+}
+
+// FiscalYear returns the fiscal year whose budget the proposal draws on,
+// the one its proposal date falls in.
+func (s *BudgetService) FiscalYear(proposal *models.InvestmentProposal) int {
+	date := proposal.ProposalDate
+	if date.IsZero() {
+		date = time.Now()
+	}
+	return FiscalYear(date, s.fiscalYearStartMonth)
+}
+
+// Reserve reserves the locked IDR cost of a proposal that is being
+// submitted, after releasing anything it still held. When the cost does not
+// fit in the remaining budget, or the department has no budget for the
+// fiscal year, it fails with ErrBudgetExceeded in block mode and returns a
+// warning in warn mode. Proposals whose department is not in the master
+// data are not tracked.
+func (s *BudgetService) Reserve(tx *gorm.DB, proposal *models.InvestmentProposal, actorID uint) (string, error) {
+	if err := s.Release(tx, proposal, actorID); err != nil {
+		return "", err
+	}
+
+	department, err := s.department(tx, proposal)
+	if err != nil || department == nil || proposal.EstimatedCostIDR == nil {
+		return "", err
+	}
+
+	entry := models.BudgetLedgerEntry{
+		DepartmentID: department.ID,
+		FiscalYear:   s.FiscalYear(proposal),
+		ProposalID:   proposal.ID,
+		Kind:         models.BudgetReserve,
+		Reserved:     *proposal.EstimatedCostIDR,
+		ActorID:      &actorID,
+	}
+
+	var warning string
+	if s.enforcement != BudgetOff {
+		usage, err := s.consumption(tx, department, entry.FiscalYear)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case usage.Remaining == nil:
+			warning = fmt.Sprintf("no FY%d budget is set for %s", entry.FiscalYear, department.Code)
+		case entry.Reserved > *usage.Remaining:
+			warning = fmt.Sprintf("estimated_cost_idr %s exceeds the remaining FY%d budget of %s (%s)",
+				entry.Reserved, entry.FiscalYear, department.Code, usage.Remaining)
+		}
+		if warning != "" {
+			if s.enforcement == BudgetBlock {
+				return "", fmt.Errorf("%w: %s", ErrBudgetExceeded, warning)
+			}
+			entry.OverBudget = true
+		}
+	}
+
+	return warning, tx.Create(&entry).Error
+}
+
+// Commit turns the reservation of a proposal that received its final
+// approval into a commitment of its IDR cost.
+func (s *BudgetService) Commit(tx *gorm.DB, proposal *models.InvestmentProposal, actorID uint) error {
+	department, err := s.department(tx, proposal)
+	if err != nil {
+		return err
+	}
+	if department == nil {
+		return s.Release(tx, proposal, actorID)
+	}
+
+	year := s.FiscalYear(proposal)
+	balances, err := s.held(tx, proposal.ID)
+	if err != nil {
+		return err
+	}
+
+	entry := models.BudgetLedgerEntry{
+		DepartmentID: department.ID,
+		FiscalYear:   year,
+		ProposalID:   proposal.ID,
+		Kind:         models.BudgetCommit,
+		ActorID:      &actorID,
+	}
+	var others []heldBudget
+	for _, b := range balances {
+		if b.DepartmentID == department.ID && b.FiscalYear == year {
+			entry.Reserved = -b.Reserved
+			entry.Committed = b.Reserved
+		} else {
+			others = append(others, b)
+		}
+	}
+	if proposal.EstimatedCostIDR != nil {
+		entry.Committed = *proposal.EstimatedCostIDR
+	}
+
+	if err := s.release(tx, proposal.ID, others, actorID); err != nil {
+		return err
+	}
+	if entry.Reserved == 0 && entry.Committed == 0 {
+		return nil
+	}
+	return tx.Create(&entry).Error
+}
+
+// CommitOverrun commits the part of a re-approved overrun that goes beyond
+// what the proposal had committed.
+func (s *BudgetService) CommitOverrun(tx *gorm.DB, proposal *models.InvestmentProposal, amount models.Amount, actorID uint) error {
+	department, err := s.department(tx, proposal)
+	if err != nil || department == nil || amount <= 0 {
+		return err
+	}
+	return tx.Create(&models.BudgetLedgerEntry{
+		DepartmentID: department.ID,
+		FiscalYear:   s.FiscalYear(proposal),
+		ProposalID:   proposal.ID,
+		Kind:         models.BudgetCommit,
+		Committed:    amount,
+		ActorID:      &actorID,
+	}).Error
+}
+
+// Release gives back whatever a proposal that left the workflow still
+// holds reserved.
+func (s *BudgetService) Release(tx *gorm.DB, proposal *models.InvestmentProposal, actorID uint) error {
+	balances, err := s.held(tx, proposal.ID)
+	if err != nil {
+		return err
+	}
+	return s.release(tx, proposal.ID, balances, actorID)
+}
+
+// Consumption returns the budget, reservations and commitments of every
+// department and fiscal year that has a budget or ledger entries, latest
+// fiscal year first.
+func (s *BudgetService) Consumption(tx *gorm.DB, filter BudgetFilter) ([]BudgetConsumption, error) {
+	result := []BudgetConsumption{}
+	if filter.Departments != nil && len(filter.Departments) == 0 {
+		return result, nil
+	}
+
+	departmentQuery := tx.Model(&models.Department{})
+	if filter.Departments != nil {
+		departmentQuery = departmentQuery.Where("UPPER(code) IN ?", filter.Departments)
+	}
+	var departments []models.Department
+	if err := departmentQuery.Find(&departments).Error; err != nil {
+		return nil, err
+	}
+	if len(departments) == 0 {
+		return result, nil
+	}
+
+	byID := map[uint]*models.Department{}
+	ids := make([]uint, 0, len(departments))
+	for i := range departments {
+		byID[departments[i].ID] = &departments[i]
+		ids = append(ids, departments[i].ID)
+	}
+
+	budgetQuery := tx.Where("department_id IN ?", ids)
+	ledgerQuery := tx.Model(&models.BudgetLedgerEntry{}).Where("department_id IN ?", ids)
+	if filter.FiscalYear != 0 {
+		budgetQuery = budgetQuery.Where("fiscal_year = ?", filter.FiscalYear)
+		ledgerQuery = ledgerQuery.Where("fiscal_year = ?", filter.FiscalYear)
+	}
+
+	var budgets []models.DepartmentBudget
+	if err := budgetQuery.Find(&budgets).Error; err != nil {
+		return nil, err
+	}
+	var sums []budgetSum
+	err := ledgerQuery.
+		Select("department_id, fiscal_year, SUM(reserved) AS reserved, SUM(committed) AS committed").
+		Group("department_id, fiscal_year").
+		Scan(&sums).Error
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		departmentID uint
+		fiscalYear   int
+	}
+	rows := map[key]*BudgetConsumption{}
+	row := func(departmentID uint, year int) *BudgetConsumption {
+		k := key{departmentID, year}
+		if rows[k] == nil {
+			d := byID[departmentID]
+			rows[k] = &BudgetConsumption{
+				DepartmentID:   d.ID,
+				DepartmentCode: d.Code,
+				DepartmentName: d.Name,
+				Unit:           d.Unit,
+				FiscalYear:     year,
+			}
+		}
+		return rows[k]
+	}
+	for i := range budgets {
+		row(budgets[i].DepartmentID, budgets[i].FiscalYear).Budget = &budgets[i].Amount
+	}
+	for _, sum := range sums {
+		r := row(sum.DepartmentID, sum.FiscalYear)
+		r.Reserved, r.Committed = sum.Reserved, sum.Committed
+	}
+
+	for _, r := range rows {
+		r.complete()
+		result = append(result, *r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].FiscalYear != result[j].FiscalYear {
+			return result[i].FiscalYear > result[j].FiscalYear
+		}
+		return result[i].DepartmentCode < result[j].DepartmentCode
+	})
+	return result, nil
+}
+
+// Backfill reserves the cost of proposals that were already in the
+// workflow, and commits that of approved ones, when the ledger is first
+// introduced. It runs once.
+func (s *BudgetService) Backfill(db *gorm.DB) error {
+	var applied int64
+	if err := db.Model(&models.DataMigration{}).Where("name = ?", budgetLedgerMigration).Count(&applied).Error; err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var proposals []models.InvestmentProposal
+		err := tx.Select("id, department_id, proposal_date, status, estimated_cost_idr").
+			Where("status IN ? AND estimated_cost_idr IS NOT NULL", []models.ProposalStatus{
+				models.StatusSubmitted, models.StatusReviewing, models.StatusApproved,
+			}).
+			Find(&proposals).Error
+		if err != nil {
+			return err
+		}
+
+		var entries []models.BudgetLedgerEntry
+		for i := range proposals {
+			p := &proposals[i]
+			department, err := s.department(tx, p)
+			if err != nil {
+				return err
+			}
+			if department == nil {
+				continue
+			}
+
+			entry := models.BudgetLedgerEntry{
+				DepartmentID: department.ID,
+				FiscalYear:   s.FiscalYear(p),
+				ProposalID:   p.ID,
+				Kind:         models.BudgetReserve,
+				Reserved:     *p.EstimatedCostIDR,
+			}
+			if p.Status == models.StatusApproved {
+				entry.Kind, entry.Reserved, entry.Committed = models.BudgetCommit, 0, *p.EstimatedCostIDR
+			}
+			entries = append(entries, entry)
+		}
+
+		if len(entries) > 0 {
+			if err := tx.CreateInBatches(&entries, 500).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&models.DataMigration{Name: budgetLedgerMigration, AppliedAt: time.Now()}).Error; err != nil {
+			return err
+		}
+		log.Printf("Budget ledger started with %d proposals", len(entries))
+		return nil
+	})
+}
+
+// heldBudget is what a proposal holds reserved in one budget.
+type heldBudget struct {
+	DepartmentID uint
+	FiscalYear   int
+	Reserved     models.Amount
+}
+
+type budgetSum struct {
+	DepartmentID uint
+	FiscalYear   int
+	Reserved     models.Amount
+	Committed    models.Amount
+}
+
+// department returns the department a proposal's budget belongs to, nil
+// when its department is not in the master data.
+func (s *BudgetService) department(tx *gorm.DB, proposal *models.InvestmentProposal) (*models.Department, error) {
+	code := strings.ToUpper(strings.TrimSpace(proposal.DepartmentID))
+	if code == "" {
+		return nil, nil
+	}
+
+	var department models.Department
+	result := tx.Where("code = ?", code).Limit(1).Find(&department)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return &department, nil
+}
+
+func (s *BudgetService) consumption(tx *gorm.DB, department *models.Department, year int) (*BudgetConsumption, error) {
+	usage, err := s.Consumption(tx, BudgetFilter{FiscalYear: year, Departments: []string{department.Code}})
+	if err != nil {
+		return nil, err
+	}
+	if len(usage) == 0 {
+		return &BudgetConsumption{}, nil
+	}
+	return &usage[0], nil
+}
+
+func (s *BudgetService) held(tx *gorm.DB, proposalID uint) ([]heldBudget, error) {
+	var balances []heldBudget
+	err := tx.Model(&models.BudgetLedgerEntry{}).
+		Select("department_id, fiscal_year, SUM(reserved) AS reserved").
+		Where("proposal_id = ?", proposalID).
+		Group("department_id, fiscal_year").
+		Having("SUM(reserved) <> 0").
+		Scan(&balances).Error
+	return balances, err
+}
+
+func (s *BudgetService) release(tx *gorm.DB, proposalID uint, balances []heldBudget, actorID uint) error {
+	for _, b := range balances {
+		entry := models.BudgetLedgerEntry{
+			DepartmentID: b.DepartmentID,
+			FiscalYear:   b.FiscalYear,
+			ProposalID:   proposalID,
+			Kind:         models.BudgetRelease,
+			Reserved:     -b.Reserved,
+			ActorID:      &actorID,
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// complete fills in what is left of the budget.
+func (c *BudgetConsumption) complete() {
+	if c.Budget == nil {
+		return
+	}
+	remaining := *c.Budget - c.Reserved - c.Committed
+	c.Remaining = &remaining
+	if *c.Budget > 0 {
+		percent := math.Round(float64(c.Reserved+c.Committed)/float64(*c.Budget)*10000) / 100
+		c.UtilizationPercent = &percent
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"fui-backend/config"
+	"fui-backend/models"
+)
+
+func TestBudgetReserve(t *testing.T) {
+	tests := []struct {
+		name        string
+		enforcement string
+		budget      *models.Amount // nil when the department has no budget for the year
+		wantWarning string
+		wantBlocked bool
+	}{
+		{name: "within budget", enforcement: BudgetBlock, budget: amount(5000000_00)},
+		{name: "over budget, warn", enforcement: BudgetWarn, budget: amount(500000_00), wantWarning: "estimated_cost_idr 1000000.00 exceeds the remaining FY2026 budget of IT (500000.00)"},
+		{name: "over budget, block", enforcement: BudgetBlock, budget: amount(500000_00), wantBlocked: true},
+		{name: "no budget, warn", enforcement: BudgetWarn, wantWarning: "no FY2026 budget is set for IT"},
+		{name: "no budget, block", enforcement: BudgetBlock, wantBlocked: true},
+		{name: "no budget, off", enforcement: BudgetOff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			service, err := NewBudgetService(&config.BudgetConfig{Enforcement: tt.enforcement, FiscalYearStartMonth: 1})
+			if err != nil {
+				t.Fatalf("NewBudgetService: %v", err)
+			}
+
+			department := models.Department{Code: "IT", Name: "Information Technology", IsActive: true}
+			if err := db.Create(&department).Error; err != nil {
+				t.Fatalf("seed department: %v", err)
+			}
+			if tt.budget != nil {
+				if err := db.Create(&models.DepartmentBudget{DepartmentID: department.ID, FiscalYear: 2026, Amount: *tt.budget}).Error; err != nil {
+					t.Fatalf("seed budget: %v", err)
+				}
+			}
+			proposal := models.InvestmentProposal{
+				ProposalNumber:   "INV-1",
+				Title:            "Laptops",
+				DepartmentID:     "IT",
+				EstimatedCostIDR: amount(1000000_00),
+				ProposalDate:     time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local),
+				SubmittedByID:    1,
+			}
+			if err := db.Create(&proposal).Error; err != nil {
+				t.Fatalf("seed proposal: %v", err)
+			}
+
+			warning, err := service.Reserve(db, &proposal, 1)
+			var entries []models.BudgetLedgerEntry
+			db.Where("proposal_id = ?", proposal.ID).Find(&entries)
+			if tt.wantBlocked {
+				if !errors.Is(err, ErrBudgetExceeded) {
+					t.Errorf("Reserve() error = %v, want ErrBudgetExceeded", err)
+				}
+				if len(entries) != 0 {
+					t.Errorf("%d ledger entries after a blocked reserve, want none", len(entries))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Reserve: %v", err)
+			}
+			if warning != tt.wantWarning {
+				t.Errorf("warning = %q, want %q", warning, tt.wantWarning)
+			}
+			if len(entries) != 1 || entries[0].Reserved != 1000000_00 || entries[0].OverBudget != (tt.wantWarning != "") {
+				t.Errorf("ledger = %+v, want one reservation of 1.000.000 marked over budget only with a warning", entries)
+			}
+		})
+	}
+}
+
+func amount(a models.Amount) *models.Amount {
+	return &a
+}
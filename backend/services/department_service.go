@@ -0,0 +1,186 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"fui-backend/models"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var ErrUnknownDepartment = errors.New("unknown department")
+
+var departmentCodePattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9_-]{0,19}$`)
+
+// DepartmentInput is a department as sent by the client.
+type DepartmentInput struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Unit     string `json:"unit"`
+	HeadID   *uint  `json:"head_id"`
+	IsActive *bool  `json:"is_active"`
+}
+
+// DepartmentBudgetInput is the capex budget of one fiscal year.
+type DepartmentBudgetInput struct {
+	Amount models.Amount `json:"amount"`
+	Notes  string        `json:"notes"`
+}
+
+// DepartmentError lists why a department or budget is invalid.
+type DepartmentError struct {
+	Problems []string
+}
+
+func (e *DepartmentError) Error() string {
+	return "invalid department: " + strings.Join(e.Problems, ", ")
+}
+
+// DepartmentService validates departments and resolves the department
+// references of proposals and users.
+type DepartmentService struct{}
+
+func NewDepartmentService() *DepartmentService {
+	return &DepartmentService{}
+}
+
+// Apply validates in and copies it onto department. The code of a saved
+// department cannot change, as proposals and users refer to it.
+func (s *DepartmentService) Apply(tx *gorm.DB, department *models.Department, in DepartmentInput) error {
+	var problems []string
+
+	code := strings.ToUpper(strings.TrimSpace(in.Code))
+	switch {
+	case department.ID != 0 && code != "" && code != department.Code:
+		problems = append(problems, "code cannot be changed")
+	case department.ID == 0 && !departmentCodePattern.MatchString(code):
+		problems = append(problems, "code must be 1-20 letters, digits, - or _")
+	case department.ID == 0:
+		department.Code = code
+	}
+
+	department.Name = strings.TrimSpace(in.Name)
+	department.Unit = strings.TrimSpace(in.Unit)
+	if department.Name == "" {
+		problems = append(problems, "name is required")
+	}
+
+	if in.HeadID != nil {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("id = ? AND is_active = ?", *in.HeadID, true).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			problems = append(problems, "head must be an active user")
+		}
+	}
+	department.HeadID = in.HeadID
+	department.Head = nil
+
+	if in.IsActive != nil {
+		department.IsActive = *in.IsActive
+	} else if department.ID == 0 {
+		department.IsActive = true
+	}
+
+	if len(problems) > 0 {
+		return &DepartmentError{Problems: problems}
+	}
+
+	// Names are matched when resolving references, so they stay unique
+	// within a unit, and distinct from other departments' codes
+	var count int64
+	err := tx.Model(&models.Department{}).
+		Where("id <> ? AND ((LOWER(name) = LOWER(?) AND LOWER(unit) = LOWER(?)) OR code IN ? OR LOWER(name) = LOWER(?))",
+			department.ID, department.Name, department.Unit,
+			[]string{department.Code, strings.ToUpper(department.Name)}, department.Code).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return &DepartmentError{Problems: []string{"another department already uses this code or name"}}
+	}
+	return nil
+}
+
+// ValidateBudget checks the fiscal year and amount of a budget.
+func (s *DepartmentService) ValidateBudget(fiscalYear int, in DepartmentBudgetInput) error {
+	var problems []string
+	if fiscalYear < 2000 || fiscalYear > 2100 {
+		problems = append(problems, "fiscal year must be between 2000 and 2100")
+	}
+	if in.Amount < 0 {
+		problems = append(problems, "amount cannot be negative")
+	}
+	if len(problems) > 0 {
+		return &DepartmentError{Problems: problems}
+	}
+	return nil
+}
+
+// Resolve returns the code of the active department that value names, by
+// code or by name, ignoring case. An empty value resolves to "". A name
+// shared by departments of several units must be given as a code.
+func (s *DepartmentService) Resolve(tx *gorm.DB, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+
+	var departments []models.Department
+	err := tx.Select("code").
+		Where("is_active = ? AND (code = ? OR LOWER(name) = LOWER(?))", true, strings.ToUpper(value), value).
+		Find(&departments).Error
+	if err != nil {
+		return "", err
+	}
+	if len(departments) != 1 {
+		return "", fmt.Errorf("%w: %s", ErrUnknownDepartment, value)
+	}
+	return departments[0].Code, nil
+}
+
+// ResolveList resolves a comma-separated list of departments, as kept in
+// User.Department, and returns their codes in the same form.
+func (s *DepartmentService) ResolveList(tx *gorm.DB, value string) (string, error) {
+	var codes []string
+	seen := map[string]bool{}
+	for _, d := range splitDepartments(value) {
+		code, err := s.Resolve(tx, d)
+		if err != nil {
+			return "", err
+		}
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	return strings.Join(codes, ", "), nil
+}
+
+// InUse reports whether proposals or users refer to the department.
+func (s *DepartmentService) InUse(tx *gorm.DB, department *models.Department) (bool, error) {
+	var proposals int64
+	if err := tx.Model(&models.InvestmentProposal{}).Where("department_id = ?", department.Code).Count(&proposals).Error; err != nil {
+		return false, err
+	}
+	if proposals > 0 {
+		return true, nil
+	}
+
+	var users []string
+	if err := tx.Model(&models.User{}).Where("department <> ''").Pluck("department", &users).Error; err != nil {
+		return false, err
+	}
+	for _, value := range users {
+		for _, d := range splitDepartments(value) {
+			if strings.EqualFold(d, department.Code) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
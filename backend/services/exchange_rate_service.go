@@ -0,0 +1,251 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"fui-backend/models"
+	"io"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxExchangeRateImportRows = 5000
+
+var ErrNoExchangeRate = errors.New("no exchange rate")
+
+// ExchangeRateInput is an exchange rate as sent by the client or read from
+// one CSV line. EffectiveDate is YYYY-MM-DD.
+type ExchangeRateInput struct {
+	Currency      string      `json:"currency"`
+	EffectiveDate string      `json:"effective_date"`
+	Rate          models.Rate `json:"rate"`
+}
+
+// ExchangeRateError lists the exchange rates of a request that are invalid.
+type ExchangeRateError struct {
+	Problems []string
+}
+
+func (e *ExchangeRateError) Error() string {
+	return "invalid exchange rates: " + strings.Join(e.Problems, ", ")
+}
+
+// ExchangeRateService keeps the IDR exchange rates and converts proposal
+// costs with them.
+type ExchangeRateService struct{}
+
+func NewExchangeRateService() *ExchangeRateService {
+	return &ExchangeRateService{}
+}
+
+// Build validates an input and returns it as an exchange rate.
+func (s *ExchangeRateService) Build(in ExchangeRateInput, source string, actorID uint) (models.ExchangeRate, error) {
+	rate, problems := buildExchangeRate(in)
+	if len(problems) > 0 {
+		return rate, &ExchangeRateError{Problems: problems}
+	}
+	rate.Source = source
+	rate.CreatedByID = actorID
+	return rate, nil
+}
+
+// ParseCSV reads a file with the columns currency, effective_date and rate,
+// in any order after a header line. Every line must be valid.
+func (s *ExchangeRateService) ParseCSV(r io.Reader, actorID uint) ([]models.ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, &ExchangeRateError{Problems: []string{"file is empty or not CSV"}}
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range []string{"currency", "effective_date", "rate"} {
+		if _, ok := columns[name]; !ok {
+			return nil, &ExchangeRateError{Problems: []string{"missing column " + name}}
+		}
+	}
+
+	var problems []string
+	var rates []models.ExchangeRate
+	seen := map[string]int{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("line %d: %v", line, err))
+			break
+		}
+		if line-1 > maxExchangeRateImportRows {
+			problems = append(problems, fmt.Sprintf("at most %d rates can be imported at once", maxExchangeRateImportRows))
+			break
+		}
+
+		in := ExchangeRateInput{
+			Currency:      csvField(record, columns["currency"]),
+			EffectiveDate: csvField(record, columns["effective_date"]),
+		}
+		rate, err := models.ParseRate(csvField(record, columns["rate"]))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("line %d: rate must be a number", line))
+			continue
+		}
+		in.Rate = rate
+
+		r, lineProblems := buildExchangeRate(in)
+		for _, p := range lineProblems {
+			problems = append(problems, fmt.Sprintf("line %d: %s", line, p))
+		}
+		if len(lineProblems) > 0 {
+			continue
+		}
+
+		key := r.Currency + " " + in.EffectiveDate
+		if first, ok := seen[key]; ok {
+			problems = append(problems, fmt.Sprintf("line %d: %s on %s is already on line %d", line, r.Currency, in.EffectiveDate, first))
+			continue
+		}
+		seen[key] = line
+
+		r.Source = models.ExchangeRateCSV
+		r.CreatedByID = actorID
+		rates = append(rates, r)
+	}
+
+	if len(problems) > 0 {
+		return nil, &ExchangeRateError{Problems: problems}
+	}
+	if len(rates) == 0 {
+		return nil, &ExchangeRateError{Problems: []string{"file has no rates"}}
+	}
+	return rates, nil
+}
+
+// Import stores rates, replacing the rate of a currency on a date that
+// already has one.
+func (s *ExchangeRateService) Import(tx *gorm.DB, rates []models.ExchangeRate) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "currency"}, {Name: "effective_date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "created_by_id", "updated_at"}),
+	}).CreateInBatches(&rates, 500).Error
+}
+
+// Current returns the rate in effect today of every currency, ordered by
+// currency.
+func (s *ExchangeRateService) Current(tx *gorm.DB) ([]models.ExchangeRate, error) {
+	var rates []models.ExchangeRate
+	err := tx.Preload("CreatedBy").
+		Where("effective_date <= ?", time.Now()).
+		Order("currency ASC, effective_date DESC").
+		Find(&rates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	current := []models.ExchangeRate{}
+	for _, r := range rates {
+		if n := len(current); n == 0 || current[n-1].Currency != r.Currency {
+			current = append(current, r)
+		}
+	}
+	return current, nil
+}
+
+// RateAt returns the rate of currency in effect at t. IDR is always 1.
+func (s *ExchangeRateService) RateAt(tx *gorm.DB, currency string, t time.Time) (models.Rate, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" || currency == models.BaseCurrency {
+		return models.RateOne, nil
+	}
+
+	var rate models.ExchangeRate
+	result := tx.Where("currency = ? AND effective_date <= ?", currency, t).
+		Order("effective_date DESC").Limit(1).
+		Find(&rate)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, fmt.Errorf("%w for %s on %s", ErrNoExchangeRate, currency, t.Format("2006-01-02"))
+	}
+	return rate.Rate, nil
+}
+
+// ToIDR returns money in IDR at the rate of its currency in effect at t,
+// and that rate.
+func (s *ExchangeRateService) ToIDR(tx *gorm.DB, money models.Money, t time.Time) (models.Amount, models.Rate, error) {
+	rate, err := s.RateAt(tx, money.Currency, t)
+	if err != nil {
+		return 0, 0, err
+	}
+	amount, err := rate.Apply(money.Amount)
+	if err != nil {
+		return 0, 0, err
+	}
+	return amount, rate, nil
+}
+
+// Convert returns the proposal's estimated cost in IDR at the rate in
+// effect at t, and that rate.
+func (s *ExchangeRateService) Convert(tx *gorm.DB, proposal *models.InvestmentProposal, t time.Time) (models.Amount, models.Rate, error) {
+	return s.ToIDR(tx, proposal.Cost(), t)
+}
+
+// Quote sets the indicative IDR cost of a proposal that is being edited, at
+// today's rate, and releases a rate locked by an earlier submit. The cost
+// is left empty when the currency has no rate yet.
+func (s *ExchangeRateService) Quote(tx *gorm.DB, proposal *models.InvestmentProposal) error {
+	proposal.EstimatedCostIDR, proposal.ExchangeRate, proposal.RateLockedAt = nil, nil, nil
+
+	cost, rate, err := s.Convert(tx, proposal, time.Now())
+	if errors.Is(err, ErrNoExchangeRate) || errors.Is(err, models.ErrAmountOverflow) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	proposal.EstimatedCostIDR, proposal.ExchangeRate = &cost, &rate
+	return nil
+}
+
+func buildExchangeRate(in ExchangeRateInput) (models.ExchangeRate, []string) {
+	var problems []string
+	rate := models.ExchangeRate{
+		Currency: strings.ToUpper(strings.TrimSpace(in.Currency)),
+		Rate:     in.Rate,
+	}
+
+	switch {
+	case len(rate.Currency) != 3 || strings.Trim(rate.Currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "":
+		problems = append(problems, "currency must be a 3-letter ISO code")
+	case rate.Currency == models.BaseCurrency:
+		problems = append(problems, "IDR is the base currency and has no rate")
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(in.EffectiveDate), time.Local)
+	if err != nil {
+		problems = append(problems, "effective_date must be YYYY-MM-DD")
+	}
+	rate.EffectiveDate = date
+
+	if rate.Rate <= 0 {
+		problems = append(problems, "rate must be greater than 0")
+	}
+	return rate, problems
+}
+
+func csvField(record []string, i int) string {
+	if i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
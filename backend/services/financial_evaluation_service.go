@@ -0,0 +1,251 @@
+package services
+
+import (
+	"fmt"
+	"fui-backend/config"
+	"fui-backend/models"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxCashFlowYears = 50
+
+	// IRR is searched between these rates, as fractions
+	irrMinRate  = -0.99
+	irrMaxRate  = 10.0
+	irrScanStep = 0.01
+)
+
+// CashFlowInput is one projected year as sent by the client.
+type CashFlowInput struct {
+	Year    int           `json:"year"`
+	Inflow  models.Amount `json:"inflow"`
+	Outflow models.Amount `json:"outflow"`
+}
+
+// CashFlowError lists the cash flows of a request that are invalid.
+type CashFlowError struct {
+	Problems []string
+}
+
+func (e *CashFlowError) Error() string {
+	return "invalid cash flows: " + strings.Join(e.Problems, ", ")
+}
+
+// FinancialEvaluationService computes NPV, IRR, payback period and ROI of a
+// proposal from its projected cash flows, discounted at the WACC of the
+// proposal's investment type.
+type FinancialEvaluationService struct {
+	defaultWACC float64
+}
+
+func NewFinancialEvaluationService(cfg *config.FinanceConfig) (*FinancialEvaluationService, error) {
+	if !validWACC(cfg.DefaultWACC) {
+		return nil, fmt.Errorf("invalid DEFAULT_WACC %v: expected a percentage from 0 to 100", cfg.DefaultWACC)
+	}
+	return &FinancialEvaluationService{defaultWACC: cfg.DefaultWACC}, nil
+}
+
+// DiscountRate returns the WACC, in percent, of the investment type with
+// the given code: its own, or the default one.
+func (s *FinancialEvaluationService) DiscountRate(tx *gorm.DB, investmentType string) (float64, error) {
+	var types []models.InvestmentType
+	if err := tx.Where("code = ?", investmentType).Limit(1).Find(&types).Error; err != nil {
+		return 0, err
+	}
+	if len(types) == 1 && types[0].WACC != nil {
+		return *types[0].WACC, nil
+	}
+	return s.defaultWACC, nil
+}
+
+// BuildCashFlows validates inputs and returns them as cash flows ordered by
+// year.
+func (s *FinancialEvaluationService) BuildCashFlows(proposal *models.InvestmentProposal, inputs []CashFlowInput) ([]models.ProposalCashFlow, error) {
+	var problems []string
+	seen := map[int]bool{}
+	flows := make([]models.ProposalCashFlow, 0, len(inputs))
+	for _, in := range inputs {
+		switch {
+		case in.Year < 0 || in.Year > maxCashFlowYears:
+			problems = append(problems, fmt.Sprintf("year %d: must be between 0 and %d", in.Year, maxCashFlowYears))
+			continue
+		case seen[in.Year]:
+			problems = append(problems, fmt.Sprintf("year %d: listed more than once", in.Year))
+			continue
+		}
+		seen[in.Year] = true
+
+		if in.Inflow < 0 || in.Outflow < 0 {
+			problems = append(problems, fmt.Sprintf("year %d: inflow and outflow cannot be negative", in.Year))
+		}
+		flows = append(flows, models.ProposalCashFlow{
+			ProposalID: proposal.ID,
+			Year:       in.Year,
+			Inflow:     in.Inflow,
+			Outflow:    in.Outflow,
+		})
+	}
+
+	if len(problems) > 0 {
+		return nil, &CashFlowError{Problems: problems}
+	}
+	sort.Slice(flows, func(i, j int) bool { return flows[i].Year < flows[j].Year })
+	return flows, nil
+}
+
+// ReplaceCashFlows swaps the stored cash flows of the proposal for
+// proposal.CashFlows.
+func (s *FinancialEvaluationService) ReplaceCashFlows(tx *gorm.DB, proposal *models.InvestmentProposal) error {
+	if err := tx.Where("proposal_id = ?", proposal.ID).Delete(&models.ProposalCashFlow{}).Error; err != nil {
+		return err
+	}
+	if len(proposal.CashFlows) == 0 {
+		return nil
+	}
+
+	for i := range proposal.CashFlows {
+		proposal.CashFlows[i].ID = 0
+		proposal.CashFlows[i].ProposalID = proposal.ID
+	}
+	return tx.Create(&proposal.CashFlows).Error
+}
+
+// Evaluate sets proposal.Financials from proposal.CashFlows and the WACC of
+// its investment type, and records that WACC as the discount rate. A
+// proposal without cash flows has no evaluation.
+func (s *FinancialEvaluationService) Evaluate(tx *gorm.DB, proposal *models.InvestmentProposal) error {
+	if len(proposal.CashFlows) == 0 {
+		proposal.Financials = models.FinancialEvaluation{}
+		return nil
+	}
+
+	rate, err := s.DiscountRate(tx, proposal.InvestmentType)
+	if err != nil {
+		return err
+	}
+
+	// net[t] is the net cash flow of year t; missing years are zero
+	last := proposal.CashFlows[len(proposal.CashFlows)-1].Year
+	net := make([]float64, last+1)
+	var inflow, outflow float64
+	for _, f := range proposal.CashFlows {
+		net[f.Year] += f.Inflow.Float64() - f.Outflow.Float64()
+		inflow += f.Inflow.Float64()
+		outflow += f.Outflow.Float64()
+	}
+
+	now := time.Now()
+	eval := models.FinancialEvaluation{
+		DiscountRate: &rate,
+		EvaluatedAt:  &now,
+	}
+	if npv, err := models.AmountFromFloat(netPresentValue(net, rate/100)); err == nil {
+		eval.NPV = &npv
+	}
+	if irr, ok := internalRateOfReturn(net); ok {
+		eval.IRR = floatPtr(round2(irr * 100))
+	}
+	if payback, ok := paybackPeriod(net); ok {
+		eval.PaybackYears = floatPtr(round2(payback))
+	}
+	if outflow > 0 {
+		eval.ROI = floatPtr(round2((inflow - outflow) / outflow * 100))
+	}
+	proposal.Financials = eval
+	return nil
+}
+
+// CashFlowInputs turns stored cash flows back into inputs.
+func CashFlowInputs(flows []models.ProposalCashFlow) []CashFlowInput {
+	inputs := make([]CashFlowInput, len(flows))
+	for i, f := range flows {
+		inputs[i] = CashFlowInput{Year: f.Year, Inflow: f.Inflow, Outflow: f.Outflow}
+	}
+	return inputs
+}
+
+func netPresentValue(net []float64, rate float64) float64 {
+	var npv float64
+	for t, v := range net {
+		npv += v / math.Pow(1+rate, float64(t))
+	}
+	return npv
+}
+
+// internalRateOfReturn returns the lowest rate at which the NPV is zero.
+// Cash flows that never change sign have none.
+func internalRateOfReturn(net []float64) (float64, bool) {
+	var positive, negative bool
+	for _, v := range net {
+		positive = positive || v > 0
+		negative = negative || v < 0
+	}
+	if !positive || !negative {
+		return 0, false
+	}
+
+	lo := irrMinRate
+	loNPV := netPresentValue(net, lo)
+	for hi := lo + irrScanStep; hi <= irrMaxRate; hi += irrScanStep {
+		hiNPV := netPresentValue(net, hi)
+		if hiNPV == 0 {
+			return hi, true
+		}
+		if (loNPV < 0) != (hiNPV < 0) {
+			return bisectRate(net, lo, hi, loNPV), true
+		}
+		lo, loNPV = hi, hiNPV
+	}
+	return 0, false
+}
+
+func bisectRate(net []float64, lo, hi, loNPV float64) float64 {
+	for i := 0; i < 100 && hi-lo > 1e-10; i++ {
+		mid := (lo + hi) / 2
+		midNPV := netPresentValue(net, mid)
+		if (midNPV < 0) == (loNPV < 0) {
+			lo, loNPV = mid, midNPV
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// paybackPeriod returns when the cumulative net cash flow turns
+// non-negative for good, interpolating within the year it does.
+func paybackPeriod(net []float64) (float64, bool) {
+	cumulative := 0.0
+	recovered, at := true, 0.0
+	for t, v := range net {
+		previous := cumulative
+		cumulative += v
+		switch {
+		case cumulative < 0:
+			recovered = false
+		case !recovered:
+			recovered, at = true, float64(t-1)-previous/v
+		}
+	}
+	return at, recovered
+}
+
+// validWACC reports whether a WACC, in percent, is usable as a discount
+// rate.
+func validWACC(rate float64) bool {
+	return rate >= 0 && rate <= 100
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
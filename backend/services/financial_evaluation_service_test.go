@@ -0,0 +1,81 @@
+package services
+
+import (
+	"testing"
+
+	"fui-backend/config"
+	"fui-backend/models"
+)
+
+func TestEvaluateDiscountsAtInvestmentTypeWACC(t *testing.T) {
+	db := openTestDB(t)
+	types := NewInvestmentTypeService()
+	finance, err := NewFinancialEvaluationService(&config.FinanceConfig{DefaultWACC: 10})
+	if err != nil {
+		t.Fatalf("NewFinancialEvaluationService: %v", err)
+	}
+
+	twelve := 12.0
+	for _, it := range []models.InvestmentType{
+		{Code: "IT", Name: "Teknologi Informasi", WACC: &twelve, IsActive: true},
+		{Code: "OPERASIONAL", Name: "Operasional", IsActive: true},
+		{Code: "RENOVASI_GEDUNG", Name: "Renovasi Gedung", IsActive: true},
+	} {
+		if err := db.Create(&it).Error; err != nil {
+			t.Fatalf("seed investment type: %v", err)
+		}
+	}
+
+	// Copied once: the IT type keeps its own WACC, unknown types are skipped
+	if err := types.SeedWACC(db, "IT=15; operasional=9.5;Renovasi Gedung=8;Unknown=7"); err != nil {
+		t.Fatalf("SeedWACC: %v", err)
+	}
+	if err := types.SeedWACC(db, "Operasional=20"); err != nil {
+		t.Fatalf("SeedWACC again: %v", err)
+	}
+
+	flows := []models.ProposalCashFlow{
+		{Year: 0, Outflow: models.Amount(100000_00)},
+		{Year: 1, Inflow: models.Amount(60000_00)},
+		{Year: 2, Inflow: models.Amount(60000_00)},
+	}
+	tests := []struct {
+		investmentType string
+		wantRate       float64
+	}{
+		{"IT", 12},
+		{"OPERASIONAL", 9.5},
+		{"RENOVASI_GEDUNG", 8},
+		{"UNCATALOGUED", 10},
+		{"", 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.investmentType, func(t *testing.T) {
+			proposal := models.InvestmentProposal{InvestmentType: tt.investmentType, CashFlows: flows}
+			if err := finance.Evaluate(db, &proposal); err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+
+			eval := proposal.Financials
+			if eval.DiscountRate == nil || *eval.DiscountRate != tt.wantRate {
+				t.Fatalf("discount_rate = %v, want %v", eval.DiscountRate, tt.wantRate)
+			}
+			r := tt.wantRate / 100
+			want, _ := models.AmountFromFloat(-100000 + 60000/(1+r) + 60000/((1+r)*(1+r)))
+			if eval.NPV == nil || *eval.NPV != want {
+				t.Errorf("npv = %v, want %v", eval.NPV, want)
+			}
+		})
+	}
+}
+
+func TestSeedWACCRejectsInvalidRates(t *testing.T) {
+	db := openTestDB(t)
+	types := NewInvestmentTypeService()
+
+	for _, value := range []string{"IT", "IT=abc", "IT=-1", "IT=101"} {
+		if err := types.SeedWACC(db, value); err == nil {
+			t.Errorf("SeedWACC(%q) succeeded, want an error", value)
+		}
+	}
+}
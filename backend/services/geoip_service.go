@@ -0,0 +1,155 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"fui-backend/config"
+	"fui-backend/models"
+	"net"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoIPService enriches login logs with parsed user-agent data, country and
+// city from a local MaxMind-format database, and the site name of internal
+// networks. It never makes network calls.
+type GeoIPService struct {
+	reader *maxminddb.Reader
+	sites  []siteNetwork
+}
+
+type siteNetwork struct {
+	network *net.IPNet
+	name    string
+}
+
+type geoRecord struct {
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+var privateNetworks = mustParseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7")
+
+// NewGeoIPService always returns a usable service. If the site networks are
+// invalid or the .mmdb file cannot be opened, the errors of both are
+// returned and only the lookups that failed are skipped.
+func NewGeoIPService(cfg *config.GeoIPConfig) (*GeoIPService, error) {
+	s := &GeoIPService{}
+
+	var errs []error
+	sites, err := parseSiteNetworks(cfg.SiteNetworks)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	s.sites = sites
+
+	if cfg.DatabasePath != "" {
+		reader, err := maxminddb.Open(cfg.DatabasePath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to open GeoIP database: %w", err))
+		} else {
+			s.reader = reader
+		}
+	}
+
+	return s, errors.Join(errs...)
+}
+
+// Enrich fills the user-agent, location and site fields of entry.
+func (s *GeoIPService) Enrich(entry *models.LoginLog) {
+	ua := ParseUserAgent(entry.UserAgent)
+	entry.Browser = ua.Browser
+	entry.OS = ua.OS
+	entry.DeviceType = ua.DeviceType
+
+	ip := net.ParseIP(entry.IPAddress)
+	if ip == nil {
+		return
+	}
+
+	if ip.IsLoopback() {
+		entry.Site = "Localhost"
+		return
+	}
+
+	if isPrivateIP(ip) {
+		entry.Site = "Internal"
+		for _, site := range s.sites {
+			if site.network.Contains(ip) {
+				entry.Site = site.name
+				break
+			}
+		}
+		return
+	}
+
+	if s.reader == nil {
+		return
+	}
+
+	var record geoRecord
+	if err := s.reader.Lookup(ip, &record); err != nil {
+		return
+	}
+	entry.CountryCode = record.Country.ISOCode
+	entry.Country = record.Country.Names["en"]
+	entry.City = record.City.Names["en"]
+}
+
+func (s *GeoIPService) Close() error {
+	if s.reader == nil {
+		return nil
+	}
+	return s.reader.Close()
+}
+
+// parseSiteNetworks parses "10.101.0.0/16=RS Bekasi Barat;10.102.0.0/16=RS
+// Kelapa Gading". More specific networks should be listed first.
+func parseSiteNetworks(value string) ([]siteNetwork, error) {
+	var sites []siteNetwork
+	for _, part := range strings.Split(value, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		cidr, name, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid site network %q: expected CIDR=Name", part)
+		}
+
+		_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid site network %q: %w", part, err)
+		}
+		sites = append(sites, siteNetwork{network: network, name: strings.TrimSpace(name)})
+	}
+	return sites, nil
+}
+
+func isPrivateIP(ip net.IP) bool {
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
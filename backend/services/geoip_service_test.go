@@ -0,0 +1,68 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fui-backend/config"
+	"fui-backend/models"
+)
+
+func TestNewGeoIPServiceReportsEveryError(t *testing.T) {
+	tests := []struct {
+		name         string
+		siteNetworks string
+		databasePath string
+		wantErrors   []string
+		wantSite     string // of 10.101.1.1
+	}{
+		{
+			name:         "valid sites without a database",
+			siteNetworks: "10.101.0.0/16=RS Bekasi Barat",
+			wantSite:     "RS Bekasi Barat",
+		},
+		{
+			name:         "valid sites, missing database",
+			siteNetworks: "10.101.0.0/16=RS Bekasi Barat",
+			databasePath: "missing.mmdb",
+			wantErrors:   []string{"failed to open GeoIP database"},
+			wantSite:     "RS Bekasi Barat",
+		},
+		{
+			name:         "malformed sites, missing database",
+			siteNetworks: "10.101.0.0/16 RS Bekasi Barat",
+			databasePath: "missing.mmdb",
+			wantErrors:   []string{"invalid site network", "failed to open GeoIP database"},
+			wantSite:     "Internal",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.GeoIPConfig{SiteNetworks: tt.siteNetworks}
+			if tt.databasePath != "" {
+				cfg.DatabasePath = filepath.Join(t.TempDir(), tt.databasePath)
+			}
+
+			s, err := NewGeoIPService(cfg)
+			if s == nil {
+				t.Fatal("NewGeoIPService returned no service")
+			}
+			defer s.Close()
+			if len(tt.wantErrors) == 0 && err != nil {
+				t.Errorf("error = %v, want none", err)
+			}
+			for _, want := range tt.wantErrors {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("error = %v, want it to contain %q", err, want)
+				}
+			}
+
+			entry := models.LoginLog{IPAddress: "10.101.1.1"}
+			s.Enrich(&entry)
+			if entry.Site != tt.wantSite {
+				t.Errorf("site = %q, want %q", entry.Site, tt.wantSite)
+			}
+		})
+	}
+}
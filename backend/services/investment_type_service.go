@@ -0,0 +1,596 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fui-backend/models"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+const (
+	investmentTypeMigration = "investment_types"
+	waccMigration           = "investment_type_wacc"
+)
+
+var ErrUnknownInvestmentType = errors.New("unknown investment type")
+
+var (
+	investmentTypeCodePattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9_-]{0,29}$`)
+	customFieldNamePattern    = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+	nonCodeChars              = regexp.MustCompile(`[^A-Z0-9]+`)
+)
+
+// InvestmentTypeInput is a catalog entry as sent by the client.
+type InvestmentTypeInput struct {
+	Code        string             `json:"code"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	FieldSchema models.FieldSchema `json:"field_schema"`
+	WACC        *float64           `json:"wacc"` // percent, null for DEFAULT_WACC
+	IsActive    *bool              `json:"is_active"`
+}
+
+// InvestmentTypeError lists why a catalog entry is invalid.
+type InvestmentTypeError struct {
+	Problems []string
+}
+
+func (e *InvestmentTypeError) Error() string {
+	return "invalid investment type: " + strings.Join(e.Problems, ", ")
+}
+
+// CustomFieldError lists why the custom fields of a proposal do not match
+// the schema of its investment type.
+type CustomFieldError struct {
+	Problems []string
+}
+
+func (e *CustomFieldError) Error() string {
+	return "invalid custom fields: " + strings.Join(e.Problems, ", ")
+}
+
+// InvestmentTypeService maintains the investment type catalog and checks
+// the custom fields of proposals against it.
+type InvestmentTypeService struct{}
+
+func NewInvestmentTypeService() *InvestmentTypeService {
+	return &InvestmentTypeService{}
+}
+
+// Apply validates in and copies it onto t. The code of a saved type cannot
+// change, as proposals refer to it. A new schema applies to proposals when
+// they are next saved or submitted.
+func (s *InvestmentTypeService) Apply(tx *gorm.DB, t *models.InvestmentType, in InvestmentTypeInput) error {
+	var problems []string
+
+	code := strings.ToUpper(strings.TrimSpace(in.Code))
+	switch {
+	case t.ID != 0 && code != "" && code != t.Code:
+		problems = append(problems, "code cannot be changed")
+	case t.ID == 0 && !investmentTypeCodePattern.MatchString(code):
+		problems = append(problems, "code must be 1-30 letters, digits, - or _")
+	case t.ID == 0:
+		t.Code = code
+	}
+
+	t.Name = strings.TrimSpace(in.Name)
+	if t.Name == "" {
+		problems = append(problems, "name is required")
+	}
+	t.Description = strings.TrimSpace(in.Description)
+
+	t.FieldSchema = in.FieldSchema
+	problems = append(problems, validateFieldSchema(&t.FieldSchema)...)
+
+	t.WACC = in.WACC
+	if t.WACC != nil && !validWACC(*t.WACC) {
+		problems = append(problems, "wacc must be a percentage from 0 to 100")
+	}
+
+	if in.IsActive != nil {
+		t.IsActive = *in.IsActive
+	} else if t.ID == 0 {
+		t.IsActive = true
+	}
+
+	if len(problems) > 0 {
+		return &InvestmentTypeError{Problems: problems}
+	}
+
+	// Codes and names are both matched when resolving references
+	var count int64
+	err := tx.Model(&models.InvestmentType{}).
+		Where("id <> ? AND (code IN ? OR LOWER(name) IN ?)", t.ID,
+			[]string{t.Code, strings.ToUpper(t.Name)},
+			[]string{strings.ToLower(t.Name), strings.ToLower(t.Code)}).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return &InvestmentTypeError{Problems: []string{"another investment type already uses this code or name"}}
+	}
+	return nil
+}
+
+// validateFieldSchema checks a schema and fills in its defaults.
+func validateFieldSchema(schema *models.FieldSchema) []string {
+	var problems []string
+
+	if schema.Type == "" {
+		schema.Type = "object"
+	}
+	if schema.Type != "object" {
+		problems = append(problems, `field_schema type must be "object"`)
+	}
+	if schema.Properties == nil {
+		schema.Properties = map[string]models.FieldDefinition{}
+	}
+
+	for _, name := range sortedFieldNames(schema.Properties) {
+		def := schema.Properties[name]
+		prefix := "field_schema property " + name
+		if !customFieldNamePattern.MatchString(name) {
+			problems = append(problems, prefix+" must be lower-case letters, digits or _, starting with a letter")
+		}
+
+		isString := def.Type == models.FieldString
+		isNumber := def.Type == models.FieldInteger || def.Type == models.FieldNumber
+		switch {
+		case !isString && !isNumber && def.Type != models.FieldBoolean:
+			problems = append(problems, prefix+" type must be string, integer, number or boolean")
+			continue
+		case !isString && (def.MinLength != nil || def.MaxLength != nil || def.Pattern != "" || def.Format != ""):
+			problems = append(problems, prefix+": minLength, maxLength, pattern and format apply to strings only")
+		case !isNumber && (def.Minimum != nil || def.Maximum != nil):
+			problems = append(problems, prefix+": minimum and maximum apply to numbers only")
+		}
+
+		if def.Format != "" && def.Format != "date" {
+			problems = append(problems, prefix+` format must be "date"`)
+		}
+		if def.Pattern != "" {
+			if _, err := regexp.Compile(def.Pattern); err != nil {
+				problems = append(problems, prefix+" pattern is not a valid regular expression")
+			}
+		}
+		if (def.MinLength != nil && *def.MinLength < 0) || (def.MaxLength != nil && *def.MaxLength < 0) {
+			problems = append(problems, prefix+": minLength and maxLength cannot be negative")
+		}
+		if def.MinLength != nil && def.MaxLength != nil && *def.MinLength > *def.MaxLength {
+			problems = append(problems, prefix+": minLength is greater than maxLength")
+		}
+		if def.Minimum != nil && def.Maximum != nil && *def.Minimum > *def.Maximum {
+			problems = append(problems, prefix+": minimum is greater than maximum")
+		}
+
+		// Enum values are stored in the form field values take
+		plain := models.FieldDefinition{Type: def.Type}
+		for i, option := range def.Enum {
+			value, ok := normalizeFieldValue(&plain, option)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s enum value %v is not %s", prefix, option, fieldTypeName(&plain)))
+				continue
+			}
+			def.Enum[i] = value
+		}
+		schema.Properties[name] = def
+	}
+
+	required := []string{}
+	for _, name := range schema.Required {
+		switch _, ok := schema.Properties[name]; {
+		case !ok:
+			problems = append(problems, "field_schema requires "+name+", which is not a property")
+		case !containsFold(required, name):
+			required = append(required, name)
+		}
+	}
+	schema.Required = required
+
+	return problems
+}
+
+// Resolve returns the active investment type that value names, by code or
+// by name, ignoring case. An empty value resolves to nil.
+func (s *InvestmentTypeService) Resolve(tx *gorm.DB, value string) (*models.InvestmentType, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	var types []models.InvestmentType
+	err := tx.Where("is_active = ? AND (code = ? OR LOWER(name) = LOWER(?))", true, strings.ToUpper(value), value).
+		Find(&types).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(types) != 1 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownInvestmentType, value)
+	}
+	return &types[0], nil
+}
+
+// CheckFields validates custom field values against the schema of t and
+// returns them typed: integers as int64, numbers as float64. Empty values
+// are left out. Required fields are only checked when complete is set, so
+// drafts can be saved half filled in.
+func (s *InvestmentTypeService) CheckFields(t *models.InvestmentType, values map[string]interface{}, complete bool) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	var problems []string
+
+	if t == nil {
+		if len(values) > 0 {
+			return nil, &CustomFieldError{Problems: []string{"custom_fields need an investment_type"}}
+		}
+		return fields, nil
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		def, ok := t.FieldSchema.Properties[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("custom field %s is not defined for %s", name, t.Name))
+			continue
+		}
+		if values[name] == nil {
+			continue
+		}
+
+		value, ok := normalizeFieldValue(&def, values[name])
+		if !ok {
+			problems = append(problems, fmt.Sprintf("custom field %s must be %s", name, fieldTypeName(&def)))
+			continue
+		}
+		if value == "" {
+			continue
+		}
+		problems = append(problems, checkFieldConstraints(name, &def, value)...)
+		fields[name] = value
+	}
+
+	if complete {
+		for _, name := range t.FieldSchema.Required {
+			if _, ok := fields[name]; !ok {
+				problems = append(problems, "custom field "+name+" is required")
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &CustomFieldError{Problems: problems}
+	}
+	return fields, nil
+}
+
+// SubmitProblems lists why the investment type and custom fields of a
+// proposal do not allow it to be submitted. A missing investment type is
+// left to submitGuard.
+func (s *InvestmentTypeService) SubmitProblems(tx *gorm.DB, proposal *models.InvestmentProposal) ([]string, error) {
+	if strings.TrimSpace(proposal.InvestmentType) == "" {
+		return nil, nil
+	}
+
+	var t models.InvestmentType
+	result := tx.Where("code = ? AND is_active = ?", proposal.InvestmentType, true).Limit(1).Find(&t)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return []string{fmt.Sprintf("investment_type %s is not in the catalog", proposal.InvestmentType)}, nil
+	}
+
+	var fieldErr *CustomFieldError
+	if _, err := s.CheckFields(&t, proposal.CustomFields, true); errors.As(err, &fieldErr) {
+		return fieldErr.Problems, nil
+	}
+	return nil, nil
+}
+
+// InUse reports whether proposals or planning cycles refer to the type.
+func (s *InvestmentTypeService) InUse(tx *gorm.DB, t *models.InvestmentType) (bool, error) {
+	var proposals int64
+	if err := tx.Unscoped().Model(&models.InvestmentProposal{}).Where("investment_type = ?", t.Code).Count(&proposals).Error; err != nil {
+		return false, err
+	}
+	if proposals > 0 {
+		return true, nil
+	}
+
+	var cycles []models.PlanningCycle
+	if err := tx.Select("id, investment_types").Find(&cycles).Error; err != nil {
+		return false, err
+	}
+	for _, cycle := range cycles {
+		if containsFold(cycle.InvestmentTypes, t.Code) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Backfill starts the catalog with the investment types proposals and
+// planning cycles already use, and rewrites those values to the codes of
+// the new entries. It runs once.
+func (s *InvestmentTypeService) Backfill(db *gorm.DB) error {
+	var applied int64
+	if err := db.Model(&models.DataMigration{}).Where("name = ?", investmentTypeMigration).Count(&applied).Error; err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var values []string
+		err := tx.Unscoped().Model(&models.InvestmentProposal{}).
+			Where("investment_type <> ''").
+			Distinct().Pluck("investment_type", &values).Error
+		if err != nil {
+			return err
+		}
+
+		var cycles []models.PlanningCycle
+		if err := tx.Find(&cycles).Error; err != nil {
+			return err
+		}
+		for _, cycle := range cycles {
+			values = append(values, cycle.InvestmentTypes...)
+		}
+
+		codes := map[string]string{}
+		created := 0
+		for _, value := range values {
+			code := investmentTypeCode(value)
+			if code == "" {
+				continue
+			}
+			if _, ok := codes[value]; ok {
+				continue
+			}
+			codes[value] = code
+
+			t := models.InvestmentType{
+				Code:        code,
+				Name:        strings.TrimSpace(value),
+				FieldSchema: models.FieldSchema{Type: "object", Properties: map[string]models.FieldDefinition{}},
+				IsActive:    true,
+			}
+			result := tx.Where("code = ?", code).Attrs(t).FirstOrCreate(&t)
+			if result.Error != nil {
+				return result.Error
+			}
+			created += int(result.RowsAffected)
+		}
+
+		for value, code := range codes {
+			if value == code {
+				continue
+			}
+			err := tx.Unscoped().Model(&models.InvestmentProposal{}).
+				Where("investment_type = ?", value).
+				UpdateColumn("investment_type", code).Error
+			if err != nil {
+				return err
+			}
+		}
+		for i := range cycles {
+			changed := false
+			for j, value := range cycles[i].InvestmentTypes {
+				if code, ok := codes[value]; ok && code != value {
+					cycles[i].InvestmentTypes[j], changed = code, true
+				}
+			}
+			if changed {
+				if err := tx.Model(&cycles[i]).Select("InvestmentTypes").UpdateColumns(&cycles[i]).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := tx.Create(&models.DataMigration{Name: investmentTypeMigration, AppliedAt: time.Now()}).Error; err != nil {
+			return err
+		}
+		log.Printf("Investment type catalog started with %d types", created)
+		return nil
+	})
+}
+
+// SeedWACC copies the WACC per investment type that used to be configured
+// in WACC_BY_INVESTMENT_TYPE ("IT=12;Operasional=9.5") onto the catalog,
+// matching types by code or name. Types that already have a WACC keep it.
+// It runs once, after Backfill.
+func (s *InvestmentTypeService) SeedWACC(db *gorm.DB, value string) error {
+	var applied int64
+	if err := db.Model(&models.DataMigration{}).Where("name = ?", waccMigration).Count(&applied).Error; err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	rates, err := parseWACC(value)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(rates))
+	for name := range rates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, name := range names {
+			rate := rates[name]
+			result := tx.Model(&models.InvestmentType{}).
+				Where("wacc IS NULL AND (code = ? OR LOWER(name) = LOWER(?))", investmentTypeCode(name), name).
+				UpdateColumn("wacc", rate)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				log.Printf("WACC_BY_INVESTMENT_TYPE: no investment type without a WACC matches %q; set it in the catalog", name)
+			}
+		}
+
+		return tx.Create(&models.DataMigration{Name: waccMigration, AppliedAt: time.Now()}).Error
+	})
+}
+
+// parseWACC parses "IT=12;Operasional=9.5" into percent per investment
+// type.
+func parseWACC(value string) (map[string]float64, error) {
+	rates := map[string]float64{}
+	for _, part := range strings.Split(value, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		investmentType, rate, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid WACC %q: expected InvestmentType=Percent", part)
+		}
+		r, err := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+		if err != nil || !validWACC(r) {
+			return nil, fmt.Errorf("invalid WACC %q: expected a percentage from 0 to 100", part)
+		}
+		rates[strings.TrimSpace(investmentType)] = r
+	}
+	return rates, nil
+}
+
+// investmentTypeCode turns a free-text investment type into a catalog
+// code, e.g. "Renovasi Gedung" into RENOVASI_GEDUNG.
+func investmentTypeCode(value string) string {
+	code := strings.Trim(nonCodeChars.ReplaceAllString(strings.ToUpper(value), "_"), "_")
+	if len(code) > 30 {
+		code = strings.TrimRight(code[:30], "_")
+	}
+	return code
+}
+
+// normalizeFieldValue converts a decoded JSON value to the type def asks
+// for, and reports whether it has that type.
+func normalizeFieldValue(def *models.FieldDefinition, value interface{}) (interface{}, bool) {
+	if n, ok := value.(json.Number); ok {
+		f, err := n.Float64()
+		if err != nil {
+			return nil, false
+		}
+		value = f
+	}
+
+	switch def.Type {
+	case models.FieldString:
+		v, ok := value.(string)
+		return strings.TrimSpace(v), ok
+	case models.FieldBoolean:
+		v, ok := value.(bool)
+		return v, ok
+	case models.FieldNumber:
+		switch v := value.(type) {
+		case float64:
+			return v, true
+		case int64:
+			return float64(v), true
+		}
+	case models.FieldInteger:
+		switch v := value.(type) {
+		case float64:
+			if v == math.Trunc(v) && math.Abs(v) <= 1<<53 {
+				return int64(v), true
+			}
+		case int64:
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func checkFieldConstraints(name string, def *models.FieldDefinition, value interface{}) []string {
+	var problems []string
+
+	if len(def.Enum) > 0 {
+		found := false
+		for _, option := range def.Enum {
+			if fmt.Sprint(option) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("custom field %s must be one of %v", name, def.Enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(v)
+		if def.MinLength != nil && length < *def.MinLength {
+			problems = append(problems, fmt.Sprintf("custom field %s must be at least %d characters", name, *def.MinLength))
+		}
+		if def.MaxLength != nil && length > *def.MaxLength {
+			problems = append(problems, fmt.Sprintf("custom field %s must be at most %d characters", name, *def.MaxLength))
+		}
+		if def.Pattern != "" {
+			if matched, err := regexp.MatchString(def.Pattern, v); err != nil || !matched {
+				problems = append(problems, fmt.Sprintf("custom field %s does not match %s", name, def.Pattern))
+			}
+		}
+		if def.Format == "date" {
+			if _, err := time.Parse("2006-01-02", v); err != nil {
+				problems = append(problems, fmt.Sprintf("custom field %s must be a date (YYYY-MM-DD)", name))
+			}
+		}
+	case int64, float64:
+		f, _ := v.(float64)
+		if i, ok := v.(int64); ok {
+			f = float64(i)
+		}
+		if def.Minimum != nil && f < *def.Minimum {
+			problems = append(problems, fmt.Sprintf("custom field %s must be at least %v", name, *def.Minimum))
+		}
+		if def.Maximum != nil && f > *def.Maximum {
+			problems = append(problems, fmt.Sprintf("custom field %s must be at most %v", name, *def.Maximum))
+		}
+	}
+	return problems
+}
+
+// fieldTypeName names the type of a field for error messages.
+func fieldTypeName(def *models.FieldDefinition) string {
+	switch {
+	case def.Format == "date":
+		return "a date (YYYY-MM-DD)"
+	case def.Type == models.FieldInteger:
+		return "an integer"
+	default:
+		return "a " + def.Type
+	}
+}
+
+func sortedFieldNames(properties map[string]models.FieldDefinition) []string {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
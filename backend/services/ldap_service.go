@@ -11,11 +11,12 @@ import (
 )
 
 type LDAPService struct {
-	config *config.LDAPConfig
+	config            *config.LDAPConfig
+	departmentService *DepartmentService
 }
 
-func NewLDAPService(cfg *config.LDAPConfig) *LDAPService {
-	return &LDAPService{config: cfg}
+func NewLDAPService(cfg *config.LDAPConfig, departmentService *DepartmentService) *LDAPService {
+	return &LDAPService{config: cfg, departmentService: departmentService}
 }
 
 func (s *LDAPService) Authenticate(username, password string) (*models.User, error) {
@@ -72,6 +73,10 @@ func (s *LDAPService) Authenticate(username, password string) (*models.User, err
 	db := database.GetDB()
 	var user models.User
 	
+	// The directory's department is only taken over when it matches the
+	// department master data
+	department, deptErr := s.departmentService.ResolveList(db, department)
+
 	result := db.Where("username = ?", username).First(&user)
 	if result.Error != nil {
 		// Create new user with default role
@@ -92,7 +97,9 @@ func (s *LDAPService) Authenticate(username, password string) (*models.User, err
 		user.LastLoginAt = &now
 		user.Email = email
 		user.FullName = displayName
-		user.Department = department
+		if deptErr == nil {
+			user.Department = department
+		}
 		db.Save(&user)
 	}
 
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"fmt"
+	"fui-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// NotificationService delivers in-app notifications to users.
+type NotificationService struct{}
+
+type NotificationMessage struct {
+	Type    string
+	Title   string
+	Message string
+	Link    string
+}
+
+func NewNotificationService() *NotificationService {
+	return &NotificationService{}
+}
+
+// Notify creates one notification per user ID using tx.
+func (s *NotificationService) Notify(tx *gorm.DB, userIDs []uint, msg NotificationMessage) error {
+	seen := make(map[uint]bool, len(userIDs))
+	notifications := make([]models.Notification, 0, len(userIDs))
+	for _, id := range userIDs {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		notifications = append(notifications, models.Notification{
+			UserID:  id,
+			Type:    msg.Type,
+			Title:   msg.Title,
+			Message: msg.Message,
+			Link:    msg.Link,
+		})
+	}
+
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	if err := tx.Create(&notifications).Error; err != nil {
+		return fmt.Errorf("failed to create notifications: %w", err)
+	}
+	return nil
+}
+
+// NotifyRoles notifies every active user holding one of roles.
+func (s *NotificationService) NotifyRoles(tx *gorm.DB, roles []models.UserRole, msg NotificationMessage) error {
+	var userIDs []uint
+	err := tx.Model(&models.User{}).
+		Where("role IN ? AND is_active = ?", roles, true).
+		Pluck("id", &userIDs).Error
+	if err != nil {
+		return fmt.Errorf("failed to find users to notify: %w", err)
+	}
+
+	return s.Notify(tx, userIDs, msg)
+}
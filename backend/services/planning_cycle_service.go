@@ -0,0 +1,273 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"fui-backend/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrUnknownPlanningCycle = errors.New("unknown planning cycle")
+
+// PlanningCycleInput is a planning cycle as sent by the client. Dates are
+// YYYY-MM-DD.
+type PlanningCycleInput struct {
+	Name            string        `json:"name"`
+	FiscalYear      int           `json:"fiscal_year"`
+	OpensAt         string        `json:"opens_at"`
+	ClosesAt        string        `json:"closes_at"`
+	Description     string        `json:"description"`
+	InvestmentTypes []string      `json:"investment_types"`
+	BudgetEnvelope  models.Amount `json:"budget_envelope"`
+}
+
+// PlanningCycleError lists why a planning cycle is invalid.
+type PlanningCycleError struct {
+	Problems []string
+}
+
+func (e *PlanningCycleError) Error() string {
+	return "invalid planning cycle: " + strings.Join(e.Problems, ", ")
+}
+
+// CycleTotal is the number and IDR cost of the proposals in one group of a
+// cycle summary.
+type CycleTotal struct {
+	Key     string        `json:"key"`
+	Count   int64         `json:"count"`
+	CostIDR models.Amount `gorm:"column:cost_idr" json:"cost_idr"`
+}
+
+// CycleSummary sums up the proposals of a planning cycle. Requested covers
+// proposals in the workflow or approved, Approved includes those being
+// executed, and Remaining is the envelope less what was approved.
+type CycleSummary struct {
+	Cycle              models.PlanningCycle `json:"cycle"`
+	State              string               `json:"state"`
+	ProposalCount      int64                `json:"proposal_count"`
+	UrgentCount        int64                `json:"urgent_count"`
+	RequestedIDR       models.Amount        `json:"requested_idr"`
+	ApprovedIDR        models.Amount        `json:"approved_idr"`
+	RemainingIDR       models.Amount        `json:"remaining_idr"`
+	UtilizationPercent *float64             `json:"utilization_percent"` // approved / envelope
+	ByStatus           []CycleTotal         `json:"by_status"`
+	ByInvestmentType   []CycleTotal         `json:"by_investment_type"`
+	ByDepartment       []CycleTotal         `json:"by_department"`
+}
+
+// PlanningCycleService validates planning cycles and decides which cycle a
+// proposal belongs to and whether it may be submitted now.
+type PlanningCycleService struct {
+	investmentTypeService *InvestmentTypeService
+}
+
+func NewPlanningCycleService(investmentTypeService *InvestmentTypeService) *PlanningCycleService {
+	return &PlanningCycleService{investmentTypeService: investmentTypeService}
+}
+
+// Apply validates in and copies it onto cycle. Submission windows may not
+// overlap, so at most one cycle is open at a time. Investment types are
+// stored as catalog codes.
+func (s *PlanningCycleService) Apply(tx *gorm.DB, cycle *models.PlanningCycle, in PlanningCycleInput) error {
+	var problems []string
+
+	cycle.Name = strings.TrimSpace(in.Name)
+	if cycle.Name == "" {
+		problems = append(problems, "name is required")
+	}
+	cycle.FiscalYear = in.FiscalYear
+	if cycle.FiscalYear < 2000 || cycle.FiscalYear > 2100 {
+		problems = append(problems, "fiscal_year must be between 2000 and 2100")
+	}
+	cycle.Description = strings.TrimSpace(in.Description)
+
+	opens, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(in.OpensAt), time.Local)
+	if err != nil {
+		problems = append(problems, "opens_at must be YYYY-MM-DD")
+	}
+	closes, err2 := time.ParseInLocation("2006-01-02", strings.TrimSpace(in.ClosesAt), time.Local)
+	if err2 != nil {
+		problems = append(problems, "closes_at must be YYYY-MM-DD")
+	}
+	if err == nil && err2 == nil && closes.Before(opens) {
+		problems = append(problems, "closes_at must not be before opens_at")
+	}
+	cycle.OpensAt, cycle.ClosesAt = opens, closes
+
+	cycle.InvestmentTypes = []string{}
+	for _, value := range in.InvestmentTypes {
+		t, err := s.investmentTypeService.Resolve(tx, value)
+		switch {
+		case errors.Is(err, ErrUnknownInvestmentType):
+			problems = append(problems, err.Error())
+		case err != nil:
+			return err
+		case t != nil && !containsFold(cycle.InvestmentTypes, t.Code):
+			cycle.InvestmentTypes = append(cycle.InvestmentTypes, t.Code)
+		}
+	}
+
+	cycle.BudgetEnvelope = in.BudgetEnvelope
+	if cycle.BudgetEnvelope < 0 {
+		problems = append(problems, "budget_envelope cannot be negative")
+	}
+
+	if len(problems) > 0 {
+		return &PlanningCycleError{Problems: problems}
+	}
+
+	var others []models.PlanningCycle
+	err = tx.Where("id <> ? AND (LOWER(name) = LOWER(?) OR (opens_at <= ? AND closes_at >= ?))",
+		cycle.ID, cycle.Name, cycle.ClosesAt, cycle.OpensAt).
+		Find(&others).Error
+	if err != nil {
+		return err
+	}
+	for _, other := range others {
+		if strings.EqualFold(other.Name, cycle.Name) {
+			problems = append(problems, "another planning cycle is named "+other.Name)
+		} else {
+			problems = append(problems, fmt.Sprintf("submission window overlaps %s (%s to %s)",
+				other.Name, other.OpensAt.Format("2006-01-02"), other.ClosesAt.Format("2006-01-02")))
+		}
+	}
+	if len(problems) > 0 {
+		return &PlanningCycleError{Problems: problems}
+	}
+	return nil
+}
+
+// Current returns the cycle open at t, nil when none is.
+func (s *PlanningCycleService) Current(tx *gorm.DB, t time.Time) (*models.PlanningCycle, error) {
+	var cycle models.PlanningCycle
+	result := tx.Where("opens_at <= ? AND closes_at > ?", t, t.AddDate(0, 0, -1)).
+		Order("opens_at DESC").Limit(1).
+		Find(&cycle)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return &cycle, nil
+}
+
+// Assign tags a proposal that is being saved with the requested cycle. A
+// proposal saved without one keeps its cycle, or joins the open cycle.
+func (s *PlanningCycleService) Assign(tx *gorm.DB, proposal *models.InvestmentProposal, requested *uint) error {
+	if requested != nil {
+		var count int64
+		if err := tx.Model(&models.PlanningCycle{}).Where("id = ?", *requested).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: %d", ErrUnknownPlanningCycle, *requested)
+		}
+		proposal.PlanningCycleID = requested
+		return nil
+	}
+
+	if proposal.PlanningCycleID != nil {
+		return nil
+	}
+	current, err := s.Current(tx, time.Now())
+	if err != nil || current == nil {
+		return err
+	}
+	proposal.PlanningCycleID = &current.ID
+	return nil
+}
+
+// SubmitProblems lists why a proposal cannot be submitted at t as far as
+// planning cycles go. A proposal outside the window of its cycle, without a
+// cycle, or of an investment type its cycle does not collect must be
+// marked urgent with an urgent_justification. Nothing is checked before
+// the first cycle is set up.
+func (s *PlanningCycleService) SubmitProblems(tx *gorm.DB, proposal *models.InvestmentProposal, t time.Time) ([]string, error) {
+	var cycles int64
+	if err := tx.Model(&models.PlanningCycle{}).Count(&cycles).Error; err != nil {
+		return nil, err
+	}
+	if cycles == 0 {
+		return nil, nil
+	}
+
+	var reason string
+	if proposal.PlanningCycleID == nil {
+		reason = "the proposal has no planning cycle"
+	} else {
+		var cycle models.PlanningCycle
+		if err := tx.First(&cycle, *proposal.PlanningCycleID).Error; err != nil {
+			return nil, err
+		}
+		switch state := cycle.StateAt(t); {
+		case state == models.CycleUpcoming:
+			reason = fmt.Sprintf("submissions to %s open on %s", cycle.Name, cycle.OpensAt.Format("2006-01-02"))
+		case state == models.CycleClosed:
+			reason = fmt.Sprintf("submissions to %s closed on %s", cycle.Name, cycle.ClosesAt.Format("2006-01-02"))
+		case len(cycle.InvestmentTypes) > 0 && !containsFold(cycle.InvestmentTypes, proposal.InvestmentType):
+			reason = fmt.Sprintf("%s does not collect investment type %q", cycle.Name, proposal.InvestmentType)
+		}
+	}
+	if reason == "" {
+		return nil, nil
+	}
+
+	var problems []string
+	if !proposal.Urgent {
+		problems = append(problems, reason+"; mark it urgent to submit it anyway")
+	}
+	if strings.TrimSpace(proposal.UrgentJustification) == "" {
+		problems = append(problems, "urgent_justification is required when "+reason)
+	}
+	return problems, nil
+}
+
+// Summary sums up the proposals of a cycle that query, which must select
+// from investment_proposals, matches.
+func (s *PlanningCycleService) Summary(query *gorm.DB, cycle *models.PlanningCycle) (*CycleSummary, error) {
+	query = query.Where("investment_proposals.planning_cycle_id = ?", cycle.ID)
+	summary := &CycleSummary{Cycle: *cycle, State: cycle.StateAt(time.Now())}
+
+	group := func(column string) ([]CycleTotal, error) {
+		totals := []CycleTotal{}
+		err := query.Session(&gorm.Session{}).
+			Select(column + ` AS "key", COUNT(*) AS count, COALESCE(SUM(estimated_cost_idr), 0) AS cost_idr`).
+			Group(column).Order(column).
+			Scan(&totals).Error
+		return totals, err
+	}
+
+	var err error
+	if summary.ByStatus, err = group("status"); err != nil {
+		return nil, err
+	}
+	if summary.ByInvestmentType, err = group("investment_type"); err != nil {
+		return nil, err
+	}
+	if summary.ByDepartment, err = group("department_id"); err != nil {
+		return nil, err
+	}
+
+	for _, total := range summary.ByStatus {
+		summary.ProposalCount += total.Count
+		status := models.ProposalStatus(total.Key)
+		switch {
+		case hasStatus(models.FundedStatuses, status):
+			summary.ApprovedIDR += total.CostIDR
+			summary.RequestedIDR += total.CostIDR
+		case status == models.StatusSubmitted || status == models.StatusReviewing:
+			summary.RequestedIDR += total.CostIDR
+		}
+	}
+	if err := query.Session(&gorm.Session{}).Where("urgent = ?", true).Count(&summary.UrgentCount).Error; err != nil {
+		return nil, err
+	}
+
+	summary.RemainingIDR = cycle.BudgetEnvelope - summary.ApprovedIDR
+	if cycle.BudgetEnvelope > 0 {
+		percent := round2(float64(summary.ApprovedIDR) / float64(cycle.BudgetEnvelope) * 100)
+		summary.UtilizationPercent = &percent
+	}
+	return summary, nil
+}
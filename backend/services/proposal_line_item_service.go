@@ -0,0 +1,176 @@
+package services
+
+import (
+	"fmt"
+	"fui-backend/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const maxLineItems = 200
+
+// ProposalLineItemInput is a line item as sent by the client. Amounts are
+// computed by the server.
+type ProposalLineItemInput struct {
+	Description string        `json:"description"`
+	Category    string        `json:"category"`
+	Quantity    float64       `json:"quantity"`
+	UnitPrice   models.Amount `json:"unit_price"`
+	Currency    string        `json:"currency"`
+	TaxRate     float64       `json:"tax_rate"`
+}
+
+// LineItemError lists the line items of a request that are invalid.
+type LineItemError struct {
+	Problems []string
+}
+
+func (e *LineItemError) Error() string {
+	return "invalid line items: " + strings.Join(e.Problems, ", ")
+}
+
+// ProposalLineItemService validates and prices the cost breakdown of a
+// proposal.
+type ProposalLineItemService struct{}
+
+func NewProposalLineItemService() *ProposalLineItemService {
+	return &ProposalLineItemService{}
+}
+
+// Build validates inputs and returns the line items with their amounts.
+// Items without a currency take the proposal's; other currencies are
+// rejected, since the proposal total is kept in one currency.
+func (s *ProposalLineItemService) Build(proposal *models.InvestmentProposal, inputs []ProposalLineItemInput) ([]models.ProposalLineItem, error) {
+	if len(inputs) > maxLineItems {
+		return nil, &LineItemError{Problems: []string{fmt.Sprintf("at most %d line items are allowed", maxLineItems)}}
+	}
+
+	currency := proposalCurrency(proposal)
+	var problems []string
+	var total models.Amount
+	items := make([]models.ProposalLineItem, 0, len(inputs))
+	for i, in := range inputs {
+		n := i + 1
+		item := models.ProposalLineItem{
+			ProposalID:  proposal.ID,
+			Position:    n,
+			Description: strings.TrimSpace(in.Description),
+			Category:    strings.ToLower(strings.TrimSpace(in.Category)),
+			Quantity:    in.Quantity,
+			UnitPrice:   in.UnitPrice,
+			Currency:    strings.ToUpper(strings.TrimSpace(in.Currency)),
+			TaxRate:     in.TaxRate,
+		}
+		if item.Currency == "" {
+			item.Currency = currency
+		}
+
+		if item.Description == "" {
+			problems = append(problems, fmt.Sprintf("line item %d: description is required", n))
+		}
+		if !isLineItemCategory(item.Category) {
+			problems = append(problems, fmt.Sprintf("line item %d: category must be one of %s", n, strings.Join(models.LineItemCategories, ", ")))
+		}
+		if item.Quantity <= 0 {
+			problems = append(problems, fmt.Sprintf("line item %d: quantity must be greater than 0", n))
+		}
+		if item.UnitPrice < 0 {
+			problems = append(problems, fmt.Sprintf("line item %d: unit price cannot be negative", n))
+		}
+		if item.TaxRate < 0 || item.TaxRate > 100 {
+			problems = append(problems, fmt.Sprintf("line item %d: tax rate must be between 0 and 100", n))
+		}
+		if item.Currency != currency {
+			problems = append(problems, fmt.Sprintf("line item %d: currency %s differs from proposal currency %s", n, item.Currency, currency))
+		}
+
+		err := priceLineItem(&item)
+		if err == nil {
+			total, err = total.Add(item.Total)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("line item %d: amount is too large", n))
+		}
+		items = append(items, item)
+	}
+
+	if len(problems) > 0 {
+		return nil, &LineItemError{Problems: problems}
+	}
+	return items, nil
+}
+
+// Replace swaps the stored line items of the proposal for proposal.LineItems.
+func (s *ProposalLineItemService) Replace(tx *gorm.DB, proposal *models.InvestmentProposal) error {
+	if err := tx.Where("proposal_id = ?", proposal.ID).Delete(&models.ProposalLineItem{}).Error; err != nil {
+		return err
+	}
+	if len(proposal.LineItems) == 0 {
+		return nil
+	}
+
+	for i := range proposal.LineItems {
+		proposal.LineItems[i].ID = 0
+		proposal.LineItems[i].ProposalID = proposal.ID
+	}
+	return tx.Create(&proposal.LineItems).Error
+}
+
+// priceLineItem computes the amounts of an item, each rounded to whole
+// cents.
+func priceLineItem(item *models.ProposalLineItem) error {
+	var err error
+	if item.Subtotal, err = item.UnitPrice.Mul(item.Quantity); err != nil {
+		return err
+	}
+	if item.TaxAmount, err = item.Subtotal.Mul(item.TaxRate / 100); err != nil {
+		return err
+	}
+	item.Total, err = item.Subtotal.Add(item.TaxAmount)
+	return err
+}
+
+// LineItemsTotal is the estimated cost of a proposal with these items, which
+// Build has checked to fit in an Amount.
+func LineItemsTotal(items []models.ProposalLineItem) models.Amount {
+	var total models.Amount
+	for _, item := range items {
+		total += item.Total
+	}
+	return total
+}
+
+// LineItemInputs turns stored line items back into inputs, so they can be
+// priced again, e.g. after the proposal currency changed.
+func LineItemInputs(items []models.ProposalLineItem) []ProposalLineItemInput {
+	inputs := make([]ProposalLineItemInput, len(items))
+	for i, item := range items {
+		inputs[i] = ProposalLineItemInput{
+			Description: item.Description,
+			Category:    item.Category,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			Currency:    item.Currency,
+			TaxRate:     item.TaxRate,
+		}
+	}
+	return inputs
+}
+
+func isLineItemCategory(category string) bool {
+	for _, c := range models.LineItemCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// proposalCurrency is the proposal's currency, IDR when it has none yet.
+func proposalCurrency(proposal *models.InvestmentProposal) string {
+	if c := strings.ToUpper(strings.TrimSpace(proposal.Currency)); c != "" {
+		return c
+	}
+	return "IDR"
+}
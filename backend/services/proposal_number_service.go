@@ -0,0 +1,152 @@
+package services
+
+import (
+	"fmt"
+	"fui-backend/config"
+	"fui-backend/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxNumberAttempts bounds how many taken numbers Create skips, e.g. numbers
+// entered by hand or generated under an older pattern.
+const maxNumberAttempts = 10
+
+var (
+	numberTokenPattern  = regexp.MustCompile(`\{([A-Z0-9]+)(?::(\d+))?\}`)
+	departmentCodeStrip = regexp.MustCompile(`[^A-Z0-9]`)
+)
+
+// ProposalNumberService hands out sequential proposal numbers following the
+// configured pattern. Counters live in the proposal_sequences table and are
+// incremented inside the caller's transaction, so a number is never given
+// out twice.
+type ProposalNumberService struct {
+	config         *config.NumberingConfig
+	usesDepartment bool
+}
+
+func NewProposalNumberService(cfg *config.NumberingConfig) (*ProposalNumberService, error) {
+	if cfg.FiscalYearStartMonth < 1 || cfg.FiscalYearStartMonth > 12 {
+		return nil, fmt.Errorf("invalid fiscal year start month %d", cfg.FiscalYearStartMonth)
+	}
+
+	switch cfg.Reset {
+	case "yearly", "monthly", "never":
+	default:
+		return nil, fmt.Errorf("invalid proposal number reset %q: expected yearly, monthly or never", cfg.Reset)
+	}
+
+	s := &ProposalNumberService{config: cfg}
+	hasCounter := false
+	for _, m := range numberTokenPattern.FindAllStringSubmatch(cfg.Pattern, -1) {
+		switch m[1] {
+		case "SEQ":
+			hasCounter = true
+		case "DEPT":
+			s.usesDepartment = true
+		case "PREFIX", "FY", "FY2", "YYYY", "YY", "MM":
+		default:
+			return nil, fmt.Errorf("unknown token {%s} in proposal number pattern", m[1])
+		}
+	}
+	if !hasCounter {
+		return nil, fmt.Errorf("proposal number pattern %q has no {SEQ} token", cfg.Pattern)
+	}
+
+	return s, nil
+}
+
+// Create assigns the next number to the proposal and inserts it. Numbers
+// that turn out to be taken already are skipped.
+func (s *ProposalNumberService) Create(tx *gorm.DB, proposal *models.InvestmentProposal) error {
+	for attempt := 0; attempt < maxNumberAttempts; attempt++ {
+		number, err := s.Next(tx, proposal, time.Now())
+		if err != nil {
+			return err
+		}
+
+		proposal.ProposalNumber = number
+		err = tx.Create(proposal).Error
+		if err == nil {
+			return nil
+		}
+		if !strings.Contains(err.Error(), "UNIQUE constraint failed: investment_proposals.proposal_number") {
+			return err
+		}
+	}
+
+	return fmt.Errorf("no free proposal number after %d attempts", maxNumberAttempts)
+}
+
+// Next increments the counter of the proposal's scope and returns the
+// formatted number.
+func (s *ProposalNumberService) Next(tx *gorm.DB, proposal *models.InvestmentProposal, at time.Time) (string, error) {
+	fy := FiscalYear(at, s.config.FiscalYearStartMonth)
+	dept := departmentCode(proposal.DepartmentID)
+
+	var scope string
+	switch s.config.Reset {
+	case "yearly":
+		scope = fmt.Sprintf("FY%d", fy)
+	case "monthly":
+		scope = at.Format("2006-01")
+	default:
+		scope = "all"
+	}
+	if s.usesDepartment {
+		scope += "|" + dept
+	}
+
+	var value int64
+	err := tx.Raw(`INSERT INTO proposal_sequences (scope, value, updated_at) VALUES (?, 1, ?)
+		ON CONFLICT(scope) DO UPDATE SET value = proposal_sequences.value + 1, updated_at = excluded.updated_at
+		RETURNING value`, scope, at).Scan(&value).Error
+	if err != nil {
+		return "", fmt.Errorf("failed to take proposal number: %w", err)
+	}
+
+	values := map[string]string{
+		"PREFIX": s.config.Prefix,
+		"DEPT":   dept,
+		"FY":     strconv.Itoa(fy),
+		"FY2":    fmt.Sprintf("%02d", fy%100),
+		"YYYY":   at.Format("2006"),
+		"YY":     at.Format("06"),
+		"MM":     at.Format("01"),
+	}
+
+	number := numberTokenPattern.ReplaceAllStringFunc(s.config.Pattern, func(token string) string {
+		m := numberTokenPattern.FindStringSubmatch(token)
+		if m[1] != "SEQ" {
+			return values[m[1]]
+		}
+		width, _ := strconv.Atoi(m[2])
+		return fmt.Sprintf("%0*d", width, value)
+	})
+
+	return number, nil
+}
+
+// FiscalYear returns the fiscal year of t, labelled with the calendar year
+// in which that fiscal year starts.
+func FiscalYear(t time.Time, startMonth int) int {
+	if int(t.Month()) < startMonth {
+		return t.Year() - 1
+	}
+	return t.Year()
+}
+
+// departmentCode turns a department into the upper-case code used in
+// numbers, "GEN" when the proposal has none.
+func departmentCode(department string) string {
+	code := departmentCodeStrip.ReplaceAllString(strings.ToUpper(department), "")
+	if code == "" {
+		return "GEN"
+	}
+	return code
+}
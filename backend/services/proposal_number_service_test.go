@@ -0,0 +1,126 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"fui-backend/config"
+	"fui-backend/database"
+	"fui-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB connects the database package to a migrated scratch database
+// that is removed when tb ends.
+func openTestDB(tb testing.TB) *gorm.DB {
+	tb.Helper()
+
+	cfg := &config.Config{Database: config.DatabaseConfig{Path: filepath.Join(tb.TempDir(), "test.db")}}
+	if err := database.Connect(cfg); err != nil {
+		tb.Fatalf("connect: %v", err)
+	}
+	db := database.GetDB()
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	if err := database.Migrate(); err != nil {
+		tb.Fatalf("migrate: %v", err)
+	}
+	tb.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// Concurrent creates get distinct numbers from one contiguous sequence,
+// skipping a number that was taken outside the generator.
+func TestProposalNumberServiceConcurrentCreate(t *testing.T) {
+	const (
+		proposals = 100
+		workers   = 16
+	)
+
+	db := openTestDB(t)
+	cfg := &config.NumberingConfig{
+		Pattern:              "{PREFIX}-{FY}-{SEQ:5}",
+		Prefix:               "INV",
+		Reset:                "yearly",
+		FiscalYearStartMonth: 1,
+	}
+	numberService, err := NewProposalNumberService(cfg)
+	if err != nil {
+		t.Fatalf("NewProposalNumberService: %v", err)
+	}
+
+	user := models.User{Username: "submitter", Email: "submitter@example.com", FullName: "Submitter", Role: models.RoleCorpFA}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+
+	fy := FiscalYear(time.Now(), cfg.FiscalYearStartMonth)
+	taken := fmt.Sprintf("INV-%d-%05d", fy, 3)
+	manual := models.InvestmentProposal{ProposalNumber: taken, Title: "Manual", Status: models.StatusDraft, SubmittedByID: user.ID, ProposalDate: time.Now()}
+	if err := db.Create(&manual).Error; err != nil {
+		t.Fatalf("seed proposal: %v", err)
+	}
+
+	var (
+		mu      sync.Mutex
+		numbers []string
+		errs    []error
+		wg      sync.WaitGroup
+	)
+	jobs := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				proposal := models.InvestmentProposal{
+					Title:         fmt.Sprintf("Proposal %d", i),
+					Status:        models.StatusDraft,
+					SubmittedByID: user.ID,
+					ProposalDate:  time.Now(),
+				}
+				err := db.Transaction(func(tx *gorm.DB) error {
+					return numberService.Create(tx, &proposal)
+				})
+
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				} else {
+					numbers = append(numbers, proposal.ProposalNumber)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < proposals; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, err := range errs {
+		t.Errorf("Create: %v", err)
+	}
+
+	seen := map[string]bool{taken: true}
+	for _, n := range numbers {
+		if seen[n] {
+			t.Errorf("number %s given out twice", n)
+		}
+		seen[n] = true
+	}
+	for i := 1; i <= proposals+1; i++ {
+		if n := fmt.Sprintf("INV-%d-%05d", fy, i); !seen[n] {
+			t.Errorf("number %s missing from the sequence", n)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"errors"
+	"fui-backend/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProposalTemplateInput is a proposal template as sent by the client.
+type ProposalTemplateInput struct {
+	Name            string                  `json:"name"`
+	InvestmentType  string                  `json:"investment_type"`
+	IsActive        *bool                   `json:"is_active"`
+	Title           string                  `json:"title"`
+	Description     string                  `json:"description"`
+	Currency        string                  `json:"currency"`
+	Justification   string                  `json:"justification"`
+	ExpectedBenefit string                  `json:"expected_benefit"`
+	RiskAnalysis    string                  `json:"risk_analysis"`
+	DepartmentID    string                  `json:"department_id"`
+	CustomFields    map[string]interface{}  `json:"custom_fields"`
+	LineItems       []ProposalLineItemInput `json:"line_items"`
+	CashFlows       []CashFlowInput         `json:"cash_flows"`
+}
+
+// ProposalTemplateError lists why a proposal template is invalid.
+type ProposalTemplateError struct {
+	Problems []string
+}
+
+func (e *ProposalTemplateError) Error() string {
+	return "invalid proposal template: " + strings.Join(e.Problems, ", ")
+}
+
+// ProposalTemplateService validates proposal templates and turns them into
+// new drafts.
+type ProposalTemplateService struct {
+	departmentService     *DepartmentService
+	investmentTypeService *InvestmentTypeService
+	lineItemService       *ProposalLineItemService
+	financeService        *FinancialEvaluationService
+}
+
+func NewProposalTemplateService(departmentService *DepartmentService, investmentTypeService *InvestmentTypeService, lineItemService *ProposalLineItemService, financeService *FinancialEvaluationService) *ProposalTemplateService {
+	return &ProposalTemplateService{
+		departmentService:     departmentService,
+		investmentTypeService: investmentTypeService,
+		lineItemService:       lineItemService,
+		financeService:        financeService,
+	}
+}
+
+// Apply validates in and copies it onto template. The content is checked
+// the way a draft is saved: references must resolve and values must fit
+// the schema of the investment type, but nothing is required yet.
+func (s *ProposalTemplateService) Apply(tx *gorm.DB, template *models.ProposalTemplate, in ProposalTemplateInput) error {
+	var problems []string
+
+	template.Name = strings.TrimSpace(in.Name)
+	if template.Name == "" {
+		problems = append(problems, "name is required")
+	}
+	if in.IsActive != nil {
+		template.IsActive = *in.IsActive
+	} else if template.ID == 0 {
+		template.IsActive = true
+	}
+
+	template.Title = strings.TrimSpace(in.Title)
+	template.Description = in.Description
+	template.Currency = strings.ToUpper(strings.TrimSpace(in.Currency))
+	template.Justification = in.Justification
+	template.ExpectedBenefit = in.ExpectedBenefit
+	template.RiskAnalysis = in.RiskAnalysis
+
+	investmentType, err := s.investmentTypeService.Resolve(tx, in.InvestmentType)
+	switch {
+	case errors.Is(err, ErrUnknownInvestmentType):
+		problems = append(problems, err.Error())
+	case err != nil:
+		return err
+	case investmentType == nil:
+		problems = append(problems, "investment_type is required")
+	default:
+		template.InvestmentType = investmentType.Code
+
+		fields, err := s.investmentTypeService.CheckFields(investmentType, in.CustomFields, false)
+		var fieldErr *CustomFieldError
+		switch {
+		case errors.As(err, &fieldErr):
+			problems = append(problems, fieldErr.Problems...)
+		case err != nil:
+			return err
+		default:
+			template.CustomFields = fields
+		}
+	}
+
+	department, err := s.departmentService.Resolve(tx, in.DepartmentID)
+	switch {
+	case errors.Is(err, ErrUnknownDepartment):
+		problems = append(problems, err.Error())
+	case err != nil:
+		return err
+	default:
+		template.DepartmentID = department
+	}
+
+	// Priced and evaluated against a stand-in proposal, only to validate
+	draft := &models.InvestmentProposal{Currency: template.Currency}
+	items, err := s.lineItemService.Build(draft, in.LineItems)
+	var itemErr *LineItemError
+	switch {
+	case errors.As(err, &itemErr):
+		problems = append(problems, itemErr.Problems...)
+	case err != nil:
+		return err
+	default:
+		template.LineItems = make([]models.TemplateLineItem, len(items))
+		for i, item := range items {
+			template.LineItems[i] = models.TemplateLineItem{
+				Description: item.Description,
+				Category:    item.Category,
+				Quantity:    item.Quantity,
+				UnitPrice:   item.UnitPrice,
+				Currency:    item.Currency,
+				TaxRate:     item.TaxRate,
+			}
+		}
+	}
+
+	flows, err := s.financeService.BuildCashFlows(draft, in.CashFlows)
+	var flowErr *CashFlowError
+	switch {
+	case errors.As(err, &flowErr):
+		problems = append(problems, flowErr.Problems...)
+	case err != nil:
+		return err
+	default:
+		template.CashFlows = make([]models.TemplateCashFlow, len(flows))
+		for i, f := range flows {
+			template.CashFlows[i] = models.TemplateCashFlow{Year: f.Year, Inflow: f.Inflow, Outflow: f.Outflow}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ProposalTemplateError{Problems: problems}
+	}
+
+	var count int64
+	err = tx.Model(&models.ProposalTemplate{}).
+		Where("id <> ? AND LOWER(name) = LOWER(?)", template.ID, template.Name).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return &ProposalTemplateError{Problems: []string{"another template is named " + template.Name}}
+	}
+	return nil
+}
+
+// Draft returns a new draft with the content of template, and the line
+// items and cash flows to price and evaluate for it.
+func (s *ProposalTemplateService) Draft(template *models.ProposalTemplate, submitterID uint) (*models.InvestmentProposal, []ProposalLineItemInput, []CashFlowInput) {
+	proposal := &models.InvestmentProposal{
+		Title:           template.Title,
+		Description:     template.Description,
+		InvestmentType:  template.InvestmentType,
+		CustomFields:    template.CustomFields,
+		Currency:        template.Currency,
+		ProposalDate:    time.Now(),
+		Justification:   template.Justification,
+		ExpectedBenefit: template.ExpectedBenefit,
+		RiskAnalysis:    template.RiskAnalysis,
+		Status:          models.StatusDraft,
+		SubmittedByID:   submitterID,
+		DepartmentID:    template.DepartmentID,
+		TemplateID:      &template.ID,
+	}
+	if proposal.Currency == "" {
+		proposal.Currency = "IDR"
+	}
+	if proposal.Title == "" {
+		proposal.Title = template.Name
+	}
+
+	items := make([]ProposalLineItemInput, len(template.LineItems))
+	for i, item := range template.LineItems {
+		items[i] = ProposalLineItemInput{
+			Description: item.Description,
+			Category:    item.Category,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			Currency:    item.Currency,
+			TaxRate:     item.TaxRate,
+		}
+	}
+	flows := make([]CashFlowInput, len(template.CashFlows))
+	for i, f := range template.CashFlows {
+		flows[i] = CashFlowInput{Year: f.Year, Inflow: f.Inflow, Outflow: f.Outflow}
+	}
+	return proposal, items, flows
+}
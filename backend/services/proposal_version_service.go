@@ -0,0 +1,369 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fui-backend/database"
+	"fui-backend/models"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxTextDiffCells bounds the word diff table; longer texts are reported as
+// one deletion and one insertion.
+const maxTextDiffCells = 4_000_000
+
+var ErrVersionNotFound = errors.New("version not found")
+
+var diffTokenPattern = regexp.MustCompile(`\s+|[^\s]+`)
+
+// ProposalSnapshot is the versioned content of a proposal.
+type ProposalSnapshot struct {
+	Title               string        `json:"title"`
+	Description         string        `json:"description"`
+	InvestmentType      string        `json:"investment_type"`
+	EstimatedCost       models.Amount `json:"estimated_cost"`
+	Currency            string        `json:"currency"`
+	ExpectedStartDate   time.Time     `json:"expected_start_date"`
+	ExpectedCompletDate time.Time     `json:"expected_complete_date"`
+	Justification       string        `json:"justification"`
+	ExpectedBenefit     string        `json:"expected_benefit"`
+	RiskAnalysis        string        `json:"risk_analysis"`
+	DepartmentID        string        `json:"department_id"`
+	PlanningCycleID     *uint         `json:"planning_cycle_id"`
+	Urgent              bool          `json:"urgent"`
+	UrgentJustification string        `json:"urgent_justification"`
+
+	CustomFields map[string]interface{} `json:"custom_fields,omitempty"`
+
+	LineItems []LineItemSnapshot `json:"line_items,omitempty"`
+	CashFlows []CashFlowInput    `json:"cash_flows,omitempty"`
+}
+
+// LineItemSnapshot is the versioned content of a line item.
+type LineItemSnapshot struct {
+	Description string        `json:"description"`
+	Category    string        `json:"category"`
+	Quantity    float64       `json:"quantity"`
+	UnitPrice   models.Amount `json:"unit_price"`
+	Currency    string        `json:"currency"`
+	TaxRate     float64       `json:"tax_rate"`
+	Total       models.Amount `json:"total"`
+}
+
+type ProposalVersionResponse struct {
+	models.ProposalVersion
+	Data          ProposalSnapshot `json:"data"`
+	ChangedFields []string         `json:"changed_fields"`
+}
+
+// FieldDiff is the change of one field between two versions. Numbers carry
+// the delta, text fields a word-level list of changes.
+type FieldDiff struct {
+	Field        string         `json:"field"`
+	Old          interface{}    `json:"old"`
+	New          interface{}    `json:"new"`
+	Delta        *models.Amount `json:"delta,omitempty"`
+	DeltaPercent *float64       `json:"delta_percent,omitempty"`
+	Changes      []TextChange   `json:"changes,omitempty"`
+}
+
+// TextChange is one run of a word diff. Op is equal, insert or delete.
+type TextChange struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+type ProposalVersionDiff struct {
+	ProposalID uint        `json:"proposal_id"`
+	From       int         `json:"from"`
+	To         int         `json:"to"`
+	Fields     []FieldDiff `json:"fields"`
+}
+
+// ProposalVersionService keeps a numbered snapshot of every saved state of a
+// proposal's content and compares them.
+type ProposalVersionService struct{}
+
+func NewProposalVersionService() *ProposalVersionService {
+	return &ProposalVersionService{}
+}
+
+func NewProposalSnapshot(p *models.InvestmentProposal) ProposalSnapshot {
+	var items []LineItemSnapshot
+	for _, item := range p.LineItems {
+		items = append(items, LineItemSnapshot{
+			Description: item.Description,
+			Category:    item.Category,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			Currency:    item.Currency,
+			TaxRate:     item.TaxRate,
+			Total:       item.Total,
+		})
+	}
+
+	snapshot := ProposalSnapshot{
+		Title:               p.Title,
+		Description:         p.Description,
+		InvestmentType:      p.InvestmentType,
+		EstimatedCost:       p.EstimatedCost,
+		Currency:            p.Currency,
+		ExpectedStartDate:   p.ExpectedStartDate.UTC(),
+		ExpectedCompletDate: p.ExpectedCompletDate.UTC(),
+		Justification:       p.Justification,
+		ExpectedBenefit:     p.ExpectedBenefit,
+		RiskAnalysis:        p.RiskAnalysis,
+		DepartmentID:        p.DepartmentID,
+		PlanningCycleID:     p.PlanningCycleID,
+		Urgent:              p.Urgent,
+		UrgentJustification: p.UrgentJustification,
+		CustomFields:        p.CustomFields,
+		LineItems:           items,
+	}
+	if len(p.CashFlows) > 0 {
+		snapshot.CashFlows = CashFlowInputs(p.CashFlows)
+	}
+	return snapshot
+}
+
+// Record saves the proposal as a new version unless its content equals the
+// latest version. It returns nil when nothing changed.
+func (s *ProposalVersionService) Record(tx *gorm.DB, proposal *models.InvestmentProposal, actorID uint) (*models.ProposalVersion, error) {
+	return s.record(tx, proposal, actorID, time.Now())
+}
+
+// EnsureBaseline saves the current state of a proposal that has no versions
+// yet, such as one created before versioning existed, so its first update
+// can be compared with it. Call it before changing the proposal.
+func (s *ProposalVersionService) EnsureBaseline(tx *gorm.DB, proposal *models.InvestmentProposal) error {
+	var count int64
+	if err := tx.Model(&models.ProposalVersion{}).Where("proposal_id = ?", proposal.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	_, err := s.record(tx, proposal, proposal.SubmittedByID, proposal.UpdatedAt)
+	return err
+}
+
+func (s *ProposalVersionService) record(tx *gorm.DB, proposal *models.InvestmentProposal, actorID uint, at time.Time) (*models.ProposalVersion, error) {
+	raw, err := json.Marshal(NewProposalSnapshot(proposal))
+	if err != nil {
+		return nil, err
+	}
+
+	var latest models.ProposalVersion
+	err = tx.Where("proposal_id = ?", proposal.ID).Order("version DESC").Limit(1).Find(&latest).Error
+	if err != nil {
+		return nil, err
+	}
+	if latest.ID != 0 && latest.Snapshot == string(raw) {
+		return nil, nil
+	}
+
+	version := &models.ProposalVersion{
+		ProposalID:  proposal.ID,
+		Version:     latest.Version + 1,
+		Status:      proposal.Status,
+		Snapshot:    string(raw),
+		ChangedByID: actorID,
+		CreatedAt:   at,
+	}
+	if err := tx.Create(version).Error; err != nil {
+		return nil, fmt.Errorf("failed to save proposal version: %w", err)
+	}
+	return version, nil
+}
+
+// List returns the versions of a proposal, oldest first, each with the
+// fields that changed since the previous one.
+func (s *ProposalVersionService) List(proposalID uint) ([]ProposalVersionResponse, error) {
+	var versions []models.ProposalVersion
+	err := database.GetDB().Preload("ChangedBy").
+		Where("proposal_id = ?", proposalID).
+		Order("version ASC").
+		Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]ProposalVersionResponse, 0, len(versions))
+	var previous *ProposalSnapshot
+	for _, v := range versions {
+		resp := ProposalVersionResponse{ProposalVersion: v, ChangedFields: []string{}}
+		if err := json.Unmarshal([]byte(v.Snapshot), &resp.Data); err != nil {
+			return nil, fmt.Errorf("invalid snapshot of version %d: %w", v.Version, err)
+		}
+		if previous != nil {
+			for _, field := range diffSnapshots(previous, &resp.Data, false) {
+				resp.ChangedFields = append(resp.ChangedFields, field.Field)
+			}
+		}
+		previous = &resp.Data
+		responses = append(responses, resp)
+	}
+	return responses, nil
+}
+
+// Diff compares versions a and b of a proposal field by field.
+func (s *ProposalVersionService) Diff(proposalID uint, a, b int) (*ProposalVersionDiff, error) {
+	from, err := s.loadSnapshot(proposalID, a)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.loadSnapshot(proposalID, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProposalVersionDiff{
+		ProposalID: proposalID,
+		From:       a,
+		To:         b,
+		Fields:     diffSnapshots(from, to, true),
+	}, nil
+}
+
+func (s *ProposalVersionService) loadSnapshot(proposalID uint, version int) (*ProposalSnapshot, error) {
+	var v models.ProposalVersion
+	err := database.GetDB().Where("proposal_id = ? AND version = ?", proposalID, version).First(&v).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %d", ErrVersionNotFound, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot ProposalSnapshot
+	if err := json.Unmarshal([]byte(v.Snapshot), &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid snapshot of version %d: %w", version, err)
+	}
+	return &snapshot, nil
+}
+
+// diffSnapshots returns the fields that differ, in declaration order.
+// withText adds the word diff of text fields.
+func diffSnapshots(a, b *ProposalSnapshot, withText bool) []FieldDiff {
+	diffs := []FieldDiff{}
+
+	va, vb := reflect.ValueOf(*a), reflect.ValueOf(*b)
+	t := va.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		oldValue, newValue := va.Field(i).Interface(), vb.Field(i).Interface()
+
+		switch o := oldValue.(type) {
+		case string:
+			n := newValue.(string)
+			if o == n {
+				continue
+			}
+			diff := FieldDiff{Field: name, Old: o, New: n}
+			if withText {
+				diff.Changes = diffWords(o, n)
+			}
+			diffs = append(diffs, diff)
+
+		case models.Amount:
+			n := newValue.(models.Amount)
+			if o == n {
+				continue
+			}
+			delta := n - o
+			diff := FieldDiff{Field: name, Old: o, New: n, Delta: &delta}
+			if o != 0 {
+				percent := math.Round(float64(delta)/float64(o)*10000) / 100
+				diff.DeltaPercent = &percent
+			}
+			diffs = append(diffs, diff)
+
+		case time.Time:
+			n := newValue.(time.Time)
+			if o.Equal(n) {
+				continue
+			}
+			diffs = append(diffs, FieldDiff{Field: name, Old: o, New: n})
+
+		case bool, *uint, map[string]interface{}, []LineItemSnapshot, []CashFlowInput:
+			if reflect.DeepEqual(oldValue, newValue) {
+				continue
+			}
+			diffs = append(diffs, FieldDiff{Field: name, Old: oldValue, New: newValue})
+		}
+	}
+
+	return diffs
+}
+
+// diffWords computes a word-level diff that keeps whitespace, so joining
+// the equal and insert runs gives the new text.
+func diffWords(a, b string) []TextChange {
+	x := diffTokenPattern.FindAllString(a, -1)
+	y := diffTokenPattern.FindAllString(b, -1)
+
+	if len(x)*len(y) > maxTextDiffCells {
+		return appendChange(appendChange(nil, "delete", a), "insert", b)
+	}
+
+	// lcs[i][j] is the LCS length of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var changes []TextChange
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			changes = appendChange(changes, "equal", x[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			changes = appendChange(changes, "delete", x[i])
+			i++
+		default:
+			changes = appendChange(changes, "insert", y[j])
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		changes = appendChange(changes, "delete", x[i])
+	}
+	for ; j < len(y); j++ {
+		changes = appendChange(changes, "insert", y[j])
+	}
+
+	return changes
+}
+
+func appendChange(changes []TextChange, op, text string) []TextChange {
+	if text == "" {
+		return changes
+	}
+	if n := len(changes); n > 0 && changes[n-1].Op == op {
+		changes[n-1].Text += text
+		return changes
+	}
+	return append(changes, TextChange{Op: op, Text: text})
+}
@@ -0,0 +1,922 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"fui-backend/config"
+	"fui-backend/database"
+	"fui-backend/models"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ProposalAction string
+
+const (
+	ActionCreate          ProposalAction = "create"
+	ActionSubmit          ProposalAction = "submit"
+	ActionApprove         ProposalAction = "approve"
+	ActionReject          ProposalAction = "reject"
+	ActionRequestRevision ProposalAction = "request_revision"
+	ActionWithdraw        ProposalAction = "withdraw"
+	ActionCancel          ProposalAction = "cancel"
+	ActionStart           ProposalAction = "start"
+	ActionComplete        ProposalAction = "complete"
+	ActionClose           ProposalAction = "close"
+
+	// Not transitions: spend exceeding the approved cost and its
+	// re-approval keep the status
+	ActionOverrun        ProposalAction = "overrun"
+	ActionApproveOverrun ProposalAction = "approve_overrun"
+)
+
+var (
+	ErrUnknownAction       = errors.New("unknown action")
+	ErrInvalidTransition   = errors.New("action not allowed in current status")
+	ErrTransitionForbidden = errors.New("not allowed to perform this action")
+)
+
+const overrunStepMigration = "overrun_step"
+
+// legacyOverrunStep is where overrun re-approvals were kept before they had
+// OverrunStep.
+const legacyOverrunStep = 6
+
+// TransitionGuardError lists why a proposal cannot make a transition yet.
+type TransitionGuardError struct {
+	Problems []string
+}
+
+func (e *TransitionGuardError) Error() string {
+	return strings.Join(e.Problems, ", ")
+}
+
+// transitionActor says who may trigger a transition.
+type transitionActor int
+
+const (
+	// actorSubmitter is the user who owns the proposal.
+	actorSubmitter transitionActor = iota
+	// actorSubmitterOrAdmin is the owner of the proposal or an admin.
+	actorSubmitterOrAdmin
+	// actorApprover holds the role of the workflow step the proposal is
+	// waiting on. Admins may act for any step.
+	actorApprover
+	// actorFinance is Corp FA, who reconciles the spend, or an admin.
+	actorFinance
+)
+
+type proposalTransition struct {
+	From          []models.ProposalStatus
+	To            models.ProposalStatus
+	Actor         transitionActor
+	RequireReason bool
+	Guard         func(p *models.InvestmentProposal) []string
+	// Decision settles the pending approval of the current step.
+	Decision string
+	// Abort cancels the pending approvals and tells the approvers who
+	// already decided on the proposal.
+	Abort bool
+}
+
+// proposalTransitions is the proposal state machine. Approving moves to the
+// next workflow step and only ends in approved after the last one. An
+// approved proposal is then started, completed and closed by finance; an
+// overrun waiting for re-approval holds it before completion and closing.
+var proposalTransitions = map[ProposalAction]proposalTransition{
+	ActionSubmit: {
+		From:  []models.ProposalStatus{models.StatusDraft, models.StatusRevision, models.StatusWithdrawn},
+		To:    models.StatusSubmitted,
+		Actor: actorSubmitter,
+		Guard: submitGuard,
+	},
+	ActionApprove: {
+		From:     []models.ProposalStatus{models.StatusSubmitted, models.StatusReviewing},
+		To:       models.StatusReviewing,
+		Actor:    actorApprover,
+		Guard:    approveGuard,
+		Decision: models.ApprovalApproved,
+	},
+	ActionReject: {
+		From:          []models.ProposalStatus{models.StatusSubmitted, models.StatusReviewing},
+		To:            models.StatusRejected,
+		Actor:         actorApprover,
+		RequireReason: true,
+		Decision:      models.ApprovalRejected,
+	},
+	ActionRequestRevision: {
+		From:          []models.ProposalStatus{models.StatusSubmitted, models.StatusReviewing},
+		To:            models.StatusRevision,
+		Actor:         actorApprover,
+		RequireReason: true,
+		Decision:      models.ApprovalRevision,
+	},
+	ActionWithdraw: {
+		From:          []models.ProposalStatus{models.StatusSubmitted, models.StatusReviewing},
+		To:            models.StatusWithdrawn,
+		Actor:         actorSubmitter,
+		RequireReason: true,
+		Abort:         true,
+	},
+	ActionCancel: {
+		From: []models.ProposalStatus{
+			models.StatusDraft, models.StatusSubmitted, models.StatusReviewing,
+			models.StatusRevision, models.StatusWithdrawn,
+		},
+		To:            models.StatusCancelled,
+		Actor:         actorSubmitterOrAdmin,
+		RequireReason: true,
+		Abort:         true,
+	},
+	ActionStart: {
+		From:  []models.ProposalStatus{models.StatusApproved},
+		To:    models.StatusInProgress,
+		Actor: actorSubmitterOrAdmin,
+	},
+	ActionComplete: {
+		From:  []models.ProposalStatus{models.StatusInProgress},
+		To:    models.StatusCompleted,
+		Actor: actorSubmitterOrAdmin,
+		Guard: overrunGuard,
+	},
+	ActionClose: {
+		From:  []models.ProposalStatus{models.StatusCompleted},
+		To:    models.StatusClosed,
+		Actor: actorFinance,
+		Guard: overrunGuard,
+	},
+}
+
+// Statuses in which the submitter may still change or delete a proposal.
+var (
+	editableStatuses  = []models.ProposalStatus{models.StatusDraft, models.StatusRevision, models.StatusWithdrawn}
+	deletableStatuses = []models.ProposalStatus{models.StatusDraft}
+)
+
+// Statuses in which the actual spend of a proposal may be recorded.
+var spendingStatuses = []models.ProposalStatus{models.StatusInProgress, models.StatusCompleted}
+
+// ProposalWorkflowService moves proposals through the status state machine
+// and the approval workflow, and keeps the status history.
+type ProposalWorkflowService struct {
+	auditService          *AuditService
+	authorizationService  *AuthorizationService
+	notificationService   *NotificationService
+	exchangeRateService   *ExchangeRateService
+	budgetService         *BudgetService
+	planningCycleService  *PlanningCycleService
+	investmentTypeService *InvestmentTypeService
+	thresholds            map[models.UserRole]models.Amount // minimum IDR cost a role reviews
+	overrunTolerance      float64                           // percent
+}
+
+func NewProposalWorkflowService(cfg *config.WorkflowConfig, auditService *AuditService, authorizationService *AuthorizationService, notificationService *NotificationService, exchangeRateService *ExchangeRateService, budgetService *BudgetService, planningCycleService *PlanningCycleService, investmentTypeService *InvestmentTypeService) (*ProposalWorkflowService, error) {
+	thresholds, err := parseThresholds(cfg.Thresholds)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.OverrunTolerance < 0 {
+		return nil, fmt.Errorf("invalid overrun re-approval percent %g: must not be negative", cfg.OverrunTolerance)
+	}
+	return &ProposalWorkflowService{
+		auditService:          auditService,
+		authorizationService:  authorizationService,
+		notificationService:   notificationService,
+		exchangeRateService:   exchangeRateService,
+		budgetService:         budgetService,
+		planningCycleService:  planningCycleService,
+		investmentTypeService: investmentTypeService,
+		thresholds:            thresholds,
+		overrunTolerance:      cfg.OverrunTolerance,
+	}, nil
+}
+
+// CanEdit reports whether the proposal's fields may still be changed.
+func (s *ProposalWorkflowService) CanEdit(proposal *models.InvestmentProposal) bool {
+	return hasStatus(editableStatuses, proposal.Status)
+}
+
+// CanDelete reports whether the proposal may still be deleted.
+func (s *ProposalWorkflowService) CanDelete(proposal *models.InvestmentProposal) bool {
+	return hasStatus(deletableStatuses, proposal.Status)
+}
+
+// CanSpend reports whether actual spend may be recorded against the
+// proposal.
+func (s *ProposalWorkflowService) CanSpend(proposal *models.InvestmentProposal) bool {
+	return hasStatus(spendingStatuses, proposal.Status)
+}
+
+// RecordCreated starts the history of a new proposal.
+func (s *ProposalWorkflowService) RecordCreated(tx *gorm.DB, proposal *models.InvestmentProposal, actor *Subject) error {
+	return tx.Create(&models.ProposalStatusHistory{
+		ProposalID: proposal.ID,
+		Action:     string(ActionCreate),
+		ToStatus:   proposal.Status,
+		ActorID:    actor.UserID,
+		ActorRole:  actor.Role,
+	}).Error
+}
+
+// Transition applies action to the proposal on behalf of sub. On success the
+// proposal holds its new status and step, and the history entry is
+// returned.
+func (s *ProposalWorkflowService) Transition(sub *Subject, proposal *models.InvestmentProposal, action ProposalAction, reason string) (*models.ProposalStatusHistory, error) {
+	t, ok := proposalTransitions[action]
+	if !ok {
+		return nil, ErrUnknownAction
+	}
+
+	if !hasStatus(t.From, proposal.Status) {
+		return nil, fmt.Errorf("%w: cannot %s a proposal that is %s", ErrInvalidTransition, strings.ReplaceAll(string(action), "_", " "), proposal.Status)
+	}
+
+	if err := s.checkActor(sub, proposal, t.Actor); err != nil {
+		return nil, err
+	}
+
+	reason = strings.TrimSpace(reason)
+	var problems []string
+	if t.RequireReason && reason == "" {
+		problems = append(problems, "reason is required")
+	}
+	if t.Guard != nil {
+		problems = append(problems, t.Guard(proposal)...)
+	}
+
+	// The IDR cost is locked on submit, at the rate of the day, and a
+	// proposal without a planning cycle joins the open one
+	now := time.Now()
+	costIDR, rate := proposal.EstimatedCostIDR, proposal.ExchangeRate
+	cycleID := proposal.PlanningCycleID
+	if action == ActionSubmit {
+		tagged := *proposal
+		if err := s.planningCycleService.Assign(database.GetDB(), &tagged, nil); err != nil {
+			return nil, err
+		}
+		cycleProblems, err := s.planningCycleService.SubmitProblems(database.GetDB(), &tagged, now)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, cycleProblems...)
+		cycleID = tagged.PlanningCycleID
+
+		fieldProblems, err := s.investmentTypeService.SubmitProblems(database.GetDB(), proposal)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, fieldProblems...)
+
+		cost, r, err := s.exchangeRateService.Convert(database.GetDB(), proposal, now)
+		switch {
+		case errors.Is(err, ErrNoExchangeRate):
+			problems = append(problems, err.Error())
+		case errors.Is(err, models.ErrAmountOverflow):
+			problems = append(problems, "estimated_cost is too large to convert to IDR")
+		case err != nil:
+			return nil, err
+		default:
+			costIDR, rate = &cost, &r
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &TransitionGuardError{Problems: problems}
+	}
+
+	from, fromStep := proposal.Status, proposal.ApprovalStep
+	to, toStep := t.To, fromStep
+	if action == ActionSubmit || action == ActionApprove {
+		after := fromStep
+		if action == ActionSubmit {
+			after = 0 // a resubmitted proposal starts over
+		}
+		if step := s.nextStep(costIDR, after); step > 0 {
+			toStep = step
+		} else {
+			to, toStep = models.StatusApproved, len(models.ApprovalWorkflow)+1
+		}
+	}
+
+	history := &models.ProposalStatusHistory{
+		ProposalID:   proposal.ID,
+		Action:       string(action),
+		FromStatus:   from,
+		ToStatus:     to,
+		ApprovalStep: toStep,
+		ActorID:      sub.UserID,
+		ActorRole:    sub.Role,
+		Reason:       reason,
+	}
+
+	updates := map[string]interface{}{
+		"status":        to,
+		"approval_step": toStep,
+		"updated_at":    now,
+	}
+	if action == ActionSubmit {
+		updates["estimated_cost_idr"] = costIDR
+		updates["exchange_rate"] = rate
+		updates["rate_locked_at"] = now
+		updates["planning_cycle_id"] = cycleID
+	}
+
+	previous := *proposal
+	var budgetWarning string
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		// Guard against a concurrent transition of the same proposal, and
+		// against the recommendation approveGuard saw being withdrawn since
+		query := tx.Model(&models.InvestmentProposal{}).
+			Where("id = ? AND status = ? AND approval_step = ?", proposal.ID, from, fromStep)
+		procurement := action == ActionApprove && fromStep == models.WorkflowStep(models.RoleSourcingAndProcurement)
+		if procurement {
+			query = query.Where("recommended_quotation_id IS NOT NULL")
+		}
+		result := query.Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			if procurement {
+				var current models.InvestmentProposal
+				if err := tx.First(&current, proposal.ID).Error; err != nil {
+					return err
+				}
+				if current.Status == from && current.ApprovalStep == fromStep {
+					return &TransitionGuardError{Problems: approveGuard(&current)}
+				}
+			}
+			return fmt.Errorf("%w: proposal was changed by someone else", ErrInvalidTransition)
+		}
+
+		proposal.Status, proposal.ApprovalStep = to, toStep
+		if action == ActionSubmit {
+			proposal.EstimatedCostIDR, proposal.ExchangeRate, proposal.RateLockedAt = costIDR, rate, &now
+			proposal.PlanningCycleID = cycleID
+		}
+
+		// Checked within the transaction, which holds the write lock, so
+		// concurrent submits cannot both take the last of a budget
+		warning, err := s.updateBudget(tx, proposal, action, sub)
+		if err != nil {
+			return err
+		}
+		budgetWarning = warning
+
+		if t.Decision != "" {
+			if err := s.settleApproval(tx, proposal.ID, fromStep, t.Decision, sub, reason); err != nil {
+				return err
+			}
+		}
+
+		if t.Abort {
+			if err := s.abortApprovals(tx, proposal, action, sub, reason); err != nil {
+				return err
+			}
+		}
+
+		if to == models.StatusSubmitted || to == models.StatusReviewing {
+			if err := s.openApproval(tx, proposal.ID, toStep, sub); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(history).Error; err != nil {
+			return err
+		}
+
+		return s.auditService.Record(tx, models.AuditEntityProposal, proposal.ID, string(action), &sub.UserID, proposal)
+	})
+	if err != nil {
+		*proposal = previous
+		if errors.Is(err, ErrBudgetExceeded) {
+			return nil, &TransitionGuardError{Problems: []string{err.Error()}}
+		}
+		return nil, err
+	}
+	proposal.BudgetWarning = budgetWarning
+
+	return history, nil
+}
+
+// History returns the status timeline of a proposal, oldest first.
+func (s *ProposalWorkflowService) History(proposalID uint) ([]models.ProposalStatusHistory, error) {
+	history := []models.ProposalStatusHistory{}
+	err := database.GetDB().Preload("Actor").
+		Where("proposal_id = ?", proposalID).
+		Order("created_at ASC, id ASC").
+		Find(&history).Error
+	return history, err
+}
+
+// ReconcileSpend sets the actual cost of a proposal to the total of its
+// spend records after they changed. When the spend first exceeds the
+// approved cost by more than the tolerance, it opens a re-approval for the
+// OverrunApprover and notifies them; when a correction brings it back
+// within, the pending re-approval is cancelled. A proposal whose cost was
+// never converted to IDR gets it converted first, see convertSpendLimit.
+// It fails with ErrInvalidTransition when the proposal no longer takes
+// spend.
+func (s *ProposalWorkflowService) ReconcileSpend(tx *gorm.DB, proposal *models.InvestmentProposal, actor *Subject) error {
+	// Re-read within the transaction, so an overrun a concurrent change
+	// already raised is not raised again
+	if err := tx.First(proposal, proposal.ID).Error; err != nil {
+		return err
+	}
+	if !s.CanSpend(proposal) {
+		return fmt.Errorf("%w: spend can only be recorded while the proposal is in progress or completed", ErrInvalidTransition)
+	}
+
+	var actual models.Amount
+	err := tx.Model(&models.SpendRecord{}).
+		Select("COALESCE(SUM(amount_idr), 0)").
+		Where("proposal_id = ?", proposal.ID).
+		Row().Scan(&actual)
+	if err != nil {
+		return err
+	}
+
+	pending := proposal.OverrunPending
+	proposal.ActualCostIDR = actual
+	proposal.OverrunPending = false
+	limit := proposal.SpendLimitIDR()
+	if limit == nil {
+		if err := s.convertSpendLimit(tx, proposal); err != nil {
+			return err
+		}
+		limit = proposal.EstimatedCostIDR
+	}
+	allowed, err := limit.Mul(1 + s.overrunTolerance/100)
+	if err != nil {
+		return err
+	}
+	proposal.OverrunPending = actual > allowed
+
+	err = tx.Model(proposal).
+		Select("ActualCostIDR", "OverrunPending").
+		UpdateColumns(proposal).Error
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case proposal.OverrunPending && !pending:
+		reason := fmt.Sprintf("actual cost %s IDR exceeds the approved %s IDR by more than %g%%", actual, *limit, s.overrunTolerance)
+		if err := s.openApproval(tx, proposal.ID, models.OverrunStep, actor); err != nil {
+			return err
+		}
+		if _, err := s.recordOverrun(tx, proposal, ActionOverrun, actor, reason); err != nil {
+			return err
+		}
+		return s.notificationService.NotifyRoles(tx, []models.UserRole{models.OverrunApprover}, NotificationMessage{
+			Type:    "proposal_overrun",
+			Title:   fmt.Sprintf("Proposal %s needs re-approval", proposal.ProposalNumber),
+			Message: fmt.Sprintf("%s: %s", proposal.Title, reason),
+			Link:    fmt.Sprintf("/dashboard/proposals/%d", proposal.ID),
+		})
+
+	case !proposal.OverrunPending && pending:
+		var approvals []models.Approval
+		err := tx.Where("proposal_id = ? AND step = ? AND status = ?", proposal.ID, models.OverrunStep, models.ApprovalPending).
+			Find(&approvals).Error
+		if err != nil {
+			return err
+		}
+		for i := range approvals {
+			approvals[i].Status = models.ApprovalCancelled
+			approvals[i].Comments = "spend is back within the approved cost"
+			if err := tx.Save(&approvals[i]).Error; err != nil {
+				return err
+			}
+			if err := s.auditService.RecordApproval(tx, &approvals[i], models.ApprovalCancelled, &actor.UserID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// convertSpendLimit converts the estimated cost of a proposal approved
+// before costs were converted to IDR, at the rate of the day it was
+// submitted, and keeps it as the cost its spend is held to. Spend is
+// refused with a SpendError while that rate is missing.
+func (s *ProposalWorkflowService) convertSpendLimit(tx *gorm.DB, proposal *models.InvestmentProposal) error {
+	at := proposal.ProposalDate
+	if proposal.RateLockedAt != nil {
+		at = *proposal.RateLockedAt
+	}
+
+	cost, rate, err := s.exchangeRateService.Convert(tx, proposal, at)
+	switch {
+	case errors.Is(err, ErrNoExchangeRate):
+		return &SpendError{Problems: []string{"the estimated cost cannot be converted to IDR to hold spend to it: " + err.Error()}}
+	case errors.Is(err, models.ErrAmountOverflow):
+		return &SpendError{Problems: []string{"estimated_cost is too large to convert to IDR"}}
+	case err != nil:
+		return err
+	}
+
+	proposal.EstimatedCostIDR, proposal.ExchangeRate, proposal.RateLockedAt = &cost, &rate, &at
+	return tx.Model(proposal).
+		Select("EstimatedCostIDR", "ExchangeRate", "RateLockedAt").
+		UpdateColumns(proposal).Error
+}
+
+// ApproveOverrun re-approves the overrun of a proposal on behalf of sub, who
+// must be the OverrunApprover or an admin. The actual cost becomes the
+// approved cost, and what it adds is committed against the budget.
+func (s *ProposalWorkflowService) ApproveOverrun(sub *Subject, proposal *models.InvestmentProposal, comments string) (*models.ProposalStatusHistory, error) {
+	if !proposal.OverrunPending {
+		return nil, fmt.Errorf("%w: the proposal has no overrun waiting for re-approval", ErrInvalidTransition)
+	}
+	if sub.Role != models.RoleAdmin && sub.Role != models.OverrunApprover {
+		return nil, ErrTransitionForbidden
+	}
+	allowed, err := s.authorizationService.CanViewProposal(sub, proposal)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrTransitionForbidden
+	}
+
+	comments = strings.TrimSpace(comments)
+	previous := *proposal
+	var history *models.ProposalStatusHistory
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		// Re-read within the transaction, so spend recorded since counts
+		if err := tx.First(proposal, proposal.ID).Error; err != nil {
+			return err
+		}
+		if !proposal.OverrunPending {
+			return fmt.Errorf("%w: proposal was changed by someone else", ErrInvalidTransition)
+		}
+
+		var added models.Amount
+		if limit := proposal.SpendLimitIDR(); limit != nil {
+			added = proposal.ActualCostIDR - *limit
+		}
+		approved := proposal.ActualCostIDR
+		proposal.ApprovedCostIDR = &approved
+		proposal.OverrunPending = false
+		err := tx.Model(proposal).
+			Select("ApprovedCostIDR", "OverrunPending").
+			UpdateColumns(proposal).Error
+		if err != nil {
+			return err
+		}
+
+		if err := s.budgetService.CommitOverrun(tx, proposal, added, sub.UserID); err != nil {
+			return err
+		}
+		if err := s.settleApproval(tx, proposal.ID, models.OverrunStep, models.ApprovalApproved, sub, comments); err != nil {
+			return err
+		}
+
+		history, err = s.recordOverrun(tx, proposal, ActionApproveOverrun, sub, comments)
+		if err != nil {
+			return err
+		}
+
+		return s.notificationService.Notify(tx, []uint{proposal.SubmittedByID}, NotificationMessage{
+			Type:    "proposal_overrun_approved",
+			Title:   fmt.Sprintf("Overrun of proposal %s was re-approved", proposal.ProposalNumber),
+			Message: fmt.Sprintf("%s: approved cost is now %s IDR", proposal.Title, approved),
+			Link:    fmt.Sprintf("/dashboard/proposals/%d", proposal.ID),
+		})
+	})
+	if err != nil {
+		*proposal = previous
+		return nil, err
+	}
+	return history, nil
+}
+
+// recordOverrun adds an overrun event to the history and audit trail of a
+// proposal. The status stays as it is.
+func (s *ProposalWorkflowService) recordOverrun(tx *gorm.DB, proposal *models.InvestmentProposal, action ProposalAction, actor *Subject, reason string) (*models.ProposalStatusHistory, error) {
+	history := &models.ProposalStatusHistory{
+		ProposalID:   proposal.ID,
+		Action:       string(action),
+		FromStatus:   proposal.Status,
+		ToStatus:     proposal.Status,
+		ApprovalStep: proposal.ApprovalStep,
+		ActorID:      actor.UserID,
+		ActorRole:    actor.Role,
+		Reason:       reason,
+	}
+	if err := tx.Create(history).Error; err != nil {
+		return nil, err
+	}
+	return history, s.auditService.Record(tx, models.AuditEntityProposal, proposal.ID, string(action), &actor.UserID, proposal)
+}
+
+// MigrateOverrunStep moves the overrun re-approvals kept at step 6, after
+// the five workflow steps, to OverrunStep, once. Each is recorded in the
+// audit trail, so the approvals still verify.
+func (s *ProposalWorkflowService) MigrateOverrunStep(db *gorm.DB) error {
+	var applied int64
+	if err := db.Model(&models.DataMigration{}).Where("name = ?", overrunStepMigration).Count(&applied).Error; err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var approvals []models.Approval
+		err := tx.Where("step = ? AND approver_role = ?", legacyOverrunStep, models.OverrunApprover).
+			Order("id ASC").Find(&approvals).Error
+		if err != nil {
+			return err
+		}
+		for i := range approvals {
+			approvals[i].Step = models.OverrunStep
+			if err := tx.Model(&approvals[i]).UpdateColumn("step", models.OverrunStep).Error; err != nil {
+				return err
+			}
+			if err := s.auditService.RecordApproval(tx, &approvals[i], "migrate", nil); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(&models.DataMigration{Name: overrunStepMigration, AppliedAt: time.Now()}).Error; err != nil {
+			return err
+		}
+		if len(approvals) > 0 {
+			log.Printf("Moved %d overrun re-approvals to step %d", len(approvals), models.OverrunStep)
+		}
+		return nil
+	})
+}
+
+func (s *ProposalWorkflowService) checkActor(sub *Subject, proposal *models.InvestmentProposal, actor transitionActor) error {
+	switch actor {
+	case actorSubmitter:
+		if s.authorizationService.CanEditProposal(sub, proposal) {
+			return nil
+		}
+	case actorSubmitterOrAdmin:
+		if sub.Role == models.RoleAdmin || s.authorizationService.CanEditProposal(sub, proposal) {
+			return nil
+		}
+	case actorApprover:
+		step := proposal.ApprovalStep
+		if step < 1 || step > len(models.ApprovalWorkflow) {
+			return ErrTransitionForbidden
+		}
+		if sub.Role != models.RoleAdmin && sub.Role != models.ApprovalWorkflow[step-1] {
+			return ErrTransitionForbidden
+		}
+
+		// A Direktur only decides on proposals of their own departments
+		allowed, err := s.authorizationService.CanViewProposal(sub, proposal)
+		if err != nil {
+			return err
+		}
+		if allowed {
+			return nil
+		}
+	case actorFinance:
+		if sub.Role == models.RoleAdmin || sub.Role == models.RoleCorpFA {
+			return nil
+		}
+	}
+	return ErrTransitionForbidden
+}
+
+// nextStep returns the first workflow step after from that a proposal with
+// this IDR cost goes through, or 0 when none is left. A role with a
+// threshold only reviews proposals costing at least that much; when the
+// IDR cost is unknown, every step applies.
+func (s *ProposalWorkflowService) nextStep(costIDR *models.Amount, from int) int {
+	for step := from + 1; step <= len(models.ApprovalWorkflow); step++ {
+		threshold, ok := s.thresholds[models.ApprovalWorkflow[step-1]]
+		if !ok || costIDR == nil || *costIDR >= threshold {
+			return step
+		}
+	}
+	return 0
+}
+
+// updateBudget keeps the budget ledger in step with a transition:
+// submitting reserves the locked IDR cost, the final approval commits it,
+// and rejecting, returning for revision, withdrawing or cancelling releases
+// it. Executing the proposal leaves the commitment as it is. It returns the
+// warning of a submit that exceeds the budget.
+func (s *ProposalWorkflowService) updateBudget(tx *gorm.DB, proposal *models.InvestmentProposal, action ProposalAction, actor *Subject) (string, error) {
+	var warning string
+	switch action {
+	case ActionSubmit:
+		w, err := s.budgetService.Reserve(tx, proposal, actor.UserID)
+		if err != nil {
+			return "", err
+		}
+		warning = w
+	case ActionApprove:
+	case ActionStart, ActionComplete, ActionClose:
+		return "", nil
+	default:
+		return "", s.budgetService.Release(tx, proposal, actor.UserID)
+	}
+
+	if proposal.Status == models.StatusApproved {
+		return warning, s.budgetService.Commit(tx, proposal, actor.UserID)
+	}
+	return warning, nil
+}
+
+// openApproval creates the pending approval of a workflow step.
+func (s *ProposalWorkflowService) openApproval(tx *gorm.DB, proposalID uint, step int, actor *Subject) error {
+	approval := models.Approval{
+		ProposalID:   proposalID,
+		ApproverRole: stepRole(step),
+		Step:         step,
+		Status:       models.ApprovalPending,
+	}
+	if err := tx.Create(&approval).Error; err != nil {
+		return err
+	}
+	return s.auditService.RecordApproval(tx, &approval, "create", &actor.UserID)
+}
+
+// settleApproval records the decision on the pending approval of a step.
+// Proposals submitted before approvals were tracked have none, so one is
+// created.
+func (s *ProposalWorkflowService) settleApproval(tx *gorm.DB, proposalID uint, step int, status string, actor *Subject, comments string) error {
+	var approval models.Approval
+	err := tx.Where("proposal_id = ? AND step = ? AND status = ?", proposalID, step, models.ApprovalPending).
+		Order("id DESC").Limit(1).
+		Find(&approval).Error
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	approval.ProposalID = proposalID
+	approval.ApproverID = actor.UserID
+	approval.ApproverRole = stepRole(step)
+	approval.Step = step
+	approval.Status = status
+	approval.Comments = comments
+	approval.ApprovedAt = &now
+
+	if err := tx.Save(&approval).Error; err != nil {
+		return err
+	}
+	return s.auditService.RecordApproval(tx, &approval, status, &actor.UserID)
+}
+
+// abortApprovals cancels the pending approvals of a proposal that is
+// withdrawn or cancelled, and notifies everyone who already approved,
+// rejected or returned it.
+func (s *ProposalWorkflowService) abortApprovals(tx *gorm.DB, proposal *models.InvestmentProposal, action ProposalAction, actor *Subject, reason string) error {
+	var pending []models.Approval
+	if err := tx.Where("proposal_id = ? AND status = ?", proposal.ID, models.ApprovalPending).Find(&pending).Error; err != nil {
+		return err
+	}
+	for i := range pending {
+		pending[i].Status = models.ApprovalCancelled
+		pending[i].Comments = reason
+		if err := tx.Save(&pending[i]).Error; err != nil {
+			return err
+		}
+		if err := s.auditService.RecordApproval(tx, &pending[i], models.ApprovalCancelled, &actor.UserID); err != nil {
+			return err
+		}
+	}
+
+	var approverIDs []uint
+	err := tx.Model(&models.Approval{}).
+		Where("proposal_id = ? AND status NOT IN ? AND approver_id <> ?", proposal.ID,
+			[]string{models.ApprovalPending, models.ApprovalCancelled}, actor.UserID).
+		Distinct().Pluck("approver_id", &approverIDs).Error
+	if err != nil {
+		return err
+	}
+	if len(approverIDs) == 0 {
+		return nil
+	}
+
+	verb := "withdrawn"
+	if action == ActionCancel {
+		verb = "cancelled"
+	}
+	return s.notificationService.Notify(tx, approverIDs, NotificationMessage{
+		Type:    "proposal_" + verb,
+		Title:   fmt.Sprintf("Proposal %s was %s", proposal.ProposalNumber, verb),
+		Message: fmt.Sprintf("%s: %s", proposal.Title, reason),
+		Link:    fmt.Sprintf("/dashboard/proposals/%d", proposal.ID),
+	})
+}
+
+// submitGuard lists the fields a proposal needs before it can be submitted.
+func submitGuard(p *models.InvestmentProposal) []string {
+	var problems []string
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"title", p.Title},
+		{"description", p.Description},
+		{"investment_type", p.InvestmentType},
+		{"department_id", p.DepartmentID},
+		{"justification", p.Justification},
+	}
+	for _, field := range required {
+		if strings.TrimSpace(field.value) == "" {
+			problems = append(problems, field.name+" is required")
+		}
+	}
+
+	if p.EstimatedCost <= 0 {
+		problems = append(problems, "estimated_cost must be greater than zero")
+	}
+	if p.ExpectedStartDate.IsZero() || p.ExpectedCompletDate.IsZero() {
+		problems = append(problems, "expected_start_date and expected_complete_date are required")
+	} else if p.ExpectedCompletDate.Before(p.ExpectedStartDate) {
+		problems = append(problems, "expected_complete_date must not be before expected_start_date")
+	}
+
+	return problems
+}
+
+// approveGuard lists what a proposal needs before its current step can be
+// approved. Procurement first compares the vendor quotations and
+// recommends one.
+func approveGuard(p *models.InvestmentProposal) []string {
+	if p.ApprovalStep == models.WorkflowStep(models.RoleSourcingAndProcurement) && p.RecommendedQuotationID == nil {
+		return []string{"quotations must be compared and a vendor recommended before procurement approval"}
+	}
+	return nil
+}
+
+// overrunGuard holds a proposal whose overrun waits for re-approval.
+func overrunGuard(p *models.InvestmentProposal) []string {
+	if p.OverrunPending {
+		return []string{"the spend overrun must be re-approved by the " + string(models.OverrunApprover) + " first"}
+	}
+	return nil
+}
+
+// stepRole returns the role that approves a step: a workflow role, or the
+// OverrunApprover at OverrunStep.
+func stepRole(step int) models.UserRole {
+	if step == models.OverrunStep {
+		return models.OverrunApprover
+	}
+	return models.ApprovalWorkflow[step-1]
+}
+
+// parseThresholds parses "CFO=1000000000;CEO=5000000000". Roles must take
+// part in the approval workflow, and Sourcing dan Procurement, whose
+// vendor recommendation every proposal needs, cannot be skipped.
+func parseThresholds(value string) (map[models.UserRole]models.Amount, error) {
+	thresholds := map[models.UserRole]models.Amount{}
+	for _, part := range strings.Split(value, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, amount, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid approval threshold %q: expected Role=AmountIDR", part)
+		}
+		role := workflowRole(strings.TrimSpace(name))
+		if role == "" {
+			return nil, fmt.Errorf("invalid approval threshold %q: %s is not an approval workflow role", part, strings.TrimSpace(name))
+		}
+		threshold, err := models.ParseAmount(amount)
+		if err != nil || threshold < 0 {
+			return nil, fmt.Errorf("invalid approval threshold %q: amount must be a non-negative number", part)
+		}
+		if role == models.RoleSourcingAndProcurement && threshold > 0 {
+			return nil, fmt.Errorf("invalid approval threshold %q: %s reviews every proposal", part, role)
+		}
+		thresholds[role] = threshold
+	}
+	return thresholds, nil
+}
+
+// workflowRole returns the workflow role named name, ignoring case.
+func workflowRole(name string) models.UserRole {
+	for _, role := range models.ApprovalWorkflow {
+		if strings.EqualFold(string(role), name) {
+			return role
+		}
+	}
+	return ""
+}
+
+func hasStatus(statuses []models.ProposalStatus, status models.ProposalStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,393 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"fui-backend/config"
+	"fui-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// newTestWorkflowService wires a workflow service the way main does, with
+// the audit service it records to.
+func newTestWorkflowService(t *testing.T) (*ProposalWorkflowService, *AuditService) {
+	t.Helper()
+
+	audit := NewAuditService(&config.AuditConfig{})
+	types := NewInvestmentTypeService()
+	budget, err := NewBudgetService(&config.BudgetConfig{Enforcement: "warn", FiscalYearStartMonth: 1})
+	if err != nil {
+		t.Fatalf("NewBudgetService: %v", err)
+	}
+	workflow, err := NewProposalWorkflowService(&config.WorkflowConfig{OverrunTolerance: 10}, audit, NewAuthorizationService(), NewNotificationService(), NewExchangeRateService(), budget, NewPlanningCycleService(types), types)
+	if err != nil {
+		t.Fatalf("NewProposalWorkflowService: %v", err)
+	}
+	return workflow, audit
+}
+
+func TestMigrateOverrunStep(t *testing.T) {
+	db := openTestDB(t)
+	workflow, audit := newTestWorkflowService(t)
+
+	approvals := []models.Approval{
+		{ProposalID: 1, ApproverRole: models.RoleCFO, Step: 4, Status: models.ApprovalApproved},
+		{ProposalID: 1, ApproverRole: models.OverrunApprover, Step: legacyOverrunStep, Status: models.ApprovalApproved},
+		{ProposalID: 2, ApproverRole: models.OverrunApprover, Step: legacyOverrunStep, Status: models.ApprovalPending},
+	}
+	for i := range approvals {
+		if err := db.Create(&approvals[i]).Error; err != nil {
+			t.Fatalf("seed approval: %v", err)
+		}
+		if err := audit.RecordApproval(db, &approvals[i], "create", nil); err != nil {
+			t.Fatalf("record approval: %v", err)
+		}
+	}
+
+	if err := workflow.MigrateOverrunStep(db); err != nil {
+		t.Fatalf("MigrateOverrunStep: %v", err)
+	}
+	for i, want := range []int{4, models.OverrunStep, models.OverrunStep} {
+		var approval models.Approval
+		db.First(&approval, approvals[i].ID)
+		if approval.Step != want {
+			t.Errorf("approval %d at step %d, want %d", approval.ID, approval.Step, want)
+		}
+	}
+
+	result, err := audit.Verify()
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !result.Valid {
+		t.Errorf("audit chain broken after the migration: %+v", result.FirstBreak)
+	}
+
+	// Runs once
+	later := models.Approval{ProposalID: 3, ApproverRole: models.OverrunApprover, Step: legacyOverrunStep, Status: models.ApprovalPending}
+	db.Create(&later)
+	if err := workflow.MigrateOverrunStep(db); err != nil {
+		t.Fatalf("MigrateOverrunStep again: %v", err)
+	}
+	db.First(&later, later.ID)
+	if later.Step != legacyOverrunStep {
+		t.Errorf("second run moved approval %d to step %d", later.ID, later.Step)
+	}
+}
+
+// The state machine as a matrix: the status each action leads to from each
+// status, or nothing where it is not allowed. Submit and approve may end in
+// approved after the last workflow step, which Transition decides.
+func TestProposalTransitions(t *testing.T) {
+	statuses := []models.ProposalStatus{
+		models.StatusDraft, models.StatusSubmitted, models.StatusReviewing, models.StatusApproved,
+		models.StatusRejected, models.StatusRevision, models.StatusWithdrawn, models.StatusCancelled,
+		models.StatusInProgress, models.StatusCompleted, models.StatusClosed,
+	}
+	tests := []struct {
+		action        ProposalAction
+		to            map[models.ProposalStatus]models.ProposalStatus
+		actor         transitionActor
+		requireReason bool
+	}{
+		{ActionSubmit, map[models.ProposalStatus]models.ProposalStatus{
+			models.StatusDraft: models.StatusSubmitted, models.StatusRevision: models.StatusSubmitted, models.StatusWithdrawn: models.StatusSubmitted,
+		}, actorSubmitter, false},
+		{ActionApprove, map[models.ProposalStatus]models.ProposalStatus{
+			models.StatusSubmitted: models.StatusReviewing, models.StatusReviewing: models.StatusReviewing,
+		}, actorApprover, false},
+		{ActionReject, map[models.ProposalStatus]models.ProposalStatus{
+			models.StatusSubmitted: models.StatusRejected, models.StatusReviewing: models.StatusRejected,
+		}, actorApprover, true},
+		{ActionRequestRevision, map[models.ProposalStatus]models.ProposalStatus{
+			models.StatusSubmitted: models.StatusRevision, models.StatusReviewing: models.StatusRevision,
+		}, actorApprover, true},
+		{ActionWithdraw, map[models.ProposalStatus]models.ProposalStatus{
+			models.StatusSubmitted: models.StatusWithdrawn, models.StatusReviewing: models.StatusWithdrawn,
+		}, actorSubmitter, true},
+		{ActionCancel, map[models.ProposalStatus]models.ProposalStatus{
+			models.StatusDraft: models.StatusCancelled, models.StatusSubmitted: models.StatusCancelled, models.StatusReviewing: models.StatusCancelled,
+			models.StatusRevision: models.StatusCancelled, models.StatusWithdrawn: models.StatusCancelled,
+		}, actorSubmitterOrAdmin, true},
+		{ActionStart, map[models.ProposalStatus]models.ProposalStatus{
+			models.StatusApproved: models.StatusInProgress,
+		}, actorSubmitterOrAdmin, false},
+		{ActionComplete, map[models.ProposalStatus]models.ProposalStatus{
+			models.StatusInProgress: models.StatusCompleted,
+		}, actorSubmitterOrAdmin, false},
+		{ActionClose, map[models.ProposalStatus]models.ProposalStatus{
+			models.StatusCompleted: models.StatusClosed,
+		}, actorFinance, false},
+	}
+	if len(tests) != len(proposalTransitions) {
+		t.Errorf("%d transitions, the test covers %d", len(proposalTransitions), len(tests))
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.action), func(t *testing.T) {
+			transition, ok := proposalTransitions[tt.action]
+			if !ok {
+				t.Fatalf("no transition for %s", tt.action)
+			}
+			if transition.Actor != tt.actor || transition.RequireReason != tt.requireReason {
+				t.Errorf("actor %v, reason required %v; want %v, %v", transition.Actor, transition.RequireReason, tt.actor, tt.requireReason)
+			}
+			for _, from := range statuses {
+				allowed := hasStatus(transition.From, from)
+				want, wantAllowed := tt.to[from]
+				switch {
+				case allowed != wantAllowed:
+					t.Errorf("from %s: allowed %v, want %v", from, allowed, wantAllowed)
+				case allowed && transition.To != want:
+					t.Errorf("from %s: to %s, want %s", from, transition.To, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTransition(t *testing.T) {
+	db := openTestDB(t)
+	workflow, _ := newTestWorkflowService(t)
+	if err := db.Create(&models.InvestmentType{Code: "IT", Name: "Teknologi Informasi", IsActive: true}).Error; err != nil {
+		t.Fatalf("seed investment type: %v", err)
+	}
+
+	const ownerID = 1
+	procurement := models.WorkflowStep(models.RoleSourcingAndProcurement)
+	last := len(models.ApprovalWorkflow)
+	quotationID := uint(1)
+	tests := []struct {
+		name        string
+		status      models.ProposalStatus
+		step        int
+		setup       func(p *models.InvestmentProposal)
+		action      ProposalAction
+		sub         Subject
+		reason      string
+		want        models.ProposalStatus
+		wantStep    int
+		wantErr     error
+		wantProblem string
+	}{
+		{name: "submit a draft", status: models.StatusDraft, action: ActionSubmit, sub: Subject{UserID: ownerID}, want: models.StatusSubmitted, wantStep: 1},
+		{name: "resubmit starts over", status: models.StatusRevision, step: 3, action: ActionSubmit, sub: Subject{UserID: ownerID}, want: models.StatusSubmitted, wantStep: 1},
+		{name: "submit someone else's draft", status: models.StatusDraft, action: ActionSubmit, sub: Subject{UserID: 9, Role: models.RoleAdmin}, wantErr: ErrTransitionForbidden},
+		{name: "submit without a title", status: models.StatusDraft, setup: func(p *models.InvestmentProposal) { p.Title = " " }, action: ActionSubmit, sub: Subject{UserID: ownerID}, wantProblem: "title is required"},
+		{name: "approve the first step", status: models.StatusSubmitted, step: 1, action: ActionApprove, sub: Subject{UserID: 2, Role: models.RoleCorpFA}, want: models.StatusReviewing, wantStep: 2},
+		{name: "approve as another role", status: models.StatusSubmitted, step: 1, action: ActionApprove, sub: Subject{UserID: 2, Role: models.RoleCFO}, wantErr: ErrTransitionForbidden},
+		{name: "admin approves any step", status: models.StatusReviewing, step: 4, action: ActionApprove, sub: Subject{UserID: 2, Role: models.RoleAdmin}, want: models.StatusReviewing, wantStep: 5},
+		{name: "approve the last step", status: models.StatusReviewing, step: last, action: ActionApprove, sub: Subject{UserID: 2, Role: models.RoleCEO}, want: models.StatusApproved, wantStep: last + 1},
+		{name: "procurement approves without a recommendation", status: models.StatusReviewing, step: procurement, action: ActionApprove, sub: Subject{UserID: 2, Role: models.RoleSourcingAndProcurement}, wantProblem: "quotations must be compared and a vendor recommended before procurement approval"},
+		{name: "procurement approves the recommendation", status: models.StatusReviewing, step: procurement, setup: func(p *models.InvestmentProposal) { p.RecommendedQuotationID = &quotationID }, action: ActionApprove, sub: Subject{UserID: 2, Role: models.RoleSourcingAndProcurement}, want: models.StatusReviewing, wantStep: procurement + 1},
+		{name: "reject without a reason", status: models.StatusReviewing, step: 2, action: ActionReject, sub: Subject{UserID: 2, Role: models.RoleDirektur, Departments: []string{"IT"}}, wantProblem: "reason is required"},
+		{name: "reject", status: models.StatusReviewing, step: 2, action: ActionReject, sub: Subject{UserID: 2, Role: models.RoleDirektur, Departments: []string{"IT"}}, reason: "tidak perlu", want: models.StatusRejected, wantStep: 2},
+		{name: "reject for another department", status: models.StatusReviewing, step: 2, action: ActionReject, sub: Subject{UserID: 2, Role: models.RoleDirektur, Departments: []string{"FIN"}}, reason: "tidak perlu", wantErr: ErrTransitionForbidden},
+		{name: "request revision", status: models.StatusSubmitted, step: 1, action: ActionRequestRevision, sub: Subject{UserID: 2, Role: models.RoleCorpFA}, reason: "lengkapi", want: models.StatusRevision, wantStep: 1},
+		{name: "withdraw", status: models.StatusReviewing, step: 2, action: ActionWithdraw, sub: Subject{UserID: ownerID}, reason: "ditunda", want: models.StatusWithdrawn, wantStep: 2},
+		{name: "approve a withdrawn proposal", status: models.StatusWithdrawn, step: 2, action: ActionApprove, sub: Subject{UserID: 2, Role: models.RoleAdmin}, wantErr: ErrInvalidTransition},
+		{name: "admin cancels", status: models.StatusDraft, action: ActionCancel, sub: Subject{UserID: 2, Role: models.RoleAdmin}, reason: "duplikat", want: models.StatusCancelled},
+		{name: "cancel an approved proposal", status: models.StatusApproved, step: last + 1, action: ActionCancel, sub: Subject{UserID: ownerID}, reason: "batal", wantErr: ErrInvalidTransition},
+		{name: "start", status: models.StatusApproved, step: last + 1, action: ActionStart, sub: Subject{UserID: ownerID}, want: models.StatusInProgress, wantStep: last + 1},
+		{name: "complete during an overrun", status: models.StatusInProgress, step: last + 1, setup: func(p *models.InvestmentProposal) { p.OverrunPending = true }, action: ActionComplete, sub: Subject{UserID: ownerID}, wantProblem: "the spend overrun must be re-approved by the CFO first"},
+		{name: "complete", status: models.StatusInProgress, step: last + 1, action: ActionComplete, sub: Subject{UserID: ownerID}, want: models.StatusCompleted, wantStep: last + 1},
+		{name: "owner closes", status: models.StatusCompleted, step: last + 1, action: ActionClose, sub: Subject{UserID: ownerID}, wantErr: ErrTransitionForbidden},
+		{name: "Corp FA closes", status: models.StatusCompleted, step: last + 1, action: ActionClose, sub: Subject{UserID: 2, Role: models.RoleCorpFA}, want: models.StatusClosed, wantStep: last + 1},
+		{name: "unknown action", status: models.StatusDraft, action: "archive", sub: Subject{UserID: ownerID}, wantErr: ErrUnknownAction},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proposal := models.InvestmentProposal{
+				ProposalNumber:      fmt.Sprintf("INV-%d", i),
+				Title:               "Laptops",
+				Description:         "Laptop untuk tim",
+				Justification:       "Laptop lama rusak",
+				InvestmentType:      "IT",
+				DepartmentID:        "IT",
+				EstimatedCost:       1000000_00,
+				Currency:            "IDR",
+				ProposalDate:        time.Now(),
+				ExpectedStartDate:   time.Now(),
+				ExpectedCompletDate: time.Now().AddDate(0, 3, 0),
+				Status:              tt.status,
+				ApprovalStep:        tt.step,
+				SubmittedByID:       ownerID,
+			}
+			if tt.setup != nil {
+				tt.setup(&proposal)
+			}
+			if err := db.Create(&proposal).Error; err != nil {
+				t.Fatalf("seed proposal: %v", err)
+			}
+
+			sub := tt.sub
+			history, err := workflow.Transition(&sub, &proposal, tt.action, tt.reason)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Transition() error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantProblem != "":
+				var guardErr *TransitionGuardError
+				if !errors.As(err, &guardErr) || len(guardErr.Problems) != 1 || guardErr.Problems[0] != tt.wantProblem {
+					t.Fatalf("Transition() error = %v, want the problem %q", err, tt.wantProblem)
+				}
+			case err != nil:
+				t.Fatalf("Transition: %v", err)
+			default:
+				if history.FromStatus != tt.status || history.ToStatus != tt.want || history.Action != string(tt.action) {
+					t.Errorf("history = %s from %s to %s, want %s from %s to %s", history.Action, history.FromStatus, history.ToStatus, tt.action, tt.status, tt.want)
+				}
+			}
+
+			var stored models.InvestmentProposal
+			db.First(&stored, proposal.ID)
+			want, wantStep := tt.want, tt.wantStep
+			if err != nil {
+				want, wantStep = tt.status, tt.step
+			}
+			if stored.Status != want || stored.ApprovalStep != wantStep {
+				t.Errorf("stored proposal %s at step %d, want %s at step %d", stored.Status, stored.ApprovalStep, want, wantStep)
+			}
+			if proposal.Status != want || proposal.ApprovalStep != wantStep {
+				t.Errorf("proposal %s at step %d, want %s at step %d", proposal.Status, proposal.ApprovalStep, want, wantStep)
+			}
+		})
+	}
+}
+
+// A recommendation withdrawn after approveGuard passed still stops the
+// procurement approval, inside the transaction.
+func TestProcurementApprovalRechecksRecommendation(t *testing.T) {
+	db := openTestDB(t)
+	workflow, _ := newTestWorkflowService(t)
+
+	procurement := models.WorkflowStep(models.RoleSourcingAndProcurement)
+	proposal := models.InvestmentProposal{
+		ProposalNumber: "INV-1",
+		Title:          "Laptops",
+		DepartmentID:   "IT",
+		EstimatedCost:  1000000_00,
+		Currency:       "IDR",
+		ProposalDate:   time.Now(),
+		Status:         models.StatusReviewing,
+		ApprovalStep:   procurement,
+		SubmittedByID:  1,
+	}
+	if err := db.Create(&proposal).Error; err != nil {
+		t.Fatalf("seed proposal: %v", err)
+	}
+	// Loaded while it still had a recommendation
+	quotationID := uint(1)
+	proposal.RecommendedQuotationID = &quotationID
+
+	sub := Subject{UserID: 2, Role: models.RoleSourcingAndProcurement}
+	_, err := workflow.Transition(&sub, &proposal, ActionApprove, "")
+	var guardErr *TransitionGuardError
+	if !errors.As(err, &guardErr) || len(guardErr.Problems) != 1 || guardErr.Problems[0] != "quotations must be compared and a vendor recommended before procurement approval" {
+		t.Fatalf("Transition() error = %v, want the recommendation problem", err)
+	}
+
+	var stored models.InvestmentProposal
+	db.First(&stored, proposal.ID)
+	if stored.Status != models.StatusReviewing || stored.ApprovalStep != procurement {
+		t.Errorf("stored proposal %s at step %d, want it still waiting on procurement", stored.Status, stored.ApprovalStep)
+	}
+	var history int64
+	db.Model(&models.ProposalStatusHistory{}).Where("proposal_id = ?", proposal.ID).Count(&history)
+	if history != 0 {
+		t.Errorf("%d status history entries, want none", history)
+	}
+}
+
+func TestParseThresholds(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[models.UserRole]models.Amount
+		wantErr string
+	}{
+		{value: "", want: map[models.UserRole]models.Amount{}},
+		{value: "CFO=1000000000; ceo=5000000000", want: map[models.UserRole]models.Amount{models.RoleCFO: 1000000000_00, models.RoleCEO: 5000000000_00}},
+		{value: "Sourcing dan Procurement=0", want: map[models.UserRole]models.Amount{models.RoleSourcingAndProcurement: 0}},
+		{value: "CFO", wantErr: `invalid approval threshold "CFO": expected Role=AmountIDR`},
+		{value: "Admin=1000", wantErr: `invalid approval threshold "Admin=1000": Admin is not an approval workflow role`},
+		{value: "CFO=-1", wantErr: `invalid approval threshold "CFO=-1": amount must be a non-negative number`},
+		{value: "Sourcing dan Procurement=1000000", wantErr: `invalid approval threshold "Sourcing dan Procurement=1000000": Sourcing dan Procurement reviews every proposal`},
+	}
+	for _, tt := range tests {
+		got, err := parseThresholds(tt.value)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("parseThresholds(%q) error = %v, want %s", tt.value, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseThresholds(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+// Two spend changes that both loaded the proposal before either overran
+// open a single re-approval.
+func TestReconcileSpendRereadsProposal(t *testing.T) {
+	db := openTestDB(t)
+	workflow, _ := newTestWorkflowService(t)
+
+	cfo := models.User{Username: "cfo", Role: models.OverrunApprover, IsActive: true}
+	if err := db.Create(&cfo).Error; err != nil {
+		t.Fatalf("seed CFO: %v", err)
+	}
+	limit := models.Amount(1000000_00)
+	proposal := models.InvestmentProposal{
+		ProposalNumber:   "INV-1",
+		Title:            "Server",
+		Status:           models.StatusInProgress,
+		ApprovalStep:     len(models.ApprovalWorkflow) + 1,
+		EstimatedCost:    limit,
+		EstimatedCostIDR: &limit,
+		Currency:         "IDR",
+		ProposalDate:     time.Now(),
+		SubmittedByID:    1,
+	}
+	if err := db.Create(&proposal).Error; err != nil {
+		t.Fatalf("seed proposal: %v", err)
+	}
+	first, second := proposal, proposal
+
+	sub := Subject{UserID: 1}
+	for i, loaded := range []*models.InvestmentProposal{&first, &second} {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			record := models.SpendRecord{ProposalID: proposal.ID, PONumber: fmt.Sprintf("PO-%d", i), InvoiceAmount: 1200000_00, Currency: "IDR", AmountIDR: 1200000_00, InvoiceDate: time.Now(), CreatedByID: 1}
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+			return workflow.ReconcileSpend(tx, loaded, &sub)
+		})
+		if err != nil {
+			t.Fatalf("reconcile %d: %v", i+1, err)
+		}
+	}
+
+	var approvals, notifications int64
+	db.Model(&models.Approval{}).Where("proposal_id = ? AND step = ?", proposal.ID, models.OverrunStep).Count(&approvals)
+	db.Model(&models.Notification{}).Where("user_id = ?", cfo.ID).Count(&notifications)
+	if approvals != 1 || notifications != 1 {
+		t.Errorf("%d overrun approvals and %d notifications, want one of each", approvals, notifications)
+	}
+	if !second.OverrunPending || second.ActualCostIDR != 2400000_00 {
+		t.Errorf("after the second reconcile: overrun_pending %v, actual_cost_idr %s, want true and 2400000", second.OverrunPending, second.ActualCostIDR)
+	}
+
+	// Closed since it was loaded
+	stale := second
+	db.Model(&proposal).UpdateColumn("status", models.StatusClosed)
+	err := db.Transaction(func(tx *gorm.DB) error { return workflow.ReconcileSpend(tx, &stale, &sub) })
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("reconcile a closed proposal: %v, want ErrInvalidTransition", err)
+	}
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"fui-backend/models"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultComparisonWeights rank quotations mostly on price.
+var DefaultComparisonWeights = models.ComparisonWeights{Price: 60, LeadTime: 20, Warranty: 20}
+
+// QuotationInput is a quotation as sent by the client. An empty currency is
+// the proposal's.
+type QuotationInput struct {
+	VendorID       uint          `json:"vendor_id"`
+	Reference      string        `json:"reference"`
+	Price          models.Amount `json:"price"`
+	Currency       string        `json:"currency"`
+	LeadTimeDays   int           `json:"lead_time_days"`
+	WarrantyMonths int           `json:"warranty_months"`
+	AttachmentID   *uint         `json:"attachment_id"`
+	Notes          string        `json:"notes"`
+}
+
+// ComparisonInput asks for a ranking of the quotations of a proposal.
+// Without weights DefaultComparisonWeights apply; without a recommended
+// quotation the best-ranked one is recommended.
+type ComparisonInput struct {
+	Weights                *models.ComparisonWeights `json:"weights"`
+	RecommendedQuotationID *uint                     `json:"recommended_quotation_id"`
+	Reason                 string                    `json:"reason"`
+}
+
+// ErrQuotationsLocked is returned when the quotations of a proposal may no
+// longer change.
+var ErrQuotationsLocked = errors.New("quotations can only change while the proposal is in review, until procurement approves it")
+
+// QuotationError lists why a quotation or comparison is invalid.
+type QuotationError struct {
+	Problems []string
+}
+
+func (e *QuotationError) Error() string {
+	return "invalid quotation: " + strings.Join(e.Problems, ", ")
+}
+
+// QuotationService validates the vendor quotations of proposals and ranks
+// them for procurement.
+type QuotationService struct {
+	exchangeRateService *ExchangeRateService
+}
+
+func NewQuotationService(exchangeRateService *ExchangeRateService) *QuotationService {
+	return &QuotationService{exchangeRateService: exchangeRateService}
+}
+
+// CanChange reports whether the quotations of the proposal may still
+// change: while it is in the approval workflow, until procurement has
+// approved it.
+func (s *QuotationService) CanChange(proposal *models.InvestmentProposal) bool {
+	if proposal.Status != models.StatusSubmitted && proposal.Status != models.StatusReviewing {
+		return false
+	}
+	return proposal.ApprovalStep <= models.WorkflowStep(models.RoleSourcingAndProcurement)
+}
+
+// CheckChangeable reloads the proposal inside tx and returns
+// ErrQuotationsLocked when its quotations may no longer change. The
+// database begins write transactions immediately, so the proposal cannot
+// move on in the workflow before tx ends.
+func (s *QuotationService) CheckChangeable(tx *gorm.DB, proposal *models.InvestmentProposal) error {
+	if err := tx.First(proposal, proposal.ID).Error; err != nil {
+		return err
+	}
+	if !s.CanChange(proposal) {
+		return ErrQuotationsLocked
+	}
+	return nil
+}
+
+// Apply validates in and copies it onto the quotation of proposal. A
+// vendor offers at most one quotation per proposal.
+func (s *QuotationService) Apply(tx *gorm.DB, proposal *models.InvestmentProposal, quotation *models.Quotation, in QuotationInput) error {
+	var problems []string
+
+	quotation.ProposalID = proposal.ID
+	quotation.Reference = strings.TrimSpace(in.Reference)
+	quotation.Notes = strings.TrimSpace(in.Notes)
+
+	if in.VendorID != quotation.VendorID || quotation.ID == 0 {
+		var vendor models.Vendor
+		result := tx.Where("id = ? AND is_active = ?", in.VendorID, true).Limit(1).Find(&vendor)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			problems = append(problems, "vendor must be an active vendor")
+		} else {
+			quotation.VendorID = vendor.ID
+			quotation.Vendor = vendor
+		}
+	}
+
+	quotation.Price = in.Price
+	if quotation.Price <= 0 {
+		problems = append(problems, "price must be greater than 0")
+	}
+
+	quotation.Currency = strings.ToUpper(strings.TrimSpace(in.Currency))
+	if quotation.Currency == "" {
+		quotation.Currency = proposalCurrency(proposal)
+	}
+	if len(quotation.Currency) != 3 || strings.Trim(quotation.Currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		problems = append(problems, "currency must be a 3-letter ISO code")
+	}
+
+	quotation.LeadTimeDays = in.LeadTimeDays
+	if quotation.LeadTimeDays < 0 {
+		problems = append(problems, "lead_time_days cannot be negative")
+	}
+	quotation.WarrantyMonths = in.WarrantyMonths
+	if quotation.WarrantyMonths < 0 {
+		problems = append(problems, "warranty_months cannot be negative")
+	}
+
+	quotation.AttachmentID = in.AttachmentID
+	quotation.Attachment = nil
+	if in.AttachmentID != nil {
+		var count int64
+		if err := tx.Model(&models.Attachment{}).Where("id = ? AND proposal_id = ?", *in.AttachmentID, proposal.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			problems = append(problems, "attachment must be an attachment of the proposal")
+		}
+	}
+
+	if len(problems) > 0 {
+		return &QuotationError{Problems: problems}
+	}
+
+	var count int64
+	err := tx.Model(&models.Quotation{}).
+		Where("id <> ? AND proposal_id = ? AND vendor_id = ?", quotation.ID, proposal.ID, quotation.VendorID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return &QuotationError{Problems: []string{"the vendor already has a quotation for this proposal"}}
+	}
+	return nil
+}
+
+// Compare ranks the quotations of the proposal by in.Weights and picks the
+// recommended one. Prices are compared in IDR at today's rates. Each
+// criterion scores from 0 for the worst quotation to 100 for the best:
+// lower price and lead time and longer warranty are better. Ties go to the
+// lower price. The comparison is returned unsaved.
+func (s *QuotationService) Compare(tx *gorm.DB, proposal *models.InvestmentProposal, in ComparisonInput) (*models.QuotationComparison, error) {
+	var problems []string
+
+	weights := DefaultComparisonWeights
+	if in.Weights != nil {
+		weights = *in.Weights
+	}
+	if weights.Price < 0 || weights.LeadTime < 0 || weights.Warranty < 0 {
+		problems = append(problems, "weights cannot be negative")
+	} else if weights.Price+weights.LeadTime+weights.Warranty <= 0 {
+		problems = append(problems, "at least one weight must be greater than 0")
+	}
+
+	var quotations []models.Quotation
+	if err := tx.Preload("Vendor").Where("proposal_id = ?", proposal.ID).Order("id ASC").Find(&quotations).Error; err != nil {
+		return nil, err
+	}
+	if len(quotations) == 0 {
+		problems = append(problems, "the proposal has no quotations to compare")
+	}
+
+	now := time.Now()
+	ranking := make([]models.QuotationScore, len(quotations))
+	for i, q := range quotations {
+		ranking[i] = models.QuotationScore{
+			QuotationID:    q.ID,
+			VendorID:       q.VendorID,
+			VendorName:     q.Vendor.Name,
+			Price:          q.Price,
+			Currency:       q.Currency,
+			LeadTimeDays:   q.LeadTimeDays,
+			WarrantyMonths: q.WarrantyMonths,
+		}
+
+		priceIDR, _, err := s.exchangeRateService.ToIDR(tx, q.Money(), now)
+		if errors.Is(err, ErrNoExchangeRate) {
+			problems = append(problems, fmt.Sprintf("quotation of %s: %s", q.Vendor.Name, err.Error()))
+			continue
+		}
+		if errors.Is(err, models.ErrAmountOverflow) {
+			problems = append(problems, fmt.Sprintf("quotation of %s: price is too large to convert to IDR", q.Vendor.Name))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ranking[i].PriceIDR = priceIDR
+	}
+
+	if len(problems) > 0 {
+		return nil, &QuotationError{Problems: problems}
+	}
+
+	price := criterionScores(ranking, false, func(q models.QuotationScore) float64 { return q.PriceIDR.Float64() })
+	leadTime := criterionScores(ranking, false, func(q models.QuotationScore) float64 { return float64(q.LeadTimeDays) })
+	warranty := criterionScores(ranking, true, func(q models.QuotationScore) float64 { return float64(q.WarrantyMonths) })
+	total := weights.Price + weights.LeadTime + weights.Warranty
+	for i := range ranking {
+		q := &ranking[i]
+		q.PriceScore, q.LeadTimeScore, q.WarrantyScore = price[i], leadTime[i], warranty[i]
+		q.Score = roundScore((q.PriceScore*weights.Price + q.LeadTimeScore*weights.LeadTime + q.WarrantyScore*weights.Warranty) / total)
+	}
+	sort.SliceStable(ranking, func(i, j int) bool {
+		if ranking[i].Score != ranking[j].Score {
+			return ranking[i].Score > ranking[j].Score
+		}
+		return ranking[i].PriceIDR < ranking[j].PriceIDR
+	})
+	for i := range ranking {
+		ranking[i].Rank = i + 1
+	}
+
+	recommended := ranking[0]
+	reason := strings.TrimSpace(in.Reason)
+	if in.RecommendedQuotationID != nil {
+		found := false
+		for _, q := range ranking {
+			if q.QuotationID == *in.RecommendedQuotationID {
+				recommended, found = q, true
+				break
+			}
+		}
+		switch {
+		case !found:
+			problems = append(problems, "recommended_quotation_id is not a quotation of this proposal")
+		case recommended.Rank > 1 && reason == "":
+			problems = append(problems, "reason is required to recommend a quotation other than the best-ranked")
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &QuotationError{Problems: problems}
+	}
+
+	return &models.QuotationComparison{
+		ProposalID:             proposal.ID,
+		Weights:                weights,
+		Ranking:                ranking,
+		RecommendedQuotationID: recommended.QuotationID,
+		RecommendedVendorID:    recommended.VendorID,
+		Reason:                 reason,
+	}, nil
+}
+
+// Recommend saves the comparison and makes its recommendation the
+// proposal's.
+func (s *QuotationService) Recommend(tx *gorm.DB, proposal *models.InvestmentProposal, comparison *models.QuotationComparison) error {
+	if err := tx.Omit("RecommendedVendor", "ComparedBy").Create(comparison).Error; err != nil {
+		return err
+	}
+	proposal.RecommendedQuotationID = &comparison.RecommendedQuotationID
+	proposal.RecommendedVendorID = &comparison.RecommendedVendorID
+	return tx.Model(proposal).
+		Select("RecommendedQuotationID", "RecommendedVendorID").
+		UpdateColumns(proposal).Error
+}
+
+// Reset clears the recommendation of the proposal after its quotations
+// changed, so they have to be compared again.
+func (s *QuotationService) Reset(tx *gorm.DB, proposal *models.InvestmentProposal) error {
+	proposal.RecommendedQuotationID, proposal.RecommendedVendorID = nil, nil
+	return tx.Model(proposal).
+		Select("RecommendedQuotationID", "RecommendedVendorID").
+		UpdateColumns(proposal).Error
+}
+
+// criterionScores scores each quotation on one criterion, linearly from 0
+// for the worst value among them to 100 for the best. When all quotations
+// have the same value, each scores 100.
+func criterionScores(ranking []models.QuotationScore, higherIsBetter bool, value func(models.QuotationScore) float64) []float64 {
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, q := range ranking {
+		low = math.Min(low, value(q))
+		high = math.Max(high, value(q))
+	}
+
+	scores := make([]float64, len(ranking))
+	for i, q := range ranking {
+		switch {
+		case high == low:
+			scores[i] = 100
+		case higherIsBetter:
+			scores[i] = roundScore((value(q) - low) / (high - low) * 100)
+		default:
+			scores[i] = roundScore((high - value(q)) / (high - low) * 100)
+		}
+	}
+	return scores
+}
+
+func roundScore(score float64) float64 {
+	return math.Round(score*100) / 100
+}
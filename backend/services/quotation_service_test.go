@@ -0,0 +1,196 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"fui-backend/models"
+)
+
+func TestCriterionScores(t *testing.T) {
+	tests := []struct {
+		name           string
+		values         []float64
+		higherIsBetter bool
+		want           []float64
+	}{
+		{"none", nil, false, []float64{}},
+		{"single", []float64{7}, false, []float64{100}},
+		{"all equal", []float64{5, 5, 5}, true, []float64{100, 100, 100}},
+		{"lower is better", []float64{10, 30, 20}, false, []float64{100, 0, 50}},
+		{"higher is better", []float64{10, 30, 20}, true, []float64{0, 100, 50}},
+		{"rounded to cents", []float64{0, 1, 3}, true, []float64{0, 33.33, 100}},
+		{"rounded to cents, lower is better", []float64{0, 2, 3}, false, []float64{100, 33.33, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranking := make([]models.QuotationScore, len(tt.values))
+			for i, v := range tt.values {
+				ranking[i].LeadTimeDays = int(v)
+			}
+			got := criterionScores(ranking, tt.higherIsBetter, func(q models.QuotationScore) float64 { return float64(q.LeadTimeDays) })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("criterionScores(%v) = %v, want %v", tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareQuotations(t *testing.T) {
+	db := openTestDB(t)
+	service := NewQuotationService(NewExchangeRateService())
+
+	if err := db.Create(&models.ExchangeRate{Currency: "USD", EffectiveDate: time.Now().AddDate(0, 0, -1), Rate: 15000 * models.RateOne, Source: models.ExchangeRateManual}).Error; err != nil {
+		t.Fatalf("seed exchange rate: %v", err)
+	}
+
+	proposal := models.InvestmentProposal{ProposalNumber: "INV-1", Title: "Laptops", Status: models.StatusReviewing, SubmittedByID: 1}
+	if err := db.Create(&proposal).Error; err != nil {
+		t.Fatalf("seed proposal: %v", err)
+	}
+	// Price in IDR, lead time and warranty scores with the default weights:
+	// A 100/0/0 = 60, B 50/100/100 = 70, C 0/50/0 = 10
+	ids := map[string]uint{}
+	for _, q := range []struct {
+		vendor   string
+		price    models.Amount
+		currency string
+		leadTime int
+		warranty int
+	}{
+		{"A", 1000000_00, "IDR", 30, 12},
+		{"B", 100_00, "USD", 10, 24}, // IDR 1.500.000
+		{"C", 2000000_00, "IDR", 20, 12},
+	} {
+		vendor := models.Vendor{Code: q.vendor, Name: q.vendor, IsActive: true}
+		if err := db.Create(&vendor).Error; err != nil {
+			t.Fatalf("seed vendor: %v", err)
+		}
+		quotation := models.Quotation{ProposalID: proposal.ID, VendorID: vendor.ID, Price: q.price, Currency: q.currency, LeadTimeDays: q.leadTime, WarrantyMonths: q.warranty}
+		if err := db.Create(&quotation).Error; err != nil {
+			t.Fatalf("seed quotation: %v", err)
+		}
+		ids[q.vendor] = quotation.ID
+	}
+	id := func(vendor string) *uint {
+		id := ids[vendor]
+		return &id
+	}
+
+	tests := []struct {
+		name        string
+		in          ComparisonInput
+		ranking     string // vendor:score in rank order
+		recommended string
+		problems    []string
+	}{
+		{
+			name:        "default weights",
+			ranking:     "B:70 A:60 C:10",
+			recommended: "B",
+		},
+		{
+			name:        "price only",
+			in:          ComparisonInput{Weights: &models.ComparisonWeights{Price: 1}},
+			ranking:     "A:100 B:50 C:0",
+			recommended: "A",
+		},
+		{
+			name:        "ties go to the lower price",
+			in:          ComparisonInput{Weights: &models.ComparisonWeights{Warranty: 5}},
+			ranking:     "B:100 A:0 C:0",
+			recommended: "B",
+		},
+		{
+			name:        "another quotation with a reason",
+			in:          ComparisonInput{RecommendedQuotationID: id("C"), Reason: " vendor resmi "},
+			ranking:     "B:70 A:60 C:10",
+			recommended: "C",
+		},
+		{
+			name:        "the best-ranked needs no reason",
+			in:          ComparisonInput{RecommendedQuotationID: id("B")},
+			ranking:     "B:70 A:60 C:10",
+			recommended: "B",
+		},
+		{
+			name:     "another quotation without a reason",
+			in:       ComparisonInput{RecommendedQuotationID: id("A")},
+			problems: []string{"reason is required to recommend a quotation other than the best-ranked"},
+		},
+		{
+			name:     "quotation of another proposal",
+			in:       ComparisonInput{RecommendedQuotationID: func() *uint { id := uint(999); return &id }()},
+			problems: []string{"recommended_quotation_id is not a quotation of this proposal"},
+		},
+		{
+			name:     "negative weight",
+			in:       ComparisonInput{Weights: &models.ComparisonWeights{Price: 100, LeadTime: -1}},
+			problems: []string{"weights cannot be negative"},
+		},
+		{
+			name:     "no weight",
+			in:       ComparisonInput{Weights: &models.ComparisonWeights{}},
+			problems: []string{"at least one weight must be greater than 0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparison, err := service.Compare(db, &proposal, tt.in)
+			if len(tt.problems) > 0 {
+				quotationErr, ok := err.(*QuotationError)
+				if !ok || !reflect.DeepEqual(quotationErr.Problems, tt.problems) {
+					t.Fatalf("Compare() error = %v, want problems %q", err, tt.problems)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Compare: %v", err)
+			}
+
+			var ranking []string
+			for i, q := range comparison.Ranking {
+				if q.Rank != i+1 {
+					t.Errorf("%s has rank %d at position %d", q.VendorName, q.Rank, i+1)
+				}
+				if q.VendorName == "B" && q.PriceIDR != 1500000_00 {
+					t.Errorf("B price_idr = %v, want 1500000 at the USD rate", q.PriceIDR)
+				}
+				ranking = append(ranking, fmt.Sprintf("%s:%v", q.VendorName, q.Score))
+			}
+			if got := strings.Join(ranking, " "); got != tt.ranking {
+				t.Errorf("ranking = %s, want %s", got, tt.ranking)
+			}
+			if comparison.RecommendedQuotationID != ids[tt.recommended] {
+				t.Errorf("recommended quotation %d, want %s's %d", comparison.RecommendedQuotationID, tt.recommended, ids[tt.recommended])
+			}
+			if comparison.Reason != strings.TrimSpace(tt.in.Reason) {
+				t.Errorf("reason = %q, want it trimmed", comparison.Reason)
+			}
+		})
+	}
+}
+
+func TestCompareQuotationsProblems(t *testing.T) {
+	db := openTestDB(t)
+	service := NewQuotationService(NewExchangeRateService())
+
+	proposal := models.InvestmentProposal{ProposalNumber: "INV-1", Title: "Laptops", Status: models.StatusReviewing, SubmittedByID: 1}
+	if err := db.Create(&proposal).Error; err != nil {
+		t.Fatalf("seed proposal: %v", err)
+	}
+	if _, err := service.Compare(db, &proposal, ComparisonInput{}); err == nil || err.Error() != "invalid quotation: the proposal has no quotations to compare" {
+		t.Errorf("Compare without quotations: %v", err)
+	}
+
+	vendor := models.Vendor{Code: "EU", Name: "Euro Vendor", IsActive: true}
+	db.Create(&vendor)
+	db.Create(&models.Quotation{ProposalID: proposal.ID, VendorID: vendor.ID, Price: 100_00, Currency: "EUR"})
+	_, err := service.Compare(db, &proposal, ComparisonInput{})
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid quotation: quotation of Euro Vendor: ") {
+		t.Errorf("Compare without an EUR rate: %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"fui-backend/config"
+	"fui-backend/database"
+	"fui-backend/models"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+const retentionBatchSize = 1000
+
+var archiveNamePattern = regexp.MustCompile(`^login_logs-(\d{4}-\d{2})\.ndjson\.gz$`)
+
+// RetentionService moves expired login logs into compressed monthly NDJSON
+// archives and then removes them from the database for good.
+type RetentionService struct {
+	config *config.RetentionConfig
+	mu     sync.Mutex
+}
+
+type RetentionResult struct {
+	Cutoff   time.Time      `json:"cutoff"`
+	Archived int            `json:"archived"`
+	Months   map[string]int `json:"months"`
+}
+
+type LoginLogArchive struct {
+	Name       string    `json:"name"`
+	Month      string    `json:"month"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+func NewRetentionService(cfg *config.RetentionConfig) *RetentionService {
+	return &RetentionService{config: cfg}
+}
+
+// Start runs the retention job once and then every IntervalHours in the
+// background. It does nothing when retention is disabled.
+func (s *RetentionService) Start() {
+	if s.config.LoginLogDays <= 0 {
+		log.Println("Login log retention disabled")
+		return
+	}
+
+	interval := time.Duration(s.config.IntervalHours) * time.Hour
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	go func() {
+		for {
+			if result, err := s.ArchiveLoginLogs(); err != nil {
+				log.Println("Warning: login log retention failed:", err)
+			} else if result.Archived > 0 {
+				log.Printf("Login log retention archived %d rows older than %s", result.Archived, result.Cutoff.Format("2006-01-02"))
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// ArchiveLoginLogs archives and hard-deletes login logs older than the
+// retention period, as well as soft-deleted rows, in batches. Each batch is
+// appended to its month's file as a new gzip member before the rows are
+// deleted, so an interrupted run can only duplicate rows in an archive,
+// never lose them.
+func (s *RetentionService) ArchiveLoginLogs() (*RetentionResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := &RetentionResult{
+		Cutoff: time.Now().AddDate(0, 0, -s.config.LoginLogDays),
+		Months: map[string]int{},
+	}
+
+	if s.config.LoginLogDays <= 0 {
+		return result, nil
+	}
+
+	if err := os.MkdirAll(s.config.ArchiveDir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create archive dir: %w", err)
+	}
+
+	db := database.GetDB()
+	for {
+		var logs []models.LoginLog
+		err := db.Unscoped().
+			Where("login_at < ? OR deleted_at IS NOT NULL", models.LoginTimeAfter(result.Cutoff)).
+			Order("id ASC").Limit(retentionBatchSize).
+			Find(&logs).Error
+		if err != nil {
+			return result, fmt.Errorf("failed to read expired login logs: %w", err)
+		}
+		if len(logs) == 0 {
+			return result, nil
+		}
+
+		byMonth := map[string][]models.LoginLog{}
+		ids := make([]uint, 0, len(logs))
+		for _, l := range logs {
+			month := l.LoginAt.Local().Format("2006-01")
+			byMonth[month] = append(byMonth[month], l)
+			ids = append(ids, l.ID)
+		}
+
+		for month, rows := range byMonth {
+			if err := s.appendArchive(month, rows); err != nil {
+				return result, err
+			}
+		}
+
+		if err := db.Unscoped().Delete(&models.LoginLog{}, ids).Error; err != nil {
+			return result, fmt.Errorf("failed to delete archived login logs: %w", err)
+		}
+
+		for month, rows := range byMonth {
+			result.Months[month] += len(rows)
+		}
+		result.Archived += len(logs)
+	}
+}
+
+func (s *RetentionService) appendArchive(month string, rows []models.LoginLog) error {
+	path := filepath.Join(s.config.ArchiveDir, fmt.Sprintf("login_logs-%s.ndjson.gz", month))
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open archive %s: %w", path, err)
+	}
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	enc := json.NewEncoder(zw)
+	for i := range rows {
+		if err := enc.Encode(archivedLoginLog(&rows[i])); err != nil {
+			return fmt.Errorf("failed to write archive %s: %w", path, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write archive %s: %w", path, err)
+	}
+
+	return f.Sync()
+}
+
+// archivedLoginLog adds the soft-delete timestamp, which LoginLog hides
+// from JSON, so archives keep the full row.
+func archivedLoginLog(l *models.LoginLog) interface{} {
+	var deletedAt *time.Time
+	if l.DeletedAt.Valid {
+		deletedAt = &l.DeletedAt.Time
+	}
+	return struct {
+		*models.LoginLog
+		DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	}{l, deletedAt}
+}
+
+// ListArchives returns the archive files, newest month first.
+func (s *RetentionService) ListArchives() ([]LoginLogArchive, error) {
+	entries, err := os.ReadDir(s.config.ArchiveDir)
+	if os.IsNotExist(err) {
+		return []LoginLogArchive{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive dir: %w", err)
+	}
+
+	archives := []LoginLogArchive{}
+	for _, entry := range entries {
+		m := archiveNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		archives = append(archives, LoginLogArchive{
+			Name:       entry.Name(),
+			Month:      m[1],
+			Size:       info.Size(),
+			ModifiedAt: info.ModTime(),
+		})
+	}
+
+	sort.Slice(archives, func(i, j int) bool { return archives[i].Month > archives[j].Month })
+	return archives, nil
+}
+
+// ArchivePath returns the path of an archive by file name, rejecting
+// anything that is not an archive name.
+func (s *RetentionService) ArchivePath(name string) (string, error) {
+	if !archiveNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid archive name")
+	}
+
+	path := filepath.Join(s.config.ArchiveDir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
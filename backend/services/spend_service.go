@@ -0,0 +1,258 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"fui-backend/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SpendRecordInput is a spend record as sent by the client. InvoiceDate is
+// YYYY-MM-DD; an empty currency is the proposal's.
+type SpendRecordInput struct {
+	PONumber      string        `json:"po_number"`
+	InvoiceNumber string        `json:"invoice_number"`
+	VendorID      *uint         `json:"vendor_id"`
+	InvoiceAmount models.Amount `json:"invoice_amount"`
+	Currency      string        `json:"currency"`
+	InvoiceDate   string        `json:"invoice_date"`
+	Description   string        `json:"description"`
+}
+
+// SpendError lists why a spend record is invalid.
+type SpendError struct {
+	Problems []string
+}
+
+func (e *SpendError) Error() string {
+	return "invalid spend record: " + strings.Join(e.Problems, ", ")
+}
+
+// SpendVariance compares the actual spend of a proposal with its estimated
+// cost, in IDR. A positive variance is an overrun. VarianceIDR and
+// VariancePercent are nil when the estimated cost was never converted.
+type SpendVariance struct {
+	ProposalID       uint                  `json:"proposal_id"`
+	ProposalNumber   string                `json:"proposal_number"`
+	Title            string                `json:"title"`
+	DepartmentID     string                `json:"department_id"`
+	Status           models.ProposalStatus `json:"status"`
+	EstimatedCost    models.Amount         `json:"estimated_cost"`
+	Currency         string                `json:"currency"`
+	EstimatedCostIDR *models.Amount        `json:"estimated_cost_idr"`
+	ApprovedCostIDR  *models.Amount        `json:"approved_cost_idr"`
+	ActualCostIDR    models.Amount         `json:"actual_cost_idr"`
+	VarianceIDR      *models.Amount        `json:"variance_idr"`
+	VariancePercent  *float64              `json:"variance_percent"`
+	SpendCount       int64                 `json:"spend_count"`
+	OverrunPending   bool                  `json:"overrun_pending"`
+}
+
+// VarianceReport is the spend variance of a set of proposals with its
+// totals. The estimated and variance totals cover the proposals whose
+// estimated cost is known.
+type VarianceReport struct {
+	Proposals        []SpendVariance `json:"proposals"`
+	EstimatedCostIDR models.Amount   `json:"estimated_cost_idr"`
+	ActualCostIDR    models.Amount   `json:"actual_cost_idr"`
+	VarianceIDR      models.Amount   `json:"variance_idr"`
+	VariancePercent  *float64        `json:"variance_percent"`
+	OverrunCount     int             `json:"overrun_count"` // proposals that spent more than estimated
+}
+
+// SpendService validates the actual spend of approved proposals and
+// compares it with their estimated cost.
+type SpendService struct {
+	exchangeRateService *ExchangeRateService
+}
+
+func NewSpendService(exchangeRateService *ExchangeRateService) *SpendService {
+	return &SpendService{exchangeRateService: exchangeRateService}
+}
+
+// Apply validates in and copies it onto the spend record of proposal,
+// converting the invoice amount to IDR at the rate of the invoice date. An
+// invoice is recorded once per purchase order.
+func (s *SpendService) Apply(tx *gorm.DB, proposal *models.InvestmentProposal, record *models.SpendRecord, in SpendRecordInput) error {
+	var problems []string
+
+	record.ProposalID = proposal.ID
+	record.PONumber = strings.TrimSpace(in.PONumber)
+	record.InvoiceNumber = strings.TrimSpace(in.InvoiceNumber)
+	record.Description = strings.TrimSpace(in.Description)
+	if record.PONumber == "" {
+		problems = append(problems, "po_number is required")
+	} else if len(record.PONumber) > 50 {
+		problems = append(problems, "po_number must be at most 50 characters")
+	}
+	if len(record.InvoiceNumber) > 50 {
+		problems = append(problems, "invoice_number must be at most 50 characters")
+	}
+
+	record.VendorID = in.VendorID
+	record.Vendor = nil
+	if in.VendorID != nil {
+		var count int64
+		if err := tx.Model(&models.Vendor{}).Where("id = ?", *in.VendorID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			problems = append(problems, "vendor not found")
+		}
+	}
+
+	record.InvoiceAmount = in.InvoiceAmount
+	if record.InvoiceAmount <= 0 {
+		problems = append(problems, "invoice_amount must be greater than 0")
+	}
+
+	record.Currency = strings.ToUpper(strings.TrimSpace(in.Currency))
+	if record.Currency == "" {
+		record.Currency = proposalCurrency(proposal)
+	}
+	validCurrency := len(record.Currency) == 3 && strings.Trim(record.Currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") == ""
+	if !validCurrency {
+		problems = append(problems, "currency must be a 3-letter ISO code")
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(in.InvoiceDate), time.Local)
+	switch {
+	case err != nil:
+		problems = append(problems, "invoice_date must be a date (YYYY-MM-DD)")
+	case date.After(time.Now()):
+		problems = append(problems, "invoice_date cannot be in the future")
+	default:
+		record.InvoiceDate = date
+	}
+
+	if validCurrency && !record.InvoiceDate.IsZero() && record.InvoiceAmount > 0 {
+		amount, rate, err := s.exchangeRateService.ToIDR(tx, record.Money(), record.InvoiceDate)
+		switch {
+		case errors.Is(err, ErrNoExchangeRate):
+			problems = append(problems, err.Error())
+		case errors.Is(err, models.ErrAmountOverflow):
+			problems = append(problems, "invoice_amount is too large to convert to IDR")
+		case err != nil:
+			return err
+		default:
+			record.ExchangeRate, record.AmountIDR = rate, amount
+		}
+	}
+
+	if len(problems) > 0 {
+		return &SpendError{Problems: problems}
+	}
+
+	if record.InvoiceNumber != "" {
+		var count int64
+		err := tx.Model(&models.SpendRecord{}).
+			Where("id <> ? AND proposal_id = ? AND po_number = ? AND invoice_number = ?",
+				record.ID, proposal.ID, record.PONumber, record.InvoiceNumber).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return &SpendError{Problems: []string{fmt.Sprintf("invoice %s of PO %s is already recorded", record.InvoiceNumber, record.PONumber)}}
+		}
+	}
+	return nil
+}
+
+// Variance returns the spend variance of one proposal.
+func (s *SpendService) Variance(tx *gorm.DB, proposal *models.InvestmentProposal) (*SpendVariance, error) {
+	var count int64
+	if err := tx.Model(&models.SpendRecord{}).Where("proposal_id = ?", proposal.ID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	variance := spendVariance(proposal, count)
+	return &variance, nil
+}
+
+// Report returns the spend variance of the funded proposals query selects,
+// which must select from investment_proposals, ordered by proposal number.
+func (s *SpendService) Report(query *gorm.DB) (*VarianceReport, error) {
+	var proposals []models.InvestmentProposal
+	err := query.
+		Where("investment_proposals.status IN ?", models.FundedStatuses).
+		Order("investment_proposals.proposal_number ASC").
+		Find(&proposals).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, len(proposals))
+	for i := range proposals {
+		ids[i] = proposals[i].ID
+	}
+	var counts []struct {
+		ProposalID uint
+		Count      int64
+	}
+	if len(ids) > 0 {
+		err := query.Session(&gorm.Session{NewDB: true}).
+			Model(&models.SpendRecord{}).
+			Select("proposal_id, COUNT(*) AS count").
+			Where("proposal_id IN ?", ids).
+			Group("proposal_id").
+			Scan(&counts).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	spendCounts := make(map[uint]int64, len(counts))
+	for _, c := range counts {
+		spendCounts[c.ProposalID] = c.Count
+	}
+
+	report := &VarianceReport{Proposals: make([]SpendVariance, 0, len(proposals))}
+	for i := range proposals {
+		v := spendVariance(&proposals[i], spendCounts[proposals[i].ID])
+		report.Proposals = append(report.Proposals, v)
+		report.ActualCostIDR += v.ActualCostIDR
+		if v.VarianceIDR != nil {
+			report.EstimatedCostIDR += *v.EstimatedCostIDR
+			report.VarianceIDR += *v.VarianceIDR
+			if *v.VarianceIDR > 0 {
+				report.OverrunCount++
+			}
+		}
+	}
+	report.VariancePercent = variancePercent(report.VarianceIDR, report.EstimatedCostIDR)
+	return report, nil
+}
+
+func spendVariance(p *models.InvestmentProposal, spendCount int64) SpendVariance {
+	v := SpendVariance{
+		ProposalID:       p.ID,
+		ProposalNumber:   p.ProposalNumber,
+		Title:            p.Title,
+		DepartmentID:     p.DepartmentID,
+		Status:           p.Status,
+		EstimatedCost:    p.EstimatedCost,
+		Currency:         proposalCurrency(p),
+		EstimatedCostIDR: p.EstimatedCostIDR,
+		ApprovedCostIDR:  p.ApprovedCostIDR,
+		ActualCostIDR:    p.ActualCostIDR,
+		SpendCount:       spendCount,
+		OverrunPending:   p.OverrunPending,
+	}
+	if p.EstimatedCostIDR != nil {
+		variance := p.ActualCostIDR - *p.EstimatedCostIDR
+		v.VarianceIDR = &variance
+		v.VariancePercent = variancePercent(variance, *p.EstimatedCostIDR)
+	}
+	return v
+}
+
+// variancePercent returns variance as a percentage of estimated, nil when
+// nothing was estimated.
+func variancePercent(variance, estimated models.Amount) *float64 {
+	if estimated <= 0 {
+		return nil
+	}
+	return floatPtr(round2(float64(variance) / float64(estimated) * 100))
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+)
+
+type UserAgentInfo struct {
+	Browser    string
+	OS         string
+	DeviceType string // desktop, mobile, tablet, bot
+}
+
+type uaPattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// Order matters: Edge and Opera also send "Chrome/", and Chrome also sends
+// "Safari/".
+var browserPatterns = []uaPattern{
+	{"Edge", regexp.MustCompile(`Edg(?:e|A|iOS)?/(\d+)`)},
+	{"Opera", regexp.MustCompile(`(?:OPR|Opera)/(\d+)`)},
+	{"Samsung Internet", regexp.MustCompile(`SamsungBrowser/(\d+)`)},
+	{"Firefox", regexp.MustCompile(`(?:Firefox|FxiOS)/(\d+)`)},
+	{"Chrome", regexp.MustCompile(`(?:Chrome|CriOS)/(\d+)`)},
+	{"Safari", regexp.MustCompile(`Version/(\d+).*Safari/`)},
+	{"Internet Explorer", regexp.MustCompile(`(?:MSIE |Trident/.*rv:)(\d+)`)},
+	{"curl", regexp.MustCompile(`curl/(\d+)`)},
+	{"Postman", regexp.MustCompile(`PostmanRuntime/(\d+)`)},
+	{"Python", regexp.MustCompile(`python-requests/(\d+)`)},
+	{"Go", regexp.MustCompile(`Go-http-client/(\d+)`)},
+}
+
+var osPatterns = []uaPattern{
+	{"Windows", regexp.MustCompile(`Windows NT`)},
+	{"iOS", regexp.MustCompile(`iPhone|iPad|iPod`)},
+	{"macOS", regexp.MustCompile(`Mac OS X|Macintosh`)},
+	{"Android", regexp.MustCompile(`Android`)},
+	{"ChromeOS", regexp.MustCompile(`CrOS`)},
+	{"Linux", regexp.MustCompile(`Linux|X11`)},
+}
+
+var botPattern = regexp.MustCompile(`(?i)bot|crawler|spider|curl|postman|python-requests|go-http-client|wget`)
+
+// ParseUserAgent extracts browser (with major version), OS and device type
+// from a User-Agent header. Unknown values are returned as "Other".
+func ParseUserAgent(ua string) UserAgentInfo {
+	info := UserAgentInfo{Browser: "Other", OS: "Other", DeviceType: "desktop"}
+	if ua == "" {
+		info.DeviceType = "unknown"
+		return info
+	}
+
+	for _, p := range browserPatterns {
+		if m := p.re.FindStringSubmatch(ua); m != nil {
+			info.Browser = p.name + " " + m[1]
+			break
+		}
+	}
+
+	for _, p := range osPatterns {
+		if p.re.MatchString(ua) {
+			info.OS = p.name
+			break
+		}
+	}
+
+	switch {
+	case botPattern.MatchString(ua):
+		info.DeviceType = "bot"
+	case strings.Contains(ua, "iPad") || strings.Contains(ua, "Tablet") ||
+		(strings.Contains(ua, "Android") && !strings.Contains(ua, "Mobile")):
+		info.DeviceType = "tablet"
+	case strings.Contains(ua, "Mobile") || strings.Contains(ua, "iPhone"):
+		info.DeviceType = "mobile"
+	}
+
+	return info
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"fui-backend/models"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var vendorCodePattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9_-]{0,19}$`)
+
+// VendorInput is a vendor as sent by the client.
+type VendorInput struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	TaxID       string `json:"tax_id"`
+	ContactName string `json:"contact_name"`
+	Email       string `json:"email"`
+	Phone       string `json:"phone"`
+	Address     string `json:"address"`
+	IsActive    *bool  `json:"is_active"`
+}
+
+// VendorError lists why a vendor is invalid.
+type VendorError struct {
+	Problems []string
+}
+
+func (e *VendorError) Error() string {
+	return "invalid vendor: " + strings.Join(e.Problems, ", ")
+}
+
+// VendorService validates the vendor master data.
+type VendorService struct{}
+
+func NewVendorService() *VendorService {
+	return &VendorService{}
+}
+
+// Apply validates in and copies it onto vendor. The code of a saved vendor
+// cannot change.
+func (s *VendorService) Apply(tx *gorm.DB, vendor *models.Vendor, in VendorInput) error {
+	var problems []string
+
+	code := strings.ToUpper(strings.TrimSpace(in.Code))
+	switch {
+	case vendor.ID != 0 && code != "" && code != vendor.Code:
+		problems = append(problems, "code cannot be changed")
+	case vendor.ID == 0 && !vendorCodePattern.MatchString(code):
+		problems = append(problems, "code must be 1-20 letters, digits, - or _")
+	case vendor.ID == 0:
+		vendor.Code = code
+	}
+
+	vendor.Name = strings.TrimSpace(in.Name)
+	if vendor.Name == "" {
+		problems = append(problems, "name is required")
+	}
+
+	vendor.TaxID = strings.TrimSpace(in.TaxID)
+	vendor.ContactName = strings.TrimSpace(in.ContactName)
+	vendor.Email = strings.TrimSpace(in.Email)
+	vendor.Phone = strings.TrimSpace(in.Phone)
+	vendor.Address = strings.TrimSpace(in.Address)
+	if vendor.Email != "" && !strings.Contains(vendor.Email, "@") {
+		problems = append(problems, "email is not a valid address")
+	}
+
+	if in.IsActive != nil {
+		vendor.IsActive = *in.IsActive
+	} else if vendor.ID == 0 {
+		vendor.IsActive = true
+	}
+
+	if len(problems) > 0 {
+		return &VendorError{Problems: problems}
+	}
+
+	var count int64
+	err := tx.Model(&models.Vendor{}).
+		Where("id <> ? AND (code = ? OR LOWER(name) = LOWER(?))", vendor.ID, vendor.Code, vendor.Name).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return &VendorError{Problems: []string{"another vendor already uses this code or name"}}
+	}
+	return nil
+}
+
+// InUse reports whether quotations or spend records refer to the vendor.
+func (s *VendorService) InUse(tx *gorm.DB, vendor *models.Vendor) (bool, error) {
+	for _, model := range []interface{}{&models.Quotation{}, &models.SpendRecord{}} {
+		var count int64
+		if err := tx.Model(model).Where("vendor_id = ?", vendor.ID).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -4,6 +4,7 @@ import { useEffect, useState } from "react";
 import {
   loginLogService,
   LoginLog,
+  LoginLogQuery,
   LoginStats,
 } from "@/services/login-log.service";
 import {
@@ -21,19 +22,32 @@ export default function LoginLogsPage() {
   const [logs, setLogs] = useState<LoginLog[]>([]);
   const [stats, setStats] = useState<LoginStats | null>(null);
   const [loading, setLoading] = useState(true);
+  const [loadingMore, setLoadingMore] = useState(false);
   const [filter, setFilter] = useState<"all" | "success" | "failed">("all");
+  const [nextCursor, setNextCursor] = useState<string | undefined>();
+  const [total, setTotal] = useState(0);
+
+  const buildQuery = (cursor?: string): LoginLogQuery => {
+    const query: LoginLogQuery = { cursor };
+    if (filter === "success") query.success = true;
+    if (filter === "failed") query.success = false;
+    return query;
+  };
 
   useEffect(() => {
     loadData();
-  }, []);
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [filter]);
 
   const loadData = async () => {
     try {
-      const [logsData, statsData] = await Promise.all([
-        loginLogService.getLoginLogs(),
+      const [page, statsData] = await Promise.all([
+        loginLogService.getLoginLogs(buildQuery()),
         loginLogService.getLoginStats(),
       ]);
-      setLogs(logsData);
+      setLogs(page.data);
+      setNextCursor(page.next_cursor);
+      setTotal(page.total);
       setStats(statsData);
     } catch (error) {
       console.error("Failed to load login logs:", error);
@@ -42,11 +56,20 @@ export default function LoginLogsPage() {
     }
   };
 
-  const filteredLogs = logs.filter((log) => {
-    if (filter === "success") return log.success;
-    if (filter === "failed") return !log.success;
-    return true;
-  });
+  const loadMore = async () => {
+    if (!nextCursor) return;
+    setLoadingMore(true);
+    try {
+      const page = await loginLogService.getLoginLogs(buildQuery(nextCursor));
+      setLogs((prev) => [...prev, ...page.data]);
+      setNextCursor(page.next_cursor);
+    } catch (error) {
+      console.error("Failed to load more login logs:", error);
+    } finally {
+      setLoadingMore(false);
+    }
+  };
+
 
   if (loading) {
     return (
@@ -157,7 +180,7 @@ export default function LoginLogsPage() {
       <div className="bg-white rounded-lg shadow overflow-hidden">
         <div className="px-6 py-4 border-b border-gray-200">
           <h2 className="text-xl font-semibold text-gray-900">
-            Riwayat Login ({filteredLogs.length})
+            Riwayat Login ({logs.length} dari {total})
           </h2>
         </div>
 
@@ -189,7 +212,7 @@ export default function LoginLogsPage() {
               </tr>
             </thead>
             <tbody className="bg-white divide-y divide-gray-200">
-              {filteredLogs.length === 0 ? (
+              {logs.length === 0 ? (
                 <tr>
                   <td
                     colSpan={7}
@@ -200,7 +223,7 @@ export default function LoginLogsPage() {
                   </td>
                 </tr>
               ) : (
-                filteredLogs.map((log) => (
+                logs.map((log) => (
                   <tr
                     key={log.id}
                     className="hover:bg-gray-50 transition-colors"
@@ -236,6 +259,14 @@ export default function LoginLogsPage() {
                         <Globe className="w-4 h-4 text-gray-400 mr-2" />
                         <span className="text-sm text-gray-600">
                           {log.ip_address}
+                          {(log.site || log.city || log.country) && (
+                            <span className="block text-xs text-gray-400">
+                              {log.site ||
+                                [log.city, log.country]
+                                  .filter(Boolean)
+                                  .join(", ")}
+                            </span>
+                          )}
                         </span>
                       </div>
                     </td>
@@ -246,14 +277,8 @@ export default function LoginLogsPage() {
                           className="text-xs text-gray-600 max-w-xs truncate"
                           title={log.user_agent}
                         >
-                          {log.user_agent.includes("Chrome")
-                            ? "Chrome"
-                            : log.user_agent.includes("Firefox")
-                            ? "Firefox"
-                            : log.user_agent.includes("Safari")
-                            ? "Safari"
-                            : log.user_agent.includes("Edge")
-                            ? "Edge"
+                          {log.browser
+                            ? `${log.browser} / ${log.os}`
                             : "Other"}
                         </span>
                       </div>
@@ -280,6 +305,18 @@ export default function LoginLogsPage() {
             </tbody>
           </table>
         </div>
+
+        {nextCursor && (
+          <div className="px-6 py-4 border-t border-gray-200 text-center">
+            <button
+              onClick={loadMore}
+              disabled={loadingMore}
+              className="px-4 py-2 rounded-lg text-sm font-medium bg-gray-100 text-gray-700 hover:bg-gray-200 disabled:opacity-50"
+            >
+              {loadingMore ? "Memuat..." : "Muat lebih banyak"}
+            </button>
+          </div>
+        )}
       </div>
 
       {/* Info Box */}
@@ -25,18 +25,17 @@ export default function DashboardPage() {
 
   const loadProposals = async () => {
     try {
-      const data = await proposalService.getProposals();
-      setProposals(data);
+      const page = await proposalService.getProposals();
+      setProposals(page.data);
 
-      // Calculate stats
+      // Stats come from the server-side counts, not just the loaded page
+      const counts = page.status_counts;
       setStats({
-        total: data.length,
-        draft: data.filter((p) => p.status === "draft").length,
-        submitted: data.filter(
-          (p) => p.status === "submitted" || p.status === "reviewing"
-        ).length,
-        approved: data.filter((p) => p.status === "approved").length,
-        rejected: data.filter((p) => p.status === "rejected").length,
+        total: page.total,
+        draft: counts.draft ?? 0,
+        submitted: (counts.submitted ?? 0) + (counts.reviewing ?? 0),
+        approved: counts.approved ?? 0,
+        rejected: counts.rejected ?? 0,
       });
     } catch (error) {
       console.error("Failed to load proposals:", error);
@@ -53,6 +52,11 @@ export default function DashboardPage() {
       approved: { color: "bg-green-100 text-green-800", text: "Approved" },
       rejected: { color: "bg-red-100 text-red-800", text: "Rejected" },
       revision: { color: "bg-orange-100 text-orange-800", text: "Revision" },
+      withdrawn: { color: "bg-purple-100 text-purple-800", text: "Withdrawn" },
+      cancelled: { color: "bg-gray-200 text-gray-600", text: "Cancelled" },
+      in_progress: { color: "bg-indigo-100 text-indigo-800", text: "In Progress" },
+      completed: { color: "bg-teal-100 text-teal-800", text: "Completed" },
+      closed: { color: "bg-slate-200 text-slate-700", text: "Closed" },
     };
 
     const badge = badges[status] || badges.draft;
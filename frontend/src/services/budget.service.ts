@@ -0,0 +1,58 @@
+import api from "@/lib/axios";
+import {
+  BudgetConsumption,
+  BudgetLedgerEntry,
+  ProposalStatus,
+  VarianceReport,
+} from "@/types";
+
+export interface BudgetQuery {
+  fiscal_year?: number;
+  // Department codes, comma-separated
+  department?: string;
+}
+
+export interface BudgetLedgerQuery extends BudgetQuery {
+  cursor?: string;
+  limit?: number;
+  proposal_id?: number;
+  kind?: "reserve" | "commit" | "release";
+  order?: "asc" | "desc";
+}
+
+export interface BudgetLedgerPage {
+  data: BudgetLedgerEntry[];
+  next_cursor?: string;
+  total: number;
+}
+
+export interface VarianceQuery {
+  status?: ProposalStatus;
+  department_id?: string;
+  // Only proposals that spent more than estimated
+  overrun?: boolean;
+}
+
+export const budgetService = {
+  async getConsumption(params: BudgetQuery = {}): Promise<BudgetConsumption[]> {
+    const response = await api.get<BudgetConsumption[]>(
+      "/budgets/consumption",
+      { params }
+    );
+    return response.data;
+  },
+
+  async getLedger(params: BudgetLedgerQuery = {}): Promise<BudgetLedgerPage> {
+    const response = await api.get<BudgetLedgerPage>("/budgets/ledger", {
+      params,
+    });
+    return response.data;
+  },
+
+  async getVarianceReport(params: VarianceQuery = {}): Promise<VarianceReport> {
+    const response = await api.get<VarianceReport>("/budgets/variance", {
+      params,
+    });
+    return response.data;
+  },
+};
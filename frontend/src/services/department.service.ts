@@ -0,0 +1,76 @@
+import api from "@/lib/axios";
+import { Department, DepartmentBudget, DepartmentInput } from "@/types";
+
+export interface DepartmentQuery {
+  q?: string;
+  unit?: string;
+  active?: boolean;
+}
+
+export interface DepartmentBudgetInput {
+  amount: number | string;
+  notes?: string;
+}
+
+export const departmentService = {
+  // Active departments, for proposal and user forms
+  async getActiveDepartments(): Promise<Department[]> {
+    const response = await api.get<Department[]>("/departments");
+    return response.data;
+  },
+
+  async getDepartments(params: DepartmentQuery = {}): Promise<Department[]> {
+    const response = await api.get<Department[]>("/admin/departments", {
+      params,
+    });
+    return response.data;
+  },
+
+  async getDepartment(id: number): Promise<Department> {
+    const response = await api.get<Department>(`/admin/departments/${id}`);
+    return response.data;
+  },
+
+  async createDepartment(data: DepartmentInput): Promise<Department> {
+    const response = await api.post<Department>("/admin/departments", data);
+    return response.data;
+  },
+
+  async updateDepartment(
+    id: number,
+    data: DepartmentInput
+  ): Promise<Department> {
+    const response = await api.put<Department>(
+      `/admin/departments/${id}`,
+      data
+    );
+    return response.data;
+  },
+
+  async deleteDepartment(id: number): Promise<void> {
+    await api.delete(`/admin/departments/${id}`);
+  },
+
+  async getBudgets(id: number): Promise<DepartmentBudget[]> {
+    const response = await api.get<DepartmentBudget[]>(
+      `/admin/departments/${id}/budgets`
+    );
+    return response.data;
+  },
+
+  async setBudget(
+    id: number,
+    fiscalYear: number,
+    data: DepartmentBudgetInput
+  ): Promise<DepartmentBudget> {
+    const response = await api.put<DepartmentBudget>(
+      `/admin/departments/${id}/budgets/${fiscalYear}`,
+      data
+    );
+    return response.data;
+  },
+
+  async deleteBudget(id: number, fiscalYear: number): Promise<void> {
+    await api.delete(`/admin/departments/${id}/budgets/${fiscalYear}`);
+  },
+};
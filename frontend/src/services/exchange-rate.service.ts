@@ -0,0 +1,68 @@
+import api from "@/lib/axios";
+import { ExchangeRate, ExchangeRateInput } from "@/types";
+
+export interface ExchangeRatePage {
+  data: ExchangeRate[];
+  next_cursor?: string;
+  total: number;
+}
+
+export interface ExchangeRateQuery {
+  cursor?: string;
+  limit?: number;
+  currency?: string;
+  source?: "manual" | "csv";
+  from?: string;
+  to?: string;
+  sort?: "effective_date" | "currency" | "created_at";
+  order?: "asc" | "desc";
+}
+
+export interface ExchangeRateImportResult {
+  message: string;
+  imported: number;
+}
+
+export const exchangeRateService = {
+  async getExchangeRates(
+    params: ExchangeRateQuery = {}
+  ): Promise<ExchangeRatePage> {
+    const response = await api.get<ExchangeRatePage>("/exchange-rates", {
+      params,
+    });
+    return response.data;
+  },
+
+  async getCurrentExchangeRates(): Promise<ExchangeRate[]> {
+    const response = await api.get<ExchangeRate[]>("/exchange-rates/current");
+    return response.data;
+  },
+
+  async createExchangeRate(data: ExchangeRateInput): Promise<ExchangeRate> {
+    const response = await api.post<ExchangeRate>("/exchange-rates", data);
+    return response.data;
+  },
+
+  async updateExchangeRate(
+    id: number,
+    data: ExchangeRateInput
+  ): Promise<ExchangeRate> {
+    const response = await api.put<ExchangeRate>(`/exchange-rates/${id}`, data);
+    return response.data;
+  },
+
+  async deleteExchangeRate(id: number): Promise<void> {
+    await api.delete(`/exchange-rates/${id}`);
+  },
+
+  // CSV with the header currency,effective_date,rate
+  async importExchangeRates(file: File): Promise<ExchangeRateImportResult> {
+    const form = new FormData();
+    form.append("file", file);
+    const response = await api.post<ExchangeRateImportResult>(
+      "/exchange-rates/import",
+      form
+    );
+    return response.data;
+  },
+};
@@ -0,0 +1,57 @@
+import api from "@/lib/axios";
+import { InvestmentType, InvestmentTypeInput } from "@/types";
+
+export interface InvestmentTypeQuery {
+  q?: string;
+  active?: boolean;
+}
+
+export const investmentTypeService = {
+  // Active types with their field schemas, for the proposal form
+  async getActiveInvestmentTypes(): Promise<InvestmentType[]> {
+    const response = await api.get<InvestmentType[]>("/investment-types");
+    return response.data;
+  },
+
+  async getInvestmentTypes(
+    params: InvestmentTypeQuery = {}
+  ): Promise<InvestmentType[]> {
+    const response = await api.get<InvestmentType[]>(
+      "/admin/investment-types",
+      { params }
+    );
+    return response.data;
+  },
+
+  async getInvestmentType(id: number): Promise<InvestmentType> {
+    const response = await api.get<InvestmentType>(
+      `/admin/investment-types/${id}`
+    );
+    return response.data;
+  },
+
+  async createInvestmentType(
+    data: InvestmentTypeInput
+  ): Promise<InvestmentType> {
+    const response = await api.post<InvestmentType>(
+      "/admin/investment-types",
+      data
+    );
+    return response.data;
+  },
+
+  async updateInvestmentType(
+    id: number,
+    data: InvestmentTypeInput
+  ): Promise<InvestmentType> {
+    const response = await api.put<InvestmentType>(
+      `/admin/investment-types/${id}`,
+      data
+    );
+    return response.data;
+  },
+
+  async deleteInvestmentType(id: number): Promise<void> {
+    await api.delete(`/admin/investment-types/${id}`);
+  },
+};
@@ -10,27 +10,92 @@ export interface LoginLog {
   success: boolean;
   fail_reason?: string;
   login_at: string;
+  browser: string;
+  os: string;
+  device_type: string;
+  country: string;
+  country_code: string;
+  city: string;
+  site: string;
+}
+
+export interface LoginStatsBucket {
+  bucket: string;
+  success: number;
+  failure: number;
+  unique_users: number;
+}
+
+export interface LoginStatsCount {
+  key: string;
+  count: number;
 }
 
 export interface LoginStats {
+  from: string;
+  to: string;
+  bucket: "hour" | "day" | "week";
   total_logins: number;
   successful_logins: number;
   failed_logins: number;
+  unique_users: number;
+  series: LoginStatsBucket[];
+  top_failing_usernames: LoginStatsCount[];
+  top_failing_ips: LoginStatsCount[];
+  fail_reasons: LoginStatsCount[];
+}
+
+export interface LoginStatsQuery {
+  from?: string;
+  to?: string;
+  bucket?: "hour" | "day" | "week";
+  top?: number;
+}
+
+export interface LoginLogPage {
+  data: LoginLog[];
+  next_cursor?: string;
+  total: number;
+}
+
+export interface LoginLogQuery {
+  cursor?: string;
+  limit?: number;
+  from?: string;
+  to?: string;
+  success?: boolean;
+  username?: string;
+  ip?: string;
+  fail_reason?: string;
+  browser?: string;
+  os?: string;
+  device_type?: string;
+  country?: string;
+  country_code?: string;
+  city?: string;
+  site?: string;
+  sort?: "login_at" | "username" | "ip_address" | "success" | "id";
+  order?: "asc" | "desc";
 }
 
 export const loginLogService = {
-  async getLoginLogs(): Promise<LoginLog[]> {
-    const response = await api.get("/admin/logs/login");
+  async getLoginLogs(params: LoginLogQuery = {}): Promise<LoginLogPage> {
+    const response = await api.get("/admin/logs/login", { params });
     return response.data;
   },
 
-  async getLoginStats(): Promise<LoginStats> {
-    const response = await api.get("/admin/logs/login/stats");
+  async getLoginStats(params: LoginStatsQuery = {}): Promise<LoginStats> {
+    const response = await api.get("/admin/logs/login/stats", { params });
     return response.data;
   },
 
-  async getUserLoginHistory(username: string): Promise<LoginLog[]> {
-    const response = await api.get(`/admin/logs/login/user/${username}`);
+  async getUserLoginHistory(
+    username: string,
+    params: LoginLogQuery = {}
+  ): Promise<LoginLogPage> {
+    const response = await api.get(`/admin/logs/login/user/${username}`, {
+      params,
+    });
     return response.data;
   },
 };
@@ -0,0 +1,63 @@
+import api from "@/lib/axios";
+import {
+  CycleSummary,
+  PlanningCycle,
+  PlanningCycleInput,
+  PlanningCycleState,
+} from "@/types";
+
+export interface PlanningCycleQuery {
+  fiscal_year?: number;
+  state?: PlanningCycleState;
+}
+
+export const planningCycleService = {
+  async getPlanningCycles(
+    params: PlanningCycleQuery = {}
+  ): Promise<PlanningCycle[]> {
+    const response = await api.get<PlanningCycle[]>("/planning-cycles", {
+      params,
+    });
+    return response.data;
+  },
+
+  // The cycle open for submissions, or null
+  async getCurrentPlanningCycle(): Promise<PlanningCycle | null> {
+    const response = await api.get<PlanningCycle | null>(
+      "/planning-cycles/current"
+    );
+    return response.data;
+  },
+
+  async getPlanningCycle(id: number): Promise<PlanningCycle> {
+    const response = await api.get<PlanningCycle>(`/planning-cycles/${id}`);
+    return response.data;
+  },
+
+  async getSummary(id: number): Promise<CycleSummary> {
+    const response = await api.get<CycleSummary>(
+      `/planning-cycles/${id}/summary`
+    );
+    return response.data;
+  },
+
+  async createPlanningCycle(data: PlanningCycleInput): Promise<PlanningCycle> {
+    const response = await api.post<PlanningCycle>("/planning-cycles", data);
+    return response.data;
+  },
+
+  async updatePlanningCycle(
+    id: number,
+    data: PlanningCycleInput
+  ): Promise<PlanningCycle> {
+    const response = await api.put<PlanningCycle>(
+      `/planning-cycles/${id}`,
+      data
+    );
+    return response.data;
+  },
+
+  async deletePlanningCycle(id: number): Promise<void> {
+    await api.delete(`/planning-cycles/${id}`);
+  },
+};
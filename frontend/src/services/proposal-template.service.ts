@@ -0,0 +1,62 @@
+import api from "@/lib/axios";
+import { ProposalTemplate, ProposalTemplateInput } from "@/types";
+
+export interface ProposalTemplateQuery {
+  investment_type?: string;
+  active?: boolean;
+  q?: string;
+}
+
+export const proposalTemplateService = {
+  // Active templates new proposals may start from
+  async getActiveProposalTemplates(
+    investmentType?: string
+  ): Promise<ProposalTemplate[]> {
+    const response = await api.get<ProposalTemplate[]>("/proposal-templates", {
+      params: { investment_type: investmentType },
+    });
+    return response.data;
+  },
+
+  async getProposalTemplates(
+    params: ProposalTemplateQuery = {}
+  ): Promise<ProposalTemplate[]> {
+    const response = await api.get<ProposalTemplate[]>(
+      "/admin/proposal-templates",
+      { params }
+    );
+    return response.data;
+  },
+
+  async getProposalTemplate(id: number): Promise<ProposalTemplate> {
+    const response = await api.get<ProposalTemplate>(
+      `/admin/proposal-templates/${id}`
+    );
+    return response.data;
+  },
+
+  async createProposalTemplate(
+    data: ProposalTemplateInput
+  ): Promise<ProposalTemplate> {
+    const response = await api.post<ProposalTemplate>(
+      "/admin/proposal-templates",
+      data
+    );
+    return response.data;
+  },
+
+  async updateProposalTemplate(
+    id: number,
+    data: ProposalTemplateInput
+  ): Promise<ProposalTemplate> {
+    const response = await api.put<ProposalTemplate>(
+      `/admin/proposal-templates/${id}`,
+      data
+    );
+    return response.data;
+  },
+
+  async deleteProposalTemplate(id: number): Promise<void> {
+    await api.delete(`/admin/proposal-templates/${id}`);
+  },
+};
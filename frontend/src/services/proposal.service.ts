@@ -1,11 +1,53 @@
 import api from "@/lib/axios";
-import { InvestmentProposal, CreateProposalRequest, Comment } from "@/types";
+import {
+  InvestmentProposal,
+  CreateProposalRequest,
+  Comment,
+  ProposalStatus,
+  ProposalStatusHistory,
+  ProposalVersion,
+  ProposalVersionDiff,
+} from "@/types";
+
+export interface ProposalPage {
+  data: InvestmentProposal[];
+  next_cursor?: string;
+  total: number;
+  status_counts: Partial<Record<ProposalStatus, number>>;
+  total_cost_idr: number;
+}
+
+export interface ProposalQuery {
+  cursor?: string;
+  limit?: number;
+  sort?: string;
+  order?: "asc" | "desc";
+  q?: string;
+  status?: string;
+  investment_type?: string;
+  department_id?: string;
+  currency?: string;
+  planning_cycle_id?: number;
+  urgent?: boolean;
+  submitted_by_id?: number;
+  min_cost?: number;
+  max_cost?: number;
+  min_cost_idr?: number;
+  max_cost_idr?: number;
+  from?: string;
+  to?: string;
+}
 
 export const proposalService = {
-  async getProposals(status?: string): Promise<InvestmentProposal[]> {
-    const params = status ? { status } : {};
-    const response = await api.get<InvestmentProposal[]>("/proposals", {
+  async getProposals(params: ProposalQuery = {}): Promise<ProposalPage> {
+    const response = await api.get<ProposalPage>("/proposals", { params });
+    return response.data;
+  },
+
+  async exportProposals(params: ProposalQuery = {}): Promise<Blob> {
+    const response = await api.get<Blob>("/proposals/export", {
       params,
+      responseType: "blob",
     });
     return response.data;
   },
@@ -33,6 +75,25 @@ export const proposalService = {
     return response.data;
   },
 
+  // Copies a proposal into a new draft of the current user
+  async cloneProposal(
+    id: number,
+    includeAttachments = false
+  ): Promise<InvestmentProposal> {
+    const response = await api.post<InvestmentProposal>(
+      `/proposals/${id}/clone`,
+      { include_attachments: includeAttachments }
+    );
+    return response.data;
+  },
+
+  async createFromTemplate(templateId: number): Promise<InvestmentProposal> {
+    const response = await api.post<InvestmentProposal>(
+      `/proposals/from-template/${templateId}`
+    );
+    return response.data;
+  },
+
   async deleteProposal(id: number): Promise<void> {
     await api.delete(`/proposals/${id}`);
   },
@@ -44,6 +105,118 @@ export const proposalService = {
     return response.data;
   },
 
+  async approveProposal(
+    id: number,
+    reason?: string
+  ): Promise<InvestmentProposal> {
+    const response = await api.post<InvestmentProposal>(
+      `/proposals/${id}/approve`,
+      { reason }
+    );
+    return response.data;
+  },
+
+  async rejectProposal(
+    id: number,
+    reason: string
+  ): Promise<InvestmentProposal> {
+    const response = await api.post<InvestmentProposal>(
+      `/proposals/${id}/reject`,
+      { reason }
+    );
+    return response.data;
+  },
+
+  async requestRevision(
+    id: number,
+    reason: string
+  ): Promise<InvestmentProposal> {
+    const response = await api.post<InvestmentProposal>(
+      `/proposals/${id}/request-revision`,
+      { reason }
+    );
+    return response.data;
+  },
+
+  async withdrawProposal(
+    id: number,
+    reason: string
+  ): Promise<InvestmentProposal> {
+    const response = await api.post<InvestmentProposal>(
+      `/proposals/${id}/withdraw`,
+      { reason }
+    );
+    return response.data;
+  },
+
+  async cancelProposal(
+    id: number,
+    reason: string
+  ): Promise<InvestmentProposal> {
+    const response = await api.post<InvestmentProposal>(
+      `/proposals/${id}/cancel`,
+      { reason }
+    );
+    return response.data;
+  },
+
+  async startProposal(id: number): Promise<InvestmentProposal> {
+    const response = await api.post<InvestmentProposal>(
+      `/proposals/${id}/start`
+    );
+    return response.data;
+  },
+
+  async completeProposal(id: number): Promise<InvestmentProposal> {
+    const response = await api.post<InvestmentProposal>(
+      `/proposals/${id}/complete`
+    );
+    return response.data;
+  },
+
+  async closeProposal(id: number): Promise<InvestmentProposal> {
+    const response = await api.post<InvestmentProposal>(
+      `/proposals/${id}/close`
+    );
+    return response.data;
+  },
+
+  async approveOverrun(
+    id: number,
+    reason?: string
+  ): Promise<InvestmentProposal> {
+    const response = await api.post<InvestmentProposal>(
+      `/proposals/${id}/approve-overrun`,
+      { reason }
+    );
+    return response.data;
+  },
+
+  async getHistory(id: number): Promise<ProposalStatusHistory[]> {
+    const response = await api.get<ProposalStatusHistory[]>(
+      `/proposals/${id}/history`
+    );
+    return response.data;
+  },
+
+  async getVersions(id: number): Promise<ProposalVersion[]> {
+    const response = await api.get<ProposalVersion[]>(
+      `/proposals/${id}/versions`
+    );
+    return response.data;
+  },
+
+  async diffVersions(
+    id: number,
+    from: number,
+    to: number
+  ): Promise<ProposalVersionDiff> {
+    const response = await api.get<ProposalVersionDiff>(
+      `/proposals/${id}/versions/${from}/diff/${to}`
+    );
+    return response.data;
+  },
+
   async addComment(id: number, content: string): Promise<Comment> {
     const response = await api.post<Comment>(`/proposals/${id}/comments`, {
       content,
@@ -0,0 +1,62 @@
+import api from "@/lib/axios";
+import {
+  ComparisonRequest,
+  Quotation,
+  QuotationComparison,
+  QuotationInput,
+} from "@/types";
+
+export const quotationService = {
+  async getQuotations(proposalId: number): Promise<Quotation[]> {
+    const response = await api.get<Quotation[]>(
+      `/proposals/${proposalId}/quotations`
+    );
+    return response.data;
+  },
+
+  async createQuotation(
+    proposalId: number,
+    data: QuotationInput
+  ): Promise<Quotation> {
+    const response = await api.post<Quotation>(
+      `/proposals/${proposalId}/quotations`,
+      data
+    );
+    return response.data;
+  },
+
+  async updateQuotation(
+    proposalId: number,
+    id: number,
+    data: QuotationInput
+  ): Promise<Quotation> {
+    const response = await api.put<Quotation>(
+      `/proposals/${proposalId}/quotations/${id}`,
+      data
+    );
+    return response.data;
+  },
+
+  async deleteQuotation(proposalId: number, id: number): Promise<void> {
+    await api.delete(`/proposals/${proposalId}/quotations/${id}`);
+  },
+
+  // Ranks the quotations and records the recommended vendor
+  async compareQuotations(
+    proposalId: number,
+    data: ComparisonRequest = {}
+  ): Promise<QuotationComparison> {
+    const response = await api.post<QuotationComparison>(
+      `/proposals/${proposalId}/quotations/compare`,
+      data
+    );
+    return response.data;
+  },
+
+  async getComparisons(proposalId: number): Promise<QuotationComparison[]> {
+    const response = await api.get<QuotationComparison[]>(
+      `/proposals/${proposalId}/quotations/comparisons`
+    );
+    return response.data;
+  },
+};
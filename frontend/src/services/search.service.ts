@@ -0,0 +1,35 @@
+import api from "@/lib/axios";
+import { ProposalStatus } from "@/types";
+
+export type SearchKind = "proposal" | "comment" | "attachment";
+
+export interface SearchResult {
+  kind: SearchKind;
+  source_id: number;
+  proposal_id: number;
+  proposal_number: string;
+  // title and snippet are HTML: matches are wrapped in <mark>, the rest is escaped
+  title: string;
+  status: ProposalStatus;
+  snippet: string;
+  rank: number;
+}
+
+export interface SearchResponse {
+  data: SearchResult[];
+  total: number;
+}
+
+export interface SearchQuery {
+  q: string;
+  limit?: number;
+  offset?: number;
+  kind?: string;
+}
+
+export const searchService = {
+  async search(params: SearchQuery): Promise<SearchResponse> {
+    const response = await api.get<SearchResponse>("/search", { params });
+    return response.data;
+  },
+};
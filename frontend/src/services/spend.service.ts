@@ -0,0 +1,46 @@
+import api from "@/lib/axios";
+import { SpendRecord, SpendRecordInput, SpendVariance } from "@/types";
+
+export const spendService = {
+  async getSpendRecords(proposalId: number): Promise<SpendRecord[]> {
+    const response = await api.get<SpendRecord[]>(
+      `/proposals/${proposalId}/spend`
+    );
+    return response.data;
+  },
+
+  // Recording spend may put the proposal on hold for overrun re-approval
+  async createSpendRecord(
+    proposalId: number,
+    data: SpendRecordInput
+  ): Promise<SpendRecord> {
+    const response = await api.post<SpendRecord>(
+      `/proposals/${proposalId}/spend`,
+      data
+    );
+    return response.data;
+  },
+
+  async updateSpendRecord(
+    proposalId: number,
+    id: number,
+    data: SpendRecordInput
+  ): Promise<SpendRecord> {
+    const response = await api.put<SpendRecord>(
+      `/proposals/${proposalId}/spend/${id}`,
+      data
+    );
+    return response.data;
+  },
+
+  async deleteSpendRecord(proposalId: number, id: number): Promise<void> {
+    await api.delete(`/proposals/${proposalId}/spend/${id}`);
+  },
+
+  async getVariance(proposalId: number): Promise<SpendVariance> {
+    const response = await api.get<SpendVariance>(
+      `/proposals/${proposalId}/variance`
+    );
+    return response.data;
+  },
+};
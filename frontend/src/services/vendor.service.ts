@@ -0,0 +1,33 @@
+import api from "@/lib/axios";
+import { Vendor, VendorInput } from "@/types";
+
+export interface VendorQuery {
+  q?: string;
+  active?: boolean;
+}
+
+export const vendorService = {
+  async getVendors(params: VendorQuery = {}): Promise<Vendor[]> {
+    const response = await api.get<Vendor[]>("/vendors", { params });
+    return response.data;
+  },
+
+  async getVendor(id: number): Promise<Vendor> {
+    const response = await api.get<Vendor>(`/vendors/${id}`);
+    return response.data;
+  },
+
+  async createVendor(data: VendorInput): Promise<Vendor> {
+    const response = await api.post<Vendor>("/vendors", data);
+    return response.data;
+  },
+
+  async updateVendor(id: number, data: VendorInput): Promise<Vendor> {
+    const response = await api.put<Vendor>(`/vendors/${id}`, data);
+    return response.data;
+  },
+
+  async deleteVendor(id: number): Promise<void> {
+    await api.delete(`/vendors/${id}`);
+  },
+};
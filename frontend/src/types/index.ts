@@ -36,7 +36,16 @@ export type ProposalStatus =
   | "reviewing"
   | "approved"
   | "rejected"
-  | "revision";
+  | "revision"
+  | "withdrawn"
+  | "cancelled"
+  | "in_progress"
+  | "completed"
+  | "closed";
+
+// Amounts are exact to two decimals on the server. They arrive as numbers
+// and may be sent as numbers or decimal strings.
+export type Amount = number;
 
 export interface InvestmentProposal {
   id: number;
@@ -44,8 +53,13 @@ export interface InvestmentProposal {
   title: string;
   description: string;
   investment_type: string;
-  estimated_cost: number;
+  estimated_cost: Amount;
   currency: string;
+  // IDR value at exchange_rate; indicative until locked on submit, null
+  // while the currency has no rate
+  estimated_cost_idr: Amount | null;
+  exchange_rate: number | null;
+  rate_locked_at: string | null;
   proposal_date: string;
   expected_start_date: string;
   expected_complete_date: string;
@@ -53,9 +67,33 @@ export interface InvestmentProposal {
   expected_benefit: string;
   risk_analysis: string;
   status: ProposalStatus;
+  approval_step: number;
+  // Only on the response to a submit that exceeds the department budget
+  budget_warning?: string;
   submitted_by_id: number;
   submitted_by: User;
   department_id: string;
+  planning_cycle_id: number | null;
+  planning_cycle?: PlanningCycle;
+  urgent: boolean;
+  urgent_justification: string;
+  // Set by the latest quotation comparison; required for procurement to
+  // approve
+  recommended_quotation_id: number | null;
+  recommended_vendor_id: number | null;
+  // Total of the spend records; approved_cost_idr is set when an overrun
+  // was re-approved, overrun_pending while one waits for the CFO
+  actual_cost_idr: Amount;
+  approved_cost_idr: Amount | null;
+  overrun_pending: boolean;
+  // What the draft was started from, if anything
+  cloned_from_id: number | null;
+  template_id: number | null;
+  // Values of the fields the schema of the investment type defines
+  custom_fields: Record<string, CustomFieldValue> | null;
+  line_items?: ProposalLineItem[];
+  cash_flows?: ProposalCashFlow[];
+  financials: FinancialEvaluation;
   attachments?: Attachment[];
   approvals?: Approval[];
   comments?: Comment[];
@@ -67,7 +105,7 @@ export interface CreateProposalRequest {
   title: string;
   description: string;
   investment_type: string;
-  estimated_cost: number;
+  estimated_cost: Amount;
   currency: string;
   expected_start_date: string;
   expected_complete_date: string;
@@ -75,6 +113,445 @@ export interface CreateProposalRequest {
   expected_benefit: string;
   risk_analysis: string;
   department_id: string;
+  // Omit to keep the current cycle, or to join the open one
+  planning_cycle_id?: number;
+  // Required to submit outside the window of the cycle
+  urgent?: boolean;
+  urgent_justification?: string;
+  // Checked against the schema of the investment type; omit to keep them
+  custom_fields?: Record<string, CustomFieldValue | null>;
+  // Replaces the cost breakdown and sets estimated_cost; omit to keep it
+  line_items?: ProposalLineItemInput[];
+  // Replaces the projected cash flows; omit to keep them
+  cash_flows?: CashFlowInput[];
+}
+
+export interface CashFlowInput {
+  year: number; // 0 = initial investment
+  inflow: Amount;
+  outflow: Amount;
+}
+
+export interface ProposalCashFlow extends CashFlowInput {
+  id: number;
+  proposal_id: number;
+  created_at: string;
+  updated_at: string;
+}
+
+// IDR per unit of currency, effective from effective_date (YYYY-MM-DD)
+export interface ExchangeRateInput {
+  currency: string;
+  effective_date: string;
+  rate: number | string;
+}
+
+export interface ExchangeRate {
+  id: number;
+  currency: string;
+  effective_date: string;
+  rate: number;
+  source: "manual" | "csv";
+  created_by_id: number;
+  created_by: User;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface DepartmentInput {
+  code: string;
+  name: string;
+  unit: string;
+  head_id: number | null;
+  is_active?: boolean;
+}
+
+export interface DepartmentBudget {
+  id: number;
+  department_id: number;
+  fiscal_year: number;
+  amount: number;
+  notes: string;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface Department {
+  id: number;
+  code: string;
+  name: string;
+  unit: string;
+  head_id: number | null;
+  head?: User;
+  is_active: boolean;
+  budgets?: DepartmentBudget[];
+  created_at: string;
+  updated_at: string;
+}
+
+// Budget, remaining and utilization are null when no budget is set
+export interface BudgetConsumption {
+  department_id: number;
+  department_code: string;
+  department_name: string;
+  unit: string;
+  fiscal_year: number;
+  budget: number | null;
+  reserved: number;
+  committed: number;
+  remaining: number | null;
+  utilization_percent: number | null;
+}
+
+export interface BudgetLedgerEntry {
+  id: number;
+  department_id: number;
+  department?: Department;
+  fiscal_year: number;
+  proposal_id: number;
+  proposal?: InvestmentProposal;
+  kind: "reserve" | "commit" | "release";
+  reserved: number;
+  committed: number;
+  over_budget: boolean;
+  actor_id: number | null;
+  actor?: User;
+  created_at: string;
+}
+
+export type CustomFieldValue = string | number | boolean;
+
+// A JSON Schema subset; dates are strings with format "date" (YYYY-MM-DD)
+export interface FieldDefinition {
+  type: "string" | "integer" | "number" | "boolean";
+  title?: string;
+  description?: string;
+  enum?: CustomFieldValue[];
+  minimum?: number;
+  maximum?: number;
+  minLength?: number;
+  maxLength?: number;
+  pattern?: string;
+  format?: "date";
+}
+
+export interface FieldSchema {
+  type: "object";
+  properties: Record<string, FieldDefinition>;
+  required?: string[];
+}
+
+export interface InvestmentType {
+  id: number;
+  code: string;
+  name: string;
+  description: string;
+  field_schema: FieldSchema;
+  wacc: number | null; // percent; null uses DEFAULT_WACC
+  is_active: boolean;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface InvestmentTypeInput {
+  code: string;
+  name: string;
+  description?: string;
+  field_schema: FieldSchema;
+  wacc?: number | null;
+  is_active?: boolean;
+}
+
+export interface Vendor {
+  id: number;
+  code: string;
+  name: string;
+  tax_id: string;
+  contact_name: string;
+  email: string;
+  phone: string;
+  address: string;
+  is_active: boolean;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface VendorInput {
+  code: string;
+  name: string;
+  tax_id?: string;
+  contact_name?: string;
+  email?: string;
+  phone?: string;
+  address?: string;
+  is_active?: boolean;
+}
+
+export interface Quotation {
+  id: number;
+  proposal_id: number;
+  vendor_id: number;
+  vendor: Vendor;
+  reference: string;
+  price: Amount;
+  currency: string;
+  lead_time_days: number;
+  warranty_months: number;
+  attachment_id: number | null;
+  attachment?: Attachment;
+  notes: string;
+  created_by_id: number;
+  created_by?: User;
+  created_at: string;
+  updated_at: string;
+}
+
+// An empty currency is the proposal's
+export interface QuotationInput {
+  vendor_id: number;
+  reference?: string;
+  price: Amount;
+  currency?: string;
+  lead_time_days: number;
+  warranty_months: number;
+  attachment_id?: number;
+  notes?: string;
+}
+
+export interface ComparisonWeights {
+  price: number;
+  lead_time: number;
+  warranty: number;
+}
+
+// Criterion scores run from 0 (worst quotation) to 100 (best)
+export interface QuotationScore {
+  rank: number;
+  quotation_id: number;
+  vendor_id: number;
+  vendor_name: string;
+  price: Amount;
+  currency: string;
+  price_idr: Amount;
+  lead_time_days: number;
+  warranty_months: number;
+  price_score: number;
+  lead_time_score: number;
+  warranty_score: number;
+  score: number;
+}
+
+export interface QuotationComparison {
+  id: number;
+  proposal_id: number;
+  weights: ComparisonWeights;
+  ranking: QuotationScore[];
+  recommended_quotation_id: number;
+  recommended_vendor_id: number;
+  recommended_vendor?: Vendor;
+  reason: string;
+  compared_by_id: number;
+  compared_by?: User;
+  created_at: string;
+}
+
+// Omit weights for the defaults; a quotation other than the best-ranked
+// needs a reason
+export interface ComparisonRequest {
+  weights?: ComparisonWeights;
+  recommended_quotation_id?: number;
+  reason?: string;
+}
+
+// An invoice paid for a proposal being executed, converted to IDR at the
+// rate of its invoice date
+export interface SpendRecord {
+  id: number;
+  proposal_id: number;
+  po_number: string;
+  invoice_number: string;
+  vendor_id: number | null;
+  vendor?: Vendor;
+  invoice_amount: Amount;
+  currency: string;
+  invoice_date: string;
+  exchange_rate: number;
+  amount_idr: Amount;
+  description: string;
+  created_by_id: number;
+  created_by?: User;
+  created_at: string;
+  updated_at: string;
+}
+
+// invoice_date is YYYY-MM-DD; an empty currency is the proposal's
+export interface SpendRecordInput {
+  po_number: string;
+  invoice_number?: string;
+  vendor_id?: number | null;
+  invoice_amount: Amount;
+  currency?: string;
+  invoice_date: string;
+  description?: string;
+}
+
+// Actual spend against estimated cost; a positive variance is an overrun.
+// Null when the estimated cost was never converted to IDR.
+export interface SpendVariance {
+  proposal_id: number;
+  proposal_number: string;
+  title: string;
+  department_id: string;
+  status: ProposalStatus;
+  estimated_cost: Amount;
+  currency: string;
+  estimated_cost_idr: Amount | null;
+  approved_cost_idr: Amount | null;
+  actual_cost_idr: Amount;
+  variance_idr: Amount | null;
+  variance_percent: number | null;
+  spend_count: number;
+  overrun_pending: boolean;
+}
+
+export interface VarianceReport {
+  proposals: SpendVariance[];
+  estimated_cost_idr: Amount;
+  actual_cost_idr: Amount;
+  variance_idr: Amount;
+  variance_percent: number | null;
+  overrun_count: number;
+}
+
+// Drafts can start from a template; line items are priced when they do
+export interface ProposalTemplate {
+  id: number;
+  name: string;
+  investment_type: string;
+  is_active: boolean;
+  title: string;
+  description: string;
+  currency: string;
+  justification: string;
+  expected_benefit: string;
+  risk_analysis: string;
+  department_id: string;
+  custom_fields: Record<string, CustomFieldValue> | null;
+  line_items: ProposalLineItemInput[];
+  cash_flows: CashFlowInput[];
+  created_by_id: number;
+  created_by?: User;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface ProposalTemplateInput {
+  name: string;
+  investment_type: string;
+  is_active?: boolean;
+  title?: string;
+  description?: string;
+  currency?: string;
+  justification?: string;
+  expected_benefit?: string;
+  risk_analysis?: string;
+  department_id?: string;
+  custom_fields?: Record<string, CustomFieldValue | null>;
+  line_items?: ProposalLineItemInput[];
+  cash_flows?: CashFlowInput[];
+}
+
+export type PlanningCycleState = "upcoming" | "open" | "closed";
+
+// Dates are YYYY-MM-DD; an empty investment_types accepts any type
+export interface PlanningCycleInput {
+  name: string;
+  fiscal_year: number;
+  opens_at: string;
+  closes_at: string;
+  description?: string;
+  investment_types: string[];
+  budget_envelope: Amount;
+}
+
+export interface PlanningCycle {
+  id: number;
+  name: string;
+  fiscal_year: number;
+  opens_at: string;
+  closes_at: string;
+  description: string;
+  investment_types: string[];
+  budget_envelope: Amount;
+  state?: PlanningCycleState;
+  created_by_id: number;
+  created_by?: User;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface CycleTotal {
+  key: string;
+  count: number;
+  cost_idr: Amount;
+}
+
+// Utilization is approved / budget_envelope; null without an envelope
+export interface CycleSummary {
+  cycle: PlanningCycle;
+  state: PlanningCycleState;
+  proposal_count: number;
+  urgent_count: number;
+  requested_idr: Amount;
+  approved_idr: Amount;
+  remaining_idr: Amount;
+  utilization_percent: number | null;
+  by_status: CycleTotal[];
+  by_investment_type: CycleTotal[];
+  by_department: CycleTotal[];
+}
+
+// Rates are percentages; null when they cannot be computed
+export interface FinancialEvaluation {
+  discount_rate: number | null;
+  npv: Amount | null;
+  irr: number | null;
+  payback_years: number | null;
+  roi: number | null;
+  evaluated_at: string | null;
+}
+
+export type LineItemCategory =
+  | "equipment"
+  | "installation"
+  | "training"
+  | "contingency"
+  | "other";
+
+export interface ProposalLineItemInput {
+  description: string;
+  category: LineItemCategory;
+  quantity: number;
+  unit_price: Amount;
+  currency?: string;
+  tax_rate: number;
+}
+
+export interface ProposalLineItem {
+  id: number;
+  proposal_id: number;
+  position: number;
+  description: string;
+  category: LineItemCategory;
+  quantity: number;
+  unit_price: Amount;
+  currency: string;
+  tax_rate: number;
+  subtotal: Amount;
+  tax_amount: Amount;
+  total: Amount;
+  created_at: string;
+  updated_at: string;
 }
 
 export interface Attachment {
@@ -94,13 +571,85 @@ export interface Approval {
   approver_id: number;
   approver: User;
   approver_role: UserRole;
-  status: string;
+  step: number; // -1 for the re-approval of a spend overrun
+  status: "pending" | "approved" | "rejected" | "revision" | "cancelled";
   comments: string;
   approved_at: string | null;
   created_at: string;
   updated_at: string;
 }
 
+export interface ProposalSnapshot {
+  title: string;
+  description: string;
+  investment_type: string;
+  estimated_cost: Amount;
+  currency: string;
+  expected_start_date: string;
+  expected_complete_date: string;
+  justification: string;
+  expected_benefit: string;
+  risk_analysis: string;
+  department_id: string;
+  line_items?: {
+    description: string;
+    category: LineItemCategory;
+    quantity: number;
+    unit_price: Amount;
+    currency: string;
+    tax_rate: number;
+    total: Amount;
+  }[];
+  cash_flows?: CashFlowInput[];
+}
+
+export interface ProposalVersion {
+  id: number;
+  proposal_id: number;
+  version: number;
+  status: ProposalStatus;
+  changed_by_id: number;
+  changed_by: User;
+  created_at: string;
+  data: ProposalSnapshot;
+  changed_fields: string[];
+}
+
+export interface TextChange {
+  op: "equal" | "insert" | "delete";
+  text: string;
+}
+
+export interface FieldDiff {
+  field: string;
+  old: unknown;
+  new: unknown;
+  delta?: number;
+  delta_percent?: number;
+  changes?: TextChange[];
+}
+
+export interface ProposalVersionDiff {
+  proposal_id: number;
+  from: number;
+  to: number;
+  fields: FieldDiff[];
+}
+
+export interface ProposalStatusHistory {
+  id: number;
+  proposal_id: number;
+  action: string;
+  from_status: ProposalStatus | "";
+  to_status: ProposalStatus;
+  approval_step: number;
+  actor_id: number;
+  actor: User;
+  actor_role: UserRole;
+  reason: string;
+  created_at: string;
+}
+
 export interface Comment {
   id: number;
   proposal_id: number;